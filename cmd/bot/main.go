@@ -1,47 +1,296 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/joho/godotenv"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"constellation-school-bot/internal/callback"
 	"constellation-school-bot/internal/config"
 	"constellation-school-bot/internal/database"
 	"constellation-school-bot/internal/handlers"
+	"constellation-school-bot/internal/health"
+	"constellation-school-bot/internal/logging"
+	"constellation-school-bot/internal/metrics"
+	"constellation-school-bot/internal/notify"
+	"constellation-school-bot/internal/reminders"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+	"constellation-school-bot/internal/webhook"
+	"constellation-school-bot/internal/worker"
 )
 
 func main() {
 	// Загружаем переменные окружения из .env файла
-	if err := godotenv.Load(); err != nil {
-		log.Println("Файл .env не найден, используем системные переменные")
-	}
-	
+	envErr := godotenv.Load()
+
 	cfg := config.Load()
-	
+
+	// Структурированные логи: уровень и формат из LOG_LEVEL и LOG_FORMAT
+	logging.Setup(os.Stderr, cfg.LogLevel, cfg.LogFormat)
+	if envErr != nil {
+		slog.Info("Файл .env не найден, используем системные переменные")
+	}
+
+	if err := run(cfg); err != nil {
+		slog.Error("Бот остановлен из-за ошибки", "err", err)
+		os.Exit(1)
+	}
+}
+
+// run - запуск и корректная остановка бота. Ошибка возвращается после
+// обычной последовательности завершения, чтобы отработали отложенные закрытия.
+func run(cfg *config.Config) error {
+	// Контекст жизненного цикла: отменяется по SIGINT/SIGTERM (docker stop)
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	db, err := database.Connect(cfg)
 	if err != nil {
-		log.Fatal("Ошибка подключения к БД:", err)
+		return err
+	}
+	defer func() {
+		if err := db.Close(); err != nil {
+			slog.Warn("Ошибка закрытия БД", "err", err)
+		}
+		slog.Info("Соединение с БД закрыто")
+	}()
+
+	// Репозитории данных для обработчиков
+	st := store.New(db)
+	handlers.InitializeStore(st)
+	handlers.InitializeReminders(cfg.ReminderOffsets)
+	metrics.RegisterDB(db)
+
+	// Подпись кнопок опасных действий
+	if cfg.CallbackSecret != "" {
+		handlers.InitializeCallbackSigner(callback.NewSigner([]byte(cfg.CallbackSecret), cfg.CallbackTTL))
+	} else {
+		signer, err := callback.NewRandomSigner(cfg.CallbackTTL)
+		if err != nil {
+			return fmt.Errorf("ошибка создания ключа подписи кнопок: %w", err)
+		}
+		handlers.InitializeCallbackSigner(signer)
+		slog.Warn("CALLBACK_SECRET не задан: кнопки удаления станут недействительны после перезапуска")
 	}
-	defer db.Close()
 
 	// Инициализируем rate limiter
-	handlers.InitializeRateLimiter(db)
-	log.Println("Rate limiter инициализирован")
+	handlers.InitializeRateLimiter(ctx, db)
+
+	// Инициализируем хранилище состояний FSM (переживает перезапуск бота)
+	stateStore, err := handlers.NewStateStore(cfg, db)
+	if err != nil {
+		return fmt.Errorf("ошибка инициализации хранилища состояний: %w", err)
+	}
+	handlers.InitializeStateStore(ctx, stateStore)
+	if closer, ok := stateStore.(io.Closer); ok {
+		defer func() {
+			if err := closer.Close(); err != nil {
+				slog.Warn("Ошибка закрытия хранилища состояний", "err", err)
+			}
+		}()
+	}
 
 	bot, err := tgbotapi.NewBotAPI(cfg.BotToken)
 	if err != nil {
-		log.Fatal("Ошибка создания бота:", err)
+		return fmt.Errorf("ошибка создания бота: %w", err)
+	}
+
+	// Запросы и ответы Bot API пишутся только на уровне debug, с маскированием телефонов
+	bot.Debug = logging.ParseLevel(cfg.LogLevel) <= slog.LevelDebug
+	if err := tgbotapi.SetLogger(botAPILogger{}); err != nil {
+		slog.Warn("Не удалось подключить логгер Bot API", "err", err)
+	}
+	slog.Info("Бот запущен", "username", bot.Self.UserName)
+
+	// Проверки здоровья для healthcheck docker-compose
+	checker := health.New(health.Options{
+		DB: db,
+		GetMe: func() error {
+			_, err := bot.GetMe()
+			return err
+		},
+		TelegramCacheTTL: cfg.HealthTelegramCacheTTL,
+		MaxUpdateAge:     cfg.HealthMaxUpdateAge,
+	})
+
+	// Метрики Prometheus (/metrics) и проверки (/healthz, /readyz) в любом режиме получения обновлений
+	var metricsServer *http.Server
+	if cfg.MetricsListenAddr != "" {
+		metricsServer = metrics.NewServer(cfg.MetricsListenAddr, checker.Register)
+		go func() {
+			slog.Info("Служебный сервер: /metrics, /healthz, /readyz", "addr", cfg.MetricsListenAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				slog.Error("Ошибка служебного сервера", "err", err)
+			}
+		}()
+	}
+
+	var updates tgbotapi.UpdatesChannel
+	var stopReceiving func(ctx context.Context)
+	// Ошибка приема обновлений останавливает бота через обычное завершение
+	receiveErr := make(chan error, 1)
+
+	// Режим получения обновлений задается через BOT_MODE
+	switch cfg.BotMode {
+	case "webhook":
+		server, err := webhook.NewServer(cfg.WebhookListenAddr, cfg.WebhookPath, cfg.WebhookSecret)
+		if err != nil {
+			return fmt.Errorf("ошибка настройки webhook: %w", err)
+		}
+
+		go func() {
+			if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				receiveErr <- fmt.Errorf("ошибка webhook сервера: %w", err)
+			}
+		}()
+
+		if err := webhook.Register(bot, cfg.WebhookURL, cfg.WebhookPath, cfg.WebhookSecret); err != nil {
+			server.Shutdown(context.Background())
+			return fmt.Errorf("ошибка регистрации webhook: %w", err)
+		}
+
+		updates = server.Updates()
+		stopReceiving = func(ctx context.Context) {
+			if err := server.Shutdown(ctx); err != nil {
+				slog.Warn("Ошибка остановки webhook сервера", "err", err)
+			}
+		}
+	case "polling", "":
+		// Telegram не отдает обновления через getUpdates при активном webhook
+		if err := webhook.Unregister(bot); err != nil {
+			slog.Warn("Ошибка снятия webhook", "err", err)
+		}
+
+		u := tgbotapi.NewUpdate(0)
+		u.Timeout = 60
+
+		updates = bot.GetUpdatesChan(u)
+		stopReceiving = func(ctx context.Context) {
+			bot.StopReceivingUpdates()
+		}
+		slog.Info("Режим long polling")
+	default:
+		return fmt.Errorf("неизвестный режим BOT_MODE=%s (ожидается polling или webhook)", cfg.BotMode)
+	}
+
+	// Обновления разных чатов обрабатываются параллельно, одного чата - по порядку
+	messenger := telegram.NewClient(bot)
+
+	// Уведомления из очереди отправляются в фоне с учетом лимитов Telegram
+	dispatcher := notify.New(st.Outbox, messenger, notify.Options{
+		GlobalRate:      cfg.NotifyRatePerSecond,
+		PerChatInterval: cfg.NotifyChatInterval,
+		PollInterval:    cfg.NotifyPollInterval,
+		MaxAttempts:     cfg.NotifyMaxAttempts,
+	})
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		dispatcher.Run(ctx)
+	}()
+
+	// Автоматические напоминания об уроках ставятся в ту же очередь уведомлений
+	scheduler := reminders.New(st.Reminders, reminders.Options{
+		Offsets:       cfg.ReminderOffsets,
+		CheckInterval: cfg.ReminderCheckInterval,
+	})
+	schedulerDone := make(chan struct{})
+	go func() {
+		defer close(schedulerDone)
+		scheduler.Run(ctx)
+	}()
+
+	pool := worker.NewPool(cfg.Workers, cfg.WorkerQueueSize, func(update tgbotapi.Update) {
+		defer checker.UpdateProcessed()
+		handlers.HandleUpdate(messenger, update, db)
+	})
+	metrics.RegisterWorkerPool(pool.Stats)
+
+	var runErr error
+receive:
+	for {
+		select {
+		case <-ctx.Done():
+			break receive
+		case runErr = <-receiveErr:
+			break receive
+		case update, ok := <-updates:
+			if !ok {
+				break receive
+			}
+			checker.UpdateReceived()
+			if err := pool.Submit(ctx, update); err != nil {
+				checker.UpdateProcessed()
+				slog.Warn("Обновление не поставлено в очередь", "update_id", update.UpdateID, "err", err)
+				break receive
+			}
+		}
 	}
 
-	bot.Debug = true // Включаем debug режим
-	log.Printf("Бот запущен: %s", bot.Self.UserName)
+	// Корректное завершение: перестаем получать обновления, даем обработчикам
+	// и фоновым воркерам завершиться до дедлайна, затем закрываем хранилище состояний и БД (defer выше).
+	// Повторно Telegram их не доставит: offset long polling уже сдвинут при получении,
+	// поэтому обновления, оставшиеся в канале updates или в очередях пула после дедлайна, теряются.
+	if runErr != nil {
+		slog.Warn("Прием обновлений остановлен, завершаем работу", "err", runErr, "timeout", cfg.ShutdownTimeout)
+	} else {
+		slog.Info("Получен сигнал остановки, завершаем работу", "timeout", cfg.ShutdownTimeout)
+	}
+	stop()
 
-	u := tgbotapi.NewUpdate(0)
-	u.Timeout = 60
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
 
-	updates := bot.GetUpdatesChan(u)
+	stopReceiving(shutdownCtx)
 
-	for update := range updates {
-		handlers.HandleUpdate(bot, update, db)
+	if err := pool.Shutdown(shutdownCtx); err != nil {
+		slog.Warn("Не все обновления обработаны", "err", err)
+	} else {
+		slog.Info("Все обновления обработаны")
 	}
-}
\ No newline at end of file
+
+	if err := handlers.WaitForBackgroundWorkers(shutdownCtx); err != nil {
+		slog.Warn("Фоновые задачи не завершены", "err", err)
+	}
+
+	// Диспетчер и планировщик напоминаний останавливаются по ctx; неотправленное остается в очереди до перезапуска
+	select {
+	case <-dispatcherDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Диспетчер уведомлений не остановился до дедлайна")
+	}
+	select {
+	case <-schedulerDone:
+	case <-shutdownCtx.Done():
+		slog.Warn("Планировщик напоминаний не остановился до дедлайна")
+	}
+
+	if metricsServer != nil {
+		if err := metricsServer.Shutdown(shutdownCtx); err != nil {
+			slog.Warn("Ошибка остановки служебного сервера", "err", err)
+		}
+	}
+
+	// Хранилище состояний и БД закрываются отложенными вызовами выше
+	return runErr
+}
+
+// botAPILogger - логи tgbotapi в slog на уровне debug
+type botAPILogger struct{}
+
+func (botAPILogger) Println(v ...interface{}) {
+	slog.Debug(strings.TrimSuffix(fmt.Sprintln(v...), "\n"))
+}
+
+func (botAPILogger) Printf(format string, v ...interface{}) {
+	slog.Debug(fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+
+	"constellation-school-bot/internal/config"
+	"constellation-school-bot/internal/database"
+)
+
+const usage = `Использование: migrate <команда>
+
+Команды:
+  up          применить все непримененные миграции
+  down [N]    откатить последние N миграций (по умолчанию 1)
+  status      показать состояние миграций`
+
+func main() {
+	if len(os.Args) < 2 {
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+
+	if err := godotenv.Load(); err != nil {
+		log.Println("Файл .env не найден, используем системные переменные")
+	}
+	cfg := config.Load()
+
+	// Подключаемся напрямую: database.Connect сам проверяет актуальность схемы
+	dsn := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		cfg.DBHost, cfg.DBPort, cfg.DBUser, cfg.DBPassword, cfg.DBName)
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		log.Fatal("Ошибка открытия соединения с БД:", err)
+	}
+	defer db.Close()
+
+	if err := db.Ping(); err != nil {
+		log.Fatal("Ошибка подключения к БД:", err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx := context.Background()
+
+	switch os.Args[1] {
+	case "up":
+		count, err := migrator.Up(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("✅ Применено миграций: %d\n", count)
+
+	case "down":
+		steps := 1
+		if len(os.Args) > 2 {
+			steps, err = strconv.Atoi(os.Args[2])
+			if err != nil || steps < 1 {
+				log.Fatalf("Некорректное число шагов: %s", os.Args[2])
+			}
+		}
+
+		count, err := migrator.Down(ctx, steps)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("✅ Откачено миграций: %d\n", count)
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		for _, status := range statuses {
+			state := "ожидает"
+			if status.Applied {
+				state = "применена " + status.AppliedAt.Format("02.01.2006 15:04")
+			}
+			fmt.Printf("%04d  %-40s %s\n", status.Version, status.Name, state)
+		}
+
+	default:
+		fmt.Println(usage)
+		os.Exit(2)
+	}
+}
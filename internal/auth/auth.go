@@ -0,0 +1,86 @@
+// Package auth - роли пользователей и права доступа.
+//
+// Обработчики не сравнивают роли напрямую: маршрут команды или кнопки
+// объявляет нужное право, а роль пользователя определяет, есть ли оно.
+package auth
+
+// Role - роль пользователя (users.role)
+type Role string
+
+const (
+	Guest     Role = "" // не зарегистрирован или деактивирован
+	Student   Role = "student"
+	Teacher   Role = "teacher"
+	Admin     Role = "admin"     // управление уроками, студентами и рассылками
+	Superuser Role = "superuser" // все права, включая преподавателей и роли
+)
+
+// Permission - право на действие
+type Permission string
+
+const (
+	LessonEnroll    Permission = "lesson.enroll"     // запись на уроки и лист ожидания
+	LessonCreate    Permission = "lesson.create"     // создание уроков
+	LessonViewOwn   Permission = "lesson.view.own"   // свое расписание и студенты
+	LessonEditOwn   Permission = "lesson.edit.own"   // перенос и отмена своих уроков
+	LessonEditAny   Permission = "lesson.edit.any"   // перенос и отмена любых уроков
+	LessonDeleteAny Permission = "lesson.delete.any" // удаление любых уроков
+	LessonRestore   Permission = "lesson.restore"    // восстановление отмененных уроков
+	StudentManage   Permission = "student.manage"    // активация и деактивация студентов
+	TeacherView     Permission = "teacher.view"      // список преподавателей
+	TeacherManage   Permission = "teacher.manage"    // добавление, удаление и восстановление преподавателей
+	NotifyStudents  Permission = "notify.students"   // уведомления студентам урока
+	NotifyAll       Permission = "notify.all"        // рассылки всем пользователям
+	StatsView       Permission = "stats.view"        // статистика
+	AuditView       Permission = "audit.view"        // журнал аудита изменений
+	RoomManage      Permission = "room.manage"       // аудитории и их расписание
+	SystemView      Permission = "system.view"       // логи ошибок и rate limiting
+	RoleManage      Permission = "role.manage"       // назначение администраторов
+)
+
+var (
+	studentPermissions = []Permission{LessonEnroll}
+
+	teacherPermissions = []Permission{LessonCreate, LessonViewOwn, LessonEditOwn}
+
+	adminPermissions = extend(teacherPermissions,
+		LessonEditAny, LessonDeleteAny, LessonRestore,
+		StudentManage, TeacherView, NotifyStudents, NotifyAll, StatsView, AuditView, RoomManage)
+
+	superuserPermissions = extend(adminPermissions,
+		TeacherManage, SystemView, RoleManage)
+)
+
+// Права ролей
+var rolePermissions = map[Role]map[Permission]bool{
+	Student:   permissionSet(studentPermissions),
+	Teacher:   permissionSet(teacherPermissions),
+	Admin:     permissionSet(adminPermissions),
+	Superuser: permissionSet(superuserPermissions),
+}
+
+// extend - права базовой роли и дополнительные (без изменения базового списка)
+func extend(base []Permission, extra ...Permission) []Permission {
+	return append(append([]Permission(nil), base...), extra...)
+}
+
+func permissionSet(permissions []Permission) map[Permission]bool {
+	set := make(map[Permission]bool, len(permissions))
+	for _, p := range permissions {
+		set[p] = true
+	}
+	return set
+}
+
+// ParseRole - роль из users.role; неизвестные значения считаются гостем
+func ParseRole(role string) Role {
+	if _, ok := rolePermissions[Role(role)]; ok {
+		return Role(role)
+	}
+	return Guest
+}
+
+// Can - есть ли у роли право; пустое право означает действие, доступное всем
+func (r Role) Can(p Permission) bool {
+	return p == "" || rolePermissions[r][p]
+}
@@ -0,0 +1,48 @@
+package auth
+
+import "testing"
+
+// Тест прав ролей
+func TestRolePermissions(t *testing.T) {
+	cases := []struct {
+		role       Role
+		permission Permission
+		want       bool
+	}{
+		{Guest, "", true},
+		{Guest, LessonEnroll, false},
+		{Student, LessonEnroll, true},
+		{Student, LessonCreate, false},
+		{Teacher, LessonCreate, true},
+		{Teacher, LessonEditAny, false},
+		{Teacher, LessonEnroll, false},
+		{Admin, LessonEditOwn, true},
+		{Admin, LessonDeleteAny, true},
+		{Admin, NotifyAll, true},
+		{Admin, AuditView, true},
+		{Teacher, AuditView, false},
+		{Admin, RoomManage, true},
+		{Teacher, RoomManage, false},
+		{Admin, TeacherManage, false},
+		{Admin, RoleManage, false},
+		{Superuser, TeacherManage, true},
+		{Superuser, RoleManage, true},
+		{Superuser, LessonDeleteAny, true},
+	}
+
+	for _, c := range cases {
+		if got := c.role.Can(c.permission); got != c.want {
+			t.Errorf("%q.Can(%q) = %v, want %v", c.role, c.permission, got, c.want)
+		}
+	}
+}
+
+// Тест разбора роли из БД
+func TestParseRole(t *testing.T) {
+	if ParseRole("admin") != Admin || ParseRole("superuser") != Superuser {
+		t.Error("Known roles must be parsed")
+	}
+	if ParseRole("root") != Guest || ParseRole("") != Guest {
+		t.Error("Unknown roles must be parsed as guest")
+	}
+}
@@ -0,0 +1,75 @@
+package callback
+
+// Action - действие кнопки
+type Action string
+
+// Разделы меню (без параметров)
+const (
+	MainMenu           Action = "main_menu"
+	StudentDashboard   Action = "student_dashboard"
+	Schedule           Action = "schedule"
+	MyLessons          Action = "my_lessons"
+	MyStudents         Action = "my_students"
+	MyWaitlist         Action = "my_waitlist"
+	EnrollSubjects     Action = "enroll_subjects"
+	Profile            Action = "profile"
+	Help               Action = "help"
+	HelpStudent        Action = "help_student"
+	HelpTeacher        Action = "help_teacher"
+	HelpAdmin          Action = "help_admin"
+	Teachers           Action = "teachers"
+	DeleteTeacherMenu  Action = "delete_teacher_menu"
+	RestoreTeacherMenu Action = "restore_teacher_menu"
+	Stats              Action = "stats"
+	Notifications      Action = "notifications"
+	Logs               Action = "logs"
+	CreateLessonMenu   Action = "create_lesson_menu"
+	DeleteLessonMenu   Action = "delete_lesson_menu"
+)
+
+// Действия с уроком (ID - урок)
+const (
+	Enroll              Action = "enroll"
+	Unenroll            Action = "unenroll"
+	Waitlist            Action = "waitlist"
+	LessonInfo          Action = "info"
+	RefreshLesson       Action = "refresh"
+	CancelLesson        Action = "cancel_lesson"
+	ConfirmDeleteLesson Action = "confirm_delete_lesson"
+	DeleteLesson        Action = "delete_lesson"
+	MarkAttendance      Action = "attend" // Arg - <ID студента>:<отметка>
+)
+
+// Действия с предметом (ID - предмет)
+const (
+	EnrollSubject       Action = "enroll_subject"
+	CreateLessonSubject Action = "create_lesson_subject"
+	DeleteLessonSubject Action = "delete_lesson_subject"
+)
+
+// Действия с преподавателем (ID - преподаватель)
+const (
+	ConfirmDeleteTeacher Action = "confirm_delete_teacher"
+	DeleteTeacher        Action = "delete_teacher"
+	RestoreTeacher       Action = "restore_teacher"
+)
+
+// AuditPage - страница журнала аудита (ID - номер страницы, Arg - фильтр)
+const AuditPage Action = "audit"
+
+// Dialog - кнопки пошаговых диалогов (Arg - <диалог>:<действие>[:<значение>])
+const Dialog Action = "dlg"
+
+// Действия, которые принимаются только с подписью сервера (см. Signer)
+var signedActions = map[Action]bool{
+	CancelLesson:   true,
+	MarkAttendance: true,
+	DeleteLesson:   true,
+	DeleteTeacher:  true,
+	RestoreTeacher: true,
+}
+
+// RequiresSignature - действие меняет чужие данные и не должно приниматься из поддельных кнопок
+func (a Action) RequiresSignature() bool {
+	return signedActions[a]
+}
@@ -0,0 +1,132 @@
+// Package callback - кодек callback_data inline-кнопок.
+//
+// Формат: v1:<действие>[:<id>[:<аргумент>]], например "v1:enroll:15".
+// Префикс версии позволяет отличить кнопки старых сообщений при смене формата,
+// а длина проверяется до отправки (лимит Telegram - 64 байта).
+// Опасные действия (удаление, отмена) передаются только подписанными, см. Signer.
+package callback
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Version - текущая версия формата
+const Version = "v1"
+
+// MaxLength - лимит Telegram на callback_data в байтах
+const MaxLength = 64
+
+const separator = ":"
+
+var (
+	ErrTooLong            = errors.New("callback данные длиннее 64 байт")
+	ErrUnsupportedVersion = errors.New("неподдерживаемая версия callback данных")
+	ErrMalformed          = errors.New("неверный формат callback данных")
+)
+
+// Payload - разобранные данные кнопки
+type Payload struct {
+	Action Action
+	ID     int    // ID урока, преподавателя или предмета (0 - нет)
+	Arg    string // дополнительный аргумент, может содержать ":"
+}
+
+// New - кнопка без параметров
+func New(action Action) Payload {
+	return Payload{Action: action}
+}
+
+// WithID - кнопка для конкретной сущности
+func WithID(action Action, id int) Payload {
+	return Payload{Action: action, ID: id}
+}
+
+// WithArg - копия с дополнительным аргументом
+func (p Payload) WithArg(arg string) Payload {
+	p.Arg = arg
+	return p
+}
+
+// Encode - строка для callback_data (действия, требующие подписи, кодирует Signer)
+func Encode(p Payload) (string, error) {
+	if p.Action.RequiresSignature() {
+		return "", fmt.Errorf("%w: %s", ErrSignatureRequired, p.Action)
+	}
+
+	body, err := encodeBody(p)
+	if err != nil {
+		return "", err
+	}
+
+	data := Version + separator + body
+	if len(data) > MaxLength {
+		return "", fmt.Errorf("%w: %q (%d байт)", ErrTooLong, data, len(data))
+	}
+	return data, nil
+}
+
+// encodeBody - <действие>[:<id>[:<аргумент>]]
+func encodeBody(p Payload) (string, error) {
+	if p.Action == "" || strings.Contains(string(p.Action), separator) {
+		return "", fmt.Errorf("%w: действие %q", ErrMalformed, p.Action)
+	}
+	if p.ID < 0 {
+		return "", fmt.Errorf("%w: отрицательный ID %d", ErrMalformed, p.ID)
+	}
+
+	parts := []string{string(p.Action)}
+	if p.ID != 0 || p.Arg != "" {
+		parts = append(parts, strconv.Itoa(p.ID))
+	}
+	if p.Arg != "" {
+		parts = append(parts, p.Arg)
+	}
+	return strings.Join(parts, separator), nil
+}
+
+// MustEncode - Encode для данных, собранных в коде; паника означает ошибку программиста
+func MustEncode(p Payload) string {
+	data, err := Encode(p)
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Decode - разбор callback_data
+func Decode(data string) (Payload, error) {
+	if len(data) > MaxLength {
+		return Payload{}, ErrTooLong
+	}
+
+	parts := strings.SplitN(data, separator, 4)
+	if parts[0] != Version {
+		return Payload{}, fmt.Errorf("%w: %q", ErrUnsupportedVersion, data)
+	}
+	if len(parts) < 2 || parts[1] == "" {
+		return Payload{}, fmt.Errorf("%w: %q", ErrMalformed, data)
+	}
+
+	p := Payload{Action: Action(parts[1])}
+	if len(parts) > 2 {
+		id, err := strconv.Atoi(parts[2])
+		if err != nil || id < 0 {
+			return Payload{}, fmt.Errorf("%w: ID в %q", ErrMalformed, data)
+		}
+		p.ID = id
+	}
+	if len(parts) > 3 {
+		p.Arg = parts[3]
+	}
+	return p, nil
+}
+
+// Button - inline-кнопка с закодированными данными
+func Button(text string, p Payload) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(text, MustEncode(p))
+}
@@ -0,0 +1,86 @@
+package callback
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Тест кодирования и обратного разбора
+func TestEncodeDecodeRoundTrip(t *testing.T) {
+	tests := []struct {
+		payload Payload
+		data    string
+	}{
+		{New(MainMenu), "v1:main_menu"},
+		{WithID(Enroll, 15), "v1:enroll:15"},
+		{WithID(ConfirmDeleteLesson, 7), "v1:confirm_delete_lesson:7"},
+		{New(Dialog).WithArg("create_lesson:choice:3"), "v1:dlg:0:create_lesson:choice:3"},
+	}
+
+	for _, test := range tests {
+		data, err := Encode(test.payload)
+		if err != nil {
+			t.Errorf("Unexpected error for %+v: %v", test.payload, err)
+			continue
+		}
+		if data != test.data {
+			t.Errorf("Expected %q, got %q", test.data, data)
+		}
+
+		decoded, err := Decode(data)
+		if err != nil {
+			t.Errorf("Unexpected decode error for %q: %v", data, err)
+			continue
+		}
+		if decoded != test.payload {
+			t.Errorf("Expected %+v, got %+v", test.payload, decoded)
+		}
+	}
+}
+
+// Тест ограничения Telegram на длину
+func TestEncodeTooLong(t *testing.T) {
+	_, err := Encode(New(Dialog).WithArg(strings.Repeat("x", MaxLength)))
+	if !errors.Is(err, ErrTooLong) {
+		t.Errorf("Expected ErrTooLong, got %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustEncode to panic on too long data")
+		}
+	}()
+	MustEncode(New(Dialog).WithArg(strings.Repeat("x", MaxLength)))
+}
+
+// Тест отказа в разборе устаревших и поврежденных данных
+func TestDecodeInvalid(t *testing.T) {
+	tests := []struct {
+		data string
+		err  error
+	}{
+		{"enroll_lesson_15", ErrUnsupportedVersion},
+		{"confirm_delete_lesson:7", ErrUnsupportedVersion},
+		{"v2:enroll:15", ErrUnsupportedVersion},
+		{"v1:", ErrMalformed},
+		{"v1:enroll:abc", ErrMalformed},
+		{"v1:enroll:-1", ErrMalformed},
+		{"v1:" + strings.Repeat("x", MaxLength), ErrTooLong},
+	}
+
+	for _, test := range tests {
+		if _, err := Decode(test.data); !errors.Is(err, test.err) {
+			t.Errorf("Decode(%q): expected %v, got %v", test.data, test.err, err)
+		}
+	}
+}
+
+// Тест отказа в кодировании некорректных действий
+func TestEncodeInvalidAction(t *testing.T) {
+	for _, p := range []Payload{{}, New("a:b"), WithID(Enroll, -5)} {
+		if _, err := Encode(p); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Encode(%+v): expected ErrMalformed, got %v", p, err)
+		}
+	}
+}
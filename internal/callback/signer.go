@@ -0,0 +1,120 @@
+package callback
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// SignedVersion - префикс подписанных данных:
+// s1:<срок действия base36>:<подпись>:<действие>[:<id>[:<аргумент>]]
+const SignedVersion = "s1"
+
+// signatureSize - байт HMAC-SHA256 в подписи (11 символов base64)
+const signatureSize = 8
+
+var (
+	ErrSignatureRequired = errors.New("действие требует подписанных callback данных")
+	ErrBadSignature      = errors.New("неверная подпись callback данных")
+	ErrExpired           = errors.New("срок действия кнопки истек")
+)
+
+// Signer - подпись кнопок опасных действий секретом сервера.
+// Подпись привязана к чату, в который отправлена кнопка, и ограничена по времени,
+// поэтому измененный клиент не может подставить чужой ID или переиспользовать старую кнопку.
+type Signer struct {
+	key []byte
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewSigner - подписывающий ключ и срок действия кнопок
+func NewSigner(secret []byte, ttl time.Duration) *Signer {
+	return &Signer{key: secret, ttl: ttl, now: time.Now}
+}
+
+// NewRandomSigner - подпись случайным ключом: кнопки становятся недействительны после перезапуска
+func NewRandomSigner(ttl time.Duration) (*Signer, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, fmt.Errorf("генерация ключа подписи: %w", err)
+	}
+	return NewSigner(secret, ttl), nil
+}
+
+// Encode - данные кнопки для чата chatID; подписываются только действия, требующие подписи
+func (s *Signer) Encode(p Payload, chatID int64) (string, error) {
+	if !p.Action.RequiresSignature() {
+		return Encode(p)
+	}
+
+	body, err := encodeBody(p)
+	if err != nil {
+		return "", err
+	}
+
+	expires := strconv.FormatInt(s.now().Add(s.ttl).Unix(), 36)
+	data := strings.Join([]string{SignedVersion, expires, s.sign(chatID, expires, body), body}, separator)
+	if len(data) > MaxLength {
+		return "", fmt.Errorf("%w: %q (%d байт)", ErrTooLong, data, len(data))
+	}
+	return data, nil
+}
+
+// Decode - разбор данных кнопки, нажатой в чате chatID, с проверкой подписи
+func (s *Signer) Decode(data string, chatID int64) (Payload, error) {
+	if !strings.HasPrefix(data, SignedVersion+separator) {
+		p, err := Decode(data)
+		if err == nil && p.Action.RequiresSignature() {
+			return Payload{}, fmt.Errorf("%w: %s", ErrSignatureRequired, p.Action)
+		}
+		return p, err
+	}
+
+	if len(data) > MaxLength {
+		return Payload{}, ErrTooLong
+	}
+	parts := strings.SplitN(data, separator, 4)
+	if len(parts) != 4 {
+		return Payload{}, fmt.Errorf("%w: %q", ErrMalformed, data)
+	}
+	expires, signature, body := parts[1], parts[2], parts[3]
+
+	if !hmac.Equal([]byte(signature), []byte(s.sign(chatID, expires, body))) {
+		return Payload{}, ErrBadSignature
+	}
+
+	expiresAt, err := strconv.ParseInt(expires, 36, 64)
+	if err != nil {
+		return Payload{}, fmt.Errorf("%w: срок действия в %q", ErrMalformed, data)
+	}
+	if s.now().Unix() > expiresAt {
+		return Payload{}, ErrExpired
+	}
+
+	return Decode(Version + separator + body)
+}
+
+// Button - inline-кнопка для чата chatID; паника означает ошибку программиста
+func (s *Signer) Button(text string, p Payload, chatID int64) tgbotapi.InlineKeyboardButton {
+	data, err := s.Encode(p, chatID)
+	if err != nil {
+		panic(err)
+	}
+	return tgbotapi.NewInlineKeyboardButtonData(text, data)
+}
+
+// sign - HMAC чата, срока действия и данных кнопки
+func (s *Signer) sign(chatID int64, expires, body string) string {
+	mac := hmac.New(sha256.New, s.key)
+	fmt.Fprintf(mac, "%d|%s|%s", chatID, expires, body)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)[:signatureSize])
+}
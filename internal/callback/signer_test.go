@@ -0,0 +1,85 @@
+package callback
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestSigner(now time.Time) *Signer {
+	s := NewSigner([]byte("test-secret"), time.Hour)
+	s.now = func() time.Time { return now }
+	return s
+}
+
+// Тест подписи и проверки опасного действия
+func TestSignerRoundTrip(t *testing.T) {
+	s := newTestSigner(time.Unix(1_700_000_000, 0))
+
+	data, err := s.Encode(WithID(DeleteTeacher, 12345), 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasPrefix(data, SignedVersion+":") || len(data) > MaxLength {
+		t.Errorf("Unexpected signed data %q", data)
+	}
+
+	p, err := s.Decode(data, 42)
+	if err != nil {
+		t.Fatalf("Unexpected decode error: %v", err)
+	}
+	if p != WithID(DeleteTeacher, 12345) {
+		t.Errorf("Unexpected payload %+v", p)
+	}
+}
+
+// Тест: обычные действия не подписываются и проходят как раньше
+func TestSignerPassesUnsignedActions(t *testing.T) {
+	s := newTestSigner(time.Now())
+
+	data, err := s.Encode(WithID(Enroll, 7), 42)
+	if err != nil || data != "v1:enroll:7" {
+		t.Fatalf("Expected plain data, got %q (%v)", data, err)
+	}
+	if p, err := s.Decode(data, 99); err != nil || p != WithID(Enroll, 7) {
+		t.Errorf("Expected plain payload, got %+v (%v)", p, err)
+	}
+}
+
+// Тест отказа для поддельных, чужих и просроченных кнопок
+func TestSignerRejectsTampering(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	s := newTestSigner(now)
+	data, _ := s.Encode(WithID(DeleteLesson, 5), 42)
+
+	// Неподписанная версия опасного действия
+	if _, err := s.Decode("v1:delete_lesson:5", 42); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("Expected ErrSignatureRequired, got %v", err)
+	}
+	if _, err := Encode(WithID(DeleteLesson, 5)); !errors.Is(err, ErrSignatureRequired) {
+		t.Errorf("Expected plain Encode to refuse signed action, got %v", err)
+	}
+
+	// Подмена ID урока
+	forged := strings.TrimSuffix(data, ":5") + ":6"
+	if _, err := s.Decode(forged, 42); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for forged ID, got %v", err)
+	}
+
+	// Кнопка из другого чата
+	if _, err := s.Decode(data, 43); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for another chat, got %v", err)
+	}
+
+	// Другой ключ сервера
+	if _, err := NewSigner([]byte("other"), time.Hour).Decode(data, 42); !errors.Is(err, ErrBadSignature) {
+		t.Errorf("Expected ErrBadSignature for another key, got %v", err)
+	}
+
+	// Истекший срок
+	s.now = func() time.Time { return now.Add(2 * time.Hour) }
+	if _, err := s.Decode(data, 42); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
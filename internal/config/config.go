@@ -3,6 +3,8 @@ package config
 import (
 	"os"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
@@ -13,14 +15,89 @@ type Config struct {
 	DBUser        string
 	DBPassword    string
 	DBName        string
+	AutoMigrate   bool // применять миграции при запуске бота
 	RedisHost     string
 	RedisPort     string
 	RedisPassword string
 	RedisDB       int
+	FSMStorage    string
+
+	// Режим получения обновлений: polling или webhook
+	BotMode           string
+	WebhookURL        string // публичный URL (за reverse proxy), например https://bot.example.com
+	WebhookPath       string
+	WebhookListenAddr string
+	WebhookSecret     string // проверяется в заголовке X-Telegram-Bot-Api-Secret-Token
+
+	// Параллельная обработка обновлений
+	Workers         int
+	WorkerQueueSize int
+
+	// Время на завершение обработки при остановке (SIGTERM)
+	ShutdownTimeout time.Duration
+
+	// Подпись кнопок опасных действий (пустой секрет - случайный ключ на время работы)
+	CallbackSecret string
+	CallbackTTL    time.Duration
+
+	// Адрес служебного сервера: метрики Prometheus (/metrics), /healthz и /readyz;
+	// пустой - сервер не запускается
+	MetricsListenAddr string
+
+	// Проверки здоровья: кэш getMe и допустимое время без обработанных обновлений
+	HealthTelegramCacheTTL time.Duration
+	HealthMaxUpdateAge     time.Duration
+
+	// Логи процесса: уровень (debug, info, warn, error) и формат (text или json)
+	LogLevel  string
+	LogFormat string
+
+	// Очередь уведомлений: лимиты отправки Telegram, проверка очереди и число попыток
+	NotifyRatePerSecond int
+	NotifyChatInterval  time.Duration
+	NotifyPollInterval  time.Duration
+	NotifyMaxAttempts   int
+
+	// Автоматические напоминания: за сколько до начала урока (пустой список - выключены)
+	// и как часто искать записи, которым пора напоминание
+	ReminderOffsets       []time.Duration
+	ReminderCheckInterval time.Duration
 }
 
 func Load() *Config {
 	redisDB, _ := strconv.Atoi(getEnv("REDIS_DB", "0"))
+	workers, _ := strconv.Atoi(getEnv("WORKERS", "8"))
+	workerQueueSize, _ := strconv.Atoi(getEnv("WORKER_QUEUE_SIZE", "100"))
+	shutdownTimeout, err := time.ParseDuration(getEnv("SHUTDOWN_TIMEOUT", "25s"))
+	if err != nil {
+		shutdownTimeout = 25 * time.Second
+	}
+	callbackTTL, err := time.ParseDuration(getEnv("CALLBACK_TTL", "24h"))
+	if err != nil {
+		callbackTTL = 24 * time.Hour
+	}
+	healthTelegramCacheTTL, err := time.ParseDuration(getEnv("HEALTH_TELEGRAM_CACHE_TTL", "1m"))
+	if err != nil {
+		healthTelegramCacheTTL = time.Minute
+	}
+	healthMaxUpdateAge, err := time.ParseDuration(getEnv("HEALTH_MAX_UPDATE_AGE", "5m"))
+	if err != nil {
+		healthMaxUpdateAge = 5 * time.Minute
+	}
+	notifyRatePerSecond, _ := strconv.Atoi(getEnv("NOTIFY_RATE_PER_SECOND", "25"))
+	notifyChatInterval, err := time.ParseDuration(getEnv("NOTIFY_CHAT_INTERVAL", "1s"))
+	if err != nil {
+		notifyChatInterval = time.Second
+	}
+	notifyPollInterval, err := time.ParseDuration(getEnv("NOTIFY_POLL_INTERVAL", "1s"))
+	if err != nil {
+		notifyPollInterval = time.Second
+	}
+	notifyMaxAttempts, _ := strconv.Atoi(getEnv("NOTIFY_MAX_ATTEMPTS", "5"))
+	reminderCheckInterval, err := time.ParseDuration(getEnv("REMINDER_CHECK_INTERVAL", "1m"))
+	if err != nil {
+		reminderCheckInterval = time.Minute
+	}
 	superUserID, _ := strconv.ParseInt(getEnv("BOT_SUPERUSER_ID", "0"), 10, 64)
 
 	return &Config{
@@ -31,10 +108,42 @@ func Load() *Config {
 		DBUser:        getEnv("DB_USER", "constellation_user"),
 		DBPassword:    getEnv("DB_PASSWORD", "constellation_pass"),
 		DBName:        getEnv("DB_NAME", "constellation_db"),
+		AutoMigrate:   getEnv("DB_AUTO_MIGRATE", "true") == "true",
 		RedisHost:     getEnv("REDIS_HOST", "localhost"),
 		RedisPort:     getEnv("REDIS_PORT", "6379"),
 		RedisPassword: getEnv("REDIS_PASSWORD", ""),
 		RedisDB:       redisDB,
+		FSMStorage:    getEnv("FSM_STORAGE", "postgres"),
+
+		BotMode:           getEnv("BOT_MODE", "polling"),
+		WebhookURL:        getEnv("WEBHOOK_URL", ""),
+		WebhookPath:       getEnv("WEBHOOK_PATH", "/telegram/webhook"),
+		WebhookListenAddr: getEnv("WEBHOOK_LISTEN_ADDR", ":8080"),
+		WebhookSecret:     getEnv("WEBHOOK_SECRET", ""),
+
+		Workers:         workers,
+		WorkerQueueSize: workerQueueSize,
+
+		ShutdownTimeout: shutdownTimeout,
+
+		CallbackSecret: getEnv("CALLBACK_SECRET", ""),
+		CallbackTTL:    callbackTTL,
+
+		MetricsListenAddr: getEnv("METRICS_LISTEN_ADDR", ":2112"),
+
+		HealthTelegramCacheTTL: healthTelegramCacheTTL,
+		HealthMaxUpdateAge:     healthMaxUpdateAge,
+
+		LogLevel:  getEnv("LOG_LEVEL", "info"),
+		LogFormat: getEnv("LOG_FORMAT", "text"),
+
+		NotifyRatePerSecond: notifyRatePerSecond,
+		NotifyChatInterval:  notifyChatInterval,
+		NotifyPollInterval:  notifyPollInterval,
+		NotifyMaxAttempts:   notifyMaxAttempts,
+
+		ReminderOffsets:       parseDurations(getEnvAllowEmpty("REMINDER_OFFSETS", "24h,1h")),
+		ReminderCheckInterval: reminderCheckInterval,
 	}
 }
 
@@ -44,3 +153,22 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvAllowEmpty - как getEnv, но явно заданное пустое значение не заменяется значением по умолчанию
+func getEnvAllowEmpty(key, defaultValue string) string {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// parseDurations - список интервалов через запятую; некорректные значения пропускаются
+func parseDurations(value string) []time.Duration {
+	var durations []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		if d, err := time.ParseDuration(strings.TrimSpace(part)); err == nil && d > 0 {
+			durations = append(durations, d)
+		}
+	}
+	return durations
+}
@@ -1,13 +1,14 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
-	"strings"
-	
+	"log/slog"
+
 	_ "github.com/lib/pq"
 	"constellation-school-bot/internal/config"
+	"constellation-school-bot/internal/store"
 )
 
 func Connect(cfg *config.Config) (*sql.DB, error) {
@@ -23,245 +24,40 @@ func Connect(cfg *config.Config) (*sql.DB, error) {
 		return nil, fmt.Errorf("ошибка подключения к БД: %w", err)
 	}
 
-	if err := createTables(db); err != nil {
-		return nil, fmt.Errorf("ошибка создания таблиц: %w", err)
-	}
-
-	if err := insertDefaultSubjects(db); err != nil {
-		return nil, fmt.Errorf("ошибка заполнения предметов: %w", err)
-	}
-
-	if err := migrateStatuses(db); err != nil {
-		return nil, fmt.Errorf("ошибка миграции статусов: %w", err)
-	}
-
-	if err := removeRedundantFields(db); err != nil {
-		return nil, fmt.Errorf("ошибка удаления избыточных полей: %w", err)
+	if err := ensureSchema(db, cfg.AutoMigrate); err != nil {
+		return nil, err
 	}
 
 	if err := createInitialSuperUser(db); err != nil {
 		return nil, fmt.Errorf("ошибка создания суперпользователя: %w", err)
 	}
 
-	log.Println("База данных подключена и таблицы созданы")
+	slog.Info("База данных подключена, схема актуальна")
 	return db, nil
 }
 
-func createTables(db *sql.DB) error {
-	tables := []string{
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			tg_id VARCHAR(100) UNIQUE NOT NULL,
-			role VARCHAR(20) NOT NULL,
-			full_name VARCHAR(255) NOT NULL,
-			phone VARCHAR(20),
-			is_active BOOLEAN DEFAULT true,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS teachers (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS students (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS subjects (
-			id SERIAL PRIMARY KEY,
-			name VARCHAR(255) NOT NULL,
-			code VARCHAR(50) UNIQUE NOT NULL,
-			category VARCHAR(50) NOT NULL,
-			description TEXT,
-			is_active BOOLEAN DEFAULT true
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS lessons (
-			id SERIAL PRIMARY KEY,
-			teacher_id INTEGER REFERENCES teachers(id),
-			subject_id INTEGER REFERENCES subjects(id),
-			start_time TIMESTAMP NOT NULL,
-			duration_minutes INTEGER DEFAULT 90,
-			max_students INTEGER DEFAULT 10,
-			status VARCHAR(20) DEFAULT 'active',
-			soft_deleted BOOLEAN DEFAULT FALSE,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS enrollments (
-			id SERIAL PRIMARY KEY,
-			student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
-			lesson_id INTEGER REFERENCES lessons(id) ON DELETE CASCADE,
-			status VARCHAR(20) DEFAULT 'enrolled',
-			enrolled_at TIMESTAMP DEFAULT NOW()
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS waitlist (
-			id SERIAL PRIMARY KEY,
-			student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
-			lesson_id INTEGER REFERENCES lessons(id) ON DELETE CASCADE,
-			position INTEGER NOT NULL,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS pending_operations (
-			id SERIAL PRIMARY KEY,
-			user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
-			operation VARCHAR(50) NOT NULL,
-			lesson_id INTEGER,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-		
-		`CREATE TABLE IF NOT EXISTS simple_logs (
-			id SERIAL PRIMARY KEY,
-			level VARCHAR(10) NOT NULL,
-			action VARCHAR(100) NOT NULL,
-			details TEXT,
-			user_id INTEGER,
-			created_at TIMESTAMP DEFAULT NOW()
-		)`,
-	}
-
-	for _, table := range tables {
-		if _, err := db.Exec(table); err != nil {
-			return fmt.Errorf("ошибка создания таблицы: %w", err)
-		}
-	}
-
-	// Создаем только критичные индексы для малого бизнеса (50-100 пользователей)
-	constraints := []string{
-		`CREATE INDEX idx_users_tg_id ON users(tg_id)`,           // КРИТИЧЕН: каждый запрос к боту
-		`CREATE INDEX idx_lessons_start_time ON lessons(start_time)`, // КРИТИЧЕН: расписание и сортировка
-		`CREATE INDEX idx_pending_operations_user_operation ON pending_operations(user_id, operation)`, // КРИТИЧЕН: rate-limiting
-		// Убраны избыточные индексы для упрощения (NO OVER-ENGINEERING):
-		// - idx_enrollments_lesson_id (можно восстановить при росте >100 пользователей)
-		// - idx_waitlist_lesson_id (используется редко, только при переполнении)
-	}
-	
-	for _, constraint := range constraints {
-		if _, err := db.Exec(constraint); err != nil {
-			// Игнорируем ошибку, если индекс уже существует
-			if !strings.Contains(err.Error(), "already exists") {
-				return fmt.Errorf("ошибка создания ограничения: %w", err)
-			}
-		}
-	}
-
-	return nil
-}
-
-func insertDefaultSubjects(db *sql.DB) error {
-	subjects := []struct {
-		name, code, category, description string
-	}{
-		{
-			name:        "3D-моделирование",
-			code:        "3D_MODELING",
-			category:    "digital_design",
-			description: "Основы 3D-моделирования и визуализации",
-		},
-		{
-			name:        "Геймдев",
-			code:        "GAMEDEV",
-			category:    "programming",
-			description: "Разработка компьютерных игр",
-		},
-		{
-			name:        "VFX-дизайн",
-			code:        "VFX_DESIGN",
-			category:    "digital_design",
-			description: "Визуальные эффекты и постобработка",
-		},
-		{
-			name:        "Графический дизайн",
-			code:        "GRAPHIC_DESIGN",
-			category:    "design",
-			description: "Основы графического дизайна",
-		},
-		{
-			name:        "Веб-разработка",
-			code:        "WEB_DEV",
-			category:    "programming",
-			description: "Создание веб-сайтов и приложений",
-		},
-		{
-			name:        "Компьютерная грамотность",
-			code:        "COMPUTER_LITERACY",
-			category:    "basics",
-			description: "Основы работы с компьютером",
-		},
-	}
-
-	for _, subject := range subjects {
-		query := `INSERT INTO subjects (name, code, category, description) 
-				  VALUES ($1, $2, $3, $4) 
-				  ON CONFLICT (code) DO NOTHING`
-		_, err := db.Exec(query, subject.name, subject.code, subject.category, subject.description)
-		if err != nil {
-			return fmt.Errorf("ошибка добавления предмета %s: %w", subject.name, err)
-		}
-	}
-
-	return nil
-}
-
-func migrateStatuses(db *sql.DB) error {
-	// Миграция статусов уроков: 'scheduled', 'confirmed' -> 'active', остальные -> 'cancelled'
-	_, err := db.Exec(`
-		UPDATE lessons 
-		SET status = CASE 
-			WHEN status IN ('scheduled', 'confirmed') THEN 'active'
-			ELSE 'cancelled'
-		END
-		WHERE status NOT IN ('active', 'cancelled')
-	`)
-	if err != nil {
-		return fmt.Errorf("ошибка миграции статусов уроков: %w", err)
-	}
-
-	// Миграция статусов записей: все кроме 'cancelled' -> 'enrolled'
-	_, err = db.Exec(`
-		UPDATE enrollments 
-		SET status = CASE 
-			WHEN status LIKE '%cancelled%' THEN 'cancelled'
-			ELSE 'enrolled'
-		END
-		WHERE status NOT IN ('enrolled', 'cancelled')
-	`)
+// ensureSchema - применяет миграции (autoMigrate) или проверяет, что схема актуальна
+func ensureSchema(db *sql.DB, autoMigrate bool) error {
+	migrator, err := NewMigrator(db)
 	if err != nil {
-		return fmt.Errorf("ошибка миграции статусов записей: %w", err)
+		return fmt.Errorf("ошибка загрузки миграций: %w", err)
 	}
 
-	return nil
-}
-
-func removeRedundantFields(db *sql.DB) error {
-	// Удаляем неиспользуемое поле default_duration из subjects
-	_, err := db.Exec(`
-		ALTER TABLE subjects 
-		DROP COLUMN IF EXISTS default_duration
-	`)
-	if err != nil {
-		return fmt.Errorf("ошибка удаления поля default_duration: %w", err)
+	ctx := context.Background()
+	if autoMigrate {
+		if _, err := migrator.Up(ctx); err != nil {
+			return fmt.Errorf("ошибка применения миграций: %w", err)
+		}
+		return nil
 	}
 
-	// Обновляем default значения для статусов
-	_, err = db.Exec(`
-		ALTER TABLE lessons ALTER COLUMN status SET DEFAULT 'active'
-	`)
+	pending, err := migrator.Pending(ctx)
 	if err != nil {
-		return fmt.Errorf("ошибка обновления default для lessons.status: %w", err)
+		return fmt.Errorf("ошибка проверки миграций: %w", err)
 	}
-
-	_, err = db.Exec(`
-		ALTER TABLE enrollments ALTER COLUMN status SET DEFAULT 'enrolled'  
-	`)
-	if err != nil {
-		return fmt.Errorf("ошибка обновления default для enrollments.status: %w", err)
+	if pending > 0 {
+		return fmt.Errorf("схема БД устарела: %d непримененных миграций, выполните `go run ./cmd/migrate up`", pending)
 	}
-
 	return nil
 }
 
@@ -297,7 +93,8 @@ func CancelLesson(db *sql.DB, lessonID int) error {
 	return tx.Commit()
 }
 
-// EnrollStudent записывает студента на урок
+// EnrollStudent записывает студента на урок.
+// Оборачивает store.ErrStudentScheduleConflict, если студент записан на другой урок в это время
 // Автор: Maksim Novihin  
 func EnrollStudent(db *sql.DB, studentID, lessonID int) error {
 	_, err := db.Exec(`
@@ -306,7 +103,7 @@ func EnrollStudent(db *sql.DB, studentID, lessonID int) error {
 	`, studentID, lessonID)
 	
 	if err != nil {
-		return fmt.Errorf("ошибка записи студента на урок: %w", err)
+		return fmt.Errorf("ошибка записи студента на урок: %w", store.ScheduleConflict(err))
 	}
 	
 	return nil
@@ -369,6 +166,6 @@ func createInitialSuperUser(db *sql.DB) error {
 		return fmt.Errorf("ошибка создания суперпользователя: %w", err)
 	}
 
-	log.Println("Создан первый суперпользователь с ID: 7231695922")
+	slog.Info("Создан первый суперпользователь", "tg_id", "7231695922")
 	return nil
 }
@@ -35,8 +35,7 @@ WithOccurrence(2).WithStartupTimeout(30*time.Second)),
 	db, err := sql.Open("postgres", connectionString)
 	require.NoError(t, err)
 	require.NoError(t, db.Ping())
-	require.NoError(t, createTables(db))
-	require.NoError(t, insertDefaultSubjects(db))
+	require.NoError(t, ensureSchema(db, true))
 
 	t.Cleanup(func() {
 		db.Close()
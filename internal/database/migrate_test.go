@@ -0,0 +1,65 @@
+package database
+
+import (
+	"io/fs"
+	"testing"
+	"testing/fstest"
+)
+
+// Тест загрузки миграций: сортировка по версии и контрольная сумма up-скрипта
+func TestLoadMigrationsSorted(t *testing.T) {
+	fsys := fstest.MapFS{
+		"0002_second.up.sql":   {Data: []byte("SELECT 2;")},
+		"0002_second.down.sql": {Data: []byte("SELECT -2;")},
+		"0001_first.up.sql":    {Data: []byte("SELECT 1;")},
+	}
+
+	migrations, err := LoadMigrations(fsys)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Version != 2 {
+		t.Fatalf("Expected migrations 1 and 2 in order, got %+v", migrations)
+	}
+	if migrations[1].Down != "SELECT -2;" || migrations[0].Down != "" {
+		t.Errorf("Down scripts were not loaded correctly: %+v", migrations)
+	}
+	if len(migrations[0].Checksum) != 64 {
+		t.Errorf("Expected sha256 hex checksum, got %q", migrations[0].Checksum)
+	}
+}
+
+// Тест: некорректные наборы файлов отклоняются
+func TestLoadMigrationsInvalid(t *testing.T) {
+	cases := map[string]fstest.MapFS{
+		"bad name":      {"create_users.sql": {Data: []byte("SELECT 1;")}},
+		"missing up":    {"0001_first.down.sql": {Data: []byte("SELECT 1;")}},
+		"version clash": {"0001_a.up.sql": {Data: []byte("SELECT 1;")}, "0001_b.up.sql": {Data: []byte("SELECT 1;")}},
+	}
+
+	for name, fsys := range cases {
+		if _, err := LoadMigrations(fsys); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+// Тест: встроенные миграции корректны
+func TestEmbeddedMigrations(t *testing.T) {
+	sub, err := fs.Sub(migrationFiles, "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	migrations, err := LoadMigrations(sub)
+	if err != nil {
+		t.Fatalf("Embedded migrations are invalid: %v", err)
+	}
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			t.Errorf("Expected contiguous versions, got %d at position %d", m.Version, i)
+		}
+		if m.Down == "" {
+			t.Errorf("Migration %04d_%s has no down script", m.Version, m.Name)
+		}
+	}
+}
@@ -0,0 +1,10 @@
+DROP TABLE IF EXISTS fsm_states;
+DROP TABLE IF EXISTS simple_logs;
+DROP TABLE IF EXISTS pending_operations;
+DROP TABLE IF EXISTS waitlist;
+DROP TABLE IF EXISTS enrollments;
+DROP TABLE IF EXISTS lessons;
+DROP TABLE IF EXISTS subjects;
+DROP TABLE IF EXISTS students;
+DROP TABLE IF EXISTS teachers;
+DROP TABLE IF EXISTS users;
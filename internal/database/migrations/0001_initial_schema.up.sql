@@ -0,0 +1,88 @@
+-- Базовая схема (ранее создавалась createTables при каждом запуске).
+-- IF NOT EXISTS позволяет принять под управление уже существующие БД.
+
+CREATE TABLE IF NOT EXISTS users (
+    id SERIAL PRIMARY KEY,
+    tg_id VARCHAR(100) UNIQUE NOT NULL,
+    role VARCHAR(20) NOT NULL,
+    full_name VARCHAR(255) NOT NULL,
+    phone VARCHAR(20),
+    is_active BOOLEAN DEFAULT true,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS teachers (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS students (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS subjects (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    code VARCHAR(50) UNIQUE NOT NULL,
+    category VARCHAR(50) NOT NULL,
+    description TEXT,
+    is_active BOOLEAN DEFAULT true
+);
+
+CREATE TABLE IF NOT EXISTS lessons (
+    id SERIAL PRIMARY KEY,
+    teacher_id INTEGER REFERENCES teachers(id),
+    subject_id INTEGER REFERENCES subjects(id),
+    start_time TIMESTAMP NOT NULL,
+    duration_minutes INTEGER DEFAULT 90,
+    max_students INTEGER DEFAULT 10,
+    status VARCHAR(20) DEFAULT 'active',
+    soft_deleted BOOLEAN DEFAULT FALSE,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS enrollments (
+    id SERIAL PRIMARY KEY,
+    student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
+    lesson_id INTEGER REFERENCES lessons(id) ON DELETE CASCADE,
+    status VARCHAR(20) DEFAULT 'enrolled',
+    enrolled_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS waitlist (
+    id SERIAL PRIMARY KEY,
+    student_id INTEGER REFERENCES students(id) ON DELETE CASCADE,
+    lesson_id INTEGER REFERENCES lessons(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS pending_operations (
+    id SERIAL PRIMARY KEY,
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    operation VARCHAR(50) NOT NULL,
+    lesson_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS simple_logs (
+    id SERIAL PRIMARY KEY,
+    level VARCHAR(10) NOT NULL,
+    action VARCHAR(100) NOT NULL,
+    details TEXT,
+    user_id INTEGER,
+    created_at TIMESTAMP DEFAULT NOW()
+);
+
+CREATE TABLE IF NOT EXISTS fsm_states (
+    tg_id BIGINT PRIMARY KEY,
+    state VARCHAR(50) NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    expires_at TIMESTAMP NOT NULL
+);
+
+-- Только критичные индексы для малого бизнеса (50-100 пользователей)
+CREATE INDEX IF NOT EXISTS idx_users_tg_id ON users(tg_id);
+CREATE INDEX IF NOT EXISTS idx_lessons_start_time ON lessons(start_time);
+CREATE INDEX IF NOT EXISTS idx_pending_operations_user_operation ON pending_operations(user_id, operation);
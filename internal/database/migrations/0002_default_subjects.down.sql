@@ -0,0 +1,4 @@
+-- Удаляем только предметы без уроков
+DELETE FROM subjects
+WHERE code IN ('3D_MODELING', 'GAMEDEV', 'VFX_DESIGN', 'GRAPHIC_DESIGN', 'WEB_DEV', 'COMPUTER_LITERACY')
+    AND NOT EXISTS (SELECT 1 FROM lessons l WHERE l.subject_id = subjects.id);
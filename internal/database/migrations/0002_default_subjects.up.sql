@@ -0,0 +1,9 @@
+-- Предметы школы по умолчанию
+INSERT INTO subjects (name, code, category, description) VALUES
+    ('3D-моделирование', '3D_MODELING', 'digital_design', 'Основы 3D-моделирования и визуализации'),
+    ('Геймдев', 'GAMEDEV', 'programming', 'Разработка компьютерных игр'),
+    ('VFX-дизайн', 'VFX_DESIGN', 'digital_design', 'Визуальные эффекты и постобработка'),
+    ('Графический дизайн', 'GRAPHIC_DESIGN', 'design', 'Основы графического дизайна'),
+    ('Веб-разработка', 'WEB_DEV', 'programming', 'Создание веб-сайтов и приложений'),
+    ('Компьютерная грамотность', 'COMPUTER_LITERACY', 'basics', 'Основы работы с компьютером')
+ON CONFLICT (code) DO NOTHING;
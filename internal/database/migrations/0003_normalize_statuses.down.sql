@@ -0,0 +1,3 @@
+-- Нормализация статусов необратима: исходные значения не сохранялись.
+-- Откат только снимает запись о миграции.
+SELECT 1;
@@ -0,0 +1,20 @@
+-- Статусы уроков: 'scheduled', 'confirmed' -> 'active', остальные -> 'cancelled'
+UPDATE lessons
+SET status = CASE
+    WHEN status IN ('scheduled', 'confirmed') THEN 'active'
+    ELSE 'cancelled'
+END
+WHERE status NOT IN ('active', 'cancelled');
+
+-- Статусы записей: все кроме 'cancelled' -> 'enrolled'
+UPDATE enrollments
+SET status = CASE
+    WHEN status LIKE '%cancelled%' THEN 'cancelled'
+    ELSE 'enrolled'
+END
+WHERE status NOT IN ('enrolled', 'cancelled');
+
+-- Неиспользуемое поле и значения статусов по умолчанию
+ALTER TABLE subjects DROP COLUMN IF EXISTS default_duration;
+ALTER TABLE lessons ALTER COLUMN status SET DEFAULT 'active';
+ALTER TABLE enrollments ALTER COLUMN status SET DEFAULT 'enrolled';
@@ -0,0 +1,18 @@
+ALTER TABLE simple_logs ALTER COLUMN level DROP DEFAULT;
+
+ALTER TABLE pending_operations DROP COLUMN IF EXISTS finished_at;
+
+ALTER TABLE enrollments DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE enrollments DROP COLUMN IF EXISTS soft_deleted;
+
+ALTER TABLE lessons DROP COLUMN IF EXISTS updated_at;
+
+ALTER TABLE students DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE students DROP COLUMN IF EXISTS created_at;
+ALTER TABLE students DROP COLUMN IF EXISTS soft_deleted;
+
+ALTER TABLE teachers DROP COLUMN IF EXISTS updated_at;
+ALTER TABLE teachers DROP COLUMN IF EXISTS created_at;
+ALTER TABLE teachers DROP COLUMN IF EXISTS soft_deleted;
+
+ALTER TABLE users DROP COLUMN IF EXISTS updated_at;
@@ -0,0 +1,21 @@
+-- Колонки, которые используются в коде, но отсутствовали в схеме
+
+ALTER TABLE users ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE teachers ADD COLUMN IF NOT EXISTS soft_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE teachers ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE teachers ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE students ADD COLUMN IF NOT EXISTS soft_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE students ADD COLUMN IF NOT EXISTS created_at TIMESTAMP DEFAULT NOW();
+ALTER TABLE students ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE enrollments ADD COLUMN IF NOT EXISTS soft_deleted BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE enrollments ADD COLUMN IF NOT EXISTS updated_at TIMESTAMP;
+
+ALTER TABLE pending_operations ADD COLUMN IF NOT EXISTS finished_at TIMESTAMP;
+
+-- LogAction не передает уровень
+ALTER TABLE simple_logs ALTER COLUMN level SET DEFAULT 'INFO';
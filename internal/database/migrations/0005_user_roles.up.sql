@@ -0,0 +1,3 @@
+-- Роли пользователей: отдельная роль администратора помимо superuser
+ALTER TABLE users ADD CONSTRAINT users_role_check
+    CHECK (role IN ('student', 'teacher', 'admin', 'superuser'));
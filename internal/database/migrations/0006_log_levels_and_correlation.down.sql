@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_simple_logs_correlation_id;
+DROP INDEX IF EXISTS idx_simple_logs_level_created_at;
+ALTER TABLE simple_logs DROP CONSTRAINT IF EXISTS simple_logs_level_check;
+ALTER TABLE simple_logs DROP COLUMN IF EXISTS correlation_id;
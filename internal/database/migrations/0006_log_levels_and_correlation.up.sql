@@ -0,0 +1,17 @@
+-- Уровень записи вместо префиксов в action и correlation ID обновления
+ALTER TABLE simple_logs ADD COLUMN IF NOT EXISTS correlation_id VARCHAR(32);
+
+UPDATE simple_logs SET level = upper(level);
+UPDATE simple_logs SET level = 'WARN' WHERE level = 'WARNING';
+UPDATE simple_logs SET level = 'INFO' WHERE level NOT IN ('DEBUG', 'INFO', 'WARN', 'ERROR');
+UPDATE simple_logs SET level = 'ERROR'
+WHERE action LIKE 'ERROR: %' OR action LIKE '%error%' OR action LIKE '%ошибка%';
+UPDATE simple_logs SET action = substr(action, length('ERROR: ') + 1)
+WHERE action LIKE 'ERROR: %';
+
+ALTER TABLE simple_logs DROP CONSTRAINT IF EXISTS simple_logs_level_check;
+ALTER TABLE simple_logs ADD CONSTRAINT simple_logs_level_check
+    CHECK (level IN ('DEBUG', 'INFO', 'WARN', 'ERROR'));
+
+CREATE INDEX IF NOT EXISTS idx_simple_logs_level_created_at ON simple_logs(level, created_at);
+CREATE INDEX IF NOT EXISTS idx_simple_logs_correlation_id ON simple_logs(correlation_id);
@@ -0,0 +1,18 @@
+-- Журнал аудита: кто, когда и как изменил урок, преподавателя, студента или запись
+CREATE TABLE IF NOT EXISTS audit_log (
+    id SERIAL PRIMARY KEY,
+    actor_tg_id BIGINT, -- NULL для системных действий
+    action VARCHAR(50) NOT NULL,
+    entity_type VARCHAR(20) NOT NULL
+        CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user')),
+    entity_id INTEGER NOT NULL,
+    before JSONB,
+    after JSONB,
+    correlation_id VARCHAR(32),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_log_created_at ON audit_log(created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_actor ON audit_log(actor_tg_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_entity ON audit_log(entity_type, entity_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_audit_log_action ON audit_log(action, created_at);
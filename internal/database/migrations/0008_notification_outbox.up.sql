@@ -0,0 +1,35 @@
+-- Очередь исходящих уведомлений (outbox): рассылка и сообщения каждому получателю
+CREATE TABLE IF NOT EXISTS notification_batches (
+    id SERIAL PRIMARY KEY,
+    kind VARCHAR(50) NOT NULL,
+    title TEXT NOT NULL, -- заголовок рассылки в отчете о доставке
+    created_by_tg_id BIGINT, -- NULL для системных рассылок
+    report_chat_id BIGINT,   -- чат для отчета о доставке, NULL - без отчета
+    total INTEGER NOT NULL DEFAULT 0,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    reported_at TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS notifications (
+    id SERIAL PRIMARY KEY,
+    batch_id INTEGER NOT NULL REFERENCES notification_batches(id) ON DELETE CASCADE,
+    chat_id BIGINT NOT NULL,
+    text TEXT NOT NULL,
+    parse_mode VARCHAR(20) NOT NULL DEFAULT '',
+    status VARCHAR(20) NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'sending', 'sent', 'failed', 'unreachable')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_error TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    sent_at TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notifications_due ON notifications(next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS idx_notifications_batch ON notifications(batch_id, status);
+CREATE INDEX IF NOT EXISTS idx_notification_batches_unreported ON notification_batches(id)
+    WHERE reported_at IS NULL AND report_chat_id IS NOT NULL;
+
+-- Пользователь заблокировал бота или удалил аккаунт: рассылки его пропускают до следующего сообщения
+ALTER TABLE users ADD COLUMN IF NOT EXISTS unreachable_at TIMESTAMP;
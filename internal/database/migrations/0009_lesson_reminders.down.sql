@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS reminder_opt_outs;
+DROP TABLE IF EXISTS lesson_reminders;
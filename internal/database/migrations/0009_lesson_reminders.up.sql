@@ -0,0 +1,19 @@
+-- Автоматические напоминания об уроках: отправленные напоминания по записям.
+-- start_time - время урока, о котором напомнили: после переноса урока напоминание придет снова,
+-- а перезапуск бота не приводит к повторной отправке
+CREATE TABLE IF NOT EXISTS lesson_reminders (
+    id SERIAL PRIMARY KEY,
+    enrollment_id INTEGER NOT NULL REFERENCES enrollments(id) ON DELETE CASCADE,
+    reminder VARCHAR(20) NOT NULL, -- тип напоминания: интервал до начала урока (24h, 1h)
+    start_time TIMESTAMP NOT NULL,
+    sent_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (enrollment_id, reminder, start_time)
+);
+
+-- Типы напоминаний, от которых студент отказался
+CREATE TABLE IF NOT EXISTS reminder_opt_outs (
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    reminder VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (student_id, reminder)
+);
@@ -0,0 +1,10 @@
+-- Записи журнала аудита о сериях сохраняются: прежняя проверка не применяется к существующим строкам
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user')) NOT VALID;
+
+DROP TABLE IF EXISTS series_enrollments;
+DROP INDEX IF EXISTS idx_lessons_series;
+ALTER TABLE lessons DROP COLUMN IF EXISTS series_id;
+DROP TABLE IF EXISTS lesson_series_exceptions;
+DROP TABLE IF EXISTS lesson_series;
@@ -0,0 +1,43 @@
+-- Повторяющиеся уроки: правило серии (каждую неделю или через неделю, до даты или N занятий)
+-- и уроки, созданные по нему. Уроки серии - обычные строки lessons со ссылкой series_id
+CREATE TABLE IF NOT EXISTS lesson_series (
+    id SERIAL PRIMARY KEY,
+    subject_id INTEGER NOT NULL REFERENCES subjects(id),
+    teacher_id INTEGER REFERENCES teachers(id),
+    start_time TIMESTAMP NOT NULL, -- первое занятие: день недели и время серии
+    duration_minutes INTEGER NOT NULL DEFAULT 90,
+    max_students INTEGER NOT NULL DEFAULT 10,
+    interval_weeks INTEGER NOT NULL CHECK (interval_weeks IN (1, 2)),
+    until_date DATE,     -- последний день серии
+    occurrences INTEGER, -- или число занятий
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    created_by_tg_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    CHECK (until_date IS NOT NULL OR occurrences IS NOT NULL)
+);
+
+-- Даты, в которые занятия серии нет (праздники, каникулы, отмененные занятия)
+CREATE TABLE IF NOT EXISTS lesson_series_exceptions (
+    series_id INTEGER NOT NULL REFERENCES lesson_series(id) ON DELETE CASCADE,
+    skip_date DATE NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (series_id, skip_date)
+);
+
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS series_id INTEGER REFERENCES lesson_series(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_lessons_series ON lessons(series_id, start_time) WHERE series_id IS NOT NULL;
+
+-- Студенты, записанные на всю серию сразу
+CREATE TABLE IF NOT EXISTS series_enrollments (
+    id SERIAL PRIMARY KEY,
+    series_id INTEGER NOT NULL REFERENCES lesson_series(id) ON DELETE CASCADE,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    enrolled_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE (series_id, student_id)
+);
+
+-- Серии в журнале аудита
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user', 'series'));
@@ -0,0 +1,10 @@
+-- Записи журнала аудита о курсах сохраняются: прежняя проверка не применяется к существующим строкам
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user', 'series')) NOT VALID;
+
+DROP INDEX IF EXISTS idx_lessons_course;
+ALTER TABLE lessons DROP COLUMN IF EXISTS course_id;
+DROP TABLE IF EXISTS course_enrollments;
+DROP TABLE IF EXISTS course_plan;
+DROP TABLE IF EXISTS courses;
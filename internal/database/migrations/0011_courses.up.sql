@@ -0,0 +1,43 @@
+-- Курсы: программа из нескольких уроков по предмету у одного преподавателя.
+-- Студент записывается на курс целиком и автоматически попадает на все его уроки
+CREATE TABLE IF NOT EXISTS courses (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(255) NOT NULL,
+    subject_id INTEGER NOT NULL REFERENCES subjects(id),
+    teacher_id INTEGER REFERENCES teachers(id),
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    members INTEGER NOT NULL DEFAULT 0, -- занятые места; меняется вместе с course_enrollments
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'cancelled')),
+    created_by_tg_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    CHECK (members >= 0 AND members <= capacity)
+);
+
+-- План курса: темы уроков по порядку и созданный для темы урок
+CREATE TABLE IF NOT EXISTS course_plan (
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    topic VARCHAR(255) NOT NULL,
+    lesson_id INTEGER REFERENCES lessons(id) ON DELETE SET NULL,
+    PRIMARY KEY (course_id, position)
+);
+
+-- Участники курса
+CREATE TABLE IF NOT EXISTS course_enrollments (
+    id SERIAL PRIMARY KEY,
+    course_id INTEGER NOT NULL REFERENCES courses(id) ON DELETE CASCADE,
+    student_id INTEGER NOT NULL REFERENCES students(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL DEFAULT 'active' CHECK (status IN ('active', 'left')),
+    enrolled_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP,
+    UNIQUE (course_id, student_id)
+);
+
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS course_id INTEGER REFERENCES courses(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_lessons_course ON lessons(course_id, start_time) WHERE course_id IS NOT NULL;
+
+-- Курсы в журнале аудита
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user', 'series', 'course'));
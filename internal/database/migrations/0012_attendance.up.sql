@@ -0,0 +1,9 @@
+-- Посещаемость: отметка преподавателя по записи студента на урок
+CREATE TABLE IF NOT EXISTS attendance (
+    enrollment_id INTEGER PRIMARY KEY REFERENCES enrollments(id) ON DELETE CASCADE,
+    status VARCHAR(20) NOT NULL CHECK (status IN ('present', 'late', 'absent', 'excused')),
+    marked_by_tg_id BIGINT,
+    marked_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_attendance_status ON attendance(status);
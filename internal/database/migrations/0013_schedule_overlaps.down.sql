@@ -0,0 +1,8 @@
+ALTER TABLE enrollments DROP CONSTRAINT IF EXISTS enrollments_student_no_overlap;
+DROP TRIGGER IF EXISTS lessons_enrollment_period ON lessons;
+DROP TRIGGER IF EXISTS enrollments_lesson_period ON enrollments;
+DROP FUNCTION IF EXISTS lessons_sync_enrollment_period();
+DROP FUNCTION IF EXISTS enrollments_set_lesson_period();
+DROP FUNCTION IF EXISTS lesson_period(INTEGER);
+ALTER TABLE enrollments DROP COLUMN IF EXISTS lesson_period;
+ALTER TABLE lessons DROP CONSTRAINT IF EXISTS lessons_teacher_no_overlap;
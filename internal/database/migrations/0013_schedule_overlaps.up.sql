@@ -0,0 +1,89 @@
+-- Пересечения расписания: у преподавателя и у студента не может быть двух активных уроков,
+-- интервалы [start_time, start_time + duration_minutes) которых пересекаются
+CREATE EXTENSION IF NOT EXISTS btree_gist;
+
+-- Существующие пересечения нужно разрешить вручную до применения миграции
+DO $$
+DECLARE
+    conflicts INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO conflicts
+    FROM lessons a
+    JOIN lessons b ON a.teacher_id = b.teacher_id AND a.id < b.id
+    WHERE a.status = 'active' AND a.soft_deleted = false
+        AND b.status = 'active' AND b.soft_deleted = false
+        AND a.start_time < b.start_time + COALESCE(b.duration_minutes, 90) * INTERVAL '1 minute'
+        AND b.start_time < a.start_time + COALESCE(a.duration_minutes, 90) * INTERVAL '1 minute';
+    IF conflicts > 0 THEN
+        RAISE EXCEPTION 'пересекающихся уроков преподавателей: %', conflicts
+            USING HINT = 'перенесите или отмените пересекающиеся уроки и повторите миграцию';
+    END IF;
+END $$;
+
+-- Проверка в конце запроса: уроки серии переносятся одним UPDATE и могут временно пересекаться друг с другом
+ALTER TABLE lessons ADD CONSTRAINT lessons_teacher_no_overlap EXCLUDE USING gist (
+    teacher_id WITH =,
+    tsrange(start_time, start_time + COALESCE(duration_minutes, 90) * INTERVAL '1 minute') WITH &&
+) WHERE (teacher_id IS NOT NULL AND status = 'active' AND soft_deleted = false)
+DEFERRABLE INITIALLY IMMEDIATE;
+
+-- Время урока в записи: ограничение исключения не может ссылаться на другую таблицу.
+-- NULL для отмененных и удаленных уроков - такие записи ни с чем не пересекаются
+ALTER TABLE enrollments ADD COLUMN IF NOT EXISTS lesson_period TSRANGE;
+
+CREATE OR REPLACE FUNCTION lesson_period(lesson_id INTEGER) RETURNS TSRANGE AS $$
+    SELECT tsrange(start_time, start_time + COALESCE(duration_minutes, 90) * INTERVAL '1 minute')
+    FROM lessons
+    WHERE id = lesson_id AND status = 'active' AND soft_deleted = false
+$$ LANGUAGE sql STABLE;
+
+CREATE OR REPLACE FUNCTION enrollments_set_lesson_period() RETURNS TRIGGER AS $$
+BEGIN
+    NEW.lesson_period := lesson_period(NEW.lesson_id);
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER enrollments_lesson_period
+    BEFORE INSERT OR UPDATE OF lesson_id, status ON enrollments
+    FOR EACH ROW EXECUTE FUNCTION enrollments_set_lesson_period();
+
+CREATE OR REPLACE FUNCTION lessons_sync_enrollment_period() RETURNS TRIGGER AS $$
+BEGIN
+    UPDATE enrollments SET lesson_period = lesson_period(NEW.id) WHERE lesson_id = NEW.id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER lessons_enrollment_period
+    AFTER UPDATE OF start_time, duration_minutes, status, soft_deleted ON lessons
+    FOR EACH ROW
+    WHEN (OLD.start_time IS DISTINCT FROM NEW.start_time
+        OR OLD.duration_minutes IS DISTINCT FROM NEW.duration_minutes
+        OR OLD.status IS DISTINCT FROM NEW.status
+        OR OLD.soft_deleted IS DISTINCT FROM NEW.soft_deleted)
+    EXECUTE FUNCTION lessons_sync_enrollment_period();
+
+UPDATE enrollments SET lesson_period = lesson_period(lesson_id);
+
+DO $$
+DECLARE
+    conflicts INTEGER;
+BEGIN
+    SELECT COUNT(*) INTO conflicts
+    FROM enrollments a
+    JOIN enrollments b ON a.student_id = b.student_id AND a.id < b.id
+    WHERE a.status = 'enrolled' AND b.status = 'enrolled' AND a.lesson_period && b.lesson_period;
+    IF conflicts > 0 THEN
+        RAISE EXCEPTION 'пересекающихся записей студентов: %', conflicts
+            USING HINT = 'отмените пересекающиеся записи и повторите миграцию';
+    END IF;
+END $$;
+
+-- Проверка при фиксации транзакции: перенос урока обновляет записи построчно триггером,
+-- и записи на уроки одной серии могут временно пересекаться друг с другом
+ALTER TABLE enrollments ADD CONSTRAINT enrollments_student_no_overlap EXCLUDE USING gist (
+    student_id WITH =,
+    lesson_period WITH &&
+) WHERE (status = 'enrolled')
+DEFERRABLE INITIALLY DEFERRED;
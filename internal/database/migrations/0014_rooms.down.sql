@@ -0,0 +1,9 @@
+-- Записи журнала аудита об аудиториях сохраняются: прежняя проверка не применяется к существующим строкам
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user', 'series', 'course')) NOT VALID;
+
+ALTER TABLE lessons DROP CONSTRAINT IF EXISTS lessons_room_no_overlap;
+ALTER TABLE lessons DROP COLUMN IF EXISTS room_id;
+ALTER TABLE subjects DROP COLUMN IF EXISTS required_equipment;
+DROP TABLE IF EXISTS rooms;
@@ -0,0 +1,29 @@
+-- Аудитории: вместимость и оборудование (теги, например 'gpu workstations').
+-- Урок может проходить в аудитории; предмет может требовать оборудования от аудитории
+CREATE TABLE IF NOT EXISTS rooms (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    capacity INTEGER NOT NULL CHECK (capacity > 0),
+    equipment TEXT[] NOT NULL DEFAULT '{}',
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_by_tg_id BIGINT,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+ALTER TABLE subjects ADD COLUMN IF NOT EXISTS required_equipment TEXT[] NOT NULL DEFAULT '{}';
+UPDATE subjects SET required_equipment = ARRAY['gpu workstations']
+WHERE code IN ('3D_MODELING', 'VFX_DESIGN');
+
+ALTER TABLE lessons ADD COLUMN IF NOT EXISTS room_id INTEGER REFERENCES rooms(id);
+
+-- В аудитории не может быть двух пересекающихся активных уроков (btree_gist из 0013_schedule_overlaps)
+ALTER TABLE lessons ADD CONSTRAINT lessons_room_no_overlap EXCLUDE USING gist (
+    room_id WITH =,
+    tsrange(start_time, start_time + COALESCE(duration_minutes, 90) * INTERVAL '1 minute') WITH &&
+) WHERE (room_id IS NOT NULL AND status = 'active' AND soft_deleted = false)
+DEFERRABLE INITIALLY IMMEDIATE;
+
+-- Аудитории в журнале аудита
+ALTER TABLE audit_log DROP CONSTRAINT IF EXISTS audit_log_entity_type_check;
+ALTER TABLE audit_log ADD CONSTRAINT audit_log_entity_type_check
+    CHECK (entity_type IN ('lesson', 'teacher', 'student', 'enrollment', 'waitlist', 'user', 'series', 'course', 'room'));
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"log/slog"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// requestUser - автор обновления, загружается один раз в withUser
+type requestUser struct {
+	TelegramID int64
+	Account    *store.User // nil - не зарегистрирован
+	Role       auth.Role   // auth.Guest для незарегистрированных и деактивированных
+}
+
+// Can - есть ли у пользователя право
+func (u *requestUser) Can(p auth.Permission) bool {
+	return u.Role.Can(p)
+}
+
+// resolveUser - пользователь и его роль по Telegram ID.
+// Ошибка БД не прерывает обработку: пользователь получает права гостя.
+func resolveUser(db *sql.DB, from *tgbotapi.User) *requestUser {
+	user := &requestUser{}
+	if from == nil {
+		return user
+	}
+	user.TelegramID = from.ID
+
+	account, err := repos(db).Users.GetByTelegramID(from.ID)
+	if err != nil {
+		if !errors.Is(err, store.ErrNotFound) {
+			slog.Error("Ошибка загрузки пользователя", "tg_id", from.ID, "err", err)
+		}
+		return user
+	}
+	user.Account = account
+	if account.IsActive {
+		user.Role = auth.ParseRole(account.Role)
+	}
+	return user
+}
+
+// authorize - проверка права маршрута; при отказе пользователь получает причину
+func authorize(bot telegram.Messenger, chatID int64, user *requestUser, p auth.Permission) bool {
+	if user.Can(p) {
+		return true
+	}
+
+	slog.WarnContext(telegram.Context(bot), "Доступ запрещен", "tg_id", user.TelegramID, "role", user.Role, "permission", p)
+	sendAccessDenied(bot, chatID, user)
+	return false
+}
+
+// sendAccessDenied - причина отказа: нет регистрации, аккаунт деактивирован или не хватает прав
+func sendAccessDenied(bot telegram.Messenger, chatID int64, user *requestUser) {
+	switch {
+	case user.Account == nil:
+		sendMessage(bot, chatID, "❌ Вы не зарегистрированы в системе. Используйте /register")
+	case !user.Account.IsActive:
+		sendMessage(bot, chatID, "❌ Ваш аккаунт деактивирован. Обратитесь к администратору")
+	default:
+		sendMessage(bot, chatID, "❌ У вас нет прав для этого действия")
+	}
+}
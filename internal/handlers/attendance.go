@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// maxAttendanceRows - студентов в листе посещаемости (у Telegram ограничено число кнопок сообщения)
+const maxAttendanceRows = 24
+
+// attendanceLabels - отметки посещаемости для сообщений
+var attendanceLabels = map[string]string{
+	store.AttendancePresent: "✅ присутствовал",
+	store.AttendanceLate:    "⏰ опоздал",
+	store.AttendanceAbsent:  "❌ не пришел",
+	store.AttendanceExcused: "📝 уважительная причина",
+	"":                      "▫️ не отмечен",
+}
+
+// attendanceOrder - отметки в итогах, неотмеченные последними
+var attendanceOrder = []string{
+	store.AttendancePresent, store.AttendanceLate, store.AttendanceAbsent, store.AttendanceExcused, "",
+}
+
+// attendanceCodes - короткие коды отметок в callback данных (лимит 64 байта)
+var attendanceCodes = map[string]string{
+	store.AttendancePresent: "p",
+	store.AttendanceLate:    "l",
+	store.AttendanceAbsent:  "a",
+	store.AttendanceExcused: "e",
+}
+
+// attendanceIcon - значок отметки
+func attendanceIcon(status string) string {
+	icon, _, _ := strings.Cut(attendanceLabels[status], " ")
+	return icon
+}
+
+// parseAttendanceArg - студент и отметка из аргумента кнопки `<ID студента>:<код>`
+func parseAttendanceArg(arg string) (int, string, bool) {
+	id, code, ok := strings.Cut(arg, ":")
+	studentID, err := strconv.Atoi(id)
+	if !ok || err != nil {
+		return 0, "", false
+	}
+	for status, c := range attendanceCodes {
+		if c == code {
+			return studentID, status, true
+		}
+	}
+	return 0, "", false
+}
+
+// attendanceSheet - текст и кнопки листа посещаемости урока
+func attendanceSheet(lesson *store.Lesson, marks []store.AttendanceMark, chatID int64) (string, *tgbotapi.InlineKeyboardMarkup) {
+	text := fmt.Sprintf("📋 **Посещаемость**\n\n📚 %s\n📅 %s\n\n",
+		lesson.SubjectName, lesson.StartTime.Format("02.01.2006 15:04"))
+
+	counts := make(map[string]int)
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i, mark := range marks {
+		counts[mark.Status]++
+		text += fmt.Sprintf("%d. %s - %s\n", i+1, mark.StudentName, attendanceLabels[mark.Status])
+		if i >= maxAttendanceRows {
+			continue
+		}
+
+		var row []tgbotapi.InlineKeyboardButton
+		for _, status := range store.AttendanceStatuses {
+			label := fmt.Sprintf("%d %s", i+1, attendanceIcon(status))
+			if status == mark.Status {
+				label = "[" + label + "]"
+			}
+			payload := callback.WithID(callback.MarkAttendance, lesson.ID).
+				WithArg(fmt.Sprintf("%d:%s", mark.StudentID, attendanceCodes[status]))
+			row = append(row, signedButton(label, payload, chatID))
+		}
+		rows = append(rows, row)
+	}
+
+	text += "\n**Итого:**"
+	for _, status := range attendanceOrder {
+		text += fmt.Sprintf(" %s %d", attendanceIcon(status), counts[status])
+	}
+	if len(marks) > maxAttendanceRows {
+		text += fmt.Sprintf("\n\nКнопки показаны для первых %d студентов", maxAttendanceRows)
+	}
+
+	if len(rows) == 0 {
+		return text, nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(rows...)
+	return text, &keyboard
+}
+
+// attendanceLesson - урок, посещаемость которого может отмечать пользователь.
+// Возвращает текст ошибки для пользователя или пустую строку.
+func attendanceLesson(st *store.Store, user *requestUser, lessonID int, now time.Time) (*store.Lesson, string) {
+	lesson, err := st.Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted || !canManageLesson(st, user, lessonID) {
+		return nil, "❌ Урок не найден или не принадлежит вам"
+	}
+	if lesson.Status != "active" {
+		return nil, "❌ Урок отменен"
+	}
+	if lesson.StartTime.After(now) {
+		return nil, "❌ Отмечать посещаемость можно после начала урока"
+	}
+	return lesson, ""
+}
+
+// Лист посещаемости урока: /attendance <lesson_id>
+func handleAttendanceCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	lessonID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID урока: /attendance <lesson_id>")
+		return
+	}
+
+	st := repos(db)
+	lesson, problem := attendanceLesson(st, user, lessonID, time.Now())
+	if problem != "" {
+		sendMessage(bot, message.Chat.ID, problem)
+		return
+	}
+
+	marks, err := st.Attendance.Sheet(lessonID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения листа посещаемости", "lesson_id", lessonID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения листа посещаемости")
+		return
+	}
+	if len(marks) == 0 {
+		sendMessage(bot, message.Chat.ID, "📭 На урок никто не записан")
+		return
+	}
+
+	text, keyboard := attendanceSheet(lesson, marks, message.Chat.ID)
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	bot.Send(msg)
+}
+
+// Отметка посещаемости кнопкой листа
+func handleMarkAttendanceCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	studentID, status, ok := parseAttendanceArg(data.Arg)
+	if !ok {
+		bot.AnswerCallback(query.ID, "❌ Некорректная кнопка")
+		return
+	}
+
+	st := repos(db)
+	lesson, problem := attendanceLesson(st, user, data.ID, time.Now())
+	if problem != "" {
+		bot.AnswerCallback(query.ID, problem)
+		return
+	}
+
+	previous, err := st.Attendance.Mark(lesson.ID, studentID, status, query.From.ID)
+	switch {
+	case errors.Is(err, store.ErrNotEnrolled):
+		bot.AnswerCallback(query.ID, "❌ Студент больше не записан на урок")
+	case err != nil:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка отметки посещаемости", "lesson_id", lesson.ID, "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка отметки посещаемости")
+		return
+	default:
+		if previous != status {
+			var before auditState
+			if previous != "" {
+				before = auditState{"student_id": studentID, "attendance": previous}
+			}
+			audit(bot, db, query.From.ID, auditAttendanceMarked, store.AuditEnrollment, lesson.ID,
+				before, auditState{"student_id": studentID, "attendance": status})
+		}
+		bot.AnswerCallback(query.ID, attendanceLabels[status])
+	}
+
+	// Лист перерисовывается и для отписавшегося студента
+	marks, err := st.Attendance.Sheet(lesson.ID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения листа посещаемости", "lesson_id", lesson.ID, "err", err)
+		return
+	}
+	text, keyboard := attendanceSheet(lesson, marks, query.Message.Chat.ID)
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+}
+
+// formatAttendanceHistory - посещаемость студента для /my_lessons
+func formatAttendanceHistory(records []store.AttendanceRecord, summary map[string]int) string {
+	text := "📋 **Посещаемость:**\n"
+	for _, status := range attendanceOrder {
+		if summary[status] > 0 {
+			text += fmt.Sprintf("• %s: %d\n", attendanceLabels[status], summary[status])
+		}
+	}
+	text += "\n**Последние уроки:**\n"
+	for _, record := range records {
+		text += fmt.Sprintf("%s %s - %s\n", attendanceIcon(record.Status),
+			record.StartTime.Format("02.01.2006 15:04"), record.SubjectName)
+	}
+	return text
+}
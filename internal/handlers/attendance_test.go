@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+func TestParseAttendanceArg(t *testing.T) {
+	studentID, status, ok := parseAttendanceArg("42:l")
+	if !ok || studentID != 42 || status != store.AttendanceLate {
+		t.Errorf("Unexpected result: %d %q %v", studentID, status, ok)
+	}
+
+	for _, arg := range []string{"", "42", "42:x", "x:p", "42:present"} {
+		if _, _, ok := parseAttendanceArg(arg); ok {
+			t.Errorf("Expected %q to be rejected", arg)
+		}
+	}
+}
+
+// Тест листа посещаемости: кнопка на каждую отметку, текущая выделена, данные кнопок в лимите Telegram
+func TestAttendanceSheet(t *testing.T) {
+	lesson := &store.Lesson{ID: 1234567, SubjectName: "3D-моделирование",
+		StartTime: time.Date(2025, 9, 2, 16, 30, 0, 0, time.UTC)}
+	marks := []store.AttendanceMark{
+		{StudentID: 1234567, StudentName: "Анна", Status: store.AttendanceAbsent},
+		{StudentID: 2, StudentName: "Борис"},
+	}
+
+	text, keyboard := attendanceSheet(lesson, marks, 1)
+	if !strings.Contains(text, "Анна - ❌ не пришел") || !strings.Contains(text, "Борис - ▫️ не отмечен") {
+		t.Errorf("Unexpected sheet text: %s", text)
+	}
+	if !strings.Contains(text, "**Итого:** ✅ 0 ⏰ 0 ❌ 1 📝 0 ▫️ 1") {
+		t.Errorf("Unexpected totals: %s", text)
+	}
+	if keyboard == nil || len(keyboard.InlineKeyboard) != 2 {
+		t.Fatalf("Expected a row per student, got %+v", keyboard)
+	}
+
+	for _, row := range keyboard.InlineKeyboard {
+		if len(row) != len(store.AttendanceStatuses) {
+			t.Errorf("Expected %d buttons, got %d", len(store.AttendanceStatuses), len(row))
+		}
+		for _, button := range row {
+			if len(*button.CallbackData) > 64 {
+				t.Errorf("Callback data too long: %q", *button.CallbackData)
+			}
+		}
+	}
+	if got := keyboard.InlineKeyboard[0][2].Text; got != "[1 ❌]" {
+		t.Errorf("Expected current mark to be highlighted, got %q", got)
+	}
+}
+
+func TestAttendanceSheetLimitsButtons(t *testing.T) {
+	var marks []store.AttendanceMark
+	for i := 1; i <= maxAttendanceRows+5; i++ {
+		marks = append(marks, store.AttendanceMark{StudentID: i, StudentName: fmt.Sprintf("Студент %d", i)})
+	}
+
+	text, keyboard := attendanceSheet(&store.Lesson{ID: 1}, marks, 1)
+	if len(keyboard.InlineKeyboard) != maxAttendanceRows {
+		t.Errorf("Expected %d rows, got %d", maxAttendanceRows, len(keyboard.InlineKeyboard))
+	}
+	if !strings.Contains(text, fmt.Sprintf("Студент %d", maxAttendanceRows+5)) {
+		t.Error("Expected all students in the sheet text")
+	}
+}
+
+func TestFormatAttendanceHistory(t *testing.T) {
+	records := []store.AttendanceRecord{
+		{SubjectName: "Робототехника", StartTime: time.Date(2025, 9, 9, 16, 30, 0, 0, time.UTC)},
+		{SubjectName: "Робототехника", StartTime: time.Date(2025, 9, 2, 16, 30, 0, 0, time.UTC), Status: store.AttendancePresent},
+	}
+	text := formatAttendanceHistory(records, map[string]int{store.AttendancePresent: 1, "": 1})
+
+	for _, want := range []string{"✅ присутствовал: 1", "▫️ не отмечен: 1", "▫️ 09.09.2025 16:30 - Робототехника", "✅ 02.09.2025 16:30"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in %s", want, text)
+		}
+	}
+	if strings.Contains(text, "опоздал") {
+		t.Errorf("Unexpected empty status in summary: %s", text)
+	}
+}
+
+func TestNoShowRate(t *testing.T) {
+	if rate := (store.SystemStats{}).NoShowRate(); rate != 0 {
+		t.Errorf("Expected 0 without marks, got %v", rate)
+	}
+	stats := store.SystemStats{AttendancePresent: 6, AttendanceLate: 1, AttendanceAbsent: 2, AttendanceExcused: 1}
+	if rate := stats.NoShowRate(); rate != 20 {
+		t.Errorf("Expected 20%%, got %v", rate)
+	}
+}
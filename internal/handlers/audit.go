@@ -0,0 +1,406 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/logging"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Действия журнала аудита. Порядок в auditActions - часть формата кнопок
+// навигации (фильтр хранит индекс действия), поэтому новые действия только дописываются в конец.
+const (
+	auditLessonCreated      = "lesson_created"
+	auditLessonCancelled    = "lesson_cancelled"
+	auditLessonDeleted      = "lesson_deleted"
+	auditLessonRestored     = "lesson_restored"
+	auditLessonRescheduled  = "lesson_rescheduled"
+	auditLessonEnrolled     = "lesson_enrolled"
+	auditLessonUnenrolled   = "lesson_unenrolled"
+	auditWaitlistAdded      = "waitlist_added"
+	auditWaitlistPromoted   = "waitlist_promoted"
+	auditTeacherAdded       = "teacher_added"
+	auditTeacherDeleted     = "teacher_deleted"
+	auditTeacherRestored    = "teacher_restored"
+	auditStudentDeactivated = "student_deactivated"
+	auditStudentActivated   = "student_activated"
+	auditRoleChanged        = "role_changed"
+	auditUserRegistered     = "user_registered"
+	auditSeriesCreated      = "series_created"
+	auditSeriesRescheduled  = "series_rescheduled"
+	auditSeriesCancelled    = "series_cancelled"
+	auditSeriesEnrolled     = "series_enrolled"
+	auditCourseCreated      = "course_created"
+	auditCourseScheduled    = "course_scheduled"
+	auditCourseEnrolled     = "course_enrolled"
+	auditCourseLeft         = "course_left"
+	auditAttendanceMarked   = "attendance_marked"
+	auditRoomCreated        = "room_created"
+)
+
+var auditActions = []string{
+	auditLessonCreated, auditLessonCancelled, auditLessonDeleted, auditLessonRestored,
+	auditLessonRescheduled, auditLessonEnrolled, auditLessonUnenrolled, auditWaitlistAdded,
+	auditWaitlistPromoted, auditTeacherAdded, auditTeacherDeleted, auditTeacherRestored,
+	auditStudentDeactivated, auditStudentActivated, auditRoleChanged, auditUserRegistered,
+	auditSeriesCreated, auditSeriesRescheduled, auditSeriesCancelled, auditSeriesEnrolled,
+	auditCourseCreated, auditCourseScheduled, auditCourseEnrolled, auditCourseLeft,
+	auditAttendanceMarked, auditRoomCreated,
+}
+
+// auditState - значения полей сущности до или после изменения
+type auditState map[string]any
+
+// audit - запись об изменении сущности; actorTgID 0 - системное действие.
+// before nil - сущность создана, after nil - удалена. Ошибка записи не прерывает операцию.
+func audit(bot telegram.Messenger, db *sql.DB, actorTgID int64, action string, entity store.AuditEntity, entityID int, before, after auditState) {
+	ctx := telegram.Context(bot)
+	entry := store.AuditEntry{
+		Action:        action,
+		Entity:        entity,
+		EntityID:      entityID,
+		CorrelationID: logging.CorrelationID(ctx),
+	}
+	if actorTgID != 0 {
+		entry.ActorTgID = &actorTgID
+	}
+
+	var err error
+	if entry.Before, err = marshalAuditState(before); err == nil {
+		entry.After, err = marshalAuditState(after)
+	}
+	if err == nil {
+		err = repos(db).Audit.Record(entry)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка записи в журнал аудита", "action", action, "entity", entity, "entity_id", entityID, "err", err)
+	}
+}
+
+// newLessonState - поля созданного урока
+func newLessonState(subjectID, teacherID int, startTime time.Time, maxStudents, roomID int) auditState {
+	state := auditState{
+		"subject_id":   subjectID,
+		"teacher_id":   teacherID,
+		"start_time":   startTime,
+		"max_students": maxStudents,
+		"status":       "active",
+	}
+	if roomID > 0 {
+		state["room_id"] = roomID
+	}
+	return state
+}
+
+// teacherState - преподаватель и его пользователь
+func teacherState(tgID, fullName string, softDeleted bool) auditState {
+	return auditState{"tg_id": tgID, "full_name": fullName, "soft_deleted": softDeleted}
+}
+
+// enrollmentState - запись студента на урок (EntityID записи в журнале - ID урока)
+func enrollmentState(studentID int, status string) auditState {
+	return auditState{"student_id": studentID, "status": status}
+}
+
+// waitlistState - место студента в листе ожидания урока
+func waitlistState(studentID, position int) auditState {
+	return auditState{"student_id": studentID, "position": position}
+}
+
+func marshalAuditState(state auditState) ([]byte, error) {
+	if state == nil {
+		return nil, nil
+	}
+	return json.Marshal(state)
+}
+
+// Записей журнала аудита на странице
+const auditPageSize = 5
+
+// Просмотр журнала аудита: /audit [user=<telegram_id>] [entity=<тип>] [id=<ID>]
+// [action=<действие>] [from=<ДД.ММ.ГГГГ>] [to=<ДД.ММ.ГГГГ>]
+func handleAuditCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	filter, err := parseAuditFilter(strings.Fields(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\n\n"+auditUsage())
+		return
+	}
+
+	text, keyboard, err := auditPage(db, filter, 0)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка чтения журнала аудита", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения журнала аудита")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	bot.Send(msg)
+}
+
+// Переход по страницам журнала аудита
+func handleAuditPageCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	filter, err := decodeAuditFilter(data.Arg)
+	if err != nil {
+		bot.AnswerCallback(query.ID, "⌛ Кнопка устарела, повторите /audit")
+		return
+	}
+
+	text, keyboard, err := auditPage(db, filter, data.ID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка чтения журнала аудита", "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка получения журнала аудита")
+		return
+	}
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, text, keyboard)
+}
+
+// auditPage - текст страницы журнала и кнопки навигации (nil, если страница одна)
+func auditPage(db *sql.DB, filter store.AuditFilter, page int) (string, *tgbotapi.InlineKeyboardMarkup, error) {
+	entries, total, err := repos(db).Audit.Search(filter, auditPageSize, page*auditPageSize)
+	if err != nil {
+		return "", nil, err
+	}
+	if total == 0 {
+		return "🔍 В журнале аудита нет записей по этому фильтру", nil, nil
+	}
+
+	pages := (total + auditPageSize - 1) / auditPageSize
+	var text strings.Builder
+	text.WriteString(fmt.Sprintf("🗂 **Журнал аудита** (записей: %d, стр. %d/%d)\n\n", total, page+1, pages))
+	for i, entry := range entries {
+		writeAuditEntry(&text, page*auditPageSize+i+1, entry)
+	}
+
+	if pages == 1 {
+		return text.String(), nil, nil
+	}
+	arg := encodeAuditFilter(filter)
+	var row []tgbotapi.InlineKeyboardButton
+	if page > 0 {
+		row = append(row, callback.Button("◀️ Назад", callback.WithID(callback.AuditPage, page-1).WithArg(arg)))
+	}
+	if page+1 < pages {
+		row = append(row, callback.Button("Вперед ▶️", callback.WithID(callback.AuditPage, page+1).WithArg(arg)))
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return text.String(), &keyboard, nil
+}
+
+// writeAuditEntry - запись журнала: время, действие, автор, сущность и значения до и после
+func writeAuditEntry(text *strings.Builder, n int, entry store.AuditEntry) {
+	text.WriteString(fmt.Sprintf("**%d.** %s `%s`\n", n, entry.CreatedAt.Format("02.01.2006 15:04:05"), entry.Action))
+	switch {
+	case entry.ActorTgID == nil:
+		text.WriteString("   ⚙️ Система\n")
+	case entry.ActorName != "":
+		text.WriteString(fmt.Sprintf("   👤 %s (%d)\n", escapeMarkdown(entry.ActorName), *entry.ActorTgID))
+	default:
+		text.WriteString(fmt.Sprintf("   👤 %d\n", *entry.ActorTgID))
+	}
+	text.WriteString(fmt.Sprintf("   📌 %s #%d\n", entry.Entity, entry.EntityID))
+	if entry.Before != nil {
+		text.WriteString(fmt.Sprintf("   ⬅️ До: `%s`\n", auditJSON(entry.Before)))
+	}
+	if entry.After != nil {
+		text.WriteString(fmt.Sprintf("   ➡️ После: `%s`\n", auditJSON(entry.After)))
+	}
+	if entry.CorrelationID != "" {
+		text.WriteString(fmt.Sprintf("   🔗 `%s`\n", entry.CorrelationID))
+	}
+	text.WriteString("\n")
+}
+
+// auditJSON - значения для блока кода Markdown: без обратных кавычек, длинные обрезаются
+func auditJSON(data []byte) string {
+	value := strings.ReplaceAll(string(data), "`", "'")
+	if runes := []rune(value); len(runes) > 200 {
+		value = string(runes[:200]) + "..."
+	}
+	return value
+}
+
+func auditUsage() string {
+	return "Использование: `/audit [user=<telegram_id>] [entity=<тип>] [id=<ID>] [action=<действие>] [from=<ДД.ММ.ГГГГ>] [to=<ДД.ММ.ГГГГ>]`\n" +
+		"Типы: " + joinAuditEntities() + "\n" +
+		"Действия: " + strings.Join(auditActions, ", ")
+}
+
+func joinAuditEntities() string {
+	names := make([]string, len(store.AuditEntities))
+	for i, entity := range store.AuditEntities {
+		names[i] = string(entity)
+	}
+	return strings.Join(names, ", ")
+}
+
+// Формат даты в фильтрах /audit
+const auditDateLayout = "02.01.2006"
+
+// parseAuditFilter - фильтр из аргументов вида ключ=значение; to включает указанный день
+func parseAuditFilter(args []string) (store.AuditFilter, error) {
+	var filter store.AuditFilter
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok || value == "" {
+			return filter, fmt.Errorf("неверный фильтр %q", arg)
+		}
+
+		switch key = strings.ToLower(key); key {
+		case "user":
+			id, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || id <= 0 {
+				return filter, fmt.Errorf("неверный Telegram ID %q", value)
+			}
+			filter.ActorTgID = id
+		case "entity":
+			if auditEntityIndex(store.AuditEntity(value)) < 0 {
+				return filter, fmt.Errorf("неизвестный тип %q", value)
+			}
+			filter.Entity = store.AuditEntity(value)
+		case "id":
+			id, err := strconv.Atoi(value)
+			if err != nil || id <= 0 {
+				return filter, fmt.Errorf("неверный ID %q", value)
+			}
+			filter.EntityID = id
+		case "action":
+			if auditActionIndex(value) < 0 {
+				return filter, fmt.Errorf("неизвестное действие %q", value)
+			}
+			filter.Action = value
+		case "from", "to":
+			date, err := time.ParseInLocation(auditDateLayout, value, time.Local)
+			if err != nil {
+				return filter, fmt.Errorf("неверная дата %q", value)
+			}
+			if key == "from" {
+				filter.From = date
+			} else {
+				filter.To = date.AddDate(0, 0, 1)
+			}
+		default:
+			return filter, fmt.Errorf("неизвестный фильтр %q", key)
+		}
+	}
+
+	if !filter.From.IsZero() && !filter.To.IsZero() && !filter.From.Before(filter.To) {
+		return filter, errors.New("дата from позже даты to")
+	}
+	return filter, nil
+}
+
+func auditEntityIndex(entity store.AuditEntity) int {
+	for i, e := range store.AuditEntities {
+		if e == entity {
+			return i
+		}
+	}
+	return -1
+}
+
+func auditActionIndex(action string) int {
+	for i, a := range auditActions {
+		if a == action {
+			return i
+		}
+	}
+	return -1
+}
+
+// encodeAuditFilter - фильтр для аргумента кнопки навигации (лимит callback данных - 64 байта):
+// поля через ",", буква поля и значение в base36; тип и действие - индексы в списках,
+// даты - дни с начала эпохи Unix в локальной зоне
+func encodeAuditFilter(filter store.AuditFilter) string {
+	var fields []string
+	add := func(field byte, value int64) {
+		fields = append(fields, string(field)+strconv.FormatInt(value, 36))
+	}
+
+	if filter.ActorTgID != 0 {
+		add('u', filter.ActorTgID)
+	}
+	if filter.Entity != "" {
+		add('e', int64(auditEntityIndex(filter.Entity)))
+	}
+	if filter.EntityID != 0 {
+		add('i', int64(filter.EntityID))
+	}
+	if filter.Action != "" {
+		add('a', int64(auditActionIndex(filter.Action)))
+	}
+	if !filter.From.IsZero() {
+		add('f', localDays(filter.From))
+	}
+	if !filter.To.IsZero() {
+		add('t', localDays(filter.To))
+	}
+	return strings.Join(fields, ",")
+}
+
+// decodeAuditFilter - фильтр из аргумента кнопки навигации
+func decodeAuditFilter(arg string) (store.AuditFilter, error) {
+	var filter store.AuditFilter
+	if arg == "" {
+		return filter, nil
+	}
+
+	for _, field := range strings.Split(arg, ",") {
+		if len(field) < 2 {
+			return filter, fmt.Errorf("неверное поле фильтра %q", field)
+		}
+		value, err := strconv.ParseInt(field[1:], 36, 64)
+		if err != nil || value < 0 {
+			return filter, fmt.Errorf("неверное поле фильтра %q", field)
+		}
+
+		switch field[0] {
+		case 'u':
+			filter.ActorTgID = value
+		case 'e':
+			if value >= int64(len(store.AuditEntities)) {
+				return filter, fmt.Errorf("неизвестный тип %d", value)
+			}
+			filter.Entity = store.AuditEntities[value]
+		case 'i':
+			filter.EntityID = int(value)
+		case 'a':
+			if value >= int64(len(auditActions)) {
+				return filter, fmt.Errorf("неизвестное действие %d", value)
+			}
+			filter.Action = auditActions[value]
+		case 'f':
+			filter.From = fromLocalDays(value)
+		case 't':
+			filter.To = fromLocalDays(value)
+		default:
+			return filter, fmt.Errorf("неизвестное поле фильтра %q", field)
+		}
+	}
+	return filter, nil
+}
+
+// localDays - номер дня (полночь в локальной зоне) с 01.01.1970
+func localDays(t time.Time) int64 {
+	year, month, day := t.In(time.Local).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / 86400
+}
+
+func fromLocalDays(days int64) time.Time {
+	year, month, day := time.Unix(days*86400, 0).UTC().Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.Local)
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/store"
+)
+
+// Тест разбора фильтров /audit
+func TestParseAuditFilter(t *testing.T) {
+	filter, err := parseAuditFilter(strings.Fields("user=123456789 entity=lesson id=15 action=lesson_cancelled from=01.02.2025 to=28.02.2025"))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := store.AuditFilter{
+		ActorTgID: 123456789,
+		Entity:    store.AuditLesson,
+		EntityID:  15,
+		Action:    auditLessonCancelled,
+		From:      time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local),
+		To:        time.Date(2025, 3, 1, 0, 0, 0, 0, time.Local),
+	}
+	if !reflect.DeepEqual(filter, want) {
+		t.Errorf("Expected %+v, got %+v", want, filter)
+	}
+
+	for _, args := range []string{"user=abc", "entity=planet", "action=lesson_exploded", "from=2025-01-01", "color=red", "id"} {
+		if _, err := parseAuditFilter(strings.Fields(args)); err == nil {
+			t.Errorf("Expected error for %q", args)
+		}
+	}
+}
+
+// Тест: фильтр переживает кнопку навигации и укладывается в лимит callback данных
+func TestAuditFilterRoundTrip(t *testing.T) {
+	filters := []store.AuditFilter{
+		{},
+		{Action: auditUserRegistered},
+		{
+			ActorTgID: 1<<52 - 1,
+			Entity:    store.AuditUser,
+			EntityID:  2147483647,
+			Action:    auditActions[len(auditActions)-1],
+			From:      time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local),
+			To:        time.Date(2099, 12, 31, 0, 0, 0, 0, time.Local),
+		},
+	}
+
+	for _, filter := range filters {
+		arg := encodeAuditFilter(filter)
+		data, err := callback.Encode(callback.WithID(callback.AuditPage, 9999).WithArg(arg))
+		if err != nil {
+			t.Fatalf("Filter %+v does not fit callback data: %v", filter, err)
+		}
+
+		payload, err := callback.Decode(data)
+		if err != nil {
+			t.Fatalf("Failed to decode %q: %v", data, err)
+		}
+		decoded, err := decodeAuditFilter(payload.Arg)
+		if err != nil {
+			t.Fatalf("Failed to decode filter %q: %v", payload.Arg, err)
+		}
+		if !reflect.DeepEqual(decoded, filter) {
+			t.Errorf("Expected %+v, got %+v", filter, decoded)
+		}
+	}
+
+	if _, err := decodeAuditFilter("a999"); err == nil {
+		t.Error("Expected error for unknown action index")
+	}
+}
@@ -2,23 +2,18 @@ package handlers
 
 import (
 	"database/sql"
-	"strconv"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/telegram"
 )
 
 // Команда помощи
-func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверить роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
+func handleHelp(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
 	var helpText string
 	
-	if err == sql.ErrNoRows {
+	if user.Account == nil {
 		// Незарегистрированный пользователь
 		helpText = "🆘 Помощь - Constellation School Bot\n\n" +
 			"👋 Добро пожаловать! Для начала работы необходимо зарегистрироваться.\n\n" +
@@ -35,12 +30,9 @@ func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
 			"• Веб-разработка\n" +
 			"• Компьютерная грамотность"
 			
-	} else if err != nil {
-		helpText = "❌ Ошибка получения информации о пользователе"
-		
 	} else {
-		switch role {
-		case "student":
+		switch user.Role {
+		case auth.Student:
 			helpText = "🆘 **Помощь для студентов**\n\n" +
 				"📚 **Основные команды:**\n" +
 				"• `/start` - главное меню с кнопками\n" +
@@ -49,6 +41,9 @@ func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
 				"• `/my_lessons` - мои записи на уроки\n" +
 				"• `/enroll` - записаться на урок\n" +
 				"• `/waitlist` - лист ожидания\n" +
+				"• `/enroll_series <ID серии>` - записаться на все занятия серии\n" +
+				"• `/courses` - курсы, `/enroll_course <ID>` - записаться на курс\n" +
+				"• `/reminders` - напоминания об уроках\n" +
 				"• `/help` - эта справка\n\n" +
 				"🎯 **Как записаться на урок:**\n" +
 				"1. Нажмите кнопку 'Записаться' в главном меню\n" +
@@ -57,7 +52,7 @@ func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
 				"4. Подтвердите запись\n\n" +
 				"💡 **Подсказка:** ID урока отображается в расписании как #123"
 				
-		case "teacher":
+		case auth.Teacher:
 			helpText = "🆘 **Помощь для преподавателей**\n\n" +
 				"👨‍🏫 **Основные команды:**\n" +
 				"• `/start` - главное меню с кнопками\n" +
@@ -78,31 +73,8 @@ func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
 				"2. Выберите предмет и урок\n" +
 				"3. Студенты получат уведомления автоматически"
 				
-		case "superuser":
-			helpText = "🆘 **Помощь для администраторов**\n\n" +
-				"🔧 **Управление учителями:**\n" +
-				"• `/add_teacher` - добавить преподавателя\n" +
-				"• `/delete_teacher` - удалить преподавателя\n" +
-				"• `/restore_teacher` - восстановить преподавателя\n" +
-				"• `/list_teachers` - список всех преподавателей\n\n" +
-				"📚 **Управление уроками:**\n" +
-				"• `/create_lesson` - создать урок\n" +
-				"• `/delete_lesson` - удалить урок\n" +
-				"• `/restore_lesson` - восстановить урок\n" +
-				"• `/reschedule_lesson` - перенести урок\n\n" +
-				"👥 **Управление студентами:**\n" +
-				"• `/deactivate_student` - заблокировать студента\n" +
-				"• `/activate_student` - разблокировать студента\n\n" +
-				"📢 **Уведомления:**\n" +
-				"• `/notify_all` - уведомить всех пользователей\n" +
-				"• `/notify_students` - уведомить студентов урока\n" +
-				"• `/remind_all` - напомнить о предстоящих уроках\n" +
-				"• `/cancel_with_notification` - отменить урок с уведомлением\n\n" +
-				"📊 **Статистика и логи:**\n" +
-				"• `/stats` - общая статистика системы\n" +
-				"• `/rate_limit_stats` - статистика операций\n" +
-				"• `/log_recent_errors` - последние ошибки системы\n\n" +
-				"• `/help` - эта справка"
+		case auth.Admin, auth.Superuser:
+			helpText = adminHelpText(user.Role)
 				
 		default:
 			helpText = "🆘 Помощь\n\nИспользуйте /start для начала работы"
@@ -113,14 +85,59 @@ func handleHelp(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
 	bot.Send(msg)
 }
 
+// Справка администратора: разделы по правам роли
+func adminHelpText(role auth.Role) string {
+	text := "🆘 **Помощь для администраторов**\n\n"
+	if role.Can(auth.TeacherManage) {
+		text += "🔧 **Управление учителями:**\n" +
+			"• `/add_teacher` - добавить преподавателя\n" +
+			"• `/delete_teacher` - удалить преподавателя\n" +
+			"• `/restore_teacher` - восстановить преподавателя\n" +
+			"• `/list_teachers` - список всех преподавателей\n\n"
+	} else {
+		text += "👨‍🏫 **Преподаватели:**\n" +
+			"• `/list_teachers` - список всех преподавателей\n\n"
+	}
+	text += "📚 **Управление уроками:**\n" +
+		"• `/create_lesson` - создать урок\n" +
+		"• `/delete_lesson` - удалить урок\n" +
+		"• `/restore_lesson` - восстановить урок\n" +
+		"• `/reschedule_lesson` - перенести урок\n\n" +
+		"🚪 **Аудитории:**\n" +
+		"• `/add_room <название> <мест> [оборудование]` - добавить аудиторию\n" +
+		"• `/rooms` - список аудиторий\n" +
+		"• `/room_schedule <аудитория> [дата]` - занятость аудитории за день\n\n" +
+		"👥 **Управление студентами:**\n" +
+		"• `/deactivate_student` - заблокировать студента\n" +
+		"• `/activate_student` - разблокировать студента\n\n" +
+		"📢 **Уведомления:**\n" +
+		"• `/notify_all` - уведомить всех пользователей\n" +
+		"• `/notify_students` - уведомить студентов урока\n" +
+		"• `/remind_all` - напомнить о предстоящих уроках\n" +
+		"• `/cancel_with_notification` - отменить урок с уведомлением\n\n" +
+		"📊 **Статистика и логи:**\n" +
+		"• `/stats` - общая статистика системы\n" +
+		"• `/audit` - журнал изменений (фильтры: user=, entity=, id=, action=, from=, to=)\n"
+	if role.Can(auth.SystemView) {
+		text += "• `/rate_limit_stats` - статистика операций\n" +
+			"• `/log_recent_errors` - последние ошибки системы\n"
+	}
+	if role.Can(auth.RoleManage) {
+		text += "\n🔑 **Роли:**\n" +
+			"• `/grant_admin <telegram_id>` - назначить администратора\n" +
+			"• `/revoke_admin <telegram_id>` - снять права администратора\n"
+	}
+	return text + "\n• `/help` - эта справка"
+}
+
 // Обработчик callback для студентов
-func handleStudentCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
+func handleStudentCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB) {
 	// Базовая обработка callback запросов от студентов
 	sendMessage(bot, query.Message.Chat.ID, "⚙️ Функция в разработке")
 }
 
 // Обработчик callback отмены уроков
-func handleCancelLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
+func handleCancelLessonCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB) {
 	// Базовая обработка отмены уроков
 	sendMessage(bot, query.Message.Chat.ID, "⚙️ Отмена урока - функция в разработке")
 }
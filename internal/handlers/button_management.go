@@ -3,42 +3,34 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
-	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/telegram"
 )
 
 // ================ КНОПОЧНОЕ УПРАВЛЕНИЕ ПРЕПОДАВАТЕЛЯМИ ================
 
 // Обработчик меню управления преподавателями
-func handleTeachersMenuButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	// Проверяем права администратора
-	userID := message.From.ID
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&role)
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для управления преподавателями")
-		return
-	}
-	
+func handleTeachersMenuButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Показываем меню управления преподавателями
 	text := "👨‍🏫 **Управление преподавателями**\n\n" +
 		"Выберите действие:"
 	
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{
-			tgbotapi.NewInlineKeyboardButtonData("📋 Список преподавателей", "list_teachers"),
+			callback.Button("📋 Список преподавателей", callback.New(callback.Teachers)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить преподавателя", "delete_teacher_menu"),
+			callback.Button("🗑️ Удалить преподавателя", callback.New(callback.DeleteTeacherMenu)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("🔄 Восстановить преподавателя", "restore_teacher_menu"),
+			callback.Button("🔄 Восстановить преподавателя", callback.New(callback.RestoreTeacherMenu)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			callback.Button("🔙 Главное меню", callback.New(callback.MainMenu)),
 		},
 	}
 	
@@ -50,38 +42,20 @@ func handleTeachersMenuButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, d
 }
 
 // Показать список преподавателей для удаления с кнопками
-func showDeleteTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	rows, err := db.Query(`
-		SELECT t.id, u.full_name, 
-			(SELECT COUNT(*) FROM lessons WHERE teacher_id = t.id AND soft_deleted = false AND start_time > NOW()) as active_lessons
-		FROM teachers t
-		JOIN users u ON t.user_id = u.id
-		WHERE t.soft_deleted = false
-		ORDER BY u.full_name`)
-	
+func showDeleteTeacherButtons(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	teachers, err := repos(db).Teachers.List()
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения списка преподавателей")
 		return
 	}
-	defer rows.Close()
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	count := 0
 	
-	for rows.Next() {
-		var teacherID int
-		var fullName string
-		var activeLessons int
-		
-		if err := rows.Scan(&teacherID, &fullName, &activeLessons); err != nil {
-			continue
-		}
-		
+	for _, teacher := range teachers {
 		count++
-		buttonText := fmt.Sprintf("👨‍🏫 %s (📚%d)", fullName, activeLessons)
-		callbackData := fmt.Sprintf("confirm_delete_teacher_%d", teacherID)
-		
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+		buttonText := fmt.Sprintf("👨‍🏫 %s (📚%d)", teacher.FullName, teacher.UpcomingLessons)
+		button := callback.Button(buttonText, callback.WithID(callback.ConfirmDeleteTeacher, teacher.ID))
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
@@ -91,7 +65,7 @@ func showDeleteTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, d
 	}
 	
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "teachers"),
+		callback.Button("🔙 Назад", callback.New(callback.Teachers)),
 	})
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
@@ -105,37 +79,20 @@ func showDeleteTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, d
 }
 
 // Показать список удаленных преподавателей для восстановления
-func showRestoreTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	rows, err := db.Query(`
-		SELECT t.id, u.full_name, t.updated_at
-		FROM teachers t
-		JOIN users u ON t.user_id = u.id
-		WHERE t.soft_deleted = true
-		ORDER BY t.updated_at DESC`)
-	
+func showRestoreTeacherButtons(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	teachers, err := repos(db).Teachers.Deleted()
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения списка удаленных преподавателей")
 		return
 	}
-	defer rows.Close()
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	count := 0
 	
-	for rows.Next() {
-		var teacherID int
-		var fullName string
-		var updatedAt time.Time
-		
-		if err := rows.Scan(&teacherID, &fullName, &updatedAt); err != nil {
-			continue
-		}
-		
+	for _, teacher := range teachers {
 		count++
-		buttonText := fmt.Sprintf("👨‍🏫 %s (%s)", fullName, updatedAt.Format("02.01"))
-		callbackData := fmt.Sprintf("restore_teacher_%d", teacherID)
-		
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+		buttonText := fmt.Sprintf("👨‍🏫 %s (%s)", teacher.FullName, teacher.DeletedAt.Format("02.01"))
+		button := signedButton(buttonText, callback.WithID(callback.RestoreTeacher, teacher.ID), message.Chat.ID)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
@@ -145,7 +102,7 @@ func showRestoreTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 	}
 	
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "teachers"),
+		callback.Button("🔙 Назад", callback.New(callback.Teachers)),
 	})
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
@@ -158,29 +115,19 @@ func showRestoreTeacherButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 }
 
 // Подтверждение удаления преподавателя
-func handleConfirmDeleteTeacher(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, "_")
-	if len(parts) != 4 {
-		return
-	}
+func handleConfirmDeleteTeacher(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	teacherID := data.ID
 	
-	teacherID, err := strconv.Atoi(parts[3])
+	// Получаем информацию о преподавателе и его предстоящих уроках
+	st := repos(db)
+	teacher, err := st.Teachers.Get(teacherID)
 	if err != nil {
+		sendMessage(bot, query.Message.Chat.ID, "❌ Преподаватель не найден")
 		return
 	}
-	
-	// Получаем информацию о преподавателе
-	var fullName string
-	var activeLessons int
-	err = db.QueryRow(`
-		SELECT u.full_name,
-			(SELECT COUNT(*) FROM lessons WHERE teacher_id = $1 AND soft_deleted = false AND start_time > NOW()) as active_lessons
-		FROM teachers t
-		JOIN users u ON t.user_id = u.id
-		WHERE t.id = $1`, teacherID).Scan(&fullName, &activeLessons)
-	
+	lessons, err := st.Lessons.ByTeacher(teacherID, time.Now(), time.Time{})
 	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Преподаватель не найден")
+		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка получения уроков преподавателя")
 		return
 	}
 	
@@ -188,33 +135,22 @@ func handleConfirmDeleteTeacher(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQu
 		"👨‍🏫 **Преподаватель:** %s\n"+
 		"📚 **Активных уроков:** %d\n\n"+
 		"❗️ При удалении все уроки будут отменены!\n"+
-		"Продолжить?", fullName, activeLessons)
+		"Продолжить?", escapeMarkdown(teacher.FullName), len(lessons))
 	
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{
-			tgbotapi.NewInlineKeyboardButtonData("✅ Удалить", fmt.Sprintf("execute_delete_teacher_%d", teacherID)),
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "delete_teacher_menu"),
+			signedButton("✅ Удалить", callback.WithID(callback.DeleteTeacher, teacherID), query.Message.Chat.ID),
+			callback.Button("❌ Отмена", callback.New(callback.DeleteTeacherMenu)),
 		},
 	}
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, confirmText)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &keyboard
-	bot.Send(editMsg)
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, confirmText, &keyboard)
 }
 
 // Выполнение удаления преподавателя
-func handleExecuteDeleteTeacher(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, "_")
-	if len(parts) != 4 {
-		return
-	}
-	
-	teacherID, err := strconv.Atoi(parts[3])
-	if err != nil {
-		return
-	}
+func handleExecuteDeleteTeacher(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	teacherID := data.ID
 	
 	// Создаем временное сообщение для вызова существующей функции
 	tempMessage := *query.Message
@@ -225,16 +161,8 @@ func handleExecuteDeleteTeacher(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQu
 }
 
 // Восстановление преподавателя
-func handleRestoreTeacherAction(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, "_")
-	if len(parts) != 3 {
-		return
-	}
-	
-	teacherID, err := strconv.Atoi(parts[2])
-	if err != nil {
-		return
-	}
+func handleRestoreTeacherAction(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	teacherID := data.ID
 	
 	// Создаем временное сообщение для вызова существующей функции
 	tempMessage := *query.Message
@@ -247,19 +175,19 @@ func handleRestoreTeacherAction(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQu
 // ================ КНОПОЧНОЕ УПРАВЛЕНИЕ УРОКАМИ ================
 
 // Обработчик меню управления уроками для админов
-func handleAdminLessonsMenuButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleAdminLessonsMenuButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	text := "📚 **Управление уроками (Администратор)**\n\n" +
 		"Выберите действие:"
 	
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Удалить урок", "admin_delete_lesson"),
+			callback.Button("🗑️ Удалить урок", callback.New(callback.DeleteLessonMenu)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("📋 Все уроки", "schedule"),
+			callback.Button("📋 Все уроки", callback.New(callback.Schedule)),
 		},
 		{
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "main_menu"),
+			callback.Button("🔙 Главное меню", callback.New(callback.MainMenu)),
 		},
 	}
 	
@@ -2,198 +2,87 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
-	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-)
-
-// Структура для callback данных
-type CallbackData struct {
-	Action   string
-	LessonID int
-	Extra    string
-}
-
-// Парсинг callback данных
-func parseCallbackData(data string) (*CallbackData, error) {
-	parts := strings.Split(data, "_")
-	if len(parts) < 2 {
-		return nil, fmt.Errorf("неверный формат callback данных: %s", data)
-	}
-
-	result := &CallbackData{
-		Action: parts[0],
-	}
-
-	// Парсинг lesson_id если есть
-	if len(parts) > 1 {
-		if lessonID, err := strconv.Atoi(parts[1]); err == nil {
-			result.LessonID = lessonID
-		} else {
-			result.Extra = parts[1]
-		}
-		
-		if len(parts) > 3 {
-			result.Extra = parts[3]
-		}
-	}
 
-	return result, nil
-}
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
 
 // Обработка callback кнопок выбора предмета для создания/удаления урока
-func handleLessonSubjectCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, ":")
-	if len(parts) != 2 {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный формат команды")
-		return
-	}
-	
-	action := parts[0] // "create_lesson" или "delete_lesson"
-	subjectID, err := strconv.Atoi(parts[1])
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный ID предмета")
-		return
-	}
+func handleLessonSubjectCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	subjectID := data.ID
 	
 	// Получаем название предмета
-	var subjectName string
-	err = db.QueryRow("SELECT name FROM subjects WHERE id = $1", subjectID).Scan(&subjectName)
+	subject, err := repos(db).Subjects.Get(subjectID)
 	if err != nil {
 		sendMessage(bot, query.Message.Chat.ID, "❌ Предмет не найден")
 		return
 	}
+	subjectName := subject.Name
 	
-	// Проверяем права пользователя
 	userID := query.From.ID
-	var role string
-	err = db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, query.Message.Chat.ID, "❌ У вас нет прав для этого действия")
-		return
-	}
-	
-	if action == "create_lesson" {
-		// Показываем форму для ввода даты и времени
-		text := fmt.Sprintf("📚 **Создание урока: %s**\n\n" +
-			"Введите дату и время урока в формате:\n" +
-			"`/create_lesson \"%s\" ДД.ММ.ГГГГ ЧЧ:ММ`\n\n" +
-			"**Пример:**\n" +
-			"`/create_lesson \"%s\" 16.08.2025 16:30`", 
-			subjectName, subjectName, subjectName)
-			
-		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
-		editMsg.ParseMode = "Markdown"
-		bot.Send(editMsg)
+	if data.Action == callback.CreateLessonSubject {
+		// Запускаем диалог создания урока с выбранным предметом
+		startDialog(bot, db, query.Message.Chat.ID, userID, createLessonDialog,
+			map[string]string{"subject_id": strconv.Itoa(subjectID), "subject_id" + dialogLabelSuffix: subjectName})
 		
-	} else if action == "delete_lesson" {
+	} else {
 		// Показываем уроки этого предмета для удаления
-		showLessonsForDeletion(bot, query, db, subjectID, subjectName)
+		showLessonsForDeletion(bot, query, db, user, subjectID, subjectName)
 	}
 }
 
 // Показать уроки предмета для удаления
-func showLessonsForDeletion(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, subjectID int, subjectName string) {
-	// Получаем уроки этого предмета 
-	userID := query.From.ID
-	
-	// Проверяем роль пользователя для фильтрации
-	var role string
+func showLessonsForDeletion(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, user *requestUser, subjectID int, subjectName string) {
+	// Получаем будущие уроки этого предмета
 	var teacherID int
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-		
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка проверки прав")
-		return
-	}
-	
-	var queryStr string
-	var args []interface{}
-	
-	if role == "teacher" {
+	var err error
+	if !user.Can(auth.LessonEditAny) {
 		// Для преподавателей - только их уроки
-		err = db.QueryRow(`
-			SELECT t.id FROM teachers t 
-			JOIN users u ON t.user_id = u.id 
-			WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
-		
+		teacherID, err = getTeacherID(db, int(query.From.ID))
 		if err != nil {
 			sendMessage(bot, query.Message.Chat.ID, "❌ Преподаватель не найден")
 			return
 		}
-		
-		queryStr = `
-			SELECT l.id, l.start_time, u.full_name,
-				(SELECT COUNT(*) FROM enrollments WHERE lesson_id = l.id AND status = 'enrolled') as enrolled_count
-			FROM lessons l 
-			JOIN subjects s ON l.subject_id = s.id 
-			LEFT JOIN teachers t ON l.teacher_id = t.id
-			LEFT JOIN users u ON t.user_id = u.id
-			WHERE l.subject_id = $1 AND l.teacher_id = $2 AND l.soft_deleted = false
-				AND l.start_time > NOW()
-			ORDER BY l.start_time`
-		args = []interface{}{subjectID, teacherID}
-	} else {
-		// Для админов - все уроки предмета
-		queryStr = `
-			SELECT l.id, l.start_time, u.full_name,
-				(SELECT COUNT(*) FROM enrollments WHERE lesson_id = l.id AND status = 'enrolled') as enrolled_count
-			FROM lessons l 
-			JOIN subjects s ON l.subject_id = s.id 
-			LEFT JOIN teachers t ON l.teacher_id = t.id
-			LEFT JOIN users u ON t.user_id = u.id
-			WHERE l.subject_id = $1 AND l.soft_deleted = false
-				AND l.start_time > NOW()
-			ORDER BY l.start_time`
-		args = []interface{}{subjectID}
 	}
 	
-	rows, err := db.Query(queryStr, args...)
+	lessons, err := repos(db).Lessons.BySubject(subjectID, time.Now())
 	if err != nil {
 		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка при получении уроков")
 		return
 	}
-	defer rows.Close()
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	lessonCount := 0
 	
-	for rows.Next() {
-		var lessonID int
-		var startTime time.Time
-		var teacherName string
-		var enrolledCount int
-		
-		if err := rows.Scan(&lessonID, &startTime, &teacherName, &enrolledCount); err != nil {
+	for _, lesson := range lessons {
+		if teacherID != 0 && lesson.TeacherID != teacherID {
 			continue
 		}
 		
 		lessonCount++
 		buttonText := fmt.Sprintf("📅 %s 👨‍🏫 %s (👥%d)", 
-			startTime.Format("02.01 15:04"), teacherName, enrolledCount)
-		callbackData := fmt.Sprintf("confirm_delete_lesson:%d", lessonID)
-		
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+			lesson.StartTime.Format("02.01 15:04"), lesson.TeacherName, lesson.EnrolledCount)
+		button := callback.Button(buttonText, callback.WithID(callback.ConfirmDeleteLesson, lesson.ID))
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
 	if lessonCount == 0 {
 		text := fmt.Sprintf("📚 **%s**\n\n❌ Нет активных уроков для удаления", subjectName)
-		editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
-		editMsg.ParseMode = "Markdown"
-		bot.Send(editMsg)
+		bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, text, nil)
 		return
 	}
 	
 	// Кнопка "Назад"
-	backButton := tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к предметам", "cancel_lesson")
+	backButton := callback.Button("🔙 Назад к предметам", callback.New(callback.DeleteLessonMenu))
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{backButton})
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
@@ -201,454 +90,241 @@ func showLessonsForDeletion(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery,
 		"ℹ️ Показаны только будущие уроки\n" +
 		"👥 Число показывает количество записавшихся студентов", subjectName)
 	
-	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &keyboard
-	
-	bot.Send(editMsg)
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, text, &keyboard)
 }
 
-// Новый роутер для callback запросов (заменяет существующий)
-func handleNewCallbackQuery(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	// Убрать индикатор загрузки
-	callback := tgbotapi.NewCallback(query.ID, "")
-	if _, err := bot.Request(callback); err != nil {
-		log.Printf("Ошибка callback ответа: %v", err)
-	}
+// routeCallback - маршрут нажатия кнопки; подпись и срок данных проверяются до middleware
+func routeCallback(update tgbotapi.Update) *updateRequest {
+	query := update.CallbackQuery
+	req := newUpdateRequest(update, updateCallback, query.Message.Chat.ID, query.From)
 
-	// Специальная обработка для create_lesson и delete_lesson кнопок
-	if strings.HasPrefix(query.Data, "create_lesson:") || strings.HasPrefix(query.Data, "delete_lesson:") {
-		handleLessonSubjectCallback(bot, query, db)
-		return
-	}
-	
-	// Обработка подтверждения удаления уроков
-	if strings.HasPrefix(query.Data, "confirm_delete_lesson:") {
-		handleConfirmDeleteLessonCallback(bot, query, db)
-		return
-	}
-	
-	// Обработка выполнения удаления уроков
-	if strings.HasPrefix(query.Data, "execute_delete_lesson:") {
-		handleExecuteDeleteLessonCallback(bot, query, db)
-		return
-	}
-	
-	// Обработка удаления преподавателей через кнопки
-	if strings.HasPrefix(query.Data, "confirm_delete_teacher_") {
-		handleConfirmDeleteTeacher(bot, query, db)
-		return
-	}
-	
-	if strings.HasPrefix(query.Data, "execute_delete_teacher_") {
-		handleExecuteDeleteTeacher(bot, query, db)
-		return
-	}
-	
-	if strings.HasPrefix(query.Data, "restore_teacher_") {
-		handleRestoreTeacherAction(bot, query, db)
-		return
-	}
-
-	// Парсинг callback данных
-	callbackData, err := parseCallbackData(query.Data)
-	if err != nil {
-		log.Printf("Ошибка парсинга callback: %v", err)
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный формат команды")
-		return
-	}
-
-	// Получение роли пользователя
-	userRole, err := getUserRole(db, query.From.ID)
+	s, err := signer()
 	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка определения роли пользователя")
-		return
+		req.Route = routeInvalidCallback
+		req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+			slog.Error("Ошибка создания ключа подписи кнопок", "update_id", update.UpdateID, "err", err)
+			sendMessage(bot, req.ChatID, "❌ Кнопки временно недоступны, попробуйте позже")
+		}
+		return req
+	}
+
+	data, err := s.Decode(query.Data, req.ChatID)
+	switch {
+	case err == nil:
+	case errors.Is(err, callback.ErrBadSignature), errors.Is(err, callback.ErrSignatureRequired):
+		// Данные кнопки подделаны или скопированы из другого чата
+		req.Route = routeInvalidCallback
+		req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+			slog.Warn("Отклонена поддельная кнопка", "update_id", update.UpdateID, "tg_id", query.From.ID, "data", query.Data, "err", err)
+			sendMessage(bot, req.ChatID, "❌ Недействительная кнопка")
+		}
+		return req
+	default:
+		// Истекший срок или кнопки сообщений, отправленных до смены формата
+		req.Route = routeInvalidCallback
+		req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+			slog.Warn("Ошибка разбора callback", "update_id", update.UpdateID, "data", query.Data, "err", err)
+			sendMessage(bot, req.ChatID, "⌛ Эта кнопка устарела. Откройте меню заново: /menu")
+		}
+		return req
 	}
 
-	// Обработка общих кнопок интерфейса (делегируем в handleInlineButton)
-	switch query.Data {
-	case "main_menu", "create_lesson", "cancel_lesson", "schedule", "my_lessons", 
-		 "help", "profile", "teachers", "stats", "notifications", "logs", 
-		 "help_teacher", "help_admin", "back_to_main", "back_to_schedule", 
-		 "back", "cancel_action", "student_dashboard", "enroll_subjects", 
-		 "my_lessons_menu", "school_schedule":
-		handleInlineButton(bot, query, db)
-		return
+	route, ok := callbackRoutes[data.Action]
+	if !ok {
+		req.Route = routeUnknown
+		req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+			slog.Warn("Неизвестное callback действие", "update_id", update.UpdateID, "action", data.Action, "data", query.Data)
+			sendMessage(bot, req.ChatID, "❓ Неизвестное действие")
+		}
+		return req
 	}
 
-	// Маршрутизация в зависимости от действия
-	switch callbackData.Action {
-	case "enroll":
-		handleEnrollCallback(bot, query, db, callbackData, userRole)
-	case "unenroll":
-		handleUnenrollCallback(bot, query, db, callbackData, userRole)
-	case "waitlist":
-		handleWaitlistCallback(bot, query, db, callbackData, userRole)
-	case "cancel":
-		handleNewCancelLessonCallback(bot, query, db, callbackData, userRole)
-	case "confirm":
-		handleConfirmLessonCallback(bot, query, db, callbackData, userRole)
-	case "schedule":
-		handleScheduleCallback(bot, query, db, callbackData, userRole)
-	case "info":
-		handleLessonInfoCallback(bot, query, db, callbackData, userRole)
-	default:
-		log.Printf("Неизвестное callback действие: %s (данные: %s)", callbackData.Action, query.Data)
-		sendMessage(bot, query.Message.Chat.ID, "❓ Неизвестное действие: " + query.Data)
+	req.Route = string(data.Action)
+	req.Permission = route.permission
+	req.TargetID = data.ID
+	req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		route.handler(bot, query, db, data, req.User)
 	}
+	return req
 }
 
 // Запись на урок через callback
-func handleEnrollCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	// Только студенты могут записываться
-	if userRole != "student" {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Только студенты могут записываться на уроки")
-		bot.Request(callbackResponse)
+func handleEnrollCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	// Получение студента
+	st := repos(db)
+	student, err := st.Students.GetByTelegramID(query.From.ID)
+	if err != nil {
+		bot.AnswerCallback(query.ID, "❌ Ошибка определения студента")
 		return
 	}
 
-	// ========================= RATE LIMITING =========================
-	userID := query.From.ID
-	if GlobalRateLimiter != nil {
-		allowed, reason := GlobalRateLimiter.IsOperationAllowed(userID, OPERATION_ENROLL, data.LessonID)
-		if !allowed {
-			callbackResponse := tgbotapi.NewCallback(query.ID, reason.Error())
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Регистрируем начало операции
-		if err := GlobalRateLimiter.StartOperation(userID, OPERATION_ENROLL, data.LessonID); err != nil {
-			callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Системная ошибка. Попробуйте позже.")
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Завершаем операцию в конце функции
-		defer func() {
-			if err := GlobalRateLimiter.FinishOperation(userID, OPERATION_ENROLL, data.LessonID); err != nil {
-				log.Printf("Ошибка завершения операции rate limiting: %v", err)
-			}
-		}()
-	}
-
-	// Проверка валидности урока
-	if !isLessonValid(db, data.LessonID) {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Урок больше недоступен")
-		bot.Request(callbackResponse)
+	// Запись на урок с проверкой доступности урока и наличия мест
+	err = enrollStudent(st, student.ID, data.ID, time.Now())
+	switch {
+	case err == nil:
+	case errors.Is(err, store.ErrLessonUnavailable):
+		bot.AnswerCallback(query.ID, "❌ Урок больше недоступен")
 		updateMessageWithExpiredLesson(bot, query.Message)
 		return
-	}
-
-	// Получение student_id
-	studentID, err := getStudentID(db, int(query.From.ID))
-	if err != nil {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка определения студента")
-		bot.Request(callbackResponse)
+	case errors.Is(err, store.ErrAlreadyEnrolled):
+		bot.AnswerCallback(query.ID, "ℹ️ Вы уже записаны на этот урок")
 		return
-	}
-
-	// Проверка, не записан ли уже
-	if isStudentEnrolled(db, studentID, data.LessonID) {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "ℹ️ Вы уже записаны на этот урок")
-		bot.Request(callbackResponse)
+	case errors.Is(err, store.ErrCourseLesson):
+		bot.AnswerCallback(query.ID, "❌ Урок входит в курс. Запишитесь на курс: /courses")
 		return
-	}
-
-	// Проверка наличия мест
-	if !hasAvailableSpots(db, data.LessonID) {
+	case errors.Is(err, store.ErrStudentScheduleConflict):
+		bot.AnswerCallback(query.ID, "❌ В это время вы уже записаны на другой урок")
+		return
+	case errors.Is(err, store.ErrLessonFull):
 		// Предложить лист ожидания
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Мест нет. Добавить в лист ожидания?")
-		bot.Request(callbackResponse)
+		bot.AnswerCallback(query.ID, "❌ Мест нет. Добавить в лист ожидания?")
 		
 		// Создаем кнопку для листа ожидания
 		keyboard := tgbotapi.NewInlineKeyboardMarkup(
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("⏳ В лист ожидания", fmt.Sprintf("waitlist_lesson_%d", data.LessonID)),
+				callback.Button("⏳ В лист ожидания", callback.WithID(callback.Waitlist, data.ID)),
 			),
 		)
 		
 		editMsg := tgbotapi.NewEditMessageReplyMarkup(query.Message.Chat.ID, query.Message.MessageID, keyboard)
 		bot.Send(editMsg)
 		return
-	}
-
-	// Запись на урок
-	err = enrollStudentInDB(db, studentID, data.LessonID)
-	if err != nil {
-		log.Printf("Ошибка записи на урок: %v", err)
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка записи на урок")
-		bot.Request(callbackResponse)
+	default:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка записи на урок", "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка записи на урок")
 		return
 	}
 
-	callbackResponse := tgbotapi.NewCallback(query.ID, "✅ Вы успешно записались на урок!")
-	bot.Request(callbackResponse)
+	audit(bot, db, query.From.ID, auditLessonEnrolled, store.AuditEnrollment, data.ID, nil, enrollmentState(student.ID, "enrolled"))
+	bot.AnswerCallback(query.ID, "✅ Вы успешно записались на урок!")
 
 	// Обновляем сообщение с актуальной информацией
-	updateLessonMessage(bot, query.Message, db, data.LessonID)
+	updateLessonMessage(bot, query.Message, db, data.ID)
 }
 
 // Отмена записи на урок
-func handleUnenrollCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	if userRole != "student" {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Только студенты могут отменять запись")
-		bot.Request(callbackResponse)
-		return
-	}
-
-	// ========================= RATE LIMITING =========================
-	userID := query.From.ID
-	if GlobalRateLimiter != nil {
-		allowed, reason := GlobalRateLimiter.IsOperationAllowed(userID, OPERATION_CANCEL, data.LessonID)
-		if !allowed {
-			callbackResponse := tgbotapi.NewCallback(query.ID, reason.Error())
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Регистрируем начало операции
-		if err := GlobalRateLimiter.StartOperation(userID, OPERATION_CANCEL, data.LessonID); err != nil {
-			callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Системная ошибка. Попробуйте позже.")
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Завершаем операцию в конце функции
-		defer func() {
-			if err := GlobalRateLimiter.FinishOperation(userID, OPERATION_CANCEL, data.LessonID); err != nil {
-				log.Printf("Ошибка завершения операции rate limiting: %v", err)
-			}
-		}()
-	}
-
+func handleUnenrollCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
 	studentID, err := getStudentID(db, int(query.From.ID))
 	if err != nil {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка определения студента")
-		bot.Request(callbackResponse)
+		bot.AnswerCallback(query.ID, "❌ Ошибка определения студента")
 		return
 	}
 
-	err = unenrollStudentFromDB(db, studentID, data.LessonID)
-	if err != nil {
-		log.Printf("Ошибка отмены записи: %v", err)
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка отмены записи")
-		bot.Request(callbackResponse)
+	err = repos(db).Enrollments.Unenroll(studentID, data.ID)
+	if errors.Is(err, store.ErrNotEnrolled) {
+		bot.AnswerCallback(query.ID, "ℹ️ Вы не записаны на этот урок")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка отмены записи", "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка отмены записи")
 		return
 	}
 
-	callbackResponse := tgbotapi.NewCallback(query.ID, "✅ Запись отменена")
-	bot.Request(callbackResponse)
+	audit(bot, db, query.From.ID, auditLessonUnenrolled, store.AuditEnrollment, data.ID,
+		enrollmentState(studentID, "enrolled"), enrollmentState(studentID, "cancelled"))
+	bot.AnswerCallback(query.ID, "✅ Запись отменена")
 
 	// Обновляем сообщение
-	updateLessonMessage(bot, query.Message, db, data.LessonID)
+	updateLessonMessage(bot, query.Message, db, data.ID)
 
 	// Уведомляем следующего в листе ожидания
-	notifyNextInWaitlist(bot, db, data.LessonID)
+	notifyNextInWaitlist(bot, db, data.ID)
 }
 
 // Добавление в лист ожидания
-func handleWaitlistCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	if userRole != "student" {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Только студенты могут попадать в лист ожидания")
-		bot.Request(callbackResponse)
-		return
-	}
-
-	// ========================= RATE LIMITING =========================
-	userID := query.From.ID
-	if GlobalRateLimiter != nil {
-		allowed, reason := GlobalRateLimiter.IsOperationAllowed(userID, OPERATION_WAITLIST, data.LessonID)
-		if !allowed {
-			callbackResponse := tgbotapi.NewCallback(query.ID, reason.Error())
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Регистрируем начало операции
-		if err := GlobalRateLimiter.StartOperation(userID, OPERATION_WAITLIST, data.LessonID); err != nil {
-			callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Системная ошибка. Попробуйте позже.")
-			bot.Request(callbackResponse)
-			return
-		}
-		
-		// Завершаем операцию в конце функции
-		defer func() {
-			if err := GlobalRateLimiter.FinishOperation(userID, OPERATION_WAITLIST, data.LessonID); err != nil {
-				log.Printf("Ошибка завершения операции rate limiting: %v", err)
-			}
-		}()
-	}
-
+func handleWaitlistCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
 	studentID, err := getStudentID(db, int(query.From.ID))
 	if err != nil {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка определения студента")
-		bot.Request(callbackResponse)
+		bot.AnswerCallback(query.ID, "❌ Ошибка определения студента")
 		return
 	}
 
-	err = addToWaitlist(db, studentID, data.LessonID)
-	if err != nil {
-		log.Printf("Ошибка добавления в лист ожидания: %v", err)
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка добавления в лист ожидания")
-		bot.Request(callbackResponse)
+	position, err := repos(db).Waitlist.Add(studentID, data.ID)
+	if errors.Is(err, store.ErrAlreadyWaitlisted) {
+		bot.AnswerCallback(query.ID, "ℹ️ Вы уже в листе ожидания")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка добавления в лист ожидания", "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка добавления в лист ожидания")
 		return
 	}
 
-	callbackResponse := tgbotapi.NewCallback(query.ID, "⏳ Вы добавлены в лист ожидания")
-	bot.Request(callbackResponse)
+	audit(bot, db, query.From.ID, auditWaitlistAdded, store.AuditWaitlist, data.ID, nil, waitlistState(studentID, position))
+	bot.AnswerCallback(query.ID, "⏳ Вы добавлены в лист ожидания")
 }
 
 // Отмена урока (только для учителей) - новое имя функции
-func handleNewCancelLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	if userRole != "teacher" && userRole != "admin" {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Только учителя могут отменять уроки")
-		bot.Request(callbackResponse)
+func handleNewCancelLessonCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	// Для учителей - проверяем, что это их урок
+	st := repos(db)
+	if !canManageLesson(st, user, data.ID) {
+		bot.AnswerCallback(query.ID, "❌ Вы можете отменять только свои уроки")
 		return
 	}
 
-	// Для учителей - проверяем, что это их урок
-	if userRole == "teacher" {
-		teacherID, err := getTeacherID(db, int(query.From.ID))
-		if err != nil || !isTeacherLesson(db, teacherID, data.LessonID) {
-			callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Вы можете отменять только свои уроки")
-			bot.Request(callbackResponse)
-			return
-		}
+	lesson, err := st.Lessons.Get(data.ID)
+	if err == nil {
+		err = st.Lessons.Cancel(data.ID)
 	}
-
-	err := cancelLessonInDB(db, data.LessonID)
 	if err != nil {
-		log.Printf("Ошибка отмены урока: %v", err)
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка отмены урока")
-		bot.Request(callbackResponse)
+		slog.ErrorContext(telegram.Context(bot), "Ошибка отмены урока", "err", err)
+		bot.AnswerCallback(query.ID, "❌ Ошибка отмены урока")
 		return
 	}
 
-	callbackResponse := tgbotapi.NewCallback(query.ID, "✅ Урок отменен")
-	bot.Request(callbackResponse)
+	audit(bot, db, query.From.ID, auditLessonCancelled, store.AuditLesson, data.ID,
+		auditState{"status": lesson.Status}, auditState{"status": "cancelled"})
+	bot.AnswerCallback(query.ID, "✅ Урок отменен")
 
 	// Уведомляем всех записанных студентов
-	notifyStudentsAboutCancellation(bot, db, data.LessonID)
+	notifyStudentsAboutCancellation(bot, db, data.ID, query.From.ID, query.Message.Chat.ID)
 
 	// Обновляем сообщение
 	updateCancelledLessonMessage(bot, query.Message)
 }
 
-// Подтверждение урока (только для учителей)
-func handleConfirmLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	if userRole != "teacher" && userRole != "admin" {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Только учителя могут подтверждать уроки")
-		bot.Request(callbackResponse)
-		return
-	}
-
-	callbackResponse := tgbotapi.NewCallback(query.ID, "✅ Урок подтвержден")
-	bot.Request(callbackResponse)
-
-	// Логика подтверждения урока
-	sendMessage(bot, query.Message.Chat.ID, "✅ Урок подтвержден")
-}
-
-// Показ расписания через callback
-func handleScheduleCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	// Показываем обновленное расписание
-	sendScheduleWithButtons(bot, query.Message.Chat.ID, db, userRole)
-	
-	callbackResponse := tgbotapi.NewCallback(query.ID, "🔄 Расписание обновлено")
-	bot.Request(callbackResponse)
-}
-
 // Показ информации об уроке
-func handleLessonInfoCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, data *CallbackData, userRole string) {
-	lessonInfo, err := getLessonInfo(db, data.LessonID)
+func handleLessonInfoCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	lessonInfo, err := getLessonInfo(db, data.ID)
 	if err != nil {
-		callbackResponse := tgbotapi.NewCallback(query.ID, "❌ Ошибка загрузки информации")
-		bot.Request(callbackResponse)
+		bot.AnswerCallback(query.ID, "❌ Ошибка загрузки информации")
 		return
 	}
 
 	sendMessage(bot, query.Message.Chat.ID, lessonInfo)
 	
-	callbackResponse := tgbotapi.NewCallback(query.ID, "")
-	bot.Request(callbackResponse)
+	bot.AnswerCallback(query.ID, "")
+}
+
+// Обновление карточки урока по кнопке
+func handleRefreshLessonCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	updateLessonMessage(bot, query.Message, db, data.ID)
 }
 
 // Обновление сообщения с уроком
-func updateLessonMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, lessonID int) {
-	lessonText, keyboard := getLessonWithButtons(db, lessonID, 0) // 0 = любая роль для просмотра
+func updateLessonMessage(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, lessonID int) {
+	lessonText, keyboard := getLessonWithButtons(db, message.Chat.ID, lessonID, 0) // 0 = любая роль для просмотра
 	
-	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, lessonText)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &keyboard
-	bot.Send(editMsg)
+	bot.EditMessage(message.Chat.ID, message.MessageID, lessonText, &keyboard)
 }
 
 // Обработка подтверждения удаления урока
-func handleConfirmDeleteLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, ":")
-	if len(parts) != 2 {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный формат команды")
-		return
-	}
-	
-	lessonID, err := strconv.Atoi(parts[1])
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный ID урока")
-		return
-	}
+func handleConfirmDeleteLessonCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	lessonID := data.ID
 	
 	// Получаем информацию об уроке для подтверждения
-	var subjectName, teacherName string
-	var startTime time.Time
-	var enrolledCount int
-	
-	err = db.QueryRow(`
-		SELECT s.name, u.full_name, l.start_time,
-			(SELECT COUNT(*) FROM enrollments WHERE lesson_id = l.id AND status = 'enrolled') as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		WHERE l.id = $1 AND l.soft_deleted = false`, lessonID).Scan(&subjectName, &teacherName, &startTime, &enrolledCount)
-	
-	if err != nil {
+	lesson, err := repos(db).Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted {
 		sendMessage(bot, query.Message.Chat.ID, "❌ Урок не найден")
 		return
 	}
 	
-	// Проверяем права пользователя
-	userID := query.From.ID
-	var role string
-	err = db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-		
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка проверки прав")
+	// Без права на любые уроки - только свои
+	if !canManageLesson(repos(db), user, lessonID) {
+		sendMessage(bot, query.Message.Chat.ID, "❌ Вы можете удалять только свои уроки")
 		return
 	}
 	
-	// Для преподавателей проверяем, что это их урок
-	if role == "teacher" {
-		var teacherID int
-		err = db.QueryRow(`
-			SELECT t.id FROM teachers t 
-			JOIN users u ON t.user_id = u.id 
-			WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
-		
-		if err != nil {
-			sendMessage(bot, query.Message.Chat.ID, "❌ Преподаватель не найден")
-			return
-		}
-		
-		var lessonTeacherID int
-		err = db.QueryRow("SELECT teacher_id FROM lessons WHERE id = $1", lessonID).Scan(&lessonTeacherID)
-		if err != nil || lessonTeacherID != teacherID {
-			sendMessage(bot, query.Message.Chat.ID, "❌ Вы можете удалять только свои уроки")
-			return
-		}
-	}
-	
 	// Показываем подтверждение с деталями
 	confirmText := fmt.Sprintf("⚠️ **Подтверждение удаления урока**\n\n"+
 		"📚 **Предмет:** %s\n"+
@@ -657,73 +333,50 @@ func handleConfirmDeleteLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.Cal
 		"👥 **Записано студентов:** %d\n\n"+
 		"❗️ **ВНИМАНИЕ:** Все студенты получат уведомление об отмене урока!\n\n"+
 		"Вы уверены, что хотите удалить этот урок?", 
-		subjectName, teacherName, startTime.Format("02.01.2006 15:04"), enrolledCount)
+		escapeMarkdown(lesson.SubjectName), escapeMarkdown(lesson.TeacherName), lesson.StartTime.Format("02.01.2006 15:04"),
+		lesson.EnrolledCount)
 	
 	buttons := [][]tgbotapi.InlineKeyboardButton{
 		{
-			tgbotapi.NewInlineKeyboardButtonData("✅ Да, удалить", fmt.Sprintf("execute_delete_lesson:%d", lessonID)),
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "cancel_lesson"),
+			signedButton("✅ Да, удалить", callback.WithID(callback.DeleteLesson, lessonID), query.Message.Chat.ID),
+			callback.Button("❌ Отмена", callback.New(callback.DeleteLessonMenu)),
 		},
 	}
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
-	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, confirmText)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &keyboard
-	bot.Send(editMsg)
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, confirmText, &keyboard)
 }
 
 // Выполнение удаления урока
-func handleExecuteDeleteLessonCallback(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	parts := strings.Split(query.Data, ":")
-	if len(parts) != 2 {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный формат команды")
-		return
-	}
-	
-	lessonID, err := strconv.Atoi(parts[1])
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный ID урока")
-		return
-	}
+func handleExecuteDeleteLessonCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+	lessonID := data.ID
 	
 	// Создаем временное сообщение для вызова функции удаления
+	command := "/delete_lesson"
 	tempMessage := *query.Message
-	tempMessage.Text = fmt.Sprintf("/delete_lesson %d", lessonID)
+	tempMessage.Text = fmt.Sprintf("%s %d", command, lessonID)
+	tempMessage.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Length: len(command)}}
 	tempMessage.From = query.From
 	
-	// Вызываем существующую функцию удаления урока
-	if query.From != nil {
-		var role string
-		err = db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-			strconv.FormatInt(query.From.ID, 10)).Scan(&role)
-			
-		if err == nil && role == "superuser" {
-			// Используем админскую функцию удаления
-			handleDeleteLessonCommand(bot, &tempMessage, db)
-		} else {
-			// Используем функцию отмены урока для преподавателей
-			handleCancelLessonCommand(bot, &tempMessage, db)
-		}
+	if user.Can(auth.LessonDeleteAny) {
+		// Используем админскую функцию удаления
+		handleDeleteLessonCommand(bot, &tempMessage, db)
+	} else {
+		// Используем функцию отмены урока для преподавателей
+		handleCancelLessonCommand(bot, &tempMessage, db, user)
 	}
 }
 
 // Обновление сообщения с отмененным уроком
-func updateCancelledLessonMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+func updateCancelledLessonMessage(bot telegram.Messenger, message *tgbotapi.Message) {
 	text := "❌ **Урок отменен**\n\nЭтот урок больше недоступен."
 	
-	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-	bot.Send(editMsg)
+	bot.EditMessage(message.Chat.ID, message.MessageID, text, nil)
 }
 
 // Обновление сообщения с истекшим уроком
-func updateMessageWithExpiredLesson(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+func updateMessageWithExpiredLesson(bot telegram.Messenger, message *tgbotapi.Message) {
 	text := "⏰ **Урок недоступен**\n\nЭтот урок больше не принимает записи."
 	
-	editMsg := tgbotapi.NewEditMessageText(message.Chat.ID, message.MessageID, text)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
-	bot.Send(editMsg)
+	bot.EditMessage(message.Chat.ID, message.MessageID, text, nil)
 }
@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"database/sql"
+	"log/slog"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/telegram"
+)
+
+// callbackHandler - обработчик нажатия кнопки с разобранными callback данными и автором
+type callbackHandler func(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser)
+
+// callbackRoute - обработчик кнопки и право на действие ("" - доступно всем)
+type callbackRoute struct {
+	permission auth.Permission
+	handler    callbackHandler
+}
+
+// Маршруты inline-кнопок по действию
+var callbackRoutes = map[callback.Action]callbackRoute{
+	// Разделы меню
+	callback.MainMenu:           userMenuRoute("", handleMainMenu),
+	callback.StudentDashboard:   menuRoute(auth.LessonEnroll, showStudentMainMenu),
+	callback.Schedule:           userMenuRoute("", handleScheduleCommand),
+	callback.MyLessons:          userMenuRoute("", handleMyLessonsButton),
+	callback.MyStudents:         menuRoute(auth.LessonViewOwn, handleMyStudentsButton),
+	callback.MyWaitlist:         menuRoute(auth.LessonEnroll, handleWaitlistCommand),
+	callback.EnrollSubjects:     menuRoute(auth.LessonEnroll, handleEnrollSubjectsButton),
+	callback.Profile:            menuRoute("", handleProfileButton),
+	callback.Help:               userMenuRoute("", handleHelpButton),
+	callback.HelpStudent:        menuRoute("", handleHelpStudentButton),
+	callback.HelpTeacher:        menuRoute(auth.LessonViewOwn, handleHelpTeacherButton),
+	callback.HelpAdmin:          menuRoute(auth.StatsView, handleHelpAdminButton),
+	callback.Teachers:           menuRoute(auth.TeacherView, handleTeachersButton),
+	callback.DeleteTeacherMenu:  menuRoute(auth.TeacherManage, showDeleteTeacherButtons),
+	callback.RestoreTeacherMenu: menuRoute(auth.TeacherManage, showRestoreTeacherButtons),
+	callback.Stats:              menuRoute(auth.StatsView, handleStatsButton),
+	callback.Notifications:      menuRoute(auth.NotifyStudents, handleNotificationsButton),
+	callback.Logs:               menuRoute(auth.SystemView, handleLogsButton),
+	callback.CreateLessonMenu:   menuRoute(auth.LessonCreate, handleCreateLessonButton),
+	callback.DeleteLessonMenu:   menuRoute(auth.LessonEditOwn, handleCancelLessonButton),
+
+	// Уроки
+	callback.Enroll:              {auth.LessonEnroll, handleEnrollCallback},
+	callback.Unenroll:            {auth.LessonEnroll, handleUnenrollCallback},
+	callback.Waitlist:            {auth.LessonEnroll, handleWaitlistCallback},
+	callback.CancelLesson:        {auth.LessonEditOwn, handleNewCancelLessonCallback},
+	callback.LessonInfo:          queryRoute("", handleLessonInfoCallback),
+	callback.RefreshLesson:       queryRoute("", handleRefreshLessonCallback),
+	callback.ConfirmDeleteLesson: {auth.LessonEditOwn, handleConfirmDeleteLessonCallback},
+	callback.DeleteLesson:        {auth.LessonEditOwn, handleExecuteDeleteLessonCallback},
+	callback.MarkAttendance:      {auth.LessonEditOwn, handleMarkAttendanceCallback},
+
+	// Предметы
+	callback.EnrollSubject:       queryRoute(auth.LessonEnroll, handleEnrollSubjectCallback),
+	callback.CreateLessonSubject: {auth.LessonCreate, handleLessonSubjectCallback},
+	callback.DeleteLessonSubject: {auth.LessonEditOwn, handleLessonSubjectCallback},
+
+	// Преподаватели
+	callback.ConfirmDeleteTeacher: queryRoute(auth.TeacherManage, handleConfirmDeleteTeacher),
+	callback.DeleteTeacher:        queryRoute(auth.TeacherManage, handleExecuteDeleteTeacher),
+	callback.RestoreTeacher:       queryRoute(auth.TeacherManage, handleRestoreTeacherAction),
+
+	// Журнал аудита
+	callback.AuditPage: queryRoute(auth.AuditView, handleAuditPageCallback),
+
+	// Право на шаги диалога проверяется по самому диалогу
+	callback.Dialog: {"", handleDialogCallback},
+}
+
+// Подпись кнопок опасных действий (инициализируется в main)
+var (
+	callbackSigner    *callback.Signer
+	defaultSignerOnce sync.Once
+	defaultSigner     *callback.Signer
+	defaultSignerErr  error
+)
+
+// InitializeCallbackSigner - ключ подписи callback данных
+func InitializeCallbackSigner(s *callback.Signer) {
+	callbackSigner = s
+}
+
+// signer - подпись кнопок; без инициализации (тесты) - случайный ключ процесса
+func signer() (*callback.Signer, error) {
+	if callbackSigner != nil {
+		return callbackSigner, nil
+	}
+	defaultSignerOnce.Do(func() {
+		defaultSigner, defaultSignerErr = callback.NewRandomSigner(24 * time.Hour)
+	})
+	return defaultSigner, defaultSignerErr
+}
+
+// signedButton - кнопка опасного действия, действительная только в чате chatID.
+// Без ключа подписи кнопка остается неподписанной и отклоняется при нажатии.
+func signedButton(text string, p callback.Payload, chatID int64) tgbotapi.InlineKeyboardButton {
+	s, err := signer()
+	if err != nil {
+		slog.Error("Ошибка создания ключа подписи кнопок", "err", err)
+		return callback.Button(text, p)
+	}
+	return s.Button(text, p, chatID)
+}
+
+// menuRoute - раздел меню, обработчик которого работает с сообщением.
+// Сообщение с кнопкой отправлено ботом, поэтому автором подставляется нажавший пользователь.
+func menuRoute(p auth.Permission, handler func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB)) callbackRoute {
+	return userMenuRoute(p, func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+		handler(bot, message, db)
+	})
+}
+
+// userMenuRoute - раздел меню, содержимое которого зависит от прав пользователя
+func userMenuRoute(p auth.Permission, handler commandHandler) callbackRoute {
+	return callbackRoute{p, func(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+		message := *query.Message
+		message.From = query.From
+		handler(bot, &message, db, user)
+	}}
+}
+
+// queryRoute - обработчик кнопки, которому не нужен автор
+func queryRoute(p auth.Permission, handler func(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload)) callbackRoute {
+	return callbackRoute{p, func(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload, user *requestUser) {
+		handler(bot, query, db, data)
+	}}
+}
+
+// Кнопка "Записаться на урок" - список предметов
+func handleEnrollSubjectsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	showSubjectsForEnrollment(bot, message.Chat.ID, db)
+}
+
+// Выбор предмета для записи - доступные уроки
+func handleEnrollSubjectCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, data callback.Payload) {
+	showAvailableLessonsForSubject(bot, query, db, data.ID)
+}
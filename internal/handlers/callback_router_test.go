@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+)
+
+// Тест: у каждой кнопки меню есть маршрут
+func TestMenuButtonsAreRouted(t *testing.T) {
+	keyboards := map[string]tgbotapi.InlineKeyboardMarkup{
+		"student":    createStudentMainMenu(),
+		"teacher":    createTeacherMainMenu(),
+		"admin":      createAdminMainMenu(),
+		"navigation": createNavigationKeyboard(),
+	}
+
+	for name, keyboard := range keyboards {
+		for _, row := range keyboard.InlineKeyboard {
+			for _, button := range row {
+				data, err := callback.Decode(*button.CallbackData)
+				if err != nil {
+					t.Errorf("%s: button %q has invalid data: %v", name, button.Text, err)
+					continue
+				}
+				if _, ok := callbackRoutes[data.Action]; !ok {
+					t.Errorf("%s: no route for button %q (%s)", name, button.Text, data.Action)
+				}
+			}
+		}
+	}
+}
+
+// Тест: кнопки пошаговых диалогов попадают в обработчик диалогов
+func TestDialogButtonsAreRouted(t *testing.T) {
+	data, err := callback.Decode(callback.MustEncode(dialogCallback(createLessonDialog, "choice", "3")))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.Action != callback.Dialog || data.Arg != "create_lesson:choice:3" {
+		t.Errorf("Unexpected dialog payload %+v", data)
+	}
+	if _, ok := callbackRoutes[callback.Dialog]; !ok {
+		t.Error("No route for dialog buttons")
+	}
+}
+
+// Тест: опасные кнопки и диалоги защищены правом, которого нет у студента и гостя
+func TestSensitiveRoutesRequirePermission(t *testing.T) {
+	for action, route := range callbackRoutes {
+		if action.RequiresSignature() && (auth.Student.Can(route.permission) || auth.Guest.Can(route.permission)) {
+			t.Errorf("Route %s must require a staff permission, got %q", action, route.permission)
+		}
+	}
+	for name, d := range dialogRegistry {
+		if d.Permission == "" {
+			t.Errorf("Dialog %s must declare a permission", name)
+		}
+	}
+	for _, name := range []string{"delete_lesson", "add_teacher", "notify_all", "grant_admin", "log_recent_errors"} {
+		if route, ok := commandRoutes[name]; !ok || auth.Teacher.Can(route.permission) {
+			t.Errorf("Command /%s must require an admin permission", name)
+		}
+	}
+}
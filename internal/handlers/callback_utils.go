@@ -2,316 +2,260 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/metrics"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
 )
 
-// Проверка валидности урока (не отменен, не прошел)
-func isLessonValid(db *sql.DB, lessonID int) bool {
-	var status string
-	var startTime time.Time
-	
-	err := db.QueryRow(`
-		SELECT status, start_time 
-		FROM lessons 
-		WHERE id = $1 AND soft_deleted = false`, lessonID).Scan(&status, &startTime)
-	
+// checkEnrollment - правила записи на урок: урок доступен, урок курса - только для участников курса,
+// студент еще не записан, есть места
+func checkEnrollment(st *store.Store, studentID, lessonID int, now time.Time) error {
+	lesson, err := st.Lessons.Get(lessonID)
+	if errors.Is(err, store.ErrNotFound) {
+		return store.ErrLessonUnavailable
+	} else if err != nil {
+		return err
+	}
+
+	if !lesson.IsBookable(now) {
+		return store.ErrLessonUnavailable
+	}
+
+	if lesson.CourseID > 0 {
+		member, err := st.Courses.IsMember(lesson.CourseID, studentID)
+		if err != nil {
+			return err
+		}
+		if !member {
+			return store.ErrCourseLesson
+		}
+	}
+
+	enrolled, err := st.Enrollments.IsEnrolled(studentID, lessonID)
 	if err != nil {
-		return false
+		return err
+	}
+	if enrolled {
+		return store.ErrAlreadyEnrolled
 	}
-	
-	// Урок валиден, если активен и еще не прошел
-	return status == "active" && startTime.After(time.Now())
-}
 
-// Получение student_id по telegram user_id
-func getStudentID(db *sql.DB, telegramUserID int) (int, error) {
-	var studentID int
-	err := db.QueryRow(`
-		SELECT s.id 
-		FROM students s 
-		JOIN users u ON s.user_id = u.id 
-		WHERE u.tg_id = $1`, int64(telegramUserID)).Scan(&studentID)
-	return studentID, err
+	if lesson.FreeSpots() == 0 {
+		return store.ErrLessonFull
+	}
+	return nil
 }
 
-// Получение teacher_id по telegram user_id
-func getTeacherID(db *sql.DB, telegramUserID int) (int, error) {
-	var teacherID int
-	err := db.QueryRow(`
-		SELECT t.id 
-		FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE u.tg_id = $1`, int64(telegramUserID)).Scan(&teacherID)
-	return teacherID, err
+// enrollStudent - запись на урок с проверкой правил
+func enrollStudent(st *store.Store, studentID, lessonID int, now time.Time) error {
+	if err := checkEnrollment(st, studentID, lessonID, now); err != nil {
+		return err
+	}
+	if err := st.Enrollments.Enroll(studentID, lessonID); err != nil {
+		return err
+	}
+
+	// Записавшегося больше не нужно держать в листе ожидания
+	return st.Waitlist.Remove(studentID, lessonID)
 }
 
-// Проверка, записан ли студент на урок
-func isStudentEnrolled(db *sql.DB, studentID, lessonID int) bool {
-	var count int
-	err := db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM enrollments 
-		WHERE student_id = $1 AND lesson_id = $2 AND status = 'enrolled'`, 
-		studentID, lessonID).Scan(&count)
-	
-	return err == nil && count > 0
+// promoteFromWaitlist - записывает первого из листа ожидания на освободившееся место.
+// Возвращает nil, если место не освободилось или очередь пуста.
+func promoteFromWaitlist(st *store.Store, lessonID int, now time.Time) (*store.WaitlistEntry, error) {
+	for {
+		entry, err := st.Waitlist.Next(lessonID)
+		if errors.Is(err, store.ErrNotFound) {
+			return nil, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		err = enrollStudent(st, entry.StudentID, lessonID, now)
+		switch {
+		case err == nil:
+			metrics.WaitlistPromotionsTotal.Inc()
+			return entry, nil
+		case errors.Is(err, store.ErrAlreadyEnrolled), errors.Is(err, store.ErrCourseLesson),
+			errors.Is(err, store.ErrStudentScheduleConflict):
+			// Уже записан другим путем, покинул курс или занят в это время на другом уроке -
+			// убираем из очереди и смотрим следующего
+			if err := st.Waitlist.Remove(entry.StudentID, lessonID); err != nil {
+				return nil, err
+			}
+		case errors.Is(err, store.ErrLessonFull), errors.Is(err, store.ErrLessonUnavailable):
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
 }
 
-// Проверка наличия свободных мест
-func hasAvailableSpots(db *sql.DB, lessonID int) bool {
-	var maxStudents, enrolledCount int
-	
-	err := db.QueryRow(`
-		SELECT l.max_students, 
-			COALESCE(COUNT(e.id), 0) as enrolled_count
-		FROM lessons l
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE l.id = $1 AND l.soft_deleted = false
-		GROUP BY l.id, l.max_students`, lessonID).Scan(&maxStudents, &enrolledCount)
-		
+// canManageLesson - без права на любые уроки пользователь управляет только своими
+func canManageLesson(st *store.Store, user *requestUser, lessonID int) bool {
+	if user.Can(auth.LessonEditAny) {
+		return true
+	}
+	if !user.Can(auth.LessonEditOwn) {
+		return false
+	}
+	teacher, err := st.Teachers.GetByTelegramID(user.TelegramID)
 	if err != nil {
 		return false
 	}
-	
-	return enrolledCount < maxStudents
+	owns, err := st.Teachers.OwnsLesson(teacher.ID, lessonID)
+	return err == nil && owns
 }
 
-// Запись студента на урок в БД
-func enrollStudentInDB(db *sql.DB, studentID, lessonID int) error {
-	_, err := db.Exec(`
-		INSERT INTO enrollments (student_id, lesson_id, status, enrolled_at) 
-		VALUES ($1, $2, 'enrolled', NOW())
-		ON CONFLICT (student_id, lesson_id) DO NOTHING`, 
-		studentID, lessonID)
-	return err
-}
-
-// Отмена записи студента
-func unenrollStudentFromDB(db *sql.DB, studentID, lessonID int) error {
-	_, err := db.Exec(`
-		UPDATE enrollments 
-		SET status = 'cancelled' 
-		WHERE student_id = $1 AND lesson_id = $2 AND status = 'enrolled'`, 
-		studentID, lessonID)
-	return err
-}
-
-// Добавление в лист ожидания
-func addToWaitlist(db *sql.DB, studentID, lessonID int) error {
-	_, err := db.Exec(`
-		INSERT INTO waitlist (student_id, lesson_id, created_at) 
-		VALUES ($1, $2, NOW())
-		ON CONFLICT (student_id, lesson_id) DO NOTHING`, 
-		studentID, lessonID)
-	return err
+// Получение student_id по telegram user_id
+func getStudentID(db *sql.DB, telegramUserID int) (int, error) {
+	student, err := repos(db).Students.GetByTelegramID(int64(telegramUserID))
+	if err != nil {
+		return 0, err
+	}
+	return student.ID, nil
 }
 
-// Отмена урока в БД
-func cancelLessonInDB(db *sql.DB, lessonID int) error {
-	_, err := db.Exec(`
-		UPDATE lessons 
-		SET status = 'cancelled' 
-		WHERE id = $1`, lessonID)
-	return err
+// Получение teacher_id по telegram user_id
+func getTeacherID(db *sql.DB, telegramUserID int) (int, error) {
+	teacher, err := repos(db).Teachers.GetByTelegramID(int64(telegramUserID))
+	if err != nil {
+		return 0, err
+	}
+	return teacher.ID, nil
 }
 
 // Проверка, что урок принадлежит учителю
 func isTeacherLesson(db *sql.DB, teacherID, lessonID int) bool {
-	var count int
-	err := db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM lessons 
-		WHERE id = $1 AND teacher_id = $2`, lessonID, teacherID).Scan(&count)
-	
-	return err == nil && count > 0
+	owns, err := repos(db).Teachers.OwnsLesson(teacherID, lessonID)
+	return err == nil && owns
 }
 
-// Уведомление студентов об отмене урока
-func notifyStudentsAboutCancellation(bot *tgbotapi.BotAPI, db *sql.DB, lessonID int) {
-	rows, err := db.Query(`
-		SELECT u.tg_id, u.full_name
-		FROM enrollments e
-		JOIN students s ON e.student_id = s.id
-		JOIN users u ON s.user_id = u.id
-		WHERE e.lesson_id = $1 AND e.status = 'enrolled'`, lessonID)
-		
+// Уведомление студентов об отмене урока через очередь; отчет о доставке - в reportChatID
+func notifyStudentsAboutCancellation(bot telegram.Messenger, db *sql.DB, lessonID int, senderID, reportChatID int64) {
+	students, err := repos(db).Enrollments.EnrolledStudents(lessonID)
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения записанных студентов урока", "lesson_id", lessonID, "err", err)
 		return
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var telegramID int64
-		var fullName string
-		if err := rows.Scan(&telegramID, &fullName); err != nil {
-			continue
-		}
-		
-		message := "❌ **Уведомление об отмене**\n\nВаш урок был отменен преподавателем. Приносим извинения за неудобства."
-		sendMessage(bot, telegramID, message)
-	}
+	message := "❌ **Уведомление об отмене**\n\nВаш урок был отменен преподавателем. Приносим извинения за неудобства."
+	enqueueNotification(bot, db, notificationLessonCancelled, message, studentChatIDs(students), senderID, reportChatID)
 }
 
 // Уведомление следующего в листе ожидания
-func notifyNextInWaitlist(bot *tgbotapi.BotAPI, db *sql.DB, lessonID int) {
-	// Получаем следующего в очереди
-	var telegramID int64
-	var studentID int
-	
-	err := db.QueryRow(`
-		SELECT u.tg_id, w.student_id
-		FROM waitlist w
-		JOIN students s ON w.student_id = s.id
-		JOIN users u ON s.user_id = u.id
-		WHERE w.lesson_id = $1
-		ORDER BY w.created_at
-		LIMIT 1`, lessonID).Scan(&telegramID, &studentID)
-		
-	if err != nil {
-		return // Никого нет в листе ожидания
-	}
-	
-	// Автоматически записываем на урок
-	err = enrollStudentInDB(db, studentID, lessonID)
+func notifyNextInWaitlist(bot telegram.Messenger, db *sql.DB, lessonID int) {
+	entry, err := promoteFromWaitlist(repos(db), lessonID, time.Now())
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка записи из листа ожидания", "lesson_id", lessonID, "err", err)
 		return
 	}
-	
-	// Удаляем из листа ожидания
-	db.Exec(`DELETE FROM waitlist WHERE student_id = $1 AND lesson_id = $2`, studentID, lessonID)
-	
-	// Уведомляем
+	if entry == nil {
+		return // Никого нет в листе ожидания или место уже занято
+	}
+	audit(bot, db, 0, auditWaitlistPromoted, store.AuditEnrollment, lessonID,
+		waitlistState(entry.StudentID, entry.Position), enrollmentState(entry.StudentID, "enrolled"))
+
 	message := "🎉 **Освободилось место!**\n\nВы автоматически записаны на урок из листа ожидания."
-	sendMessage(bot, telegramID, message)
+	enqueueNotification(bot, db, notificationWaitlistPromoted, message, []int64{entry.TelegramID}, 0, 0)
+}
+
+// formatLessonSpots - заполненность урока с цветовым индикатором
+func formatLessonSpots(lesson store.Lesson) string {
+	status := fmt.Sprintf("(%d/%d мест)", lesson.EnrolledCount, lesson.MaxStudents)
+	if freeSpots := lesson.FreeSpots(); freeSpots == 0 {
+		status += " 🔴"
+	} else if freeSpots <= 2 {
+		status += " 🟡"
+	} else {
+		status += " 🟢"
+	}
+	return status
 }
 
 // Получение информации об уроке
 func getLessonInfo(db *sql.DB, lessonID int) (string, error) {
-	var startTime time.Time
-	var subjectName, teacherName string
-	var maxStudents, enrolledCount int
-	
-	err := db.QueryRow(`
-		SELECT l.start_time, s.name, u.full_name, l.max_students,
-			COUNT(e.id) as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE l.id = $1
-		GROUP BY l.id, l.start_time, s.name, u.full_name, l.max_students`, lessonID).Scan(
-		&startTime, &subjectName, &teacherName, &maxStudents, &enrolledCount)
-		
+	lesson, err := repos(db).Lessons.Get(lessonID)
 	if err != nil {
 		return "", err
 	}
-	
-	freeSpots := maxStudents - enrolledCount
-	status := fmt.Sprintf("(%d/%d мест)", enrolledCount, maxStudents)
-	if freeSpots == 0 {
-		status += " 🔴"
-	} else if freeSpots <= 2 {
-		status += " 🟡" 
-	} else {
-		status += " 🟢"
+
+	return formatLessonInfo(*lesson), nil
+}
+
+// formatLessonInfo - карточка урока: время, предмет, преподаватель, заполненность
+func formatLessonInfo(lesson store.Lesson) string {
+	text := fmt.Sprintf("📅 **%s**\n📚 %s\n👨‍🏫 %s\n%s",
+		lesson.StartTime.Format("02.01.2006 15:04"), lesson.SubjectName, lesson.TeacherName, formatLessonSpots(lesson))
+	if lesson.RoomName != "" {
+		text += "\n🚪 " + lesson.RoomName
 	}
-	
-	return fmt.Sprintf("📅 **%s**\n📚 %s\n👨‍🏫 %s\n%s", 
-		startTime.Format("02.01.2006 15:04"), subjectName, teacherName, status), nil
+	if lesson.CourseID > 0 {
+		text += fmt.Sprintf("\n🎓 Урок курса #%d", lesson.CourseID)
+	} else if lesson.SeriesID > 0 {
+		text += fmt.Sprintf("\n🔁 Серия #%d", lesson.SeriesID)
+	}
+	return text
 }
 
 // Создание урока с кнопками
-func getLessonWithButtons(db *sql.DB, lessonID int, userRole int) (string, tgbotapi.InlineKeyboardMarkup) {
-	lessonText, err := getLessonInfo(db, lessonID)
+func getLessonWithButtons(db *sql.DB, chatID int64, lessonID int, userRole int) (string, tgbotapi.InlineKeyboardMarkup) {
+	lesson, err := repos(db).Lessons.Get(lessonID)
 	if err != nil {
 		return "❌ Ошибка загрузки информации об уроке", tgbotapi.NewInlineKeyboardMarkup()
 	}
+	lessonText := formatLessonInfo(*lesson)
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	
 	// Кнопки для студентов
 	if userRole == 0 || userRole == 1 { // 0=любой, 1=студент
-		if hasAvailableSpots(db, lessonID) {
+		if lesson.FreeSpots() > 0 {
 			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("✅ Записаться", fmt.Sprintf("enroll_lesson_%d", lessonID)),
+				callback.Button("✅ Записаться", callback.WithID(callback.Enroll, lessonID)),
 			))
 		} else {
 			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("⏳ В лист ожидания", fmt.Sprintf("waitlist_lesson_%d", lessonID)),
+				callback.Button("⏳ В лист ожидания", callback.WithID(callback.Waitlist, lessonID)),
 			))
 		}
 		
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отменить запись", fmt.Sprintf("unenroll_lesson_%d", lessonID)),
+			callback.Button("❌ Отменить запись", callback.WithID(callback.Unenroll, lessonID)),
 		))
 	}
 	
 	// Кнопки для учителей/админов
 	if userRole == 0 || userRole == 2 || userRole == 3 { // 0=любой, 2=учитель, 3=админ
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🚫 Отменить урок", fmt.Sprintf("cancel_lesson_%d", lessonID)),
+			signedButton("🚫 Отменить урок", callback.WithID(callback.CancelLesson, lessonID), chatID),
 		))
 	}
 	
 	// Общие кнопки
 	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("ℹ️ Подробнее", fmt.Sprintf("info_lesson_%d", lessonID)),
-		tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", fmt.Sprintf("schedule_lesson_%d", lessonID)),
+		callback.Button("ℹ️ Подробнее", callback.WithID(callback.LessonInfo, lessonID)),
+		callback.Button("🔄 Обновить", callback.WithID(callback.RefreshLesson, lessonID)),
 	))
 	
 	return lessonText, tgbotapi.NewInlineKeyboardMarkup(buttons...)
 }
 
 // Отправка расписания с кнопками
-func sendScheduleWithButtons(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB, userRole string) {
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time, s.name, u.full_name, l.max_students,
-			COUNT(e.id) as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE l.start_time > NOW() AND l.start_time < NOW() + INTERVAL '7 days'
-			AND l.soft_deleted = false AND l.status = 'active'
-		GROUP BY l.id, l.start_time, s.name, u.full_name, l.max_students
-		ORDER BY l.start_time LIMIT 5`)
-		
+func sendScheduleWithButtons(bot telegram.Messenger, chatID int64, db *sql.DB, user *requestUser) {
+	now := time.Now()
+	lessons, err := repos(db).Lessons.Upcoming(now, now.AddDate(0, 0, 7), 5)
 	if err != nil {
 		sendMessage(bot, chatID, "❌ Ошибка загрузки расписания")
 		return
 	}
-	defer rows.Close()
-
-	lessons := []struct {
-		ID            int
-		StartTime     time.Time
-		SubjectName   string
-		TeacherName   string
-		MaxStudents   int
-		EnrolledCount int
-	}{}
-
-	for rows.Next() {
-		var lesson struct {
-			ID            int
-			StartTime     time.Time
-			SubjectName   string
-			TeacherName   string
-			MaxStudents   int
-			EnrolledCount int
-		}
-		
-		if err := rows.Scan(&lesson.ID, &lesson.StartTime, &lesson.SubjectName, 
-			&lesson.TeacherName, &lesson.MaxStudents, &lesson.EnrolledCount); err != nil {
-			continue
-		}
-		lessons = append(lessons, lesson)
-	}
 
 	if len(lessons) == 0 {
 		sendMessage(bot, chatID, "📅 На ближайшую неделю уроков не запланировано")
@@ -320,16 +264,9 @@ func sendScheduleWithButtons(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB, use
 
 	// Отправляем каждый урок отдельным сообщением с кнопками
 	for _, lesson := range lessons {
-		freeSpots := lesson.MaxStudents - lesson.EnrolledCount
-		status := fmt.Sprintf("(%d/%d мест)", lesson.EnrolledCount, lesson.MaxStudents)
-		if freeSpots == 0 {
-			status += " 🔴"
-		} else if freeSpots <= 2 {
-			status += " 🟡" 
-		} else {
-			status += " 🟢"
-		}
-		
+		freeSpots := lesson.FreeSpots()
+		status := formatLessonSpots(lesson)
+
 		text := fmt.Sprintf("� **%s** (ID: #%d)\n� %s\n👨‍🏫 %s\n%s", 
 			lesson.SubjectName, lesson.ID,
 			lesson.StartTime.Format("02.01.2006 15:04"), 
@@ -337,32 +274,32 @@ func sendScheduleWithButtons(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB, use
 
 		var buttons [][]tgbotapi.InlineKeyboardButton
 		
-		// Кнопки в зависимости от роли
-		if userRole == "student" {
+		// Кнопки в зависимости от прав
+		if user.Can(auth.LessonEnroll) {
 			if freeSpots > 0 {
 				buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("✅ Записаться", fmt.Sprintf("enroll_lesson_%d", lesson.ID)),
+					callback.Button("✅ Записаться", callback.WithID(callback.Enroll, lesson.ID)),
 				))
 			} else {
 				buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-					tgbotapi.NewInlineKeyboardButtonData("⏳ В лист ожидания", fmt.Sprintf("waitlist_lesson_%d", lesson.ID)),
+					callback.Button("⏳ В лист ожидания", callback.WithID(callback.Waitlist, lesson.ID)),
 				))
 			}
 			
 			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить запись", fmt.Sprintf("unenroll_lesson_%d", lesson.ID)),
+				callback.Button("❌ Отменить запись", callback.WithID(callback.Unenroll, lesson.ID)),
 			))
 		}
 		
-		if userRole == "teacher" || userRole == "admin" {
+		if user.Can(auth.LessonEditOwn) {
 			buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("🚫 Отменить урок", fmt.Sprintf("cancel_lesson_%d", lesson.ID)),
+				signedButton("🚫 Отменить урок", callback.WithID(callback.CancelLesson, lesson.ID), chatID),
 			))
 		}
 		
 		// Общие кнопки
 		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("ℹ️ Подробнее", fmt.Sprintf("info_lesson_%d", lesson.ID)),
+			callback.Button("ℹ️ Подробнее", callback.WithID(callback.LessonInfo, lesson.ID)),
 		))
 
 		msg := tgbotapi.NewMessage(chatID, text)
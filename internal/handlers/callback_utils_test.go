@@ -0,0 +1,328 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Фейковые репозитории в памяти для проверки бизнес-правил без БД
+
+type fakeLessons struct {
+	lessons map[int]*store.Lesson
+}
+
+func (f *fakeLessons) Get(lessonID int) (*store.Lesson, error) {
+	lesson, ok := f.lessons[lessonID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	result := *lesson
+	return &result, nil
+}
+
+func (f *fakeLessons) Upcoming(from, to time.Time, limit int) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Create(lesson store.Lesson) (int, error) {
+	lesson.ID = len(f.lessons) + 1
+	lesson.Status = "active"
+	f.lessons[lesson.ID] = &lesson
+	return lesson.ID, nil
+}
+
+func (f *fakeLessons) ByTeacher(teacherID int, from, to time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) BySubject(subjectID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Full(from, to time.Time, limit int) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Reschedule(lessonID int, startTime time.Time) (time.Time, error) {
+	lesson, ok := f.lessons[lessonID]
+	if !ok || lesson.Status != "active" || lesson.SoftDeleted {
+		return time.Time{}, store.ErrNotFound
+	}
+	previous := lesson.StartTime
+	lesson.StartTime = startTime
+	return previous, nil
+}
+
+func (f *fakeLessons) Cancel(lessonID int) error {
+	lesson, ok := f.lessons[lessonID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	lesson.Status = "cancelled"
+	return nil
+}
+
+func (f *fakeLessons) Delete(lessonID int) error {
+	lesson, ok := f.lessons[lessonID]
+	if !ok || lesson.SoftDeleted {
+		return store.ErrNotFound
+	}
+	lesson.SoftDeleted = true
+	return nil
+}
+
+func (f *fakeLessons) Restore(lessonID int) error {
+	lesson, ok := f.lessons[lessonID]
+	if !ok || !lesson.SoftDeleted {
+		return store.ErrNotFound
+	}
+	lesson.SoftDeleted = false
+	return nil
+}
+
+type enrollmentKey struct{ studentID, lessonID int }
+
+type fakeEnrollments struct {
+	lessons  *fakeLessons
+	enrolled map[enrollmentKey]bool
+	busy     map[enrollmentKey]bool // студент записан на другой урок в это время
+}
+
+func (f *fakeEnrollments) IsEnrolled(studentID, lessonID int) (bool, error) {
+	return f.enrolled[enrollmentKey{studentID, lessonID}], nil
+}
+
+func (f *fakeEnrollments) Enroll(studentID, lessonID int) error {
+	key := enrollmentKey{studentID, lessonID}
+	if f.enrolled[key] {
+		return store.ErrAlreadyEnrolled
+	}
+	if f.busy[key] {
+		return store.ErrStudentScheduleConflict
+	}
+	if f.lessons.lessons[lessonID].FreeSpots() == 0 {
+		return store.ErrLessonFull
+	}
+	f.enrolled[key] = true
+	f.lessons.lessons[lessonID].EnrolledCount++
+	return nil
+}
+
+func (f *fakeEnrollments) Unenroll(studentID, lessonID int) error {
+	key := enrollmentKey{studentID, lessonID}
+	if !f.enrolled[key] {
+		return store.ErrNotEnrolled
+	}
+	delete(f.enrolled, key)
+	f.lessons.lessons[lessonID].EnrolledCount--
+	return nil
+}
+
+func (f *fakeEnrollments) EnrolledStudents(lessonID int) ([]store.Student, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) ByLesson(lessonID int) ([]store.Enrollment, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) EnrolledByStudent(lessonIDs []int) ([]store.StudentLessons, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) Lessons(studentID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+type fakeWaitlist struct {
+	entries []store.WaitlistEntry
+}
+
+func (f *fakeWaitlist) Add(studentID, lessonID int) (int, error) {
+	position := 1
+	for _, entry := range f.entries {
+		if entry.LessonID != lessonID {
+			continue
+		}
+		if entry.StudentID == studentID {
+			return 0, store.ErrAlreadyWaitlisted
+		}
+		position = entry.Position + 1
+	}
+	f.entries = append(f.entries, store.WaitlistEntry{StudentID: studentID, LessonID: lessonID, Position: position, TelegramID: int64(studentID * 100)})
+	return position, nil
+}
+
+func (f *fakeWaitlist) Next(lessonID int) (*store.WaitlistEntry, error) {
+	for _, entry := range f.entries {
+		if entry.LessonID == lessonID {
+			return &entry, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeWaitlist) Remove(studentID, lessonID int) error {
+	for i, entry := range f.entries {
+		if entry.StudentID == studentID && entry.LessonID == lessonID {
+			f.entries = append(f.entries[:i], f.entries[i+1:]...)
+			return nil
+		}
+	}
+	return nil
+}
+
+func (f *fakeWaitlist) Lessons(studentID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func newFakeStore(lessons ...store.Lesson) *store.Store {
+	fl := &fakeLessons{lessons: make(map[int]*store.Lesson)}
+	for i := range lessons {
+		fl.lessons[lessons[i].ID] = &lessons[i]
+	}
+	return &store.Store{
+		Lessons:     fl,
+		Enrollments: &fakeEnrollments{lessons: fl, enrolled: make(map[enrollmentKey]bool)},
+		Waitlist:    &fakeWaitlist{},
+	}
+}
+
+var testNow = time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+func testLesson(id, maxStudents, enrolled int) store.Lesson {
+	return store.Lesson{
+		ID:            id,
+		StartTime:     testNow.Add(24 * time.Hour),
+		MaxStudents:   maxStudents,
+		EnrolledCount: enrolled,
+		Status:        "active",
+	}
+}
+
+// Тест правил записи на урок
+func TestCheckEnrollment(t *testing.T) {
+	past := testLesson(2, 5, 0)
+	past.StartTime = testNow.Add(-time.Hour)
+	cancelled := testLesson(3, 5, 0)
+	cancelled.Status = "cancelled"
+	deleted := testLesson(4, 5, 0)
+	deleted.SoftDeleted = true
+
+	st := newFakeStore(testLesson(1, 2, 0), past, cancelled, deleted, testLesson(5, 1, 1))
+	st.Enrollments.Enroll(7, 1)
+
+	tests := []struct {
+		name      string
+		studentID int
+		lessonID  int
+		expected  error
+	}{
+		{"free spot", 8, 1, nil},
+		{"already enrolled", 7, 1, store.ErrAlreadyEnrolled},
+		{"past lesson", 8, 2, store.ErrLessonUnavailable},
+		{"cancelled lesson", 8, 3, store.ErrLessonUnavailable},
+		{"deleted lesson", 8, 4, store.ErrLessonUnavailable},
+		{"full lesson", 8, 5, store.ErrLessonFull},
+		{"unknown lesson", 8, 99, store.ErrLessonUnavailable},
+	}
+
+	for _, test := range tests {
+		err := checkEnrollment(st, test.studentID, test.lessonID, testNow)
+		if !errors.Is(err, test.expected) {
+			t.Errorf("%s: expected %v, got %v", test.name, test.expected, err)
+		}
+	}
+}
+
+// Тест: запись убирает студента из листа ожидания
+func TestEnrollStudentRemovesFromWaitlist(t *testing.T) {
+	st := newFakeStore(testLesson(1, 2, 0))
+	st.Waitlist.Add(7, 1)
+
+	if err := enrollStudent(st, 7, 1, testNow); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := st.Waitlist.Next(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected empty waitlist after enrollment, got %v", err)
+	}
+}
+
+// Тест продвижения очереди листа ожидания
+func TestPromoteFromWaitlist(t *testing.T) {
+	st := newFakeStore(testLesson(1, 1, 0))
+	st.Enrollments.Enroll(1, 1)
+	st.Waitlist.Add(2, 1)
+	st.Waitlist.Add(3, 1)
+
+	// Мест нет - никого не записываем
+	entry, err := promoteFromWaitlist(st, 1, testNow)
+	if err != nil || entry != nil {
+		t.Fatalf("Expected no promotion while lesson is full, got %+v, %v", entry, err)
+	}
+
+	// Место освободилось - записываем первого в очереди
+	st.Enrollments.Unenroll(1, 1)
+	entry, err = promoteFromWaitlist(st, 1, testNow)
+	if err != nil || entry == nil || entry.StudentID != 2 {
+		t.Fatalf("Expected student 2 to be promoted, got %+v, %v", entry, err)
+	}
+	if enrolled, _ := st.Enrollments.IsEnrolled(2, 1); !enrolled {
+		t.Error("Promoted student should be enrolled")
+	}
+
+	next, err := st.Waitlist.Next(1)
+	if err != nil || next.StudentID != 3 {
+		t.Errorf("Expected student 3 to be next in waitlist, got %+v, %v", next, err)
+	}
+}
+
+// Тест: уже записанный студент пропускается в очереди
+func TestPromoteFromWaitlistSkipsEnrolled(t *testing.T) {
+	st := newFakeStore(testLesson(1, 3, 0))
+	st.Waitlist.Add(2, 1)
+	st.Waitlist.Add(3, 1)
+	st.Enrollments.Enroll(2, 1)
+
+	entry, err := promoteFromWaitlist(st, 1, testNow)
+	if err != nil || entry == nil || entry.StudentID != 3 {
+		t.Fatalf("Expected student 3 to be promoted, got %+v, %v", entry, err)
+	}
+}
+
+// Тест: студент, занятый на другом уроке, убирается из очереди
+func TestPromoteFromWaitlistSkipsBusyStudent(t *testing.T) {
+	st := newFakeStore(testLesson(1, 3, 0))
+	st.Waitlist.Add(2, 1)
+	st.Waitlist.Add(3, 1)
+	st.Enrollments.(*fakeEnrollments).busy = map[enrollmentKey]bool{{2, 1}: true}
+
+	entry, err := promoteFromWaitlist(st, 1, testNow)
+	if err != nil || entry == nil || entry.StudentID != 3 {
+		t.Fatalf("Expected student 3 to be promoted, got %+v, %v", entry, err)
+	}
+	if _, err := st.Waitlist.Next(1); !errors.Is(err, store.ErrNotFound) {
+		t.Errorf("Expected busy student to leave the waitlist, got %v", err)
+	}
+}
+
+// Тест заполненности урока
+func TestFormatLessonSpots(t *testing.T) {
+	tests := []struct {
+		lesson   store.Lesson
+		expected string
+	}{
+		{testLesson(1, 10, 10), "(10/10 мест) 🔴"},
+		{testLesson(1, 10, 8), "(8/10 мест) 🟡"},
+		{testLesson(1, 10, 3), "(3/10 мест) 🟢"},
+	}
+
+	for _, test := range tests {
+		if result := formatLessonSpots(test.lesson); result != test.expected {
+			t.Errorf("Expected %q, got %q", test.expected, result)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// maxCourseCapacity - наибольшее число мест на курсе
+const maxCourseCapacity = 100
+
+// parseCourseArgs - разбор /create_course: первая строка `<название> <предмет> <мест>`
+// (значения из нескольких слов - в кавычках), следующие строки - темы уроков по порядку
+func parseCourseArgs(args string) (store.Course, error) {
+	var course store.Course
+
+	lines := strings.Split(strings.TrimSpace(args), "\n")
+	fields, err := splitQuotedArgs(lines[0])
+	if err != nil {
+		return course, err
+	}
+	if len(fields) != 3 || fields[0] == "" || fields[1] == "" {
+		return course, errors.New("в первой строке нужны название курса, предмет и число мест")
+	}
+	course.Name, course.SubjectName = fields[0], fields[1]
+
+	capacity, err := strconv.Atoi(fields[2])
+	if err != nil || capacity < 1 || capacity > maxCourseCapacity {
+		return course, fmt.Errorf("число мест должно быть от 1 до %d", maxCourseCapacity)
+	}
+	course.Capacity = capacity
+
+	for _, line := range lines[1:] {
+		topic := strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "-•"))
+		if topic == "" {
+			continue
+		}
+		course.Plan = append(course.Plan, store.CoursePlanItem{Position: len(course.Plan) + 1, Topic: topic})
+	}
+	if len(course.Plan) == 0 {
+		return course, errors.New("укажите темы уроков, по одной на строке")
+	}
+	if len(course.Plan) > store.MaxSeriesLessons {
+		return course, fmt.Errorf("в плане курса не больше %d уроков", store.MaxSeriesLessons)
+	}
+	return course, nil
+}
+
+// Создание курса с планом уроков
+func handleCreateCourseCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	userID := message.From.ID
+
+	args := message.CommandArguments()
+	if strings.TrimSpace(args) == "" {
+		helpText := "🎓 **Новый курс**\n\n" +
+			"**Формат:** в первой строке `/create_course <название> <предмет> <мест>`, " +
+			"в следующих - темы уроков по порядку.\n\n" +
+			"**Пример:**\n" +
+			"`/create_course \"Основы 3D\" \"3D-моделирование\" 8`\n" +
+			"`Интерфейс Blender`\n" +
+			"`Полигональное моделирование`\n" +
+			"`Материалы и свет`\n\n" +
+			"Уроки для тем создаются командой `/schedule_course`."
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	course, err := parseCourseArgs(args)
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\nФормат: /create_course <название> <предмет> <мест>, темы уроков - с новой строки")
+		return
+	}
+
+	subject, err := repos(db).Subjects.GetByName(course.SubjectName)
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Предмет не найден. Используйте /subjects для просмотра доступных предметов")
+		return
+	}
+	course.SubjectID = subject.ID
+
+	course.TeacherID, err = getTeacherID(db, int(userID))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
+		return
+	}
+	course.CreatedBy = userID
+
+	courseID, err := repos(db).Courses.Create(course)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка создания курса", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания курса")
+		return
+	}
+	audit(bot, db, userID, auditCourseCreated, store.AuditCourse, courseID, nil, auditState{
+		"name":       course.Name,
+		"subject_id": course.SubjectID,
+		"teacher_id": course.TeacherID,
+		"capacity":   course.Capacity,
+		"lessons":    len(course.Plan),
+	})
+	LogUserAction(telegram.Context(bot), db, "course_created", userID,
+		fmt.Sprintf("Курс %d (%s), уроков: %d, мест: %d", courseID, course.Name, len(course.Plan), course.Capacity))
+
+	resultText := fmt.Sprintf("✅ **Курс создан**\n\n"+
+		"🎓 %s\n"+
+		"📚 Предмет: %s\n"+
+		"👥 Мест: %d\n"+
+		"🗓 Уроков в плане: %d\n"+
+		"🆔 Курс: %d\n\n"+
+		"Запланируйте уроки: `/schedule_course %d <дата> <время> <weekly|biweekly>`",
+		escapeMarkdown(course.Name), escapeMarkdown(course.SubjectName), course.Capacity, len(course.Plan), courseID, courseID)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// canManageCourse - курс ведет этот преподаватель или у пользователя есть право на любые уроки
+func canManageCourse(st *store.Store, user *requestUser, course *store.Course) bool {
+	if user.Can(auth.LessonEditAny) {
+		return true
+	}
+	if !user.Can(auth.LessonEditOwn) {
+		return false
+	}
+	teacher, err := st.Teachers.GetByTelegramID(user.TelegramID)
+	return err == nil && teacher.ID == course.TeacherID
+}
+
+// Уроки для незапланированных тем курса: /schedule_course <course_id> <дата> <время> <weekly|biweekly> [skip=...]
+func handleScheduleCourseCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	userID := message.From.ID
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 4 {
+		helpText := "🎓 **Расписание курса**\n\n" +
+			"**Формат:** `/schedule_course <ID курса> <дата> <время> <weekly|biweekly> [skip=<даты>]`\n\n" +
+			"Для каждой темы плана, у которой еще нет урока, создается урок серии. " +
+			"Участники курса записываются на новые уроки автоматически.\n\n" +
+			"**Пример:** `/schedule_course 3 02.09.2025 16:30 weekly skip=04.11.2025`"
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	courseID, err := strconv.Atoi(args[0])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Некорректный ID курса")
+		return
+	}
+	startTime, err := parseLessonDateTime(args[1], args[2])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\nИспользуйте DD.MM.YYYY HH:MM или D.M.YYYY H:MM")
+		return
+	}
+	if startTime.Before(time.Now()) {
+		sendMessage(bot, message.Chat.ID, "❌ Первый урок не может быть в прошлом")
+		return
+	}
+	intervalWeeks, err := parseSeriesInterval(args[3])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error())
+		return
+	}
+	skip, err := parseSkipDates(args[4:])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error())
+		return
+	}
+
+	st := repos(db)
+	course, err := st.Courses.Get(courseID)
+	if err != nil || course.Status != "active" || !canManageCourse(st, user, course) {
+		sendMessage(bot, message.Chat.ID, "❌ Курс не найден или не принадлежит вам")
+		return
+	}
+	unscheduled := course.Unscheduled()
+	if unscheduled == 0 {
+		sendMessage(bot, message.Chat.ID, "ℹ️ Для всех тем курса уроки уже запланированы")
+		return
+	}
+
+	series := store.LessonSeries{
+		SubjectID:       course.SubjectID,
+		TeacherID:       course.TeacherID,
+		StartTime:       startTime,
+		DurationMinutes: 90,
+		MaxStudents:     course.Capacity,
+		IntervalWeeks:   intervalWeeks,
+		Occurrences:     unscheduled,
+		Skip:            skip,
+		CreatedBy:       userID,
+	}
+	dates := series.Dates()
+
+	seriesID, enrolled, err := st.Courses.Schedule(courseID, series, dates)
+	switch {
+	case errors.Is(err, store.ErrScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ У преподавателя уже есть урок, пересекающийся с одним из уроков курса")
+		return
+	case errors.Is(err, store.ErrStudentScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Один из участников курса записан на другой урок в это время")
+		return
+	case err != nil:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка планирования уроков курса", "course_id", courseID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка планирования уроков курса")
+		return
+	}
+	audit(bot, db, userID, auditCourseScheduled, store.AuditCourse, courseID, nil, auditState{
+		"series_id":  seriesID,
+		"start_time": startTime,
+		"lessons":    len(dates),
+		"enrolled":   enrolled,
+	})
+
+	schedule := make([]string, len(dates))
+	for i, date := range dates {
+		schedule[i] = "• " + date.Format("02.01.2006 15:04")
+	}
+
+	// Участники уже записаны - сообщаем им расписание
+	members, err := st.Courses.Members(courseID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения участников курса", "course_id", courseID, "err", err)
+	}
+	queued, err := enqueueNotification(bot, db, notificationCourseScheduled,
+		fmt.Sprintf("🎓 **Расписание курса «%s»**\n\nВы записаны на уроки:\n%s", escapeMarkdown(course.Name), strings.Join(schedule, "\n")),
+		studentChatIDs(members), userID, message.Chat.ID)
+
+	resultText := fmt.Sprintf("✅ **Уроки курса запланированы**\n\n"+
+		"🎓 %s\n"+
+		"🔁 %s\n"+
+		"🗓 Уроков: %d\n"+
+		"🆔 Серия: %d\n"+
+		"👥 Записано участников: %d\n\n"+
+		"%s",
+		escapeMarkdown(course.Name), formatSeriesRule(series), len(dates), seriesID, len(members), queuedReport(queued, err))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Список активных курсов
+func handleCoursesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	courses, err := repos(db).Courses.List()
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения курсов", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения курсов")
+		return
+	}
+	if len(courses) == 0 {
+		sendMessage(bot, message.Chat.ID, "📭 Сейчас нет открытых курсов")
+		return
+	}
+
+	text := "🎓 **Курсы**\n\n"
+	for _, course := range courses {
+		text += fmt.Sprintf("**%s** (#%d)\n📚 %s", escapeMarkdown(course.Name), course.ID, escapeMarkdown(course.SubjectName))
+		if course.TeacherName != "" {
+			text += ", " + escapeMarkdown(course.TeacherName)
+		}
+		text += fmt.Sprintf("\n👥 Свободно мест: %d из %d\n\n", course.FreeSeats(), course.Capacity)
+	}
+	text += "Подробнее: `/course <ID>`, запись: `/enroll_course <ID>`"
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Карточка курса: план уроков и места
+func handleCourseCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	courseID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID курса: /course <course_id>")
+		return
+	}
+
+	course, err := repos(db).Courses.Get(courseID)
+	if errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Курс не найден")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения курса", "course_id", courseID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения курса")
+		return
+	}
+
+	text := fmt.Sprintf("🎓 **%s** (#%d)\n\n📚 Предмет: %s\n", escapeMarkdown(course.Name), course.ID, escapeMarkdown(course.SubjectName))
+	if course.TeacherName != "" {
+		text += "👨‍🏫 Преподаватель: " + escapeMarkdown(course.TeacherName) + "\n"
+	}
+	text += fmt.Sprintf("👥 Свободно мест: %d из %d\n", course.FreeSeats(), course.Capacity)
+	if course.Status != "active" {
+		text += "❌ Курс закрыт\n"
+	}
+
+	text += "\n**План:**\n"
+	for _, item := range course.Plan {
+		when := "дата не назначена"
+		switch {
+		case item.LessonID == 0:
+		case item.LessonStatus != "active":
+			when = item.StartTime.Format("02.01.2006 15:04") + " - отменен"
+		default:
+			when = item.StartTime.Format("02.01.2006 15:04")
+		}
+		text += fmt.Sprintf("%d. %s - %s\n", item.Position, escapeMarkdown(item.Topic), when)
+	}
+	if course.Status == "active" {
+		text += fmt.Sprintf("\nЗаписаться на курс: `/enroll_course %d`", course.ID)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Запись на курс: место на курсе и запись на все предстоящие уроки
+func handleEnrollCourseCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	userID := message.From.ID
+
+	courseID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID курса: /enroll_course <course_id>. Список курсов: /courses")
+		return
+	}
+
+	st := repos(db)
+	studentID, err := getStudentID(db, int(userID))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Вы не зарегистрированы как студент. Используйте /register")
+		return
+	}
+
+	lessons, err := st.Courses.Enroll(courseID, studentID)
+	switch {
+	case err == nil:
+	case errors.Is(err, store.ErrNotFound):
+		sendMessage(bot, message.Chat.ID, "❌ Курс не найден или закрыт")
+		return
+	case errors.Is(err, store.ErrAlreadyEnrolled):
+		sendMessage(bot, message.Chat.ID, "ℹ️ Вы уже записаны на этот курс")
+		return
+	case errors.Is(err, store.ErrCourseFull):
+		sendMessage(bot, message.Chat.ID, "❌ На курсе нет свободных мест")
+		return
+	case errors.Is(err, store.ErrStudentScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Уроки курса пересекаются с другими вашими уроками. Посмотрите свои уроки: /my_lessons")
+		return
+	default:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка записи на курс", "course_id", courseID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка записи на курс")
+		return
+	}
+
+	audit(bot, db, userID, auditCourseEnrolled, store.AuditCourse, courseID, nil,
+		auditState{"student_id": studentID, "lessons": lessons})
+	LogUserAction(telegram.Context(bot), db, "course_enrolled", userID,
+		fmt.Sprintf("Курс %d, записан на уроков: %d", courseID, lessons))
+
+	resultText := "✅ **Вы записаны на курс**\n\n"
+	if course, err := st.Courses.Get(courseID); err == nil {
+		resultText += "🎓 " + escapeMarkdown(course.Name) + "\n📚 Предмет: " + escapeMarkdown(course.SubjectName) + "\n"
+	}
+	if lessons > 0 {
+		resultText += fmt.Sprintf("🗓 Записаны на уроков: %d\n", lessons)
+	} else {
+		resultText += "🗓 Уроки еще не запланированы - вы будете записаны на них автоматически\n"
+	}
+	resultText += fmt.Sprintf("\nПлан и расписание: `/course %d`", courseID)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Выход из курса с отменой записей на предстоящие уроки
+func handleLeaveCourseCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	userID := message.From.ID
+
+	courseID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID курса: /leave_course <course_id>")
+		return
+	}
+
+	studentID, err := getStudentID(db, int(userID))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Вы не зарегистрированы как студент")
+		return
+	}
+
+	cancelled, err := repos(db).Courses.Leave(courseID, studentID)
+	if errors.Is(err, store.ErrNotEnrolled) {
+		sendMessage(bot, message.Chat.ID, "ℹ️ Вы не записаны на этот курс")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка выхода из курса", "course_id", courseID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка выхода из курса")
+		return
+	}
+
+	audit(bot, db, userID, auditCourseLeft, store.AuditCourse, courseID,
+		auditState{"student_id": studentID}, nil)
+	LogUserAction(telegram.Context(bot), db, "course_left", userID,
+		fmt.Sprintf("Курс %d, отменено записей на уроки: %d", courseID, cancelled))
+
+	sendMessage(bot, message.Chat.ID, fmt.Sprintf("✅ Вы вышли из курса. Отменено записей на уроки: %d", cancelled))
+}
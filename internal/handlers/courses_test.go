@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"testing"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Тест разбора /create_course: название и предмет в кавычках, темы с новой строки
+func TestParseCourseArgs(t *testing.T) {
+	course, err := parseCourseArgs("\"Основы 3D\" \"3D-моделирование\" 8\nИнтерфейс Blender\n\n- Материалы и свет\n")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if course.Name != "Основы 3D" || course.SubjectName != "3D-моделирование" || course.Capacity != 8 {
+		t.Errorf("Unexpected course: %+v", course)
+	}
+	if len(course.Plan) != 2 || course.Plan[1].Position != 2 || course.Plan[1].Topic != "Материалы и свет" {
+		t.Errorf("Unexpected plan: %+v", course.Plan)
+	}
+	if course.Unscheduled() != 2 {
+		t.Errorf("Expected 2 unscheduled topics, got %d", course.Unscheduled())
+	}
+
+	for _, args := range []string{
+		"\"Основы 3D\" \"3D-моделирование\" 8",
+		"\"Основы 3D\" \"3D-моделирование\"\nТема",
+		"\"Основы 3D\" \"3D-моделирование\" 0\nТема",
+		"\"Основы 3D 3D-моделирование 8\nТема",
+	} {
+		if _, err := parseCourseArgs(args); err == nil {
+			t.Errorf("Expected error for %q", args)
+		}
+	}
+}
+
+func TestCourseFreeSeats(t *testing.T) {
+	course := store.Course{Capacity: 2, Members: 1, Plan: []store.CoursePlanItem{{LessonID: 5}, {}}}
+	if course.FreeSeats() != 1 || course.Unscheduled() != 1 {
+		t.Errorf("Unexpected seats or plan: %d %d", course.FreeSeats(), course.Unscheduled())
+	}
+	course.Members = 3
+	if course.FreeSeats() != 0 {
+		t.Errorf("Expected no free seats, got %d", course.FreeSeats())
+	}
+}
@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Dialog - многошаговый сценарий (мастер): шаги с валидацией, кнопки выбора,
+// возврат назад, отмена и итоговое подтверждение
+type Dialog struct {
+	Name  string // уникальное имя, входит в состояние FSM и callback данные
+	Title string // заголовок, показывается на каждом шаге
+	// Permission - право, без которого шаги диалога не принимаются
+	Permission auth.Permission
+	Steps      []DialogStep
+	// Summary - текст итогового подтверждения по собранным данным
+	Summary func(db *sql.DB, data map[string]string) string
+	// Submit - выполнение действия после подтверждения (сам отправляет результат пользователю)
+	Submit func(bot telegram.Messenger, db *sql.DB, chatID, userID int64, data map[string]string) error
+}
+
+// DialogStep - один шаг диалога
+type DialogStep struct {
+	Key    string // ключ, под которым сохраняется ответ
+	Prompt string
+	// Choices - варианты ответа кнопками (nil - только текстовый ввод)
+	Choices func(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error)
+	// AllowText - разрешить текстовый ввод на шаге с кнопками
+	AllowText bool
+	// Validate - проверка и нормализация ответа
+	Validate func(db *sql.DB, data map[string]string, input string) (string, error)
+}
+
+// DialogChoice - вариант ответа на шаге диалога
+type DialogChoice struct {
+	Value string
+	Label string
+}
+
+const (
+	dialogStatePrefix = "dialog:"
+	dialogStepKey     = "_step"
+	dialogLabelSuffix = "_label"
+)
+
+// Зарегистрированные диалоги (по имени)
+var dialogRegistry = map[string]*Dialog{}
+
+// registerDialog - регистрирует диалог для маршрутизации текста и callback кнопок
+func registerDialog(d *Dialog) *Dialog {
+	dialogRegistry[d.Name] = d
+	return d
+}
+
+// dialogFromState - диалог, в котором находится пользователь (nil, если не в диалоге)
+func dialogFromState(state UserState) *Dialog {
+	name, ok := strings.CutPrefix(string(state), dialogStatePrefix)
+	if !ok {
+		return nil
+	}
+	return dialogRegistry[name]
+}
+
+// startDialog - запускает диалог. Шаги, ключи которых есть в preset, пропускаются.
+func startDialog(bot telegram.Messenger, db *sql.DB, chatID, userID int64, d *Dialog, preset map[string]string) {
+	resetUserState(userID)
+	setUserState(userID, UserState(dialogStatePrefix+d.Name))
+
+	for key, value := range preset {
+		if err := setUserData(userID, key, value); err != nil {
+			slog.ErrorContext(telegram.Context(bot), "Ошибка запуска диалога", "dialog", d.Name, "err", err)
+			sendMessage(bot, chatID, "❌ Не удалось начать диалог, попробуйте позже")
+			return
+		}
+	}
+
+	step := 0
+	for step < len(d.Steps) {
+		if _, ok := preset[d.Steps[step].Key]; !ok {
+			break
+		}
+		step++
+	}
+
+	showDialogStep(bot, db, chatID, userID, d, step)
+}
+
+// showDialogStep - сохраняет номер шага и показывает его (или итоговое подтверждение)
+func showDialogStep(bot telegram.Messenger, db *sql.DB, chatID, userID int64, d *Dialog, step int) {
+	if err := setUserData(userID, dialogStepKey, strconv.Itoa(step)); err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка сохранения шага диалога", "dialog", d.Name, "err", err)
+		sendMessage(bot, chatID, "❌ Время диалога истекло. Начните заново")
+		return
+	}
+
+	data := getUserData(userID)
+
+	if step >= len(d.Steps) {
+		text := fmt.Sprintf("📋 **%s**\n\n%s\n\nВсе верно?", d.Title, d.Summary(db, data))
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				callback.Button("✅ Подтвердить", dialogCallback(d, "confirm")),
+			),
+			dialogNavigationRow(d, true),
+		)
+
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = keyboard
+		bot.Send(msg)
+		return
+	}
+
+	current := d.Steps[step]
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	if current.Choices != nil {
+		choices, err := current.Choices(db, userID, data)
+		if err != nil {
+			slog.ErrorContext(telegram.Context(bot), "Ошибка получения вариантов шага", "dialog", d.Name, "step", current.Key, "err", err)
+			sendMessage(bot, chatID, "❌ Ошибка загрузки вариантов")
+			return
+		}
+		if len(choices) == 0 && !current.AllowText {
+			resetUserState(userID)
+			sendMessage(bot, chatID, "📭 Нет доступных вариантов. Диалог завершен")
+			return
+		}
+		for _, choice := range choices {
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				callback.Button(choice.Label, dialogCallback(d, "choice", choice.Value)),
+			))
+		}
+	}
+	rows = append(rows, dialogNavigationRow(d, step > 0))
+
+	text := fmt.Sprintf("📝 **%s** (шаг %d из %d)\n\n%s", d.Title, step+1, len(d.Steps), current.Prompt)
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(rows...)
+	bot.Send(msg)
+}
+
+// Кнопки "Назад" и "Отмена"
+func dialogNavigationRow(d *Dialog, withBack bool) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	if withBack {
+		row = append(row, callback.Button("⬅️ Назад", dialogCallback(d, "back")))
+	}
+	return append(row, callback.Button("❌ Отмена", dialogCallback(d, "cancel")))
+}
+
+// Данные кнопки диалога, аргумент: <диалог>:<действие>[:<значение>]
+func dialogCallback(d *Dialog, parts ...string) callback.Payload {
+	return callback.New(callback.Dialog).WithArg(d.Name + ":" + strings.Join(parts, ":"))
+}
+
+// Текущий шаг диалога
+func currentDialogStep(data map[string]string) int {
+	step, err := strconv.Atoi(data[dialogStepKey])
+	if err != nil || step < 0 {
+		return 0
+	}
+	return step
+}
+
+// Обработка текстового ответа в диалоге
+func handleDialogText(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, d *Dialog) {
+	userID := message.From.ID
+	data := getUserData(userID)
+	step := currentDialogStep(data)
+
+	if step >= len(d.Steps) {
+		sendMessage(bot, message.Chat.ID, "👆 Подтвердите или измените данные кнопками выше. Для отмены используйте /cancel")
+		return
+	}
+
+	current := d.Steps[step]
+	if current.Choices != nil && !current.AllowText {
+		sendMessage(bot, message.Chat.ID, "👆 Выберите вариант кнопками выше. Для отмены используйте /cancel")
+		return
+	}
+
+	acceptDialogAnswer(bot, db, message.Chat.ID, userID, d, step, strings.TrimSpace(message.Text), "")
+}
+
+// Проверка и сохранение ответа, переход к следующему шагу
+func acceptDialogAnswer(bot telegram.Messenger, db *sql.DB, chatID, userID int64, d *Dialog, step int, value, label string) {
+	current := d.Steps[step]
+	data := getUserData(userID)
+
+	if current.Validate != nil {
+		normalized, err := current.Validate(db, data, value)
+		if err != nil {
+			sendMessage(bot, chatID, "❌ "+err.Error())
+			return
+		}
+		value = normalized
+	}
+
+	if label == "" {
+		label = value
+	}
+
+	if err := setUserData(userID, current.Key, value); err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка сохранения ответа", "dialog", d.Name, "step", current.Key, "err", err)
+		sendMessage(bot, chatID, "❌ Время диалога истекло. Начните заново")
+		return
+	}
+	if err := setUserData(userID, current.Key+dialogLabelSuffix, label); err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка сохранения ответа", "dialog", d.Name, "step", current.Key, "err", err)
+	}
+
+	showDialogStep(bot, db, chatID, userID, d, step+1)
+}
+
+// Обработка кнопок диалога (выбор варианта, назад, отмена, подтверждение)
+func handleDialogCallback(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, payload callback.Payload, user *requestUser) {
+	chatID := query.Message.Chat.ID
+	userID := query.From.ID
+
+	parts := strings.SplitN(payload.Arg, ":", 3)
+	if len(parts) < 2 {
+		sendMessage(bot, chatID, "❌ Неверный формат команды")
+		return
+	}
+
+	// Кнопки устаревшего или чужого диалога игнорируются
+	d := dialogFromState(getUserState(userID))
+	if d == nil || d.Name != parts[0] {
+		sendMessage(bot, chatID, "⌛ Этот диалог уже завершен")
+		return
+	}
+
+	// Права могли быть отозваны, пока диалог был открыт
+	if !authorize(bot, chatID, user, d.Permission) {
+		resetUserState(userID)
+		return
+	}
+
+	data := getUserData(userID)
+	step := currentDialogStep(data)
+
+	switch parts[1] {
+	case "cancel":
+		resetUserState(userID)
+		bot.EditMessage(chatID, query.Message.MessageID, "❌ "+d.Title+": отменено", nil)
+
+	case "back":
+		if step > 0 {
+			step--
+		}
+		showDialogStep(bot, db, chatID, userID, d, step)
+
+	case "choice":
+		if len(parts) < 3 || step >= len(d.Steps) || d.Steps[step].Choices == nil {
+			sendMessage(bot, chatID, "⌛ Эта кнопка больше не активна")
+			return
+		}
+
+		// Значение должно быть среди актуальных вариантов текущего шага
+		choices, err := d.Steps[step].Choices(db, userID, data)
+		if err != nil {
+			slog.ErrorContext(telegram.Context(bot), "Ошибка получения вариантов шага", "dialog", d.Name, "step", d.Steps[step].Key, "err", err)
+			sendMessage(bot, chatID, "❌ Ошибка загрузки вариантов")
+			return
+		}
+		for _, choice := range choices {
+			if choice.Value == parts[2] {
+				acceptDialogAnswer(bot, db, chatID, userID, d, step, choice.Value, choice.Label)
+				return
+			}
+		}
+		sendMessage(bot, chatID, "⌛ Этот вариант больше недоступен")
+
+	case "confirm":
+		if step < len(d.Steps) {
+			sendMessage(bot, chatID, "⌛ Сначала ответьте на все вопросы")
+			return
+		}
+
+		if err := d.Submit(bot, db, chatID, userID, data); err != nil {
+			// Состояние сохраняется, чтобы можно было вернуться и исправить данные
+			slog.ErrorContext(telegram.Context(bot), "Ошибка выполнения диалога", "dialog", d.Name, "err", err)
+			sendMessage(bot, chatID, "❌ "+err.Error()+"\n\nИсправьте данные кнопкой «Назад» или отмените: /cancel")
+			return
+		}
+		resetUserState(userID)
+
+	default:
+		sendMessage(bot, chatID, "❌ Неизвестное действие диалога")
+	}
+}
+
+// Отображаемое значение ответа (подпись кнопки или введенный текст)
+func dialogLabel(data map[string]string, key string) string {
+	if label, ok := data[key+dialogLabelSuffix]; ok && label != "" {
+		return label
+	}
+	return data[key]
+}
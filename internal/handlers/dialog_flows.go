@@ -0,0 +1,403 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Экранирование пользовательских данных для Markdown
+func escapeMarkdown(text string) string {
+	return tgbotapi.EscapeText(tgbotapi.ModeMarkdown, text)
+}
+
+// ========================= СОЗДАНИЕ УРОКА =========================
+
+// Допустимый диапазон размера группы
+const (
+	minLessonStudents = 1
+	maxLessonStudents = 50
+)
+
+// Параметры урока по умолчанию для быстрых команд
+const (
+	// defaultLessonDuration - длительность урока (lessons.duration_minutes)
+	defaultLessonDuration = 90 * time.Minute
+	// defaultMaxStudents - размер группы
+	defaultMaxStudents = 10
+)
+
+var createLessonDialog = registerDialog(&Dialog{
+	Name:       "create_lesson",
+	Permission: auth.LessonCreate,
+	Title:      "Создание урока",
+	Steps: []DialogStep{
+		{
+			Key:     "subject_id",
+			Prompt:  "📚 Выберите предмет:",
+			Choices: subjectChoices,
+		},
+		{
+			Key:       "date",
+			Prompt:    "📅 Выберите дату или введите ее в формате ДД.ММ.ГГГГ:",
+			Choices:   upcomingDateChoices,
+			AllowText: true,
+			Validate:  validateLessonDate,
+		},
+		{
+			Key:      "time",
+			Prompt:   "🕐 Введите время начала в формате ЧЧ:ММ (например, 16:30):",
+			Validate: validateLessonTime,
+		},
+		{
+			Key:     "room_id",
+			Prompt:  "🚪 Выберите аудиторию (показаны свободные, с оборудованием для предмета):",
+			Choices: roomChoices,
+		},
+		{
+			Key:       "max_students",
+			Prompt:    fmt.Sprintf("👥 Выберите максимальное число студентов или введите число от %d до %d:", minLessonStudents, maxLessonStudents),
+			Choices:   maxStudentsChoices,
+			AllowText: true,
+			Validate:  validateMaxStudents,
+		},
+	},
+	Summary: func(db *sql.DB, data map[string]string) string {
+		return "📚 Предмет: " + escapeMarkdown(dialogLabel(data, "subject_id")) + "\n" +
+			"📅 Дата: " + data["date"] + "\n" +
+			"🕐 Время: " + data["time"] + "\n" +
+			"🚪 Аудитория: " + escapeMarkdown(dialogLabel(data, "room_id")) + "\n" +
+			"👥 Максимум студентов: " + data["max_students"]
+	},
+	Submit: submitCreateLessonDialog,
+})
+
+// Варианты предметов
+func subjectChoices(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error) {
+	subjects, err := repos(db).Subjects.List(true)
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []DialogChoice
+	for _, subject := range subjects {
+		choices = append(choices, DialogChoice{Value: strconv.Itoa(subject.ID), Label: subject.Name})
+	}
+	return choices, nil
+}
+
+// Ближайшие 7 дней для быстрого выбора даты
+func upcomingDateChoices(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error) {
+	weekdays := []string{"Вс", "Пн", "Вт", "Ср", "Чт", "Пт", "Сб"}
+
+	var choices []DialogChoice
+	today := time.Now()
+	for i := 0; i < 7; i++ {
+		day := today.AddDate(0, 0, i)
+		choices = append(choices, DialogChoice{
+			Value: day.Format("02.01.2006"),
+			Label: weekdays[day.Weekday()] + " " + day.Format("02.01"),
+		})
+	}
+	return choices, nil
+}
+
+func validateLessonDate(db *sql.DB, data map[string]string, input string) (string, error) {
+	// Дата проверяется вместе с полуднем, чтобы переиспользовать разбор даты урока
+	date, err := parseLessonDateTime(input, "12:00")
+	if err != nil {
+		return "", fmt.Errorf("неверный формат даты, используйте ДД.ММ.ГГГГ")
+	}
+
+	year, month, day := time.Now().Date()
+	if date.Before(time.Date(year, month, day, 0, 0, 0, 0, date.Location())) {
+		return "", fmt.Errorf("нельзя создать урок в прошлом")
+	}
+	return date.Format("02.01.2006"), nil
+}
+
+func validateLessonTime(db *sql.DB, data map[string]string, input string) (string, error) {
+	startTime, err := parseLessonDateTime(data["date"], input)
+	if err != nil {
+		return "", fmt.Errorf("неверный формат времени, используйте ЧЧ:ММ")
+	}
+	if startTime.Before(time.Now()) {
+		return "", fmt.Errorf("нельзя создать урок в прошлом")
+	}
+	return startTime.Format("15:04"), nil
+}
+
+// noRoomChoice - урок без аудитории
+const noRoomChoice = "0"
+
+// Свободные в выбранное время аудитории с оборудованием для предмета
+func roomChoices(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error) {
+	subjectID, _ := strconv.Atoi(data["subject_id"])
+	startTime, err := parseLessonDateTime(data["date"], data["time"])
+	if err != nil {
+		return nil, err
+	}
+	rooms, err := repos(db).Rooms.Available(subjectID, startTime, defaultLessonDuration)
+	if err != nil {
+		return nil, err
+	}
+
+	choices := []DialogChoice{{Value: noRoomChoice, Label: "Без аудитории"}}
+	for _, room := range rooms {
+		choices = append(choices, DialogChoice{
+			Value: strconv.Itoa(room.ID),
+			Label: fmt.Sprintf("%s (%d мест)", room.Name, room.Capacity),
+		})
+	}
+	return choices, nil
+}
+
+// dialogRoomCapacity - вместимость выбранной в диалоге аудитории (maxLessonStudents без аудитории)
+func dialogRoomCapacity(db *sql.DB, data map[string]string) (int, error) {
+	if data["room_id"] == "" || data["room_id"] == noRoomChoice {
+		return maxLessonStudents, nil
+	}
+	room, err := repos(db).Rooms.Find(data["room_id"])
+	if err != nil {
+		return 0, fmt.Errorf("аудитория больше недоступна, выберите другую")
+	}
+	return room.Capacity, nil
+}
+
+// Варианты размера группы в пределах вместимости аудитории
+func maxStudentsChoices(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error) {
+	capacity, err := dialogRoomCapacity(db, data)
+	if err != nil {
+		return nil, err
+	}
+	var choices []DialogChoice
+	for _, n := range []int{5, 10, 15} {
+		if n < capacity {
+			choices = append(choices, DialogChoice{strconv.Itoa(n), strconv.Itoa(n)})
+		}
+	}
+	return append(choices, DialogChoice{strconv.Itoa(min(capacity, 20)), strconv.Itoa(min(capacity, 20))}), nil
+}
+
+func validateMaxStudents(db *sql.DB, data map[string]string, input string) (string, error) {
+	maxStudents, err := strconv.Atoi(input)
+	if err != nil || maxStudents < minLessonStudents || maxStudents > maxLessonStudents {
+		return "", fmt.Errorf("введите число от %d до %d", minLessonStudents, maxLessonStudents)
+	}
+	capacity, err := dialogRoomCapacity(db, data)
+	if err != nil {
+		return "", err
+	}
+	if maxStudents > capacity {
+		return "", fmt.Errorf("в аудитории %d мест, введите число от %d до %d", capacity, minLessonStudents, capacity)
+	}
+	return strconv.Itoa(maxStudents), nil
+}
+
+func submitCreateLessonDialog(bot telegram.Messenger, db *sql.DB, chatID, userID int64, data map[string]string) error {
+	subjectID, err := strconv.Atoi(data["subject_id"])
+	if err != nil {
+		return fmt.Errorf("предмет не выбран")
+	}
+
+	startTime, err := parseLessonDateTime(data["date"], data["time"])
+	if err != nil {
+		return err
+	}
+	if startTime.Before(time.Now()) {
+		return fmt.Errorf("нельзя создать урок в прошлом")
+	}
+
+	maxStudents, err := strconv.Atoi(data["max_students"])
+	if err != nil {
+		return fmt.Errorf("некорректное число студентов")
+	}
+
+	teacherID, err := getTeacherID(db, int(userID))
+	if err != nil {
+		return fmt.Errorf("преподаватель не найден в системе")
+	}
+
+	// Аудитория могла быть изменена с момента выбора - проверяем заново
+	roomID, _ := strconv.Atoi(data["room_id"])
+	if roomID > 0 {
+		room, err := lessonRoom(repos(db), data["room_id"], subjectID)
+		if err != nil {
+			return err
+		}
+		if maxStudents > room.Capacity {
+			return fmt.Errorf("в аудитории %d мест", room.Capacity)
+		}
+	}
+
+	lessonID, err := repos(db).Lessons.Create(store.Lesson{
+		SubjectID:       subjectID,
+		TeacherID:       teacherID,
+		StartTime:       startTime,
+		DurationMinutes: int(defaultLessonDuration / time.Minute),
+		MaxStudents:     maxStudents,
+		RoomID:          roomID,
+	})
+	switch {
+	case errors.Is(err, store.ErrScheduleConflict):
+		return fmt.Errorf("у вас уже есть урок, пересекающийся с этим временем")
+	case errors.Is(err, store.ErrRoomConflict):
+		return fmt.Errorf("аудитория занята в это время, выберите другую")
+	case err != nil:
+		return fmt.Errorf("ошибка создания урока")
+	}
+
+	LogUserAction(telegram.Context(bot), db, "lesson_created", userID, fmt.Sprintf("Урок %d (%s) на %s",
+		lessonID, dialogLabel(data, "subject_id"), startTime.Format("02.01.2006 15:04")))
+	audit(bot, db, userID, auditLessonCreated, store.AuditLesson, lessonID, nil,
+		newLessonState(subjectID, teacherID, startTime, maxStudents, roomID))
+
+	successText := "✅ **Урок успешно создан!**\n\n" +
+		"📚 Предмет: " + escapeMarkdown(dialogLabel(data, "subject_id")) + "\n" +
+		"📅 Дата: " + startTime.Format("02.01.2006 15:04") + "\n" +
+		"🚪 Аудитория: " + escapeMarkdown(dialogLabel(data, "room_id")) + "\n" +
+		"👥 Максимум студентов: " + strconv.Itoa(maxStudents) + "\n\n" +
+		"Урок уже доступен для записи студентов!"
+
+	msg := tgbotapi.NewMessage(chatID, successText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+	return nil
+}
+
+// ========================= ДОБАВЛЕНИЕ ПРЕПОДАВАТЕЛЯ =========================
+
+var addTeacherDialog = registerDialog(&Dialog{
+	Name:       "add_teacher",
+	Permission: auth.TeacherManage,
+	Title:      "Добавление преподавателя",
+	Steps: []DialogStep{
+		{
+			Key: "tg_id",
+			Prompt: "🆔 Введите Telegram ID преподавателя\n\n" +
+				"💡 Узнать ID можно, попросив преподавателя написать боту @userinfobot",
+			Validate: validateNewTeacherTelegramID,
+		},
+		{
+			Key:    "full_name",
+			Prompt: "👤 Введите имя и фамилию преподавателя:",
+			Validate: func(db *sql.DB, data map[string]string, input string) (string, error) {
+				if err := validateFullName(input); err != nil {
+					return "", err
+				}
+				return input, nil
+			},
+		},
+	},
+	Summary: func(db *sql.DB, data map[string]string) string {
+		return "👤 Имя: " + escapeMarkdown(data["full_name"]) + "\n" +
+			"🆔 Telegram ID: " + data["tg_id"]
+	},
+	Submit: func(bot telegram.Messenger, db *sql.DB, chatID, userID int64, data map[string]string) error {
+		teacherID, err := createTeacher(db, data["tg_id"], data["full_name"])
+		if err != nil {
+			return err
+		}
+		audit(bot, db, userID, auditTeacherAdded, store.AuditTeacher, teacherID, nil, teacherState(data["tg_id"], data["full_name"], false))
+
+		LogUserAction(telegram.Context(bot), db, "teacher_added", userID, fmt.Sprintf("Преподаватель %s (tg_id %s)", data["full_name"], data["tg_id"]))
+		sendTeacherAddedMessage(bot, chatID, data["tg_id"], data["full_name"])
+		return nil
+	},
+})
+
+func validateNewTeacherTelegramID(db *sql.DB, data map[string]string, input string) (string, error) {
+	tgID, err := strconv.ParseInt(input, 10, 64)
+	if err != nil || tgID <= 0 {
+		return "", fmt.Errorf("некорректный Telegram ID, введите число")
+	}
+
+	exists, err := repos(db).Users.Exists(tgID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка проверки пользователя")
+	}
+	if exists {
+		return "", fmt.Errorf("пользователь с таким Telegram ID уже существует")
+	}
+	return strconv.FormatInt(tgID, 10), nil
+}
+
+// ========================= УВЕДОМЛЕНИЕ СТУДЕНТОВ УРОКА =========================
+
+// Максимальная длина уведомления
+const maxNotificationLength = 1000
+
+var notifyStudentsDialog = registerDialog(&Dialog{
+	Name:       "notify_students",
+	Permission: auth.NotifyStudents,
+	Title:      "Уведомление студентов урока",
+	Steps: []DialogStep{
+		{
+			Key:       "lesson_id",
+			Prompt:    "📚 Выберите урок или введите его ID:",
+			Choices:   upcomingLessonChoices,
+			AllowText: true,
+			Validate: func(db *sql.DB, data map[string]string, input string) (string, error) {
+				lessonID, err := strconv.Atoi(input)
+				if err != nil {
+					return "", fmt.Errorf("некорректный ID урока")
+				}
+				if _, err := getLessonForNotification(db, lessonID); err != nil {
+					return "", fmt.Errorf("урок не найден")
+				}
+				return strconv.Itoa(lessonID), nil
+			},
+		},
+		{
+			Key:    "text",
+			Prompt: "💬 Введите текст уведомления:",
+			Validate: func(db *sql.DB, data map[string]string, input string) (string, error) {
+				if input == "" {
+					return "", fmt.Errorf("сообщение не может быть пустым")
+				}
+				if len([]rune(input)) > maxNotificationLength {
+					return "", fmt.Errorf("сообщение не должно превышать %d символов", maxNotificationLength)
+				}
+				return input, nil
+			},
+		},
+	},
+	Summary: func(db *sql.DB, data map[string]string) string {
+		return "📚 Урок: " + escapeMarkdown(dialogLabel(data, "lesson_id")) + "\n" +
+			"💬 Сообщение: " + escapeMarkdown(data["text"])
+	},
+	Submit: func(bot telegram.Messenger, db *sql.DB, chatID, userID int64, data map[string]string) error {
+		lessonID, err := strconv.Atoi(data["lesson_id"])
+		if err != nil {
+			return fmt.Errorf("урок не выбран")
+		}
+		return sendLessonNotification(bot, db, chatID, userID, lessonID, data["text"])
+	},
+})
+
+// Ближайшие активные уроки для выбора
+func upcomingLessonChoices(db *sql.DB, userID int64, data map[string]string) ([]DialogChoice, error) {
+	// Десять ближайших уроков; горизонт в год только ограничивает интервал выборки
+	now := time.Now()
+	lessons, err := repos(db).Lessons.Upcoming(now, now.AddDate(1, 0, 0), 10)
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []DialogChoice
+	for _, lesson := range lessons {
+		choices = append(choices, DialogChoice{
+			Value: strconv.Itoa(lesson.ID),
+			Label: fmt.Sprintf("%s, %s (👥 %d)", lesson.SubjectName, lesson.StartTime.Format("02.01 15:04"), lesson.EnrolledCount),
+		})
+	}
+	return choices, nil
+}
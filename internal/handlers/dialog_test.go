@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/callback"
+)
+
+// Тест определения диалога по состоянию FSM
+func TestDialogFromState(t *testing.T) {
+	if d := dialogFromState(UserState(dialogStatePrefix + "create_lesson")); d != createLessonDialog {
+		t.Errorf("Expected create_lesson dialog, got %v", d)
+	}
+	if d := dialogFromState(UserState(dialogStatePrefix + "unknown")); d != nil {
+		t.Errorf("Expected nil for unknown dialog, got %s", d.Name)
+	}
+	if d := dialogFromState(StateWaitingName); d != nil {
+		t.Errorf("Expected nil for registration state, got %s", d.Name)
+	}
+}
+
+// Тест callback данных диалогов (лимит Telegram - 64 байта)
+func TestDialogCallbackDataLength(t *testing.T) {
+	for name, d := range dialogRegistry {
+		if _, err := callback.Encode(dialogCallback(d, "choice", "2147483647")); err != nil {
+			t.Errorf("Callback data for dialog %s is invalid: %v", name, err)
+		}
+	}
+}
+
+// Тест валидаторов шагов создания урока
+func TestCreateLessonValidators(t *testing.T) {
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("02.01.2006")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("02.01.2006")
+
+	if _, err := validateLessonDate(nil, nil, tomorrow); err != nil {
+		t.Errorf("Unexpected error for date %s: %v", tomorrow, err)
+	}
+	if _, err := validateLessonDate(nil, nil, yesterday); err == nil {
+		t.Errorf("Expected error for past date %s", yesterday)
+	}
+	if _, err := validateLessonDate(nil, nil, "32.13.2025"); err == nil {
+		t.Error("Expected error for invalid date")
+	}
+
+	data := map[string]string{"date": tomorrow}
+	if value, err := validateLessonTime(nil, data, "9:05"); err != nil || value != "09:05" {
+		t.Errorf("Expected normalized time 09:05, got '%s' (%v)", value, err)
+	}
+	if _, err := validateLessonTime(nil, data, "25:00"); err == nil {
+		t.Error("Expected error for invalid time")
+	}
+
+	for _, input := range []string{"0", "51", "abc"} {
+		if _, err := validateMaxStudents(nil, nil, input); err == nil {
+			t.Errorf("Expected error for max students '%s'", input)
+		}
+	}
+	if value, err := validateMaxStudents(nil, nil, "12"); err != nil || value != "12" {
+		t.Errorf("Expected 12, got '%s' (%v)", value, err)
+	}
+}
+
+// Тест хранилища состояний в памяти
+func TestMemoryStateStore(t *testing.T) {
+	store := NewMemoryStateStore(50 * time.Millisecond)
+	userID := int64(42)
+
+	if err := store.SetData(userID, "key", "value"); err == nil {
+		t.Error("Expected error when setting data without active state")
+	}
+
+	store.SetState(userID, StateWaitingName)
+	if err := store.SetData(userID, "full_name", "Иван Иванов"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	state, data, _ := store.GetState(userID)
+	if state != StateWaitingName || data["full_name"] != "Иван Иванов" {
+		t.Errorf("Unexpected state %s with data %v", state, data)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if state, _, _ := store.GetState(userID); state != StateIdle {
+		t.Errorf("Expected expired state to be idle, got %s", state)
+	}
+}
@@ -1,14 +1,19 @@
 package handlers
 
 import (
+"context"
 "database/sql"
+"errors"
 "fmt"
-"log"
-"strconv"
+"log/slog"
 "strings"
 "unicode"
 
 tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+"constellation-school-bot/internal/auth"
+"constellation-school-bot/internal/store"
+"constellation-school-bot/internal/telegram"
 )
 
 // FSM состояния регистрации
@@ -21,85 +26,101 @@ StateWaitingPhone UserState = "waiting_phone"
 StateRegistered  UserState = "registered"
 )
 
-// Хранилище состояний (в продакшене - Redis)
-var userStates = make(map[int64]UserState)
-var userData = make(map[int64]map[string]interface{})
+// Хранилище состояний (Postgres или Redis, задается в main через InitializeStateStore)
+var stateStore StateStore = NewMemoryStateStore(stateTTL)
 
 // Timeout для состояний (в минутах)
 const StateTimeoutMinutes = 15
 
+// InitializeStateStore - устанавливает хранилище состояний FSM
+func InitializeStateStore(ctx context.Context, store StateStore) {
+	stateStore = store
+	if pgStore, ok := store.(*PostgresStateStore); ok {
+		pgStore.StartCleanupWorker(ctx)
+	}
+	slog.Info("Хранилище состояний FSM инициализировано", "store", fmt.Sprintf("%T", store))
+}
+
 // Получить состояние пользователя
 func getUserState(userID int64) UserState {
-	if state, exists := userStates[userID]; exists {
-		return state
+	state, _, err := stateStore.GetState(userID)
+	if err != nil {
+		slog.Error("Ошибка получения состояния пользователя", "tg_id", userID, "err", err)
+		return StateIdle
 	}
-	return StateIdle
+	return state
 }
 
 // Установить состояние пользователя
 func setUserState(userID int64, state UserState) {
-	userStates[userID] = state
+	if err := stateStore.SetState(userID, state); err != nil {
+		slog.Error("Ошибка установки состояния пользователя", "tg_id", userID, "err", err)
+	}
 }
 
 // Сбросить состояние пользователя
 func resetUserState(userID int64) {
-	delete(userStates, userID)
-	delete(userData, userID)
+	if err := stateStore.Reset(userID); err != nil {
+		slog.Error("Ошибка сброса состояния пользователя", "tg_id", userID, "err", err)
+	}
+}
+
+// Получить данные диалога пользователя
+func getUserData(userID int64) map[string]string {
+	_, data, err := stateStore.GetState(userID)
+	if err != nil {
+		slog.Error("Ошибка получения данных пользователя", "tg_id", userID, "err", err)
+		return map[string]string{}
+	}
+	return data
+}
+
+// Сохранить значение в данных диалога пользователя
+func setUserData(userID int64, key, value string) error {
+	return stateStore.SetData(userID, key, value)
 }
 
 // Команда /start
-func handleStart(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	var existingUser int
-	err := db.QueryRow("SELECT id FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&existingUser)
-	
-	if err == sql.ErrNoRows {
+func handleStart(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	if user.Account == nil {
 		sendMessage(bot, message.Chat.ID, 
 "👋 Добро пожаловать в Constellation School!\n\n"+
 "Для начала работы зарегистрируйтесь командой /register")
-	} else if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка проверки регистрации")
+		return
+	}
+	
+	// Показываем главное меню в зависимости от роли
+	if user.Role == auth.Student {
+		showStudentMainMenu(bot, message, db)
 	} else {
-		// Показываем главное меню в зависимости от роли
-		var role string
-		db.QueryRow("SELECT role FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&role)
-		
-		if role == "student" {
-			showStudentMainMenu(bot, message, db)
-		} else {
-			handleMainMenu(bot, message, db)
-		}
+		handleMainMenu(bot, message, db, user)
 	}
 }
 
 // Команда /register
-func handleRegister(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleRegister(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	
 	// Проверяем, не зарегистрирован ли уже пользователь
-	var existingUser int
-	err := db.QueryRow("SELECT id FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&existingUser)
-	if err == nil {
+	if exists, err := repos(db).Users.Exists(userID); err == nil && exists {
 		sendMessage(bot, message.Chat.ID, "✅ Вы уже зарегистрированы в системе!")
 		return
 	}
 	
 	setUserState(userID, StateWaitingName)
 	
-	if userData[userID] == nil {
-		userData[userID] = make(map[string]interface{})
-	}
-	
 	sendMessage(bot, message.Chat.ID, "📝 Введите ваше полное имя:\n\n💡 Для отмены регистрации используйте команду /cancel")
 }
 
-// Команда /cancel - отмена регистрации
-func handleCancel(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
+// Команда /cancel - отмена регистрации или активного диалога
+func handleCancel(bot telegram.Messenger, message *tgbotapi.Message) {
 	userID := message.From.ID
 	state := getUserState(userID)
 	
-	if state != StateIdle {
+	if d := dialogFromState(state); d != nil {
+		resetUserState(userID)
+		sendMessage(bot, message.Chat.ID, "❌ "+d.Title+": отменено")
+	} else if state != StateIdle {
 		resetUserState(userID)
 		sendMessage(bot, message.Chat.ID, "❌ Регистрация отменена. Для начала регистрации используйте /register")
 	} else {
@@ -108,39 +129,34 @@ func handleCancel(bot *tgbotapi.BotAPI, message *tgbotapi.Message) {
 }
 
 // Обработка текстовых сообщений через FSM
-func handleTextMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleTextMessage(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
 	userID := message.From.ID
 	state := getUserState(userID)
 	
+	// Активный пошаговый диалог обрабатывает текст сам
+	if d := dialogFromState(state); d != nil {
+		// Права могли быть отозваны, пока диалог был открыт
+		if !authorize(bot, message.Chat.ID, user, d.Permission) {
+			resetUserState(userID)
+			return
+		}
+		handleDialogText(bot, message, db, d)
+		return
+	}
+	
 	switch state {
 	case StateWaitingName:
-		// Улучшенная валидация имени
 		fullName := strings.TrimSpace(message.Text)
-		if len(fullName) < 2 {
-			sendMessage(bot, message.Chat.ID, "❌ Имя должно содержать минимум 2 символа")
+		if err := validateFullName(fullName); err != nil {
+			sendMessage(bot, message.Chat.ID, "❌ "+err.Error())
 			return
 		}
 		
-		if len(fullName) > 100 {
-			sendMessage(bot, message.Chat.ID, "❌ Имя не должно превышать 100 символов")
+		if err := setUserData(userID, "full_name", fullName); err != nil {
+			slog.ErrorContext(telegram.Context(bot), "Ошибка сохранения имени", "err", err)
+			sendMessage(bot, message.Chat.ID, "❌ Время регистрации истекло. Начните заново: /register")
 			return
 		}
-		
-		// Проверяем, что имя содержит хотя бы одну букву
-		hasLetter := false
-		for _, r := range fullName {
-			if unicode.IsLetter(r) {
-				hasLetter = true
-				break
-			}
-		}
-		
-		if !hasLetter {
-			sendMessage(bot, message.Chat.ID, "❌ Имя должно содержать хотя бы одну букву")
-			return
-		}
-		
-		userData[userID]["full_name"] = fullName
 		setUserState(userID, StateWaitingPhone)
 		sendMessage(bot, message.Chat.ID, "📱 Введите ваш номер телефона (формат: +79001234567):")
 		
@@ -158,13 +174,17 @@ func handleTextMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.
 			return
 		}
 		
-		userData[userID]["phone"] = phone
+		if err := setUserData(userID, "phone", phone); err != nil {
+			slog.ErrorContext(telegram.Context(bot), "Ошибка сохранения телефона", "err", err)
+			sendMessage(bot, message.Chat.ID, "❌ Время регистрации истекло. Начните заново: /register")
+			return
+		}
 		
 		// Завершение регистрации
-		err := finishRegistration(userID, message.Chat.ID, db)
+		err := finishRegistration(bot, userID, message.Chat.ID, db)
 		if err != nil {
 			sendMessage(bot, message.Chat.ID, "❌ Ошибка регистрации")
-			log.Printf("Ошибка регистрации: %v", err)
+			slog.ErrorContext(telegram.Context(bot), "Ошибка регистрации", "err", err)
 		} else {
 			sendMessage(bot, message.Chat.ID, "✅ Регистрация завершена! Используйте /help для просмотра команд")
 		}
@@ -175,83 +195,67 @@ func handleTextMessage(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.
 	}
 }
 
-// Завершение регистрации
-func finishRegistration(userID int64, chatID int64, db *sql.DB) error {
-	// Проверяем наличие данных
-	if userData[userID] == nil {
-		return fmt.Errorf("пользовательские данные не найдены")
+// Валидация полного имени (регистрация и добавление преподавателя)
+func validateFullName(fullName string) error {
+	if len(fullName) < 2 {
+		return fmt.Errorf("имя должно содержать минимум 2 символа")
 	}
 	
-	fullNameInterface, ok := userData[userID]["full_name"]
-	if !ok {
-		return fmt.Errorf("имя пользователя не указано")
+	if len(fullName) > 100 {
+		return fmt.Errorf("имя не должно превышать 100 символов")
 	}
 	
-	phoneInterface, ok := userData[userID]["phone"]
-	if !ok {
-		return fmt.Errorf("телефон пользователя не указан")
+	// Проверяем, что имя содержит хотя бы одну букву
+	for _, r := range fullName {
+		if unicode.IsLetter(r) {
+			return nil
+		}
 	}
+	return fmt.Errorf("имя должно содержать хотя бы одну букву")
+}
+
+// Завершение регистрации
+func finishRegistration(bot telegram.Messenger, userID int64, chatID int64, db *sql.DB) error {
+	// Проверяем наличие данных
+	data := getUserData(userID)
 	
-	fullName, ok := fullNameInterface.(string)
-	if !ok {
-		return fmt.Errorf("некорректное имя пользователя")
+	fullName, ok := data["full_name"]
+	if !ok || fullName == "" {
+		return fmt.Errorf("имя пользователя не указано")
 	}
 	
-	phone, ok := phoneInterface.(string)
-	if !ok {
-		return fmt.Errorf("некорректный номер телефона")
+	phone, ok := data["phone"]
+	if !ok || phone == "" {
+		return fmt.Errorf("телефон пользователя не указан")
 	}
 	
 	// Проверяем, не существует ли уже пользователь с таким tg_id
-	var existingCount int
-	err := db.QueryRow("SELECT COUNT(*) FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&existingCount)
+	exists, err := repos(db).Users.Exists(userID)
 	if err != nil {
-		log.Printf("Ошибка проверки существующего пользователя: %v", err)
+		slog.Error("Ошибка проверки существующего пользователя", "err", err)
 		return fmt.Errorf("ошибка проверки пользователя")
 	}
 	
-	if existingCount > 0 {
+	if exists {
 		return fmt.Errorf("пользователь уже зарегистрирован")
 	}
 	
-	// Начинаем транзакцию
-	tx, err := db.Begin()
-	if err != nil {
-		log.Printf("Ошибка начала транзакции: %v", err)
-		return fmt.Errorf("ошибка базы данных")
-	}
-	defer tx.Rollback()
-	
-	// Создаем пользователя
-	var userRecordID int
-	err = tx.QueryRow(`
-		INSERT INTO users (tg_id, role, full_name, phone, is_active, created_at) 
-		VALUES ($1, $2, $3, $4, $5, NOW()) 
-		RETURNING id`,
-		strconv.FormatInt(userID, 10), "student", fullName, phone, true).Scan(&userRecordID)
-	if err != nil {
-		log.Printf("Ошибка создания пользователя: %v", err)
-		return fmt.Errorf("ошибка создания пользователя")
-	}
-	
-	// Создание записи студента
-	_, err = tx.Exec("INSERT INTO students (user_id, created_at) VALUES ($1, NOW())", userRecordID)
-	if err != nil {
-		log.Printf("Ошибка создания записи студента: %v", err)
+	// Создаем пользователя и запись студента
+	userRecordID, err := repos(db).Students.Register(userID, fullName, phone)
+	if errors.Is(err, store.ErrUserExists) {
+		return fmt.Errorf("пользователь уже зарегистрирован")
+	} else if err != nil {
+		slog.Error("Ошибка создания студента", "err", err)
 		return fmt.Errorf("ошибка создания студента")
 	}
 	
-	// Подтверждаем транзакцию
-	if err = tx.Commit(); err != nil {
-		log.Printf("Ошибка подтверждения транзакции: %v", err)
-		return fmt.Errorf("ошибка сохранения данных")
-	}
-	
 	// Очищаем временные данные после успешной регистрации
-	delete(userData, userID)
+	resetUserState(userID)
 	
-	// Логируем успешную регистрацию
-	log.Printf("Пользователь %d (%s) успешно зарегистрирован", userID, fullName)
+	// Логируем успешную регистрацию (телефон в журнал аудита не попадает)
+	slog.InfoContext(telegram.Context(bot), "Пользователь зарегистрирован", "tg_id", userID)
+	audit(bot, db, userID, auditUserRegistered, store.AuditUser, userRecordID, nil,
+		auditState{"tg_id": userID, "full_name": fullName, "role": "student", "is_active": true})
 	
 	return nil
 }
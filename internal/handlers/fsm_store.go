@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"constellation-school-bot/internal/config"
+	"constellation-school-bot/internal/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StateStore - хранилище состояний FSM с ограниченным временем жизни
+type StateStore interface {
+	// GetState возвращает состояние пользователя и данные диалога (StateIdle, если состояние истекло)
+	GetState(userID int64) (UserState, map[string]string, error)
+	// SetState устанавливает состояние и продлевает TTL
+	SetState(userID int64, state UserState) error
+	// SetData сохраняет значение в данных диалога и продлевает TTL
+	SetData(userID int64, key, value string) error
+	// Reset удаляет состояние и данные пользователя
+	Reset(userID int64) error
+}
+
+// Время жизни состояния FSM
+var stateTTL = time.Duration(StateTimeoutMinutes) * time.Minute
+
+// NewStateStore - создает хранилище состояний по конфигурации (postgres или redis)
+func NewStateStore(cfg *config.Config, db *sql.DB) (StateStore, error) {
+	switch cfg.FSMStorage {
+	case "redis":
+		return NewRedisStateStore(cfg.RedisHost, cfg.RedisPort, cfg.RedisPassword, cfg.RedisDB, stateTTL)
+	case "postgres", "":
+		return NewPostgresStateStore(repos(db).FSMStates, stateTTL), nil
+	default:
+		return nil, fmt.Errorf("неизвестное хранилище состояний FSM: %s", cfg.FSMStorage)
+	}
+}
+
+// ========================= POSTGRES =========================
+
+// PostgresStateStore - хранение состояний FSM в таблице fsm_states
+type PostgresStateStore struct {
+	states store.FSMStateRepository
+	ttl    time.Duration
+}
+
+// NewPostgresStateStore - создает хранилище состояний в PostgreSQL
+func NewPostgresStateStore(states store.FSMStateRepository, ttl time.Duration) *PostgresStateStore {
+	return &PostgresStateStore{states: states, ttl: ttl}
+}
+
+func (s *PostgresStateStore) GetState(userID int64) (UserState, map[string]string, error) {
+	state, data, err := s.states.Get(userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return StateIdle, map[string]string{}, nil
+	} else if err != nil {
+		return StateIdle, nil, fmt.Errorf("ошибка чтения состояния FSM: %w", err)
+	}
+	return UserState(state), data, nil
+}
+
+func (s *PostgresStateStore) SetState(userID int64, state UserState) error {
+	// Истекшее состояние перезаписывается вместе с данными
+	if err := s.states.SetState(userID, string(state), s.ttl); err != nil {
+		return fmt.Errorf("ошибка сохранения состояния FSM: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStateStore) SetData(userID int64, key, value string) error {
+	err := s.states.SetData(userID, key, value, s.ttl)
+	if errors.Is(err, store.ErrNotFound) {
+		return fmt.Errorf("нет активного состояния FSM для пользователя %d", userID)
+	} else if err != nil {
+		return fmt.Errorf("ошибка сохранения данных FSM: %w", err)
+	}
+	return nil
+}
+
+func (s *PostgresStateStore) Reset(userID int64) error {
+	if err := s.states.Reset(userID); err != nil {
+		return fmt.Errorf("ошибка сброса состояния FSM: %w", err)
+	}
+	return nil
+}
+
+// CleanupExpiredStates - удаляет истекшие состояния
+func (s *PostgresStateStore) CleanupExpiredStates() error {
+	rowsAffected, err := s.states.Expire()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected > 0 {
+		slog.Info("Очищены истекшие состояния FSM", "count", rowsAffected)
+	}
+	return nil
+}
+
+// Периодическая очистка истекших состояний (до отмены ctx)
+func (s *PostgresStateStore) StartCleanupWorker(ctx context.Context) {
+	backgroundWorkers.Add(1)
+	go func() {
+		defer backgroundWorkers.Done()
+		ticker := time.NewTicker(time.Duration(StateTimeoutMinutes) * time.Minute)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := s.CleanupExpiredStates(); err != nil {
+					slog.Error("Ошибка очистки состояний FSM", "err", err)
+				}
+			}
+		}
+	}()
+}
+
+// ========================= REDIS =========================
+
+// RedisStateStore - хранение состояний FSM в Redis (хэш fsm:<tg_id> с EXPIRE)
+type RedisStateStore struct {
+	client *redis.Client
+	ttl    time.Duration
+}
+
+const (
+	redisStateField      = "state"
+	redisDataFieldPrefix = "data:"
+	redisTimeout         = 3 * time.Second
+)
+
+// NewRedisStateStore - создает хранилище состояний в Redis и проверяет подключение
+func NewRedisStateStore(host, port, password string, db int, ttl time.Duration) (*RedisStateStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(host, port),
+		Password: password,
+		DB:       db,
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("ошибка подключения к Redis: %w", err)
+	}
+
+	return &RedisStateStore{client: client, ttl: ttl}, nil
+}
+
+func redisStateKey(userID int64) string {
+	return fmt.Sprintf("fsm:%d", userID)
+}
+
+func (s *RedisStateStore) GetState(userID int64) (UserState, map[string]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	fields, err := s.client.HGetAll(ctx, redisStateKey(userID)).Result()
+	if err != nil {
+		return StateIdle, nil, fmt.Errorf("ошибка чтения состояния FSM: %w", err)
+	}
+
+	state, ok := fields[redisStateField]
+	if !ok {
+		return StateIdle, map[string]string{}, nil
+	}
+
+	data := map[string]string{}
+	for field, value := range fields {
+		if strings.HasPrefix(field, redisDataFieldPrefix) {
+			data[strings.TrimPrefix(field, redisDataFieldPrefix)] = value
+		}
+	}
+
+	return UserState(state), data, nil
+}
+
+func (s *RedisStateStore) SetState(userID int64, state UserState) error {
+	return s.write(userID, redisStateField, string(state), false)
+}
+
+func (s *RedisStateStore) SetData(userID int64, key, value string) error {
+	return s.write(userID, redisDataFieldPrefix+key, value, true)
+}
+
+// redisWriteScript - запись поля хэша и продление TTL; с флагом require
+// запись выполняется только при наличии состояния (0 - состояния нет)
+var redisWriteScript = redis.NewScript(`
+if ARGV[4] == "1" and redis.call("HEXISTS", KEYS[1], ARGV[5]) == 0 then
+	return 0
+end
+redis.call("HSET", KEYS[1], ARGV[1], ARGV[2])
+redis.call("PEXPIRE", KEYS[1], ARGV[3])
+return 1`)
+
+// write - записывает поле хэша и продлевает TTL атомарно (Lua-скрипт)
+func (s *RedisStateStore) write(userID int64, field, value string, requireState bool) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	require := "0"
+	if requireState {
+		require = "1"
+	}
+
+	written, err := redisWriteScript.Run(ctx, s.client, []string{redisStateKey(userID)},
+		field, value, s.ttl.Milliseconds(), require, redisStateField).Int()
+	if err != nil {
+		return fmt.Errorf("ошибка сохранения состояния FSM: %w", err)
+	}
+	if written == 0 {
+		return fmt.Errorf("нет активного состояния FSM для пользователя %d", userID)
+	}
+	return nil
+}
+
+func (s *RedisStateStore) Reset(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), redisTimeout)
+	defer cancel()
+
+	if err := s.client.Del(ctx, redisStateKey(userID)).Err(); err != nil {
+		return fmt.Errorf("ошибка сброса состояния FSM: %w", err)
+	}
+	return nil
+}
+
+// Close - закрывает подключение к Redis
+func (s *RedisStateStore) Close() error {
+	return s.client.Close()
+}
+
+// ========================= MEMORY =========================
+
+// MemoryStateStore - потокобезопасное хранилище в памяти (для тестов и запуска без БД)
+type MemoryStateStore struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	now     func() time.Time
+	entries map[int64]*memoryStateEntry
+}
+
+type memoryStateEntry struct {
+	state     UserState
+	data      map[string]string
+	expiresAt time.Time
+}
+
+// NewMemoryStateStore - создает хранилище состояний в памяти
+func NewMemoryStateStore(ttl time.Duration) *MemoryStateStore {
+	return &MemoryStateStore{ttl: ttl, now: time.Now, entries: make(map[int64]*memoryStateEntry)}
+}
+
+// entry - возвращает неистекшую запись (вызывается под блокировкой)
+func (s *MemoryStateStore) entry(userID int64) *memoryStateEntry {
+	e, ok := s.entries[userID]
+	if !ok {
+		return nil
+	}
+	if s.now().After(e.expiresAt) {
+		delete(s.entries, userID)
+		return nil
+	}
+	return e
+}
+
+func (s *MemoryStateStore) GetState(userID int64) (UserState, map[string]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(userID)
+	if e == nil {
+		return StateIdle, map[string]string{}, nil
+	}
+
+	data := make(map[string]string, len(e.data))
+	for k, v := range e.data {
+		data[k] = v
+	}
+	return e.state, data, nil
+}
+
+func (s *MemoryStateStore) SetState(userID int64, state UserState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(userID)
+	if e == nil {
+		e = &memoryStateEntry{data: make(map[string]string)}
+		s.entries[userID] = e
+	}
+	e.state = state
+	e.expiresAt = s.now().Add(s.ttl)
+	return nil
+}
+
+func (s *MemoryStateStore) SetData(userID int64, key, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e := s.entry(userID)
+	if e == nil {
+		return fmt.Errorf("нет активного состояния FSM для пользователя %d", userID)
+	}
+	e.data[key] = value
+	e.expiresAt = s.now().Add(s.ttl)
+	return nil
+}
+
+func (s *MemoryStateStore) Reset(userID int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.entries, userID)
+	return nil
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+)
+
+// Тест истечения состояний в памяти: после TTL пользователь снова в StateIdle
+func TestMemoryStateStoreExpiry(t *testing.T) {
+	start := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		elapsed   time.Duration
+		wantState UserState
+		wantData  string
+	}{
+		{"before ttl", 29 * time.Minute, StateWaitingName, "Иван"},
+		{"at ttl", 30 * time.Minute, StateWaitingName, "Иван"},
+		{"after ttl", 31 * time.Minute, StateIdle, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			s := NewMemoryStateStore(30 * time.Minute)
+			s.now = func() time.Time { return now }
+
+			if err := s.SetState(1, StateWaitingName); err != nil {
+				t.Fatalf("SetState: %v", err)
+			}
+			if err := s.SetData(1, "name", "Иван"); err != nil {
+				t.Fatalf("SetData: %v", err)
+			}
+
+			now = start.Add(tt.elapsed)
+			state, data, err := s.GetState(1)
+			if err != nil {
+				t.Fatalf("GetState: %v", err)
+			}
+			if state != tt.wantState {
+				t.Errorf("Expected state %s, got %s", tt.wantState, state)
+			}
+			if data["name"] != tt.wantData {
+				t.Errorf("Expected data '%s', got '%s'", tt.wantData, data["name"])
+			}
+		})
+	}
+}
+
+// Тест продления TTL при записи данных
+func TestMemoryStateStoreSetDataExtendsTTL(t *testing.T) {
+	now := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+	s := NewMemoryStateStore(30 * time.Minute)
+	s.now = func() time.Time { return now }
+
+	if err := s.SetState(1, StateWaitingName); err != nil {
+		t.Fatalf("SetState: %v", err)
+	}
+	now = now.Add(20 * time.Minute)
+	if err := s.SetData(1, "name", "Иван"); err != nil {
+		t.Fatalf("SetData: %v", err)
+	}
+	now = now.Add(20 * time.Minute)
+
+	if state, _, _ := s.GetState(1); state != StateWaitingName {
+		t.Errorf("Expected state to be extended by SetData, got %s", state)
+	}
+}
+
+// Тест записи данных без активного состояния: ошибка, состояние не создается
+func TestMemoryStateStoreSetDataWithoutState(t *testing.T) {
+	start := time.Date(2025, 9, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		setup func(s *MemoryStateStore, now *time.Time)
+	}{
+		{"never set", func(s *MemoryStateStore, now *time.Time) {}},
+		{"reset", func(s *MemoryStateStore, now *time.Time) {
+			s.SetState(1, StateWaitingName)
+			s.Reset(1)
+		}},
+		{"expired", func(s *MemoryStateStore, now *time.Time) {
+			s.SetState(1, StateWaitingName)
+			*now = now.Add(time.Hour)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			now := start
+			s := NewMemoryStateStore(30 * time.Minute)
+			s.now = func() time.Time { return now }
+			tt.setup(s, &now)
+
+			if err := s.SetData(1, "name", "Иван"); err == nil {
+				t.Error("Expected error for SetData without active state")
+			}
+			state, data, err := s.GetState(1)
+			if err != nil {
+				t.Fatalf("GetState: %v", err)
+			}
+			if state != StateIdle || len(data) != 0 {
+				t.Errorf("Expected idle state without data, got %s %v", state, data)
+			}
+		})
+	}
+}
@@ -4,45 +4,160 @@ import (
 	"database/sql"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/telegram"
 )
 
-// Основной обработчик обновлений
-func HandleUpdate(bot *tgbotapi.BotAPI, update tgbotapi.Update, db *sql.DB) {
-	if update.Message != nil {
-		if update.Message.IsCommand() {
-			handleCommand(bot, update.Message, db)
-		} else {
-			// Обработка текста через FSM
-			handleTextMessage(bot, update.Message, db)
-		}
-	} else if update.CallbackQuery != nil {
-		handleNewCallbackQuery(bot, update.CallbackQuery, db)
+// Основной обработчик обновлений: маршрут определяется до middleware,
+// чтобы права, rate limiting, логи и метрики знали, что будет выполнено
+func HandleUpdate(bot telegram.Messenger, update tgbotapi.Update, db *sql.DB) {
+	req := routeUpdate(update)
+	if req == nil {
+		return
 	}
+	chain(runRoute, updateMiddleware...)(bot, req, db)
 }
 
-// Маршрутизация команд
-func handleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	switch message.Command() {
-	case "start":
-		handleStart(bot, message, db)
-	case "menu":
-		handleMainMenu(bot, message, db)
-	case "register":
-		handleRegister(bot, message, db)
-	case "cancel":
-		handleCancel(bot, message)
-	case "help":
-		handleHelp(bot, message, db)
-	case "subjects", "schedule", "enroll", "waitlist", "my_lessons":
-		handleStudentCommand(bot, message, db)
-	case "create_lesson", "reschedule_lesson", "cancel_lesson", "help_teacher", "my_schedule", "my_students":
-		handleTeacherCommand(bot, message, db)
-	case "add_teacher", "delete_teacher", "notify_students", "cancel_with_notification", "reschedule_with_notify", "list_teachers", "restore_lesson", "restore_teacher", "rate_limit_stats", "stats", "log_recent_errors", "delete_lesson", "notify_all", "remind_all", "deactivate_student", "activate_student":
-		handleAdminCommand(bot, message, db)
-	default:
-		sendMessage(bot, message.Chat.ID, 
-			"❓ Неизвестная команда. Используйте /help для получения списка доступных команд.")
+// Служебные маршруты обновлений без обработчика
+const (
+	routeUnknown         = "unknown"
+	routeInvalidCallback = "invalid_callback"
+)
+
+// routeUpdate - маршрут обновления; nil для неподдерживаемых типов обновлений
+func routeUpdate(update tgbotapi.Update) *updateRequest {
+	switch {
+	case update.Message != nil && update.Message.IsCommand():
+		return routeCommand(update)
+	case update.Message != nil:
+		return routeText(update)
+	case update.CallbackQuery != nil && update.CallbackQuery.Message != nil:
+		return routeCallback(update)
 	}
+	return nil
+}
+
+// commandHandler - обработчик команды, которому нужен автор
+type commandHandler func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser)
+
+// commandRoute - обработчик команды и право на ее выполнение ("" - доступна всем)
+type commandRoute struct {
+	permission auth.Permission
+	handler    commandHandler
+}
+
+// command - маршрут обработчика, которому достаточно сообщения
+func command(p auth.Permission, handler func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB)) commandRoute {
+	return commandRoute{p, func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+		handler(bot, message, db)
+	}}
+}
+
+// Маршруты команд
+var commandRoutes = map[string]commandRoute{
+	// Общие
+	"start":    {"", handleStart},
+	"menu":     {"", handleMainMenu},
+	"register": command("", handleRegister),
+	"cancel": command("", func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+		handleCancel(bot, message)
+	}),
+	"help": {"", handleHelp},
+
+	// Студенты
+	"subjects":   command("", handleSubjectsCommand),
+	"schedule":   {"", handleScheduleCommand},
+	"enroll":     command(auth.LessonEnroll, handleEnrollCommand),
+	"waitlist":   command(auth.LessonEnroll, handleWaitlistCommand),
+	"my_lessons": command(auth.LessonEnroll, handleMyLessonsCommand),
+	"reminders":  command(auth.LessonEnroll, handleRemindersCommand),
+	"series":     command("", handleSeriesCommand),
+
+	"enroll_series": command(auth.LessonEnroll, handleEnrollSeriesCommand),
+	"courses":       command("", handleCoursesCommand),
+	"course":        command("", handleCourseCommand),
+	"enroll_course": command(auth.LessonEnroll, handleEnrollCourseCommand),
+	"leave_course":  command(auth.LessonEnroll, handleLeaveCourseCommand),
+
+	// Преподаватели
+	"create_lesson":     command(auth.LessonCreate, handleCreateLessonCommand),
+	"reschedule_lesson": {auth.LessonEditOwn, handleRescheduleLessonCommand},
+	"cancel_lesson":     {auth.LessonEditOwn, handleCancelLessonCommand},
+	"create_series":     command(auth.LessonCreate, handleCreateSeriesCommand),
+	"edit_series":       {auth.LessonEditOwn, handleEditSeriesCommand},
+	"cancel_series":     {auth.LessonEditOwn, handleCancelSeriesCommand},
+	"create_course":     command(auth.LessonCreate, handleCreateCourseCommand),
+	"schedule_course":   {auth.LessonEditOwn, handleScheduleCourseCommand},
+	"attendance":        {auth.LessonEditOwn, handleAttendanceCommand},
+	"rooms":             command(auth.LessonCreate, handleRoomsCommand),
+	"help_teacher":      command(auth.LessonViewOwn, handleHelpTeacherCommand),
+	"my_schedule":       command(auth.LessonViewOwn, handleMyScheduleCommand),
+	"my_students":       command(auth.LessonViewOwn, handleTeacherStudentsCommand),
+
+	// Уроки (администрирование)
+	"reschedule_with_notify": {auth.LessonEditAny, handleRescheduleLessonCommand},
+	"cancel_with_notification": command(auth.LessonEditAny, func(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+		sendMessage(bot, message.Chat.ID, "⚙️ Команда в разработке")
+	}),
+	"delete_lesson":  command(auth.LessonDeleteAny, handleDeleteLessonCommand),
+	"restore_lesson": command(auth.LessonRestore, handleRestoreLessonCommand),
+	"add_room":       command(auth.RoomManage, handleAddRoomCommand),
+	"room_schedule":  command(auth.RoomManage, handleRoomScheduleCommand),
+
+	// Преподаватели и студенты (администрирование)
+	"list_teachers":      command(auth.TeacherView, handleListTeachersCommand),
+	"add_teacher":        command(auth.TeacherManage, handleAddTeacherCommand),
+	"delete_teacher":     command(auth.TeacherManage, handleDeleteTeacherCommand),
+	"restore_teacher":    command(auth.TeacherManage, handleRestoreTeacherCommand),
+	"deactivate_student": command(auth.StudentManage, handleDeactivateStudentCommand),
+	"activate_student":   command(auth.StudentManage, handleActivateStudentCommand),
+	"grant_admin":        command(auth.RoleManage, handleGrantAdminCommand),
+	"revoke_admin":       command(auth.RoleManage, handleRevokeAdminCommand),
+
+	// Уведомления
+	"notify_students": command(auth.NotifyStudents, handleNotifyStudentsCommand),
+	"notify_all":      command(auth.NotifyAll, handleNotifyAllCommand),
+	"remind_all":      command(auth.NotifyAll, handleRemindAllCommand),
+
+	// Статистика и логи
+	"stats":             command(auth.StatsView, handleStatsCommand),
+	"rate_limit_stats":  command(auth.SystemView, handleRateLimitStatsCommand),
+	"log_recent_errors": command(auth.SystemView, handleLogRecentErrorsCommand),
+	"audit":             command(auth.AuditView, handleAuditCommand),
 }
 
+// routeCommand - маршрут команды; право проверяет withPermission, а не обработчик
+func routeCommand(update tgbotapi.Update) *updateRequest {
+	message := update.Message
+	req := newUpdateRequest(update, updateCommand, message.Chat.ID, message.From)
+
+	route, ok := commandRoutes[message.Command()]
+	if !ok {
+		req.Route = routeUnknown
+		req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+			sendMessage(bot, req.ChatID,
+				"❓ Неизвестная команда. Используйте /help для получения списка доступных команд.")
+		}
+		return req
+	}
 
+	req.Route = "/" + message.Command()
+	req.Permission = route.permission
+	req.TargetID = ExtractLessonIDFromMessage(message)
+	req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		route.handler(bot, message, db, req.User)
+	}
+	return req
+}
+
+// routeText - текст обрабатывает FSM; право шага диалога проверяет сам диалог
+func routeText(update tgbotapi.Update) *updateRequest {
+	message := update.Message
+	req := newUpdateRequest(update, updateText, message.Chat.ID, message.From)
+	req.Route = string(updateText)
+	req.handle = func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		handleTextMessage(bot, message, db, req.User)
+	}
+	return req
+}
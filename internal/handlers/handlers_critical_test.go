@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"testing"
-	"time"
 )
 
 // Тест парсинга ID преподавателя
@@ -71,54 +70,3 @@ func TestParseNotifyCommand(t *testing.T) {
 		}
 	}
 }
-
-// Тест создания структуры StudentNotification
-func TestStudentNotification(t *testing.T) {
-	now := time.Now()
-	student := StudentNotification{
-		TelegramID:  123456789,
-		FullName:    "Иван Иванов",
-		SubjectName: "Математика",
-		LessonTime:  now,
-		TeacherName: "Петр Петров",
-		LessonID:    42,
-	}
-
-	if student.TelegramID != 123456789 {
-		t.Errorf("Expected TelegramID 123456789, got %d", student.TelegramID)
-	}
-	if student.FullName != "Иван Иванов" {
-		t.Errorf("Expected FullName 'Иван Иванов', got '%s'", student.FullName)
-	}
-	if student.SubjectName != "Математика" {
-		t.Errorf("Expected SubjectName 'Математика', got '%s'", student.SubjectName)
-	}
-	if student.LessonID != 42 {
-		t.Errorf("Expected LessonID 42, got %d", student.LessonID)
-	}
-}
-
-// Тест создания структуры LessonInfo
-func TestLessonInfo(t *testing.T) {
-	now := time.Now()
-	lesson := LessonInfo{
-		ID:            100,
-		SubjectName:   "Физика",
-		StartTime:     now,
-		TeacherName:   "Анна Сидорова",
-		EnrolledCount: 15,
-	}
-
-	if lesson.ID != 100 {
-		t.Errorf("Expected ID 100, got %d", lesson.ID)
-	}
-	if lesson.SubjectName != "Физика" {
-		t.Errorf("Expected SubjectName 'Физика', got '%s'", lesson.SubjectName)
-	}
-	if lesson.TeacherName != "Анна Сидорова" {
-		t.Errorf("Expected TeacherName 'Анна Сидорова', got '%s'", lesson.TeacherName)
-	}
-	if lesson.EnrolledCount != 15 {
-		t.Errorf("Expected EnrolledCount 15, got %d", lesson.EnrolledCount)
-	}
-}
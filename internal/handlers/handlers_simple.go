@@ -3,10 +3,12 @@ package handlers
 import (
 	"database/sql"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/telegram"
 )
 
 // Простой роутер - перенаправляет на handlers.go
-func HandleUpdateSimple(bot *tgbotapi.BotAPI, update tgbotapi.Update, db *sql.DB) {
+func HandleUpdateSimple(bot telegram.Messenger, update tgbotapi.Update, db *sql.DB) {
 	// Используем полнофункциональный обработчик из handlers.go
 	HandleUpdate(bot, update, db)
 }
@@ -3,23 +3,24 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
-	"strings"
-	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/telegram"
 )
 
 // Создание inline-клавиатуры для главного меню студента
 func createStudentMainMenu() tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📅 Расписание", "schedule"),
-			tgbotapi.NewInlineKeyboardButtonData("📚 Мои уроки", "my_lessons"),
+			callback.Button("📅 Расписание", callback.New(callback.Schedule)),
+			callback.Button("📚 Мои уроки", callback.New(callback.MyLessons)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❓ Помощь", "help"),
-			tgbotapi.NewInlineKeyboardButtonData("👤 Профиль", "profile"),
+			callback.Button("❓ Помощь", callback.New(callback.Help)),
+			callback.Button("👤 Профиль", callback.New(callback.Profile)),
 		),
 	)
 }
@@ -28,15 +29,15 @@ func createStudentMainMenu() tgbotapi.InlineKeyboardMarkup {
 func createTeacherMainMenu() tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📅 Мои уроки", "my_lessons"),
-			tgbotapi.NewInlineKeyboardButtonData("👥 Мои студенты", "my_students"),
+			callback.Button("📅 Мои уроки", callback.New(callback.MyLessons)),
+			callback.Button("👥 Мои студенты", callback.New(callback.MyStudents)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("➕ Создать урок", "create_lesson"),
-			tgbotapi.NewInlineKeyboardButtonData("🗑️ Отменить урок", "cancel_lesson"),
+			callback.Button("➕ Создать урок", callback.New(callback.CreateLessonMenu)),
+			callback.Button("🗑️ Отменить урок", callback.New(callback.DeleteLessonMenu)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❓ Помощь", "help_teacher"),
+			callback.Button("❓ Помощь", callback.New(callback.HelpTeacher)),
 		),
 	)
 }
@@ -45,74 +46,15 @@ func createTeacherMainMenu() tgbotapi.InlineKeyboardMarkup {
 func createAdminMainMenu() tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("👨‍🏫 Преподаватели", "teachers"),
-			tgbotapi.NewInlineKeyboardButtonData("📊 Статистика", "stats"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📢 Уведомления", "notifications"),
-			tgbotapi.NewInlineKeyboardButtonData("📋 Логи", "logs"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❓ Помощь", "help_admin"),
-		),
-	)
-}
-
-// Создание inline-клавиатуры для списка предметов
-func createSubjectsKeyboard() tgbotapi.InlineKeyboardMarkup {
-	return tgbotapi.NewInlineKeyboardMarkup(
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🎮 Геймдев", "subject_GAMEDEV"),
-			tgbotapi.NewInlineKeyboardButtonData("🌐 Веб-разработка", "subject_WEB_DEV"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🎨 Графический дизайн", "subject_GRAPHIC_DESIGN"),
-			tgbotapi.NewInlineKeyboardButtonData("🎬 VFX-дизайн", "subject_VFX_DESIGN"),
-		),
-		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🎯 3D-моделирование", "subject_3D_MODELING"),
-			tgbotapi.NewInlineKeyboardButtonData("💻 Компьютерная грамотность", "subject_COMPUTER_LITERACY"),
+			callback.Button("👨‍🏫 Преподаватели", callback.New(callback.Teachers)),
+			callback.Button("📊 Статистика", callback.New(callback.Stats)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back_to_main"),
+			callback.Button("📢 Уведомления", callback.New(callback.Notifications)),
+			callback.Button("📋 Логи", callback.New(callback.Logs)),
 		),
-	)
-}
-
-// Создание inline-клавиатуры для действий с уроком
-func createLessonActionsKeyboard(lessonID int, canEnroll bool, canUnenroll bool) tgbotapi.InlineKeyboardMarkup {
-	var buttons [][]tgbotapi.InlineKeyboardButton
-
-	if canEnroll {
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Записаться", fmt.Sprintf("enroll_%d", lessonID)),
-		))
-	}
-
-	if canUnenroll {
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отписаться", fmt.Sprintf("unenroll_%d", lessonID)),
-		))
-	}
-
-	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("📋 Подробнее", fmt.Sprintf("lesson_info_%d", lessonID)),
-		tgbotapi.NewInlineKeyboardButtonData("⏰ Напомнить", fmt.Sprintf("remind_%d", lessonID)),
-	))
-
-	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к расписанию", "back_to_schedule"),
-	))
-
-	return tgbotapi.InlineKeyboardMarkup{InlineKeyboard: buttons}
-}
-
-// Создание inline-клавиатуры для подтверждения действий
-func createConfirmationKeyboard(action string, id int) tgbotapi.InlineKeyboardMarkup {
-	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Подтвердить", fmt.Sprintf("confirm_%s_%d", action, id)),
-			tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", "cancel_action"),
+			callback.Button("❓ Помощь", callback.New(callback.HelpAdmin)),
 		),
 	)
 }
@@ -121,126 +63,28 @@ func createConfirmationKeyboard(action string, id int) tgbotapi.InlineKeyboardMa
 func createNavigationKeyboard() tgbotapi.InlineKeyboardMarkup {
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("🔙 Назад", "back"),
-			tgbotapi.NewInlineKeyboardButtonData("🏠 Главная", "main_menu"),
+			callback.Button("🏠 Главное меню", callback.New(callback.MainMenu)),
 		),
 	)
 }
 
-// Обработка inline-кнопок
-func handleInlineButton(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	// Убираем индикатор загрузки
-	callback := tgbotapi.NewCallback(query.ID, "")
-	bot.Request(callback)
-
-	data := query.Data
-
-	switch {
-	case strings.HasPrefix(data, "create_lesson:") || strings.HasPrefix(data, "delete_lesson:"):
-		handleLessonSubjectCallback(bot, query, db)
-	case data == "main_menu":
-		handleMainMenu(bot, query.Message, db)
-	case data == "create_lesson":
-		handleCreateLessonButton(bot, query.Message, db)
-	case data == "cancel_lesson":
-		handleCancelLessonButton(bot, query.Message, db)
-	case data == "schedule":
-		handleScheduleButton(bot, query.Message, db)
-	case data == "my_lessons":
-		handleMyLessonsButton(bot, query.Message, db)
-	case data == "my_students":
-		handleMyStudentsButton(bot, query.Message, db)
-	case data == "help":
-		handleHelpButton(bot, query.Message, db)
-	case data == "profile":
-		handleProfileButton(bot, query.Message, db)
-	case data == "teachers":
-		handleTeachersButton(bot, query.Message, db)
-	case data == "stats":
-		handleStatsButton(bot, query.Message, db)
-	case data == "notifications":
-		handleNotificationsButton(bot, query.Message, db)
-	case data == "logs":
-		handleLogsButton(bot, query.Message, db)
-	case data == "help_teacher":
-		handleHelpTeacherButton(bot, query.Message, db)
-	case data == "help_admin":
-		handleHelpAdminButton(bot, query.Message, db)
-	case data == "back_to_main":
-		handleMainMenu(bot, query.Message, db)
-	case data == "back_to_schedule":
-		handleScheduleButton(bot, query.Message, db)
-	case data == "back":
-		handleBackButton(bot, query.Message, db)
-	case data == "cancel_action":
-		handleCancelAction(bot, query.Message, db)
-	// Новые студенческие кнопки
-	case data == "student_dashboard":
-		showStudentMainMenu(bot, query.Message, db)
-	case data == "enroll_subjects":
-		showSubjectsForEnrollment(bot, query.Message.Chat.ID, db)
-	case data == "my_lessons_menu":
-		handleMyLessonsCommand(bot, query.Message, db)
-	case data == "school_schedule":
-		handleScheduleCommand(bot, query.Message, db)
-	case data == "my_waitlist":
-		handleWaitlistCommand(bot, query.Message, db)
-	case data == "help_student":
-		sendMessage(bot, query.Message.Chat.ID, 
-			"📚 **Справка для студентов:**\n\n"+
-			"🎓 Главное меню: /start\n"+
-			"📚 Записаться на урок: используйте кнопки\n"+
-			"📅 Мои уроки: показывает ваши записи\n"+
-			"📆 Расписание: все уроки школы\n"+
-			"⏳ Лист ожидания: очередь на популярные уроки\n\n"+
-			"❓ Возникли вопросы? Обратитесь к администратору.")
-	case strings.HasPrefix(data, "info_lesson_"):
-		handleLessonInfoButton(bot, query, db)
-	default:
-		// Обработка callback'ов с предметами для записи
-		if strings.HasPrefix(data, "enroll_subject:") {
-			parts := strings.Split(data, ":")
-			if len(parts) == 2 {
-				subjectID, err := strconv.Atoi(parts[1])
-				if err == nil {
-					showAvailableLessonsForSubject(bot, query, db, subjectID)
-					return
-				}
-			}
-		}
-		
-		// Обработка динамических кнопок (записи на уроки и т.д.)
-		handleDynamicButton(bot, query, db)
-	}
-}
-
 // Обработка главного меню
-func handleMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-
-	// Получаем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&role)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения роли пользователя")
-		return
-	}
-
+func handleMainMenu(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
 	var keyboard tgbotapi.InlineKeyboardMarkup
 	var welcomeText string
 
-	switch role {
-	case "student":
+	switch user.Role {
+	case auth.Student:
 		keyboard = createStudentMainMenu()
 		welcomeText = "👋 **Добро пожаловать в главное меню!**\n\nВыберите нужный раздел:"
-	case "teacher":
+	case auth.Teacher:
 		keyboard = createTeacherMainMenu()
 		welcomeText = "👨‍🏫 **Панель преподавателя**\n\nВыберите действие:"
-	case "superuser":
+	case auth.Admin, auth.Superuser:
 		keyboard = createAdminMainMenu()
 		welcomeText = "👑 **Панель администратора**\n\nВыберите раздел управления:"
 	default:
-		sendMessage(bot, message.Chat.ID, "❌ Неизвестная роль пользователя")
+		sendAccessDenied(bot, message.Chat.ID, user)
 		return
 	}
 
@@ -250,61 +94,44 @@ func handleMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB)
 	bot.Send(msg)
 }
 
-// Обработка кнопки расписания
-func handleScheduleButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	// Используем универсальную функцию расписания
-	handleScheduleCommand(bot, message, db)
-}
-
 // Обработка кнопки "Мои уроки"
-func handleMyLessonsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-
-	// Получаем роль пользователя
-	userRole, err := getUserRole(db, userID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка определения роли пользователя")
-		return
-	}
-
-	switch userRole {
-	case "student":
+func handleMyLessonsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	switch {
+	case user.Can(auth.LessonEnroll):
 		// Для студентов показываем их записи на уроки
 		handleMyLessonsCommand(bot, message, db)
-	case "teacher", "superuser":
+	case user.Can(auth.LessonViewOwn):
 		// Для преподавателей показываем их собственные уроки
 		handleMyScheduleCommand(bot, message, db)
 	default:
-		sendMessage(bot, message.Chat.ID, "❌ Неизвестная роль пользователя")
+		sendAccessDenied(bot, message.Chat.ID, user)
 	}
 }
 
 // Обработка кнопки "Мои студенты" (для преподавателей)
-func handleMyStudentsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleMyStudentsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Используем существующую функцию
 	handleTeacherStudentsCommand(bot, message, db)
 }
 
 // Обработка кнопки помощи
-func handleHelpButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleHelpButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
 	// Используем существующую функцию
-	handleHelp(bot, message, db)
+	handleHelp(bot, message, db, user)
 }
 
 // Обработка кнопки профиля
-func handleProfileButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleProfileButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 
-	var fullName, role, phone string
-	var isActive bool
-	err := db.QueryRow("SELECT full_name, role, phone, is_active FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&fullName, &role, &phone, &isActive)
+	user, err := repos(db).Users.GetByTelegramID(userID)
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения данных профиля")
 		return
 	}
 
 	status := "✅ Активен"
-	if !isActive {
+	if !user.IsActive {
 		status = "❌ Деактивирован"
 	}
 
@@ -313,7 +140,7 @@ func handleProfileButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sq
 		"🎭 **Роль:** %s\n"+
 		"📱 **Телефон:** %s\n"+
 		"🔐 **Статус:** %s\n\n"+
-		"Для изменения данных обратитесь к администратору.", fullName, role, phone, status)
+		"Для изменения данных обратитесь к администратору.", user.FullName, user.Role, user.Phone, status)
 
 	msg := tgbotapi.NewMessage(message.Chat.ID, profileText)
 	msg.ParseMode = "Markdown"
@@ -322,19 +149,19 @@ func handleProfileButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sq
 }
 
 // Обработка кнопки преподавателей (для админов)
-func handleTeachersButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleTeachersButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Используем существующую функцию
 	handleListTeachersCommand(bot, message, db)
 }
 
 // Обработка кнопки статистики (для админов)
-func handleStatsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleStatsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Используем существующую функцию
 	handleStatsCommand(bot, message, db)
 }
 
 // Обработка кнопки уведомлений (для админов)
-func handleNotificationsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleNotificationsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	text := "📢 **Система уведомлений**\n\n" +
 		"Доступные команды:\n" +
 		"• `/notify_students <lesson_id> <сообщение>` - уведомить студентов урока\n" +
@@ -349,19 +176,19 @@ func handleNotificationsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 }
 
 // Обработка кнопки логов (для админов)
-func handleLogsButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleLogsButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Используем существующую функцию
 	handleLogRecentErrorsCommand(bot, message, db)
 }
 
 // Обработка кнопки справки для преподавателей
-func handleHelpTeacherButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleHelpTeacherButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Используем существующую функцию
 	handleHelpTeacherCommand(bot, message, db)
 }
 
 // Обработка кнопки справки для админов
-func handleHelpAdminButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleHelpAdminButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	helpText := "👑 **Справка для администраторов**\n\n" +
 		"**📋 Доступные команды:**\n\n" +
 		"**👨‍🏫 Управление преподавателями:**\n" +
@@ -377,6 +204,7 @@ func handleHelpAdminButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *
 		"• `/stats` - статистика системы\n" +
 		"• `/rate_limit_stats` - статистика rate limiting\n" +
 		"• `/log_recent_errors` - последние ошибки\n" +
+		"• `/audit` - журнал изменений с фильтрами\n" +
 		"• `/activate_student <student_id>` - активировать студента\n" +
 		"• `/deactivate_student <student_id>` - деактивировать студента"
 
@@ -386,316 +214,26 @@ func handleHelpAdminButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *
 	bot.Send(msg)
 }
 
-// Обработка кнопки "Назад"
-func handleBackButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	handleMainMenu(bot, message, db)
-}
-
-// Обработка кнопки отмены действия
-func handleCancelAction(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	handleMainMenu(bot, message, db)
-}
-
-// Обработка динамических кнопок (запись, отписка, информация об уроке)
-func handleDynamicButton(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	data := query.Data
-	message := query.Message
-
-	// Обработка записи на урок
-	if len(data) > 7 && data[:7] == "enroll_" {
-		lessonIDStr := data[7:]
-		lessonID, err := strconv.Atoi(lessonIDStr)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
-			return
-		}
-		
-		// Создаем временное сообщение для обработки
-		tempMessage := *message
-		tempMessage.Text = fmt.Sprintf("/enroll %d", lessonID)
-		handleEnrollCommand(bot, &tempMessage, db)
-		return
-	}
-
-	// Обработка отписки от урока
-	if len(data) > 9 && data[:9] == "unenroll_" {
-		lessonIDStr := data[9:]
-		lessonID, err := strconv.Atoi(lessonIDStr)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
-			return
-		}
-		
-		// Создаем временное сообщение для обработки
-		tempMessage := *message
-		tempMessage.Text = fmt.Sprintf("/unenroll %d", lessonID)
-		// Здесь нужно будет добавить функцию handleUnenrollCommand
-		sendMessage(bot, message.Chat.ID, "🔄 Функция отписки от урока в разработке")
-		return
-	}
-
-	// Обработка информации об уроке
-	if len(data) > 12 && data[:12] == "lesson_info_" {
-		lessonIDStr := data[12:]
-		lessonID, err := strconv.Atoi(lessonIDStr)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
-			return
-		}
-		
-		// Получаем информацию об уроке
-		var subjectName, teacherName, startTime string
-		var maxStudents, enrolledCount int
-		err = db.QueryRow(`
-			SELECT s.name, u.full_name, l.start_time::text, l.max_students,
-			       COALESCE(COUNT(e.id), 0) as enrolled_count
-			FROM lessons l
-			JOIN subjects s ON l.subject_id = s.id
-			LEFT JOIN teachers t ON l.teacher_id = t.id
-			LEFT JOIN users u ON t.user_id = u.id
-			LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-			WHERE l.id = $1 AND l.soft_deleted = false
-			GROUP BY s.name, u.full_name, l.start_time, l.max_students`, lessonID).Scan(&subjectName, &teacherName, &startTime, &maxStudents, &enrolledCount)
-		
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
-			return
-		}
-
-		infoText := fmt.Sprintf("📋 **Информация об уроке**\n\n"+
-			"📚 **Предмет:** %s\n"+
-			"👨‍🏫 **Преподаватель:** %s\n"+
-			"⏰ **Время:** %s\n"+
-			"👥 **Записано:** %d/%d\n"+
-			"⏱️ **Длительность:** 90 минут", 
-			subjectName, teacherName, startTime[:16], enrolledCount, maxStudents)
-
-		msg := tgbotapi.NewMessage(message.Chat.ID, infoText)
-		msg.ParseMode = "Markdown"
-		msg.ReplyMarkup = createNavigationKeyboard()
-		bot.Send(msg)
-		return
-	}
-
-	// Обработка предметов
-	if len(data) > 8 && data[:8] == "subject_" {
-		subjectCode := data[8:]
-		handleSubjectSelection(bot, message, db, subjectCode)
-		return
-	}
-
-	// Обработка подтверждений
-	if len(data) > 8 && data[:8] == "confirm_" {
-		actionData := data[8:]
-		handleConfirmation(bot, message, db, actionData)
-		return
-	}
-
-	sendMessage(bot, message.Chat.ID, "❌ Неизвестная кнопка")
-}
-
-// Обработка выбора предмета
-func handleSubjectSelection(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, subjectCode string) {
-	// Получаем уроки по предмету
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time::text, u.full_name, l.max_students,
-		       COALESCE(COUNT(e.id), 0) as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE s.code = $1 AND l.soft_deleted = false AND l.start_time > NOW()
-		GROUP BY l.id, l.start_time, u.full_name, l.max_students
-		ORDER BY l.start_time`, subjectCode)
-	
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения расписания")
-		return
-	}
-	defer rows.Close()
-
-	var lessons []struct {
-		id            int
-		startTime     string
-		teacherName   string
-		maxStudents   int
-		enrolledCount int
-	}
-
-	for rows.Next() {
-		var lesson struct {
-			id            int
-			startTime     string
-			teacherName   string
-			maxStudents   int
-			enrolledCount int
-		}
-		if err := rows.Scan(&lesson.id, &lesson.startTime, &lesson.teacherName, &lesson.maxStudents, &lesson.enrolledCount); err != nil {
-			continue
-		}
-		lessons = append(lessons, lesson)
-	}
-
-	if len(lessons) == 0 {
-		text := "📅 **Расписание пусто**\n\nНа данный момент нет запланированных уроков по этому предмету."
-		msg := tgbotapi.NewMessage(message.Chat.ID, text)
-		msg.ParseMode = "Markdown"
-		msg.ReplyMarkup = createNavigationKeyboard()
-		bot.Send(msg)
-		return
-	}
-
-	// Формируем список уроков
-	var text string
-	switch subjectCode {
-	case "GAMEDEV":
-		text = "🎮 **Расписание: Геймдев**\n\n"
-	case "WEB_DEV":
-		text = "🌐 **Расписание: Веб-разработка**\n\n"
-	case "GRAPHIC_DESIGN":
-		text = "🎨 **Расписание: Графический дизайн**\n\n"
-	case "VFX_DESIGN":
-		text = "🎬 **Расписание: VFX-дизайн**\n\n"
-	case "3D_MODELING":
-		text = "🎯 **Расписание: 3D-моделирование**\n\n"
-	case "COMPUTER_LITERACY":
-		text = "💻 **Расписание: Компьютерная грамотность**\n\n"
-	default:
-		text = "📅 **Расписание**\n\n"
-	}
-
-	for _, lesson := range lessons {
-		available := lesson.maxStudents - lesson.enrolledCount
-		status := "✅"
-		if available <= 0 {
-			status = "⏳"
-		}
-		
-		text += fmt.Sprintf("%s **Урок %d**\n", status, lesson.id)
-		text += fmt.Sprintf("⏰ %s\n", lesson.startTime[:16])
-		text += fmt.Sprintf("👨‍🏫 %s\n", lesson.teacherName)
-		text += fmt.Sprintf("👥 %d/%d мест\n\n", lesson.enrolledCount, lesson.maxStudents)
-	}
-
-	msg := tgbotapi.NewMessage(message.Chat.ID, text)
-	msg.ParseMode = "Markdown"
-	msg.ReplyMarkup = createNavigationKeyboard()
-	bot.Send(msg)
+// Обработка кнопки справки для студентов
+func handleHelpStudentButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	sendMessage(bot, message.Chat.ID, 
+		"📚 **Справка для студентов:**\n\n"+
+		"🎓 Главное меню: /start\n"+
+		"📚 Записаться на урок: используйте кнопки\n"+
+		"📅 Мои уроки: показывает ваши записи\n"+
+		"📆 Расписание: все уроки школы\n"+
+		"⏳ Лист ожидания: очередь на популярные уроки\n\n"+
+		"❓ Возникли вопросы? Обратитесь к администратору.")
 }
 
 // Обработчик кнопки "Создать урок"
-func handleCreateLessonButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleCreateLessonButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Вызываем функцию показа предметов для создания урока
-	showSubjectButtons(bot, message, db, "create")
+	showSubjectButtons(bot, message, db, callback.CreateLessonSubject)
 }
 
 // Обработчик кнопки "Отменить урок"
-func handleCancelLessonButton(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleCancelLessonButton(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Вызываем функцию показа предметов для удаления урока
-	showSubjectButtons(bot, message, db, "delete")
-}
-
-// Обработка подтверждений действий
-func handleConfirmation(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, actionData string) {
-	// Здесь можно добавить обработку подтверждений различных действий
-	sendMessage(bot, message.Chat.ID, "✅ Действие подтверждено!")
-}
-
-// Обработчик кнопки "Подробнее" для урока
-func handleLessonInfoButton(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB) {
-	// Извлекаем lesson ID из callback data
-	parts := strings.Split(query.Data, "_")
-	if len(parts) != 3 {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Неверный формат данных")
-		return
-	}
-	
-	lessonIDStr := parts[2]
-	lessonID, err := strconv.Atoi(lessonIDStr)
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Некорректный ID урока")
-		return
-	}
-	
-	// Получаем детальную информацию об уроке
-	var lesson struct {
-		ID            int
-		StartTime     time.Time
-		Duration      int
-		SubjectName   string
-		TeacherName   string
-		MaxStudents   int
-		EnrolledCount int
-		Status        string
-		Description   string
-	}
-	
-	err = db.QueryRow(`
-		SELECT l.id, l.start_time, l.duration_minutes, s.name, u.full_name, 
-			l.max_students, COUNT(e.id) as enrolled_count, l.status, s.description
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE l.id = $1 AND l.soft_deleted = false
-		GROUP BY l.id, l.start_time, l.duration_minutes, s.name, u.full_name, 
-			l.max_students, l.status, s.description`,
-		lessonID).Scan(&lesson.ID, &lesson.StartTime, &lesson.Duration, 
-		&lesson.SubjectName, &lesson.TeacherName, &lesson.MaxStudents, 
-		&lesson.EnrolledCount, &lesson.Status, &lesson.Description)
-		
-	if err != nil {
-		sendMessage(bot, query.Message.Chat.ID, "❌ Урок не найден")
-		return
-	}
-	
-	// Формируем детальное описание урока
-	freeSpots := lesson.MaxStudents - lesson.EnrolledCount
-	endTime := lesson.StartTime.Add(time.Duration(lesson.Duration) * time.Minute)
-	
-	text := fmt.Sprintf("📚 **Детали урока #%d**\n\n", lesson.ID) +
-		fmt.Sprintf("📖 **Предмет:** %s\n", lesson.SubjectName) +
-		fmt.Sprintf("👨‍🏫 **Преподаватель:** %s\n", lesson.TeacherName) +
-		fmt.Sprintf("📅 **Дата:** %s\n", lesson.StartTime.Format("02.01.2006")) +
-		fmt.Sprintf("🕐 **Время:** %s - %s\n", 
-			lesson.StartTime.Format("15:04"), endTime.Format("15:04")) +
-		fmt.Sprintf("⏰ **Длительность:** %d минут\n", lesson.Duration) +
-		fmt.Sprintf("👥 **Записано:** %d из %d студентов\n", lesson.EnrolledCount, lesson.MaxStudents) +
-		fmt.Sprintf("✅ **Свободно мест:** %d\n", freeSpots) +
-		fmt.Sprintf("📊 **Статус:** %s\n", lesson.Status)
-		
-	if lesson.Description != "" {
-		text += fmt.Sprintf("\n📝 **Описание:**\n%s", lesson.Description)
-	}
-	
-	// Кнопки действий
-	userRole, _ := getUserRole(db, query.From.ID)
-	var buttons [][]tgbotapi.InlineKeyboardButton
-	
-	if userRole == "student" && freeSpots > 0 {
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("✅ Записаться", fmt.Sprintf("enroll_lesson_%d", lesson.ID)),
-		))
-	}
-	
-	if userRole == "student" && freeSpots == 0 {
-		buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("⏳ В лист ожидания", fmt.Sprintf("waitlist_lesson_%d", lesson.ID)),
-		))
-	}
-	
-	buttons = append(buttons, tgbotapi.NewInlineKeyboardRow(
-		tgbotapi.NewInlineKeyboardButtonData("🔙 Назад к расписанию", "back_to_schedule"),
-	))
-	
-	// Редактируем сообщение
-	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &tgbotapi.InlineKeyboardMarkup{
-		InlineKeyboard: buttons,
-	}
-	
-	bot.Send(editMsg)
+	showSubjectButtons(bot, message, db, callback.DeleteLessonSubject)
 }
@@ -0,0 +1,26 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Фоновые воркеры пакета (очистка rate limiter и состояний FSM)
+var backgroundWorkers sync.WaitGroup
+
+// WaitForBackgroundWorkers - ждет завершения фоновых воркеров после отмены их контекста
+func WaitForBackgroundWorkers(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		backgroundWorkers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("фоновые воркеры не завершились вовремя: %w", ctx.Err())
+	}
+}
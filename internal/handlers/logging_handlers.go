@@ -1,73 +1,60 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
-	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/logging"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
 )
 
-// Структура для логов
-type SimpleLog struct {
-	ID        int
-	Action    string
-	UserID    *int
-	Details   string
-	CreatedAt time.Time
-}
+// Логирование действия в БД с уровнем (store.LogLevel*) и correlation ID обновления из ctx.
+// Запись дублируется в лог процесса с тем же уровнем.
+func LogAction(ctx context.Context, db *sql.DB, level, action string, userID *int, details string) error {
+	attrs := []any{"action", action, "details", details}
+	if userID != nil {
+		attrs = append(attrs, "user_id", *userID)
+	}
+	slog.Log(ctx, slogLevel(level), "Журнал действий", attrs...)
 
-// Логирование действия в БД
-func LogAction(db *sql.DB, action string, userID *int, details string) error {
-	_, err := db.Exec(`
-		INSERT INTO simple_logs (action, user_id, details, created_at)
-		VALUES ($1, $2, $3, NOW())`,
-		action, userID, details)
+	err := repos(db).Logs.Add(store.LogEntry{
+		Level:         level,
+		Action:        action,
+		UserID:        userID,
+		Details:       details,
+		CorrelationID: logging.CorrelationID(ctx),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка записи в журнал действий", "action", action, "err", err)
+	}
 	return err
 }
 
-// Получение последних ошибок
-func GetRecentErrors(db *sql.DB, limit int) ([]SimpleLog, error) {
-	rows, err := db.Query(`
-		SELECT id, action, user_id, details, created_at
-		FROM simple_logs
-		WHERE action LIKE '%error%' OR action LIKE '%ошибка%'
-		ORDER BY created_at DESC
-		LIMIT $1`, limit)
-	
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-	
-	var logs []SimpleLog
-	for rows.Next() {
-		var log SimpleLog
-		if err := rows.Scan(&log.ID, &log.Action, &log.UserID, &log.Details, &log.CreatedAt); err != nil {
-			continue
-		}
-		logs = append(logs, log)
+// slogLevel - уровень лога процесса для уровня simple_logs
+func slogLevel(level string) slog.Level {
+	switch level {
+	case store.LogLevelError:
+		return slog.LevelError
+	case store.LogLevelWarn:
+		return slog.LevelWarn
 	}
-	
-	return logs, nil
+	return slog.LevelInfo
+}
+
+// Получение последних ошибок
+func GetRecentErrors(db *sql.DB, limit int) ([]store.LogEntry, error) {
+	return repos(db).Logs.RecentErrors(limit)
 }
 
 // Команда для просмотра последних ошибок
-func handleLogRecentErrorsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для просмотра логов")
-		return
-	}
-	
+func handleLogRecentErrorsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Парсинг аргументов команды
 	args := strings.Fields(message.Text)
 	limit := 10 // по умолчанию 10 записей
@@ -99,6 +86,9 @@ func handleLogRecentErrorsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Messag
 		if log.UserID != nil {
 			report.WriteString(fmt.Sprintf("   👤 Пользователь: %d\n", *log.UserID))
 		}
+		if log.CorrelationID != "" {
+			report.WriteString(fmt.Sprintf("   🔗 Обновление: `%s`\n", log.CorrelationID))
+		}
 		if log.Details != "" {
 			// Обрезаем длинные детали
 			details := log.Details
@@ -121,19 +111,19 @@ func handleLogRecentErrorsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Messag
 	bot.Send(msg)
 }
 
-// Логирование ошибки с автоматическим определением пользователя
-func LogError(db *sql.DB, action string, userID int64, details string) {
+// Логирование ошибки пользователя (уровень ERROR, видна в /log_recent_errors)
+func LogError(ctx context.Context, db *sql.DB, action string, userID int64, details string) {
 	userIDInt := int(userID)
-	LogAction(db, "ERROR: "+action, &userIDInt, details)
+	LogAction(ctx, db, store.LogLevelError, action, &userIDInt, details)
 }
 
 // Логирование действия пользователя
-func LogUserAction(db *sql.DB, action string, userID int64, details string) {
+func LogUserAction(ctx context.Context, db *sql.DB, action string, userID int64, details string) {
 	userIDInt := int(userID)
-	LogAction(db, action, &userIDInt, details)
+	LogAction(ctx, db, store.LogLevelInfo, action, &userIDInt, details)
 }
 
 // Логирование системного действия
-func LogSystemAction(db *sql.DB, action string, details string) {
-	LogAction(db, "SYSTEM: "+action, nil, details)
+func LogSystemAction(ctx context.Context, db *sql.DB, action string, details string) {
+	LogAction(ctx, db, store.LogLevelInfo, "SYSTEM: "+action, nil, details)
 }
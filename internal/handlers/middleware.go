@@ -0,0 +1,353 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/logging"
+	"constellation-school-bot/internal/metrics"
+	"constellation-school-bot/internal/telegram"
+)
+
+// ========================= MIDDLEWARE ОБНОВЛЕНИЙ =========================
+//
+// HandleUpdate сначала определяет маршрут обновления (updateRequest), затем
+// пропускает его через updateMiddleware. Сквозная логика - correlation ID, паники,
+// пользователь, блокировка, права, rate limiting, логи и метрики - живет здесь,
+// а не в обработчиках.
+
+// updateKind - тип обновления
+type updateKind string
+
+const (
+	updateCommand  updateKind = "command"
+	updateText     updateKind = "text"
+	updateCallback updateKind = "callback"
+)
+
+// Итог обработки обновления (для логов и метрик)
+const (
+	outcomeOK          = "ok"
+	outcomeDenied      = "denied"       // нет права маршрута
+	outcomeBlocked     = "blocked"      // аккаунт деактивирован
+	outcomeRateLimited = "rate_limited" // незавершенная операция
+	outcomePanic       = "panic"
+)
+
+// updateRequest - обновление с маршрутом на пути через middleware к обработчику
+type updateRequest struct {
+	Update     tgbotapi.Update
+	Kind       updateKind
+	Route      string // "/enroll", действие кнопки, "text" или служебный маршрут
+	ChatID     int64
+	From       *tgbotapi.User
+	TargetID   int             // ID урока (предмета, преподавателя) из аргумента команды или кнопки
+	Permission auth.Permission // право маршрута ("" - доступен всем)
+
+	Ctx     context.Context // контекст обновления с correlation ID, заполняет withCorrelationID
+	User    *requestUser    // заполняет withUser
+	Outcome string          // заполняют middleware, прервавшие обработку
+
+	handle updateHandler // обработчик маршрута
+}
+
+// updateHandler - обработчик обновления или его часть в цепочке middleware
+type updateHandler func(bot telegram.Messenger, req *updateRequest, db *sql.DB)
+
+// middleware - обертка обработчика
+type middleware func(next updateHandler) updateHandler
+
+// Цепочка обработки: первый элемент - внешний.
+// Паники перехватываются внутри логов и метрик, чтобы они увидели итог.
+var updateMiddleware = []middleware{
+	withCorrelationID,
+	withLogging,
+	withMetrics,
+	withRecovery,
+	withCallbackAnswer,
+	withUser,
+	blockDeactivated,
+	withPermission,
+	withRateLimit,
+}
+
+// chain - обработчик, обернутый middleware
+func chain(handler updateHandler, middlewares ...middleware) updateHandler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		handler = middlewares[i](handler)
+	}
+	return handler
+}
+
+// runRoute - последний шаг цепочки: обработчик маршрута
+func runRoute(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+	req.handle(bot, req, db)
+}
+
+// newUpdateRequest - запрос без маршрута; маршрут заполняет routeCommand/routeText/routeCallback
+func newUpdateRequest(update tgbotapi.Update, kind updateKind, chatID int64, from *tgbotapi.User) *updateRequest {
+	return &updateRequest{Update: update, Kind: kind, ChatID: chatID, From: from, Ctx: context.Background()}
+}
+
+// fromID - Telegram ID автора (0 для служебных обновлений)
+func (req *updateRequest) fromID() int64 {
+	if req.From == nil {
+		return 0
+	}
+	return req.From.ID
+}
+
+// outcome - итог обработки; обновление, которое никто не прервал, обработано успешно
+func (req *updateRequest) outcome() string {
+	if req.Outcome == "" {
+		return outcomeOK
+	}
+	return req.Outcome
+}
+
+// reply - короткий ответ: всплывающее уведомление на кнопку или сообщение в чат
+func (req *updateRequest) reply(bot telegram.Messenger, text string) {
+	if req.Kind == updateCallback {
+		if err := bot.AnswerCallback(req.Update.CallbackQuery.ID, text); err != nil {
+			slog.WarnContext(req.Ctx, "Ошибка callback ответа", "err", err)
+		}
+		return
+	}
+	sendMessage(bot, req.ChatID, text)
+}
+
+// withCorrelationID - ID обновления в контексте: попадает во все записи лога
+// и simple_logs, сделанные при его обработке. Обработчики получают контекст
+// через telegram.Context(bot).
+func withCorrelationID(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		req.Ctx = logging.WithCorrelationID(req.Ctx, logging.NewCorrelationID())
+		next(telegram.WithContext(bot, req.Ctx), req, db)
+	}
+}
+
+// withLogging - запись лога на каждое обновление: маршрут, автор, итог и время
+func withLogging(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		started := time.Now()
+		next(bot, req, db)
+
+		level := slog.LevelInfo
+		switch req.outcome() {
+		case outcomePanic:
+			level = slog.LevelError
+		case outcomeDenied, outcomeBlocked, outcomeRateLimited:
+			level = slog.LevelWarn
+		}
+		slog.Log(req.Ctx, level, "Обновление обработано",
+			"update_id", req.Update.UpdateID,
+			"kind", req.Kind,
+			"route", req.Route,
+			"from", req.fromID(),
+			"outcome", req.outcome(),
+			"duration", time.Since(started).Round(time.Millisecond))
+	}
+}
+
+// withMetrics - счетчики и время обработки по маршрутам (для /stats и Prometheus)
+func withMetrics(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		started := time.Now()
+		next(bot, req, db)
+
+		elapsed := time.Since(started)
+		updateMetrics.record(req, elapsed)
+
+		metrics.UpdatesTotal.WithLabelValues(string(req.Kind), req.outcome()).Inc()
+		switch req.Kind {
+		case updateCommand:
+			metrics.CommandDuration.WithLabelValues(req.Route).Observe(elapsed.Seconds())
+		case updateCallback:
+			metrics.CallbackActionsTotal.WithLabelValues(req.Route, req.outcome()).Inc()
+		}
+	}
+}
+
+// withRecovery - паника обработчика не роняет воркер, пользователь получает ответ
+func withRecovery(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		defer func() {
+			if r := recover(); r != nil {
+				req.Outcome = outcomePanic
+				slog.ErrorContext(req.Ctx, "Паника в обработчике",
+					"update_id", req.Update.UpdateID, "kind", req.Kind, "route", req.Route,
+					"panic", r, "stack", string(debug.Stack()))
+				sendMessage(bot, req.ChatID, "❌ Внутренняя ошибка. Попробуйте позже")
+			}
+		}()
+		next(bot, req, db)
+	}
+}
+
+// withCallbackAnswer - убирает индикатор загрузки с нажатой кнопки
+func withCallbackAnswer(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		if req.Kind == updateCallback {
+			if err := bot.AnswerCallback(req.Update.CallbackQuery.ID, ""); err != nil {
+				slog.WarnContext(req.Ctx, "Ошибка callback ответа", "err", err)
+			}
+		}
+		next(bot, req, db)
+	}
+}
+
+// withUser - автор обновления загружается один раз для всей цепочки
+func withUser(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		req.User = resolveUser(db, req.From)
+
+		// Пользователь снова пишет боту - значит, разблокировал его: рассылки снова его включают
+		if account := req.User.Account; account != nil && account.Unreachable {
+			if err := repos(db).Users.MarkReachable(account.TelegramID); err != nil {
+				slog.WarnContext(req.Ctx, "Ошибка снятия отметки недоступности", "err", err)
+			} else {
+				account.Unreachable = false
+			}
+		}
+		next(bot, req, db)
+	}
+}
+
+// blockDeactivated - деактивированный пользователь не доходит ни до одного обработчика
+func blockDeactivated(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		if req.User.Account != nil && !req.User.Account.IsActive {
+			req.Outcome = outcomeBlocked
+			sendAccessDenied(bot, req.ChatID, req.User)
+			return
+		}
+		next(bot, req, db)
+	}
+}
+
+// withPermission - право маршрута проверяется до обработчика
+func withPermission(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		if !authorize(bot, req.ChatID, req.User, req.Permission) {
+			req.Outcome = outcomeDenied
+			return
+		}
+		next(bot, req, db)
+	}
+}
+
+// Маршруты с rate limiting: команда или действие кнопки -> операция
+var rateLimitedRoutes = map[string]string{
+	"/enroll":                 OPERATION_ENROLL,
+	"/waitlist":               OPERATION_WAITLIST,
+	string(callback.Enroll):   OPERATION_ENROLL,
+	string(callback.Unenroll): OPERATION_CANCEL,
+	string(callback.Waitlist): OPERATION_WAITLIST,
+}
+
+// withRateLimit - одна незавершенная операция записи на пользователя и урок
+func withRateLimit(next updateHandler) updateHandler {
+	return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		operation, limited := rateLimitedRoutes[req.Route]
+		if !limited || globalRateLimiter == nil || req.User.Account == nil {
+			next(bot, req, db)
+			return
+		}
+
+		userID := req.User.TelegramID
+		if allowed, reason := globalRateLimiter.IsOperationAllowed(userID, operation, req.TargetID); !allowed {
+			req.Outcome = outcomeRateLimited
+			metrics.RateLimitRejectionsTotal.WithLabelValues(operation).Inc()
+			req.reply(bot, reason.Error())
+			return
+		}
+		if err := globalRateLimiter.StartOperation(userID, operation, req.TargetID); err != nil {
+			req.reply(bot, "❌ Системная ошибка. Попробуйте позже.")
+			return
+		}
+		defer func() {
+			if err := globalRateLimiter.FinishOperation(userID, operation, req.TargetID); err != nil {
+				slog.ErrorContext(req.Ctx, "Ошибка завершения операции rate limiting", "operation", operation, "err", err)
+			}
+		}()
+
+		next(bot, req, db)
+	}
+}
+
+// ========================= МЕТРИКИ ОБНОВЛЕНИЙ =========================
+
+// RouteStats - обработанные обновления одного маршрута
+type RouteStats struct {
+	Kind     string
+	Route    string
+	Outcomes map[string]int64 // итог -> количество
+	Duration time.Duration    // суммарное время обработки
+}
+
+// Count - всего обновлений маршрута
+func (s RouteStats) Count() int64 {
+	var total int64
+	for _, n := range s.Outcomes {
+		total += n
+	}
+	return total
+}
+
+type routeKey struct {
+	kind  updateKind
+	route string
+}
+
+type updateMetricsRegistry struct {
+	mu     sync.Mutex
+	routes map[routeKey]*RouteStats
+}
+
+// Метрики процесса (сбрасываются при перезапуске)
+var updateMetrics = &updateMetricsRegistry{routes: make(map[routeKey]*RouteStats)}
+
+func (m *updateMetricsRegistry) record(req *updateRequest, d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	key := routeKey{req.Kind, req.Route}
+	stats, ok := m.routes[key]
+	if !ok {
+		stats = &RouteStats{Kind: string(req.Kind), Route: req.Route, Outcomes: make(map[string]int64)}
+		m.routes[key] = stats
+	}
+	stats.Outcomes[req.outcome()]++
+	stats.Duration += d
+}
+
+// UpdateStats - снимок метрик по маршрутам, отсортированный по типу и маршруту
+func UpdateStats() []RouteStats {
+	updateMetrics.mu.Lock()
+	defer updateMetrics.mu.Unlock()
+
+	snapshot := make([]RouteStats, 0, len(updateMetrics.routes))
+	for _, stats := range updateMetrics.routes {
+		outcomes := make(map[string]int64, len(stats.Outcomes))
+		for outcome, n := range stats.Outcomes {
+			outcomes[outcome] = n
+		}
+		snapshot = append(snapshot, RouteStats{Kind: stats.Kind, Route: stats.Route, Outcomes: outcomes, Duration: stats.Duration})
+	}
+	sort.Slice(snapshot, func(i, j int) bool {
+		if snapshot[i].Kind != snapshot[j].Kind {
+			return snapshot[i].Kind < snapshot[j].Kind
+		}
+		return snapshot[i].Route < snapshot[j].Route
+	})
+	return snapshot
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"database/sql"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/logging"
+	"constellation-school-bot/internal/telegram"
+	"constellation-school-bot/internal/telegram/telegramtest"
+)
+
+// Тест: middleware выполняются в порядке объявления, обработчик маршрута - последним
+func TestChainOrder(t *testing.T) {
+	var calls []string
+	trace := func(name string) middleware {
+		return func(next updateHandler) updateHandler {
+			return func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+				calls = append(calls, name+">")
+				next(bot, req, db)
+				calls = append(calls, "<"+name)
+			}
+		}
+	}
+	handler := func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		calls = append(calls, "handler")
+	}
+
+	chain(handler, trace("outer"), trace("inner"))(nil, &updateRequest{}, nil)
+
+	want := []string{"outer>", "inner>", "handler", "<inner", "<outer"}
+	if !reflect.DeepEqual(calls, want) {
+		t.Errorf("Expected %v, got %v", want, calls)
+	}
+}
+
+// Тест: обработчик получает correlation ID обновления через telegram.Context(bot)
+func TestCorrelationIDReachesHandler(t *testing.T) {
+	var handlerID string
+	handler := func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		handlerID = logging.CorrelationID(telegram.Context(bot))
+	}
+
+	req := newUpdateRequest(telegramtest.MessageUpdate(1, "/start"), updateCommand, 1, nil)
+	chain(handler, withCorrelationID)(nil, req, nil)
+
+	if handlerID == "" || handlerID != logging.CorrelationID(req.Ctx) {
+		t.Errorf("Expected handler to see request correlation ID %q, got %q", logging.CorrelationID(req.Ctx), handlerID)
+	}
+}
+
+// Тест: маршрут, право и ID урока определяются до middleware
+func TestRouteUpdate(t *testing.T) {
+	req := routeUpdate(telegramtest.MessageUpdate(1, "/enroll 15"))
+	if req.Kind != updateCommand || req.Route != "/enroll" || req.Permission != auth.LessonEnroll || req.TargetID != 15 {
+		t.Errorf("Unexpected command route %+v", req)
+	}
+	if _, limited := rateLimitedRoutes[req.Route]; !limited {
+		t.Errorf("Route %s must be rate limited", req.Route)
+	}
+
+	if req := routeUpdate(telegramtest.MessageUpdate(1, "/does_not_exist")); req.Route != routeUnknown {
+		t.Errorf("Expected unknown route, got %s", req.Route)
+	}
+	if req := routeUpdate(telegramtest.MessageUpdate(1, "Иван Иванов")); req.Kind != updateText || req.Permission != "" {
+		t.Errorf("Unexpected text route %+v", req)
+	}
+
+	req = routeUpdate(telegramtest.CallbackUpdate(1, 1, callback.MustEncode(callback.WithID(callback.Waitlist, 7))))
+	if req.Kind != updateCallback || req.Route != string(callback.Waitlist) || req.TargetID != 7 {
+		t.Errorf("Unexpected callback route %+v", req)
+	}
+	if req := routeUpdate(telegramtest.CallbackUpdate(1, 1, "v1:cancel_lesson:1")); req.Route != routeInvalidCallback {
+		t.Errorf("Unsigned destructive button must not be routed, got %s", req.Route)
+	}
+}
+
+// Тест: паника обработчика перехватывается, пользователь получает ответ, метрики видят итог
+func TestRecoveryMiddleware(t *testing.T) {
+	server := telegramtest.NewServer()
+	t.Cleanup(server.Close)
+	api, err := server.Bot()
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	req := &updateRequest{Kind: updateCommand, Route: "/test_panic", ChatID: 42}
+	panics := func(bot telegram.Messenger, req *updateRequest, db *sql.DB) {
+		panic("boom")
+	}
+	chain(panics, withMetrics, withRecovery)(telegram.NewClient(api), req, nil)
+
+	if reply, ok := server.LastMessage(42); !ok || !strings.Contains(reply.Text(), "Внутренняя ошибка") {
+		t.Errorf("Expected an error reply, got %q", reply.Text())
+	}
+	for _, stats := range UpdateStats() {
+		if stats.Route == "/test_panic" {
+			if stats.Outcomes[outcomePanic] != 1 || stats.Count() != 1 {
+				t.Errorf("Expected one panic, got %v", stats.Outcomes)
+			}
+			return
+		}
+	}
+	t.Error("Panicked route is missing from metrics")
+}
+
+// Тест: метрики суммируют итоги и время по маршруту
+func TestUpdateMetrics(t *testing.T) {
+	metrics := &updateMetricsRegistry{routes: make(map[routeKey]*RouteStats)}
+	metrics.record(&updateRequest{Kind: updateCallback, Route: "enroll"}, time.Second)
+	metrics.record(&updateRequest{Kind: updateCallback, Route: "enroll", Outcome: outcomeDenied}, time.Second)
+	metrics.record(&updateRequest{Kind: updateCommand, Route: "/enroll"}, time.Second)
+
+	stats := metrics.routes[routeKey{updateCallback, "enroll"}]
+	if stats.Count() != 2 || stats.Outcomes[outcomeOK] != 1 || stats.Outcomes[outcomeDenied] != 1 || stats.Duration != 2*time.Second {
+		t.Errorf("Unexpected callback stats %+v", stats)
+	}
+	if len(metrics.routes) != 2 {
+		t.Errorf("Commands and callbacks with the same name must be counted separately, got %d routes", len(metrics.routes))
+	}
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Типы рассылок в очереди уведомлений (notification_batches.kind)
+const (
+	notificationLessonMessage     = "lesson_message"
+	notificationLessonCancelled   = "lesson_cancelled"
+	notificationLessonDeleted     = "lesson_deleted"
+	notificationLessonRescheduled = "lesson_rescheduled"
+	notificationLessonRestored    = "lesson_restored"
+	notificationTeacherDeleted    = "teacher_deleted"
+	notificationTeacherRestored   = "teacher_restored"
+	notificationWaitlistPromoted  = "waitlist_promoted"
+	notificationBroadcast         = "notify_all"
+	notificationLessonReminder    = "lesson_reminder"
+	notificationCourseScheduled   = "course_scheduled"
+)
+
+// enqueueNotification - рассылка одного Markdown-текста получателям через очередь уведомлений.
+// Подставленные в текст названия и имена экранируются вызывающим (escapeMarkdown): сообщение
+// с непарной разметкой Telegram отклоняет, и диспетчер не повторяет его отправку.
+// Отправляет диспетчер (internal/notify) с учетом лимитов Telegram; отчет о доставке
+// получит reportChatID (0 - без отчета). Возвращает число получателей.
+func enqueueNotification(bot telegram.Messenger, db *sql.DB, kind, text string, chatIDs []int64, senderID, reportChatID int64) (int, error) {
+	messages := make([]store.OutboxMessage, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		messages = append(messages, store.OutboxMessage{ChatID: chatID, Text: text})
+	}
+	return enqueueMessages(bot, db, kind, notificationTitle(text), messages, senderID, reportChatID)
+}
+
+// enqueueMessages - рассылка персональных Markdown-сообщений через очередь уведомлений
+func enqueueMessages(bot telegram.Messenger, db *sql.DB, kind, title string, messages []store.OutboxMessage, senderID, reportChatID int64) (int, error) {
+	if len(messages) == 0 {
+		return 0, nil
+	}
+	for i := range messages {
+		messages[i].ParseMode = "Markdown"
+	}
+
+	_, err := repos(db).Outbox.Enqueue(store.NotificationBatch{
+		Kind:         kind,
+		Title:        title,
+		CreatedBy:    senderID,
+		ReportChatID: reportChatID,
+	}, messages)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка постановки уведомлений в очередь", "kind", kind, "recipients", len(messages), "err", err)
+		return 0, err
+	}
+	return len(messages), nil
+}
+
+// notificationTitle - заголовок рассылки для отчета: первая строка текста
+func notificationTitle(text string) string {
+	title, _, _ := strings.Cut(text, "\n")
+	return title
+}
+
+// queuedReport - строки ответа отправителю о рассылке, поставленной в очередь
+func queuedReport(queued int, err error) string {
+	switch {
+	case err != nil:
+		return "❌ Не удалось поставить уведомления в очередь"
+	case queued == 0:
+		return "📭 Получателей нет"
+	default:
+		return fmt.Sprintf("📤 В очереди на отправку: %d\n📬 Отчет о доставке придет отдельным сообщением", queued)
+	}
+}
+
+// studentChatIDs - Telegram ID студентов для рассылки
+func studentChatIDs(students []store.Student) []int64 {
+	chatIDs := make([]int64, 0, len(students))
+	for _, student := range students {
+		chatIDs = append(chatIDs, student.TelegramID)
+	}
+	return chatIDs
+}
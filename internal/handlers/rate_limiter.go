@@ -1,15 +1,18 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"sync"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
 )
 
 // ========================= БЕЛОЕ ПЯТНО #3: RATE-LIMITING =========================
@@ -23,13 +26,16 @@ const (
 
 // RateLimiter - структура для управления rate limiting
 type RateLimiter struct {
-	db *sql.DB
-	mu sync.RWMutex
+	operations store.OperationRepository
+	mu         sync.RWMutex
 }
 
+// operationTTL - незавершенная операция перестает блокировать пользователя через TIMEOUT_MINUTES
+const operationTTL = TIMEOUT_MINUTES * time.Minute
+
 // NewRateLimiter - создает новый instance rate limiter
-func NewRateLimiter(db *sql.DB) *RateLimiter {
-	return &RateLimiter{db: db}
+func NewRateLimiter(operations store.OperationRepository) *RateLimiter {
+	return &RateLimiter{operations: operations}
 }
 
 // IsOperationAllowed - проверяет можно ли выполнить операцию
@@ -37,48 +43,23 @@ func (rl *RateLimiter) IsOperationAllowed(userID int64, operation string, lesson
 	rl.mu.RLock()
 	defer rl.mu.RUnlock()
 
-	// Получаем user_id из БД по tg_id
-	var dbUserID int
-	userIDStr := strconv.FormatInt(userID, 10)
-	err := rl.db.QueryRow("SELECT id FROM users WHERE tg_id = $1", userIDStr).Scan(&dbUserID)
-	if err != nil {
-		log.Printf("Ошибка получения user_id: %v", err)
+	// Активные операции этого типа и операции любого типа для конкретного урока
+	byOperation, byLesson, err := rl.operations.Pending(userID, operation, lessonID, operationTTL)
+	if errors.Is(err, store.ErrNotFound) {
+		slog.Error("Ошибка получения user_id", "tg_id", userID)
 		return false, errors.New("Ошибка проверки прав доступа")
-	}
-	
-	// Проверяем есть ли активная операция этого типа от этого пользователя
-	var pendingCount int
-	err = rl.db.QueryRow(fmt.Sprintf(`
-		SELECT COUNT(*) FROM pending_operations 
-		WHERE user_id = $1 AND operation = $2 AND created_at > NOW() - INTERVAL '%d minutes'`, TIMEOUT_MINUTES),
-		dbUserID, operation).Scan(&pendingCount)
-	
-	if err != nil {
-		log.Printf("Ошибка проверки pending operations: %v", err)
+	} else if err != nil {
+		slog.Error("Ошибка проверки pending operations", "err", err)
 		return false, errors.New("Ошибка проверки системы")
 	}
 
-	if pendingCount > 0 {
+	if byOperation > 0 {
 		return false, fmt.Errorf("⏳ Пожалуйста, подождите. У вас есть незавершенная операция '%s'.\n"+
 			"Повторите команду через несколько секунд.", getOperationName(operation))
 	}
 	
-	// Проверяем есть ли активная операция для конкретного урока (любого типа)
-	if lessonID > 0 {
-		var lessonPendingCount int
-		err = rl.db.QueryRow(fmt.Sprintf(`
-			SELECT COUNT(*) FROM pending_operations 
-			WHERE user_id = $1 AND lesson_id = $2 AND created_at > NOW() - INTERVAL '%d minutes'`, TIMEOUT_MINUTES),
-			dbUserID, lessonID).Scan(&lessonPendingCount)
-		
-		if err != nil {
-			log.Printf("Ошибка проверки lesson-specific pending operations: %v", err)
-			return false, errors.New("Ошибка проверки системы")
-		}
-
-		if lessonPendingCount > 0 {
-			return false, errors.New("⏳ У вас уже есть незавершенная операция для этого урока. Подождите несколько секунд.")
-		}
+	if lessonID > 0 && byLesson > 0 {
+		return false, errors.New("⏳ У вас уже есть незавершенная операция для этого урока. Подождите несколько секунд.")
 	}
 	
 	return true, nil
@@ -89,27 +70,12 @@ func (rl *RateLimiter) StartOperation(userID int64, operation string, lessonID i
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	// Получаем user_id из БД по tg_id
-	var dbUserID int
-	userIDStr := strconv.FormatInt(userID, 10)
-	err := rl.db.QueryRow("SELECT id FROM users WHERE tg_id = $1", userIDStr).Scan(&dbUserID)
-	if err != nil {
-		log.Printf("Ошибка получения user_id для StartOperation: %v", err)
-		return errors.New("Ошибка системы")
-	}
-
-	// Добавляем запись о начале операции
-	_, err = rl.db.Exec(`
-		INSERT INTO pending_operations (user_id, operation, lesson_id, created_at)
-		VALUES ($1, $2, $3, NOW())`,
-		dbUserID, operation, lessonID)
-	
-	if err != nil {
-		log.Printf("Ошибка добавления pending operation: %v", err)
+	if err := rl.operations.Start(userID, operation, lessonID); err != nil {
+		slog.Error("Ошибка добавления pending operation", "err", err)
 		return errors.New("Ошибка системы")
 	}
 	
-	log.Printf("🔄 Начата операция: user=%d, operation=%s, lesson=%d", userID, operation, lessonID)
+	slog.Debug("Начата операция", "tg_id", userID, "operation", operation, "lesson_id", lessonID)
 	return nil
 }
 
@@ -118,27 +84,12 @@ func (rl *RateLimiter) FinishOperation(userID int64, operation string, lessonID
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	// Получаем user_id из БД по tg_id
-	var dbUserID int
-	userIDStr := strconv.FormatInt(userID, 10)
-	err := rl.db.QueryRow("SELECT id FROM users WHERE tg_id = $1", userIDStr).Scan(&dbUserID)
-	if err != nil {
-		log.Printf("Ошибка получения user_id для FinishOperation: %v", err)
-		return errors.New("Ошибка системы")
-	}
-
-	// Удаляем запись об операции
-	_, err = rl.db.Exec(`
-		DELETE FROM pending_operations 
-		WHERE user_id = $1 AND operation = $2 AND lesson_id = $3`,
-		dbUserID, operation, lessonID)
-	
-	if err != nil {
-		log.Printf("Ошибка удаления pending operation: %v", err)
+	if err := rl.operations.Finish(userID, operation, lessonID); err != nil {
+		slog.Error("Ошибка удаления pending operation", "err", err)
 		return errors.New("Ошибка системы")
 	}
 	
-	log.Printf("✅ Завершена операция: user=%d, operation=%s, lesson=%d", userID, operation, lessonID)
+	slog.Debug("Завершена операция", "tg_id", userID, "operation", operation, "lesson_id", lessonID)
 	return nil
 }
 
@@ -147,33 +98,37 @@ func (rl *RateLimiter) CleanupExpiredOperations() error {
 	rl.mu.Lock()
 	defer rl.mu.Unlock()
 
-	result, err := rl.db.Exec(fmt.Sprintf(`
-		DELETE FROM pending_operations 
-		WHERE created_at < NOW() - INTERVAL '%d minutes'`, TIMEOUT_MINUTES))
-	
+	rowsAffected, err := rl.operations.Expire(operationTTL)
 	if err != nil {
-		log.Printf("Ошибка очистки expired operations: %v", err)
+		slog.Error("Ошибка очистки expired operations", "err", err)
 		return err
 	}
 	
-	rowsAffected, _ := result.RowsAffected()
 	if rowsAffected > 0 {
-		log.Printf("🗑️ Очищено %d истекших операций", rowsAffected)
+		slog.Info("Очищены истекшие операции", "count", rowsAffected)
 	}
 	
 	return nil
 }
 
-// Периодическая очистка истекших операций
-func (rl *RateLimiter) StartCleanupWorker() {
+// Периодическая очистка истекших операций (до отмены ctx)
+func (rl *RateLimiter) StartCleanupWorker(ctx context.Context) {
+	backgroundWorkers.Add(1)
 	go func() {
+		defer backgroundWorkers.Done()
 		ticker := time.NewTicker(2 * time.Minute) // Очистка каждые 2 минуты
 		defer ticker.Stop()
 		
-		for range ticker.C {
-			err := rl.CleanupExpiredOperations()
-			if err != nil {
-				log.Printf("⚠️ Ошибка периодической очистки: %v", err)
+		for {
+			select {
+			case <-ctx.Done():
+				slog.Info("Очистка rate limiter остановлена")
+				return
+			case <-ticker.C:
+				err := rl.CleanupExpiredOperations()
+				if err != nil {
+					slog.Error("Ошибка периодической очистки", "err", err)
+				}
 			}
 		}
 	}()
@@ -199,10 +154,10 @@ func getOperationName(operation string) string {
 var globalRateLimiter *RateLimiter
 
 // InitializeRateLimiter - инициализирует глобальный rate limiter
-func InitializeRateLimiter(db *sql.DB) {
-	globalRateLimiter = NewRateLimiter(db)
-	globalRateLimiter.StartCleanupWorker()
-	log.Println("🚀 Rate Limiter инициализирован")
+func InitializeRateLimiter(ctx context.Context, db *sql.DB) {
+	globalRateLimiter = NewRateLimiter(repos(db).Operations)
+	globalRateLimiter.StartCleanupWorker(ctx)
+	slog.Info("Rate limiter инициализирован")
 }
 
 // ExtractLessonIDFromMessage - извлекает lesson_id из сообщения  
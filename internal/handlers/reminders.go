@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/reminders"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Интервалы автоматических напоминаний (REMINDER_OFFSETS): типы, которые студент может отключить
+var reminderOffsets []time.Duration
+
+// InitializeReminders - устанавливает интервалы автоматических напоминаний
+func InitializeReminders(offsets []time.Duration) {
+	reminderOffsets = reminders.Normalize(offsets)
+}
+
+// Команда настройки автоматических напоминаний: /reminders [on|off <тип|all>]
+func handleRemindersCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	if len(reminderOffsets) == 0 {
+		sendMessage(bot, message.Chat.ID, "🔕 Автоматические напоминания об уроках в боте отключены")
+		return
+	}
+
+	studentID, err := getStudentID(db, int(message.From.ID))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка: вы не зарегистрированы как студент")
+		return
+	}
+
+	st := repos(db)
+	args := strings.Fields(message.Text)
+	if len(args) >= 2 {
+		if len(args) < 3 || (args[1] != "on" && args[1] != "off") {
+			sendMessage(bot, message.Chat.ID, "❌ Используйте: /reminders on <тип> или /reminders off <тип>")
+			return
+		}
+		optOut := args[1] == "off"
+
+		var selected []string
+		for _, offset := range reminderOffsets {
+			if label := reminders.Label(offset); args[2] == "all" || args[2] == label {
+				selected = append(selected, label)
+			}
+		}
+		if len(selected) == 0 {
+			sendMessage(bot, message.Chat.ID, fmt.Sprintf("❌ Неизвестный тип напоминаний: %s\nДоступные: %s, all",
+				args[2], strings.Join(reminderLabels(), ", ")))
+			return
+		}
+
+		for _, reminder := range selected {
+			if err := st.Reminders.SetOptOut(studentID, reminder, optOut); err != nil {
+				slog.ErrorContext(telegram.Context(bot), "Ошибка настройки напоминаний", "reminder", reminder, "err", err)
+				sendMessage(bot, message.Chat.ID, "❌ Ошибка сохранения настройки напоминаний")
+				return
+			}
+		}
+
+		action := "reminders_enabled"
+		if optOut {
+			action = "reminders_disabled"
+		}
+		LogUserAction(telegram.Context(bot), db, action, message.From.ID, strings.Join(selected, ", "))
+	}
+
+	optedOut, err := st.Reminders.OptedOut(studentID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения настроек напоминаний", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения настроек напоминаний")
+		return
+	}
+	disabled := make(map[string]bool, len(optedOut))
+	for _, reminder := range optedOut {
+		disabled[reminder] = true
+	}
+
+	text := "🔔 **Напоминания об уроках**\n\n"
+	for _, offset := range reminderOffsets {
+		label := reminders.Label(offset)
+		status := "✅ включено"
+		if disabled[label] {
+			status = "🔕 отключено"
+		}
+		text += fmt.Sprintf("• за %s (`%s`) - %s\n", reminders.Describe(offset), label, status)
+	}
+	text += "\nОтключить: `/reminders off <тип>`\n" +
+		"Включить: `/reminders on <тип>`\n" +
+		"Все сразу: `/reminders off all`"
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// reminderLabels - типы автоматических напоминаний
+func reminderLabels() []string {
+	labels := make([]string, 0, len(reminderOffsets))
+	for _, offset := range reminderOffsets {
+		labels = append(labels, reminders.Label(offset))
+	}
+	return labels
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"database/sql"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Репозитории данных: инициализируются из main, в тестах подменяются фейками
+var dataStore *store.Store
+
+// InitializeStore - устанавливает репозитории для обработчиков
+func InitializeStore(st *store.Store) {
+	dataStore = st
+}
+
+// repos - инициализированные репозитории, иначе репозитории поверх переданного соединения
+func repos(db *sql.DB) *store.Store {
+	if dataStore != nil {
+		return dataStore
+	}
+	return store.New(db)
+}
@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Поддерживаемые форматы даты и времени урока
+var lessonDateTimeFormats = []string{
+	"02.01.2006 15:04",
+	"2.01.2006 15:04",
+	"02.1.2006 15:04",
+	"2.1.2006 15:04",
+	"02.01.2006 15:4",
+	"2.01.2006 15:4",
+	"2006-01-02 15:04",
+}
+
+// Парсинг даты и времени урока с несколькими форматами
+func parseLessonDateTime(dateStr, timeStr string) (time.Time, error) {
+	datetimeStr := dateStr + " " + timeStr
+
+	var startTime time.Time
+	var err error
+	for _, format := range lessonDateTimeFormats {
+		startTime, err = time.Parse(format, datetimeStr)
+		if err == nil {
+			return startTime, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("неверный формат даты или времени: '%s'", datetimeStr)
+}
+
+// Перенос урока (/reschedule_lesson для преподавателей, /reschedule_with_notify для администраторов)
+func handleRescheduleLessonCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	userID := message.From.ID
+
+	// Парсинг аргументов команды
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 3 {
+		helpText := "📝 **Перенос урока**\n\n" +
+			"**Формат:** `/" + message.Command() + " <ID урока> <новая дата> <новое время>`\n\n" +
+			"**Пример:** `/" + message.Command() + " 123 16.08.2025 15:00`\n\n" +
+			"**Что произойдет:**\n" +
+			"• Урок будет перенесен на новое время\n" +
+			"• Записи студентов и лист ожидания сохранятся\n" +
+			"• Все записанные студенты получат уведомление"
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	lessonID, err := strconv.Atoi(args[0])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
+		return
+	}
+
+	newStartTime, err := parseLessonDateTime(args[1], args[2])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\nИспользуйте DD.MM.YYYY HH:MM или D.M.YYYY H:MM")
+		return
+	}
+
+	if !newStartTime.After(time.Now()) {
+		sendMessage(bot, message.Chat.ID, "❌ Нельзя перенести урок в прошлое")
+		return
+	}
+
+	// Без права на любые уроки переносить можно только свои
+	st := repos(db)
+	lesson, err := st.Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted || lesson.Status != "active" {
+		sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
+		return
+	}
+	if !canManageLesson(st, user, lessonID) {
+		sendMessage(bot, message.Chat.ID, "❌ Урок не найден или не принадлежит вам")
+		return
+	}
+
+	lesson.StartTime, err = st.Lessons.Reschedule(lessonID, newStartTime)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
+		return
+	case errors.Is(err, store.ErrScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ У преподавателя уже есть урок, пересекающийся с новым временем")
+		return
+	case errors.Is(err, store.ErrStudentScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Один из записанных студентов занят на другом уроке в новое время")
+		return
+	case errors.Is(err, store.ErrRoomConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Аудитория урока занята в новое время")
+		return
+	case err != nil:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка переноса урока", "lesson_id", lessonID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка при переносе урока")
+		return
+	}
+
+	audit(bot, db, userID, auditLessonRescheduled, store.AuditLesson, lessonID,
+		auditState{"start_time": lesson.StartTime}, auditState{"start_time": newStartTime})
+
+	// Уведомляем всех записанных студентов
+	queued, err := notifyStudentsAboutReschedule(bot, db, lesson, newStartTime, userID, message.Chat.ID)
+
+	LogUserAction(telegram.Context(bot), db, "lesson_rescheduled", userID, fmt.Sprintf("Урок %d (%s) перенесен с %s на %s, уведомлений в очереди: %d",
+		lessonID, lesson.SubjectName, lesson.StartTime.Format("02.01.2006 15:04"), newStartTime.Format("02.01.2006 15:04"), queued))
+
+	resultText := fmt.Sprintf("✅ **Урок перенесен**\n\n"+
+		"📚 Предмет: %s\n"+
+		"👨‍🏫 Преподаватель: %s\n"+
+		"📅 Было: %s\n"+
+		"🔄 Стало: %s\n\n"+
+		"%s",
+		escapeMarkdown(lesson.SubjectName), escapeMarkdown(lesson.TeacherName),
+		lesson.StartTime.Format("02.01.2006 15:04"), newStartTime.Format("02.01.2006 15:04"),
+		queuedReport(queued, err))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Уведомление студентов о переносе урока (старое и новое время) через очередь
+func notifyStudentsAboutReschedule(bot telegram.Messenger, db *sql.DB, lesson *store.Lesson, newStartTime time.Time, senderID, reportChatID int64) (int, error) {
+	students, err := repos(db).Enrollments.EnrolledStudents(lesson.ID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения студентов урока", "lesson_id", lesson.ID, "err", err)
+		return 0, err
+	}
+
+	notificationText := fmt.Sprintf("🔄 **Урок перенесен**\n\n"+
+		"📚 Предмет: %s\n"+
+		"👨‍🏫 Преподаватель: %s\n"+
+		"📅 Было: %s\n"+
+		"✅ Стало: %s\n\n"+
+		"Ваша запись сохранена. Ждем вас в новое время!",
+		escapeMarkdown(lesson.SubjectName), escapeMarkdown(lesson.TeacherName),
+		lesson.StartTime.Format("02.01.2006 15:04"), newStartTime.Format("02.01.2006 15:04"))
+
+	return enqueueNotification(bot, db, notificationLessonRescheduled, notificationText, studentChatIDs(students), senderID, reportChatID)
+}
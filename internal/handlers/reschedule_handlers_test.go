@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Тест: нарушения ограничений пересечения расписания при переносе урока становятся ошибками store
+func TestRescheduleConflict(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected error
+	}{
+		{&pq.Error{Code: "23P01", Constraint: "lessons_teacher_no_overlap"}, store.ErrScheduleConflict},
+		{&pq.Error{Code: "23P01", Constraint: "enrollments_student_no_overlap"}, store.ErrStudentScheduleConflict},
+		{&pq.Error{Code: "23P01", Constraint: "lessons_room_no_overlap"}, store.ErrRoomConflict},
+		{nil, nil},
+	}
+	for _, tt := range tests {
+		if err := store.ScheduleConflict(tt.err); !errors.Is(err, tt.expected) {
+			t.Errorf("ScheduleConflict(%v) = %v, expected %v", tt.err, err, tt.expected)
+		}
+	}
+
+	other := &pq.Error{Code: "23505", Constraint: "lessons_teacher_no_overlap"}
+	if err := store.ScheduleConflict(other); err != other {
+		t.Errorf("Expected unrelated error to pass through, got %v", err)
+	}
+}
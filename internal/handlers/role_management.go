@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Назначение администратора: /grant_admin <telegram_id>
+func handleGrantAdminCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	target, ok := parseRoleTarget(bot, message, db)
+	if !ok {
+		return
+	}
+
+	switch auth.ParseRole(target.Role) {
+	case auth.Admin:
+		sendMessage(bot, message.Chat.ID, "ℹ️ Пользователь уже администратор")
+		return
+	case auth.Superuser:
+		sendMessage(bot, message.Chat.ID, "❌ Роль суперпользователя не меняется командами бота")
+		return
+	}
+
+	changeUserRole(bot, message, db, target, auth.Admin)
+}
+
+// Снятие прав администратора: /revoke_admin <telegram_id>
+// Пользователь возвращается к роли преподавателя, если у него есть карточка преподавателя, иначе - студента.
+func handleRevokeAdminCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	target, ok := parseRoleTarget(bot, message, db)
+	if !ok {
+		return
+	}
+
+	if auth.ParseRole(target.Role) != auth.Admin {
+		sendMessage(bot, message.Chat.ID, "❌ Пользователь не является администратором")
+		return
+	}
+
+	newRole := auth.Student
+	if teacher, err := repos(db).Teachers.GetByTelegramID(target.TelegramID); err == nil && !teacher.SoftDeleted {
+		newRole = auth.Teacher
+	}
+	changeUserRole(bot, message, db, target, newRole)
+}
+
+// parseRoleTarget - пользователь из аргумента команды; при ошибке отправляет подсказку
+func parseRoleTarget(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) (*store.User, bool) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) != 1 {
+		helpText := "🔑 **Роль администратора**\n\n" +
+			"**Формат:** `/" + message.Command() + " <telegram_id>`\n\n" +
+			"**Пример:** `/" + message.Command() + " 123456789`\n\n" +
+			"Администратор управляет уроками, студентами и рассылками, " +
+			"но не преподавателями и ролями."
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return nil, false
+	}
+
+	telegramID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || telegramID <= 0 {
+		sendMessage(bot, message.Chat.ID, "❌ Некорректный Telegram ID")
+		return nil, false
+	}
+	if telegramID == message.From.ID {
+		sendMessage(bot, message.Chat.ID, "❌ Нельзя менять собственную роль")
+		return nil, false
+	}
+
+	target, err := repos(db).Users.GetByTelegramID(telegramID)
+	if errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Пользователь не найден. Он должен сначала зарегистрироваться в боте")
+		return nil, false
+	} else if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка поиска пользователя")
+		return nil, false
+	}
+	return target, true
+}
+
+// changeUserRole - смена роли с записью в лог и уведомлением пользователя
+func changeUserRole(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, target *store.User, role auth.Role) {
+	previousRole := target.Role
+	if err := repos(db).Users.SetRole(target.TelegramID, string(role)); err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка смены роли")
+		return
+	}
+
+	LogUserAction(telegram.Context(bot), db, "role_changed", message.From.ID, fmt.Sprintf("Пользователь %s (%d): %s -> %s",
+		target.FullName, target.TelegramID, previousRole, role))
+	audit(bot, db, message.From.ID, auditRoleChanged, store.AuditUser, target.ID,
+		auditState{"role": previousRole}, auditState{"role": string(role)})
+
+	resultText := fmt.Sprintf("✅ **Роль изменена**\n\n"+
+		"👤 Пользователь: %s\n"+
+		"🎭 Было: %s\n"+
+		"🎭 Стало: %s", target.FullName, previousRole, role)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+
+	sendMessage(bot, target.TelegramID, fmt.Sprintf("🔑 Ваша роль изменена: %s. Откройте меню заново: /menu", role))
+}
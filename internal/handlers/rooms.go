@@ -0,0 +1,202 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// roomOption - параметр аудитории в быстрых командах: room=<ID или название>
+const roomOption = "room="
+
+// parseEquipment - теги оборудования через запятую, в нижнем регистре и без повторов
+func parseEquipment(list string) []string {
+	var tags []string
+	for _, tag := range strings.Split(list, ",") {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if tag != "" && !slices.Contains(tags, tag) {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// parseRoomArgs - аудитория из аргументов /add_room: <название> <мест> [оборудование через запятую]
+func parseRoomArgs(args string) (store.Room, error) {
+	fields, err := splitQuotedArgs(args)
+	if err != nil {
+		return store.Room{}, err
+	}
+	if len(fields) < 2 {
+		return store.Room{}, errors.New("укажите название и число мест")
+	}
+
+	capacity, err := strconv.Atoi(fields[1])
+	if err != nil || capacity < minLessonStudents || capacity > maxLessonStudents {
+		return store.Room{}, fmt.Errorf("число мест - от %d до %d", minLessonStudents, maxLessonStudents)
+	}
+	return store.Room{
+		Name:      fields[0],
+		Capacity:  capacity,
+		Equipment: parseEquipment(strings.Join(fields[2:], " ")),
+	}, nil
+}
+
+// cutRoomOption - аргументы команды без параметра room= и ссылка на аудиторию из него
+func cutRoomOption(args string) (string, string) {
+	var rest []string
+	var room string
+	for _, field := range strings.Fields(args) {
+		if ref, ok := strings.CutPrefix(field, roomOption); ok {
+			room = ref
+			continue
+		}
+		rest = append(rest, field)
+	}
+	return strings.Join(rest, " "), room
+}
+
+// formatEquipment - оборудование аудитории для сообщений
+func formatEquipment(equipment []string) string {
+	if len(equipment) == 0 {
+		return "без оборудования"
+	}
+	return strings.Join(equipment, ", ")
+}
+
+// lessonRoom - аудитория для урока по предмету; ошибка - текст для пользователя.
+// Занятость аудитории проверяет ограничение БД при создании урока.
+func lessonRoom(st *store.Store, ref string, subjectID int) (*store.Room, error) {
+	room, err := st.Rooms.Find(ref)
+	if err != nil {
+		return nil, errors.New("аудитория не найдена, список аудиторий: /rooms")
+	}
+	missing, err := st.Rooms.MissingEquipment(room.ID, subjectID)
+	if err != nil {
+		return nil, errors.New("ошибка проверки оборудования аудитории")
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("в аудитории «%s» нет оборудования для предмета: %s", room.Name, strings.Join(missing, ", "))
+	}
+	return room, nil
+}
+
+// Новая аудитория: /add_room <название> <мест> [оборудование через запятую]
+func handleAddRoomCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	room, err := parseRoomArgs(message.CommandArguments())
+	if err != nil {
+		msg := tgbotapi.NewMessage(message.Chat.ID, "❌ "+err.Error()+"\n\n"+
+			"**Формат:** `/add_room <название> <мест> [оборудование через запятую]`\n\n"+
+			"**Пример:** `/add_room \"Класс 3D\" 12 GPU workstations, проектор`")
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	room.CreatedBy = message.From.ID
+	roomID, err := repos(db).Rooms.Create(room)
+	if errors.Is(err, store.ErrRoomExists) {
+		sendMessage(bot, message.Chat.ID, "❌ Аудитория с таким названием уже есть")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка создания аудитории", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания аудитории")
+		return
+	}
+	audit(bot, db, message.From.ID, auditRoomCreated, store.AuditRoom, roomID, nil, auditState{
+		"name":      room.Name,
+		"capacity":  room.Capacity,
+		"equipment": room.Equipment,
+	})
+	LogUserAction(telegram.Context(bot), db, "room_created", message.From.ID,
+		fmt.Sprintf("Аудитория %d (%s), мест: %d", roomID, room.Name, room.Capacity))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, fmt.Sprintf("✅ **Аудитория создана**\n\n"+
+		"🚪 #%d %s\n👥 Мест: %d\n🖥 Оборудование: %s\n\n"+
+		"Назначается при создании урока: `/create_lesson <предмет> <дата> <время> room=%d`",
+		roomID, room.Name, room.Capacity, formatEquipment(room.Equipment), roomID))
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Список аудиторий
+func handleRoomsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	rooms, err := repos(db).Rooms.List()
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения аудиторий", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения аудиторий")
+		return
+	}
+	if len(rooms) == 0 {
+		sendMessage(bot, message.Chat.ID, "📭 Аудитории еще не добавлены")
+		return
+	}
+
+	text := "🚪 **Аудитории:**\n\n"
+	for _, room := range rooms {
+		text += fmt.Sprintf("#%d **%s** - %d мест, %s\n", room.ID, room.Name, room.Capacity, formatEquipment(room.Equipment))
+	}
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// formatRoomSchedule - уроки в аудитории за день
+func formatRoomSchedule(room store.Room, day time.Time, lessons []store.Lesson) string {
+	text := fmt.Sprintf("🚪 **%s** (%d мест)\n📅 %s\n\n", room.Name, room.Capacity, day.Format("02.01.2006"))
+	if len(lessons) == 0 {
+		return text + "Свободна весь день"
+	}
+	for _, lesson := range lessons {
+		end := lesson.StartTime.Add(time.Duration(lesson.DurationMinutes) * time.Minute)
+		text += fmt.Sprintf("🕐 %s-%s #%d %s\n👨‍🏫 %s, 👥 %d/%d\n",
+			lesson.StartTime.Format("15:04"), end.Format("15:04"), lesson.ID, lesson.SubjectName,
+			lesson.TeacherName, lesson.EnrolledCount, lesson.MaxStudents)
+	}
+	return text
+}
+
+// Расписание аудитории на день: /room_schedule <аудитория> [дата]
+func handleRoomScheduleCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите аудиторию: /room_schedule <ID или название> [ДД.ММ.ГГГГ]")
+		return
+	}
+
+	// Дата - необязательный последний аргумент, по умолчанию сегодня
+	day := time.Now()
+	if len(args) > 1 {
+		if date, err := parseLessonDateTime(args[len(args)-1], "00:00"); err == nil {
+			day = date
+			args = args[:len(args)-1]
+		}
+	}
+
+	st := repos(db)
+	room, err := st.Rooms.Find(strings.Trim(strings.Join(args, " "), "\""))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Аудитория не найдена. Список аудиторий: /rooms")
+		return
+	}
+	lessons, err := st.Rooms.Schedule(room.ID, day)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения расписания аудитории", "room_id", room.ID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения расписания аудитории")
+		return
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, formatRoomSchedule(*room, day, lessons))
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
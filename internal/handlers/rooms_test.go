@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Тест разбора /add_room: название в кавычках, оборудование через запятую
+func TestParseRoomArgs(t *testing.T) {
+	room, err := parseRoomArgs("\"Класс 3D\" 12 GPU  Workstations, проектор,, gpu workstations")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if room.Name != "Класс 3D" || room.Capacity != 12 {
+		t.Errorf("Unexpected room: %+v", room)
+	}
+	if !slices.Equal(room.Equipment, []string{"gpu workstations", "проектор"}) {
+		t.Errorf("Unexpected equipment: %q", room.Equipment)
+	}
+
+	for _, args := range []string{"", "\"Класс 3D\"", "\"Класс 3D\" 0", "\"Класс 3D\" 100", "\"Класс 3D 12"} {
+		if _, err := parseRoomArgs(args); err == nil {
+			t.Errorf("Expected error for %q", args)
+		}
+	}
+}
+
+func TestCutRoomOption(t *testing.T) {
+	args, room := cutRoomOption("\"3D-моделирование\" room=2 16.08.2025 16:30")
+	if args != "\"3D-моделирование\" 16.08.2025 16:30" || room != "2" {
+		t.Errorf("Unexpected result: %q, %q", args, room)
+	}
+	if _, room := cutRoomOption("WEB_DEV 16.08.2025 16:30"); room != "" {
+		t.Errorf("Expected no room, got %q", room)
+	}
+}
+
+// Тест: размер группы не больше вместимости аудитории
+func TestRoomCapStudents(t *testing.T) {
+	room := store.Room{Capacity: 8}
+	if got := room.CapStudents(10); got != 8 {
+		t.Errorf("Expected 8, got %d", got)
+	}
+	if got := room.CapStudents(5); got != 5 {
+		t.Errorf("Expected 5, got %d", got)
+	}
+}
+
+func TestFormatRoomSchedule(t *testing.T) {
+	room := store.Room{Name: "Класс 3D", Capacity: 12}
+	day := time.Date(2025, 9, 2, 0, 0, 0, 0, time.UTC)
+
+	if text := formatRoomSchedule(room, day, nil); !strings.Contains(text, "Свободна весь день") {
+		t.Errorf("Expected free day, got %s", text)
+	}
+
+	lessons := []store.Lesson{{ID: 7, SubjectName: "VFX-дизайн", TeacherName: "Анна Петрова",
+		StartTime: day.Add(16*time.Hour + 30*time.Minute), DurationMinutes: 90, EnrolledCount: 3, MaxStudents: 12}}
+	text := formatRoomSchedule(room, day, lessons)
+	for _, want := range []string{"02.09.2025", "16:30-18:00 #7 VFX-дизайн", "Анна Петрова, 👥 3/12"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in %s", want, text)
+		}
+	}
+}
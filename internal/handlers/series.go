@@ -0,0 +1,660 @@
+package handlers
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Область изменения серии: одно занятие, это и следующие, вся серия (будущие занятия)
+const (
+	seriesScopeThis      = "this"
+	seriesScopeFollowing = "following"
+	seriesScopeAll       = "all"
+)
+
+// seriesRequest - аргументы /create_series
+type seriesRequest struct {
+	SubjectName   string
+	StartTime     time.Time
+	IntervalWeeks int
+	Until         time.Time
+	Occurrences   int
+	Skip          []time.Time
+}
+
+// parseSeriesArgs - разбор `<предмет> <дата> <время> <weekly|biweekly> <N|ДД.ММ.ГГГГ> [skip=ДД.ММ.ГГГГ,...]`.
+// Предмет из нескольких слов пишется в кавычках.
+func parseSeriesArgs(args string) (seriesRequest, error) {
+	var req seriesRequest
+
+	fields, err := splitQuotedArgs(args)
+	if err != nil {
+		return req, err
+	}
+	if len(fields) < 5 || fields[0] == "" {
+		return req, errors.New("недостаточно параметров")
+	}
+	req.SubjectName, fields = fields[0], fields[1:]
+
+	startTime, err := parseLessonDateTime(fields[0], fields[1])
+	if err != nil {
+		return req, err
+	}
+	req.StartTime = startTime
+
+	if req.IntervalWeeks, err = parseSeriesInterval(fields[2]); err != nil {
+		return req, err
+	}
+
+	if count, err := strconv.Atoi(fields[3]); err == nil {
+		if count < 1 || count > store.MaxSeriesLessons {
+			return req, fmt.Errorf("число занятий должно быть от 1 до %d", store.MaxSeriesLessons)
+		}
+		req.Occurrences = count
+	} else if until, err := time.Parse("02.01.2006", fields[3]); err == nil {
+		if until.Before(truncateDay(startTime)) {
+			return req, errors.New("дата окончания серии раньше первого занятия")
+		}
+		req.Until = until
+	} else {
+		return req, fmt.Errorf("окончание серии '%s': укажите число занятий или дату ДД.ММ.ГГГГ", fields[3])
+	}
+
+	req.Skip, err = parseSkipDates(fields[4:])
+	return req, err
+}
+
+// splitQuotedArgs - аргументы через пробел; значение из нескольких слов пишется в кавычках
+func splitQuotedArgs(args string) ([]string, error) {
+	var fields []string
+	for args = strings.TrimSpace(args); args != ""; args = strings.TrimSpace(args) {
+		if rest, ok := strings.CutPrefix(args, "\""); ok {
+			end := strings.Index(rest, "\"")
+			if end == -1 {
+				return nil, errors.New("не закрыты кавычки")
+			}
+			fields = append(fields, rest[:end])
+			args = rest[end+1:]
+			continue
+		}
+		field, rest, _ := strings.Cut(args, " ")
+		fields = append(fields, field)
+		args = rest
+	}
+	return fields, nil
+}
+
+// parseSeriesInterval - периодичность серии в неделях
+func parseSeriesInterval(arg string) (int, error) {
+	switch arg {
+	case "weekly":
+		return 1, nil
+	case "biweekly":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("неизвестная периодичность '%s' (weekly или biweekly)", arg)
+}
+
+// parseSkipDates - даты без занятий из параметров `skip=ДД.ММ.ГГГГ,...`
+func parseSkipDates(fields []string) ([]time.Time, error) {
+	var skip []time.Time
+	for _, field := range fields {
+		dates, ok := strings.CutPrefix(field, "skip=")
+		if !ok {
+			return nil, fmt.Errorf("неизвестный параметр '%s'", field)
+		}
+		for _, date := range strings.Split(dates, ",") {
+			day, err := time.Parse("02.01.2006", date)
+			if err != nil {
+				return nil, fmt.Errorf("неверная дата пропуска '%s'", date)
+			}
+			skip = append(skip, day)
+		}
+	}
+	return skip, nil
+}
+
+// truncateDay - начало дня
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
+
+// Дни недели в форме "по понедельникам"
+var seriesWeekdays = []string{"воскресеньям", "понедельникам", "вторникам", "средам", "четвергам", "пятницам", "субботам"}
+
+// formatSeriesRule - правило серии: периодичность, день недели, время и окончание
+func formatSeriesRule(series store.LessonSeries) string {
+	rule := "каждую неделю"
+	if series.IntervalWeeks == 2 {
+		rule = "раз в две недели"
+	}
+	rule += fmt.Sprintf(" по %s в %s", seriesWeekdays[series.StartTime.Weekday()], series.StartTime.Format("15:04"))
+	switch {
+	case !series.Until.IsZero():
+		rule += ", до " + series.Until.Format("02.01.2006")
+	case series.Occurrences > 0:
+		rule += fmt.Sprintf(", %d занятий", series.Occurrences)
+	}
+	return rule
+}
+
+// formatSkipDates - даты исключений серии
+func formatSkipDates(dates []time.Time) string {
+	formatted := make([]string, len(dates))
+	for i, date := range dates {
+		formatted[i] = date.Format("02.01.2006")
+	}
+	return strings.Join(formatted, ", ")
+}
+
+// Создание серии повторяющихся уроков
+func handleCreateSeriesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	userID := message.From.ID
+
+	args := message.CommandArguments()
+	if args == "" {
+		helpText := "🔁 **Серия уроков**\n\n" +
+			"**Формат:** `/create_series <предмет> <дата> <время> <weekly|biweekly> <число занятий|дата окончания> [skip=<даты>]`\n\n" +
+			"**Примеры:**\n" +
+			"• `/create_series \"3D-моделирование\" 02.09.2025 16:30 weekly 12`\n" +
+			"• `/create_series Геймдев 03.09.2025 18:00 biweekly 24.12.2025 skip=05.11.2025`\n\n" +
+			"`weekly` - каждую неделю, `biweekly` - раз в две недели. " +
+			"В даты из `skip=` (через запятую) занятий не будет."
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	req, err := parseSeriesArgs(args)
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\nФормат: /create_series <предмет> <дата> <время> <weekly|biweekly> <N|ДД.ММ.ГГГГ>")
+		return
+	}
+	if req.StartTime.Before(time.Now()) {
+		sendMessage(bot, message.Chat.ID, "❌ Первое занятие серии не может быть в прошлом")
+		return
+	}
+
+	subject, err := repos(db).Subjects.GetByName(req.SubjectName)
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Предмет не найден. Используйте /subjects для просмотра доступных предметов")
+		return
+	}
+	subjectID := subject.ID
+
+	teacherID, err := getTeacherID(db, int(userID))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
+		return
+	}
+
+	series := store.LessonSeries{
+		SubjectID:       subjectID,
+		SubjectName:     req.SubjectName,
+		TeacherID:       teacherID,
+		StartTime:       req.StartTime,
+		DurationMinutes: int(defaultLessonDuration / time.Minute),
+		MaxStudents:     defaultMaxStudents,
+		IntervalWeeks:   req.IntervalWeeks,
+		Until:           req.Until,
+		Occurrences:     req.Occurrences,
+		Skip:            req.Skip,
+		CreatedBy:       userID,
+	}
+	dates := series.Dates()
+	if len(dates) == 0 {
+		sendMessage(bot, message.Chat.ID, "❌ По этому правилу в серии нет ни одного занятия")
+		return
+	}
+
+	seriesID, err := repos(db).Series.Create(series, dates)
+	if errors.Is(err, store.ErrScheduleConflict) {
+		sendMessage(bot, message.Chat.ID, "❌ У преподавателя уже есть урок, пересекающийся с одним из занятий серии")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка создания серии уроков", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания серии уроков")
+		return
+	}
+	audit(bot, db, userID, auditSeriesCreated, store.AuditSeries, seriesID, nil, auditState{
+		"subject_id":     subjectID,
+		"teacher_id":     teacherID,
+		"start_time":     req.StartTime,
+		"interval_weeks": req.IntervalWeeks,
+		"lessons":        len(dates),
+	})
+	LogUserAction(telegram.Context(bot), db, "series_created", userID,
+		fmt.Sprintf("Серия %d (%s), занятий: %d", seriesID, req.SubjectName, len(dates)))
+
+	resultText := "✅ **Серия уроков создана**\n\n" +
+		"📚 Предмет: " + escapeMarkdown(req.SubjectName) + "\n" +
+		"🔁 " + formatSeriesRule(series) + "\n" +
+		fmt.Sprintf("📅 С %s по %s\n", dates[0].Format("02.01.2006"), dates[len(dates)-1].Format("02.01.2006")) +
+		fmt.Sprintf("🗓 Занятий: %d\n", len(dates))
+	if len(req.Skip) > 0 {
+		resultText += "⏭ Без занятий: " + formatSkipDates(req.Skip) + "\n"
+	}
+	resultText += fmt.Sprintf("🆔 Серия: %d\n\n", seriesID) +
+		fmt.Sprintf("Студенты могут записаться на всю серию: `/enroll_series %d`", seriesID)
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Просмотр серии: правило и предстоящие занятия
+func handleSeriesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	seriesID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID серии: /series <series_id>")
+		return
+	}
+
+	st := repos(db)
+	series, err := st.Series.Get(seriesID)
+	if errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Серия не найдена")
+		return
+	}
+	lessons, lessonsErr := st.Series.Lessons(seriesID)
+	if err != nil || lessonsErr != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения серии", "series_id", seriesID, "err", errors.Join(err, lessonsErr))
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения серии")
+		return
+	}
+
+	text := fmt.Sprintf("🔁 **Серия #%d: %s**\n\n", series.ID, escapeMarkdown(series.SubjectName))
+	if series.TeacherName != "" {
+		text += "👨‍🏫 Преподаватель: " + escapeMarkdown(series.TeacherName) + "\n"
+	}
+	text += "📅 " + formatSeriesRule(*series) + "\n"
+	if len(series.Skip) > 0 {
+		text += "⏭ Без занятий: " + formatSkipDates(series.Skip) + "\n"
+	}
+	if series.Status == "cancelled" {
+		text += "❌ Серия отменена\n"
+	}
+
+	now := time.Now()
+	past := 0
+	text += "\n**Занятия:**\n"
+	for _, lesson := range lessons {
+		if !lesson.StartTime.After(now) {
+			past++
+			continue
+		}
+		icon := "✅"
+		if lesson.Status != "active" {
+			icon = "❌"
+		}
+		text += fmt.Sprintf("%s %s %s #%d\n", icon, lesson.StartTime.Format("02.01.2006 15:04"), formatLessonSpots(lesson), lesson.ID)
+	}
+	if past > 0 {
+		text += fmt.Sprintf("_Прошедших занятий: %d_\n", past)
+	}
+	if series.Status == "active" {
+		text += fmt.Sprintf("\nЗаписаться на всю серию: `/enroll_series %d`", series.ID)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, text)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// seriesOccurrence - урок серии, права на него и затронутые изменением занятия.
+// Возвращает текст ошибки для пользователя или пустую строку.
+func seriesOccurrence(st *store.Store, user *requestUser, lessonID int, scope string, now time.Time) (*store.Lesson, []store.Lesson, string) {
+	lesson, err := st.Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted || !canManageLesson(st, user, lessonID) {
+		return nil, nil, "❌ Урок не найден или не принадлежит вам"
+	}
+	if lesson.SeriesID == 0 {
+		return nil, nil, "❌ Урок не входит в серию. Для разового урока используйте /reschedule_lesson или /cancel_lesson"
+	}
+	if !lesson.IsBookable(now) {
+		return nil, nil, "❌ Занятие уже прошло или отменено"
+	}
+
+	lessons, err := st.Series.Lessons(lesson.SeriesID)
+	if err != nil {
+		return nil, nil, "❌ Ошибка получения занятий серии"
+	}
+
+	var affected []store.Lesson
+	for _, l := range lessons {
+		if !l.IsBookable(now) {
+			continue
+		}
+		switch {
+		case scope == seriesScopeThis && l.ID == lesson.ID,
+			scope == seriesScopeFollowing && !l.StartTime.Before(lesson.StartTime),
+			scope == seriesScopeAll:
+			affected = append(affected, l)
+		}
+	}
+	return lesson, affected, ""
+}
+
+// parseSeriesScope - область изменения серии из аргумента команды
+func parseSeriesScope(arg string) (string, bool) {
+	switch arg {
+	case seriesScopeThis, seriesScopeFollowing, seriesScopeAll:
+		return arg, true
+	}
+	return "", false
+}
+
+// seriesNotifications - персональные сообщения записанным студентам: по строке на каждое их занятие
+func seriesNotifications(st *store.Store, lessons []store.Lesson, header string, line func(store.Lesson) string) ([]store.OutboxMessage, error) {
+	var order []int64
+	lines := make(map[int64][]string)
+	for _, lesson := range lessons {
+		students, err := st.Enrollments.EnrolledStudents(lesson.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, student := range students {
+			if _, ok := lines[student.TelegramID]; !ok {
+				order = append(order, student.TelegramID)
+			}
+			lines[student.TelegramID] = append(lines[student.TelegramID], "• "+line(lesson))
+		}
+	}
+
+	messages := make([]store.OutboxMessage, 0, len(order))
+	for _, chatID := range order {
+		messages = append(messages, store.OutboxMessage{
+			ChatID: chatID,
+			Text:   header + strings.Join(lines[chatID], "\n"),
+		})
+	}
+	return messages, nil
+}
+
+// Перенос занятий серии: /edit_series <lesson_id> <this|following|all> <дата> <время>
+func handleEditSeriesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	userID := message.From.ID
+
+	args := strings.Fields(message.CommandArguments())
+	scope, scopeOK := "", false
+	if len(args) >= 2 {
+		scope, scopeOK = parseSeriesScope(args[1])
+	}
+	if len(args) < 4 || !scopeOK {
+		helpText := "🔁 **Перенос занятий серии**\n\n" +
+			"**Формат:** `/edit_series <ID урока> <this|following|all> <новая дата> <новое время>`\n\n" +
+			"• `this` - только это занятие\n" +
+			"• `following` - это и следующие\n" +
+			"• `all` - все предстоящие занятия серии\n\n" +
+			"Занятия сдвигаются на столько же, на сколько переносится указанное. " +
+			"**Пример:** `/edit_series 123 following 10.09.2025 17:00`"
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	lessonID, err := strconv.Atoi(args[0])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
+		return
+	}
+	newStartTime, err := parseLessonDateTime(args[2], args[3])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error()+"\nИспользуйте DD.MM.YYYY HH:MM или D.M.YYYY H:MM")
+		return
+	}
+
+	st := repos(db)
+	now := time.Now()
+	lesson, affected, problem := seriesOccurrence(st, user, lessonID, scope, now)
+	if problem != "" {
+		sendMessage(bot, message.Chat.ID, problem)
+		return
+	}
+
+	delta := newStartTime.Sub(lesson.StartTime)
+	if delta == 0 {
+		sendMessage(bot, message.Chat.ID, "ℹ️ Новое время совпадает с текущим")
+		return
+	}
+	lessonIDs := make([]int, len(affected))
+	for i, l := range affected {
+		lessonIDs[i] = l.ID
+		if !l.StartTime.Add(delta).After(now) {
+			sendMessage(bot, message.Chat.ID, "❌ Нельзя перенести занятия в прошлое")
+			return
+		}
+	}
+
+	// Получатели до переноса: в сообщении старое и новое время
+	messages, err := seriesNotifications(st, affected,
+		fmt.Sprintf("🔄 **Перенос занятий**\n\n📚 Предмет: %s\n\n", escapeMarkdown(lesson.SubjectName)),
+		func(l store.Lesson) string {
+			return l.StartTime.Format("02.01.2006 15:04") + " → " + l.StartTime.Add(delta).Format("02.01.2006 15:04")
+		})
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения записанных студентов серии", "series_id", lesson.SeriesID, "err", err)
+	}
+
+	err = st.Series.Shift(lesson.SeriesID, lessonIDs, delta, scope == seriesScopeAll)
+	if errors.Is(err, store.ErrScheduleConflict) {
+		sendMessage(bot, message.Chat.ID, "❌ У преподавателя уже есть урок, пересекающийся с новым временем одного из занятий")
+		return
+	} else if errors.Is(err, store.ErrStudentScheduleConflict) {
+		sendMessage(bot, message.Chat.ID, "❌ Записанный студент занят на другом уроке в новое время одного из занятий")
+		return
+	} else if errors.Is(err, store.ErrRoomConflict) {
+		sendMessage(bot, message.Chat.ID, "❌ Аудитория одного из занятий занята в новое время")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка переноса занятий серии", "series_id", lesson.SeriesID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка при переносе занятий")
+		return
+	}
+
+	audit(bot, db, userID, auditSeriesRescheduled, store.AuditSeries, lesson.SeriesID,
+		auditState{"scope": scope, "lesson_ids": lessonIDs, "start_time": lesson.StartTime},
+		auditState{"scope": scope, "lesson_ids": lessonIDs, "start_time": newStartTime})
+
+	queued, err := enqueueMessages(bot, db, notificationLessonRescheduled,
+		"🔄 Перенос занятий серии: "+escapeMarkdown(lesson.SubjectName), messages, userID, message.Chat.ID)
+
+	resultText := fmt.Sprintf("✅ **Занятия серии перенесены**\n\n"+
+		"📚 Предмет: %s\n"+
+		"🗓 Занятий: %d\n"+
+		"🔄 %s → %s\n\n"+
+		"%s",
+		escapeMarkdown(lesson.SubjectName), len(affected),
+		lesson.StartTime.Format("02.01.2006 15:04"), newStartTime.Format("02.01.2006 15:04"),
+		queuedReport(queued, err))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Отмена занятий серии: /cancel_series <lesson_id> <this|following|all>
+func handleCancelSeriesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	userID := message.From.ID
+
+	args := strings.Fields(message.CommandArguments())
+	scope, scopeOK := "", false
+	if len(args) >= 2 {
+		scope, scopeOK = parseSeriesScope(args[1])
+	}
+	if len(args) < 2 || !scopeOK {
+		helpText := "🔁 **Отмена занятий серии**\n\n" +
+			"**Формат:** `/cancel_series <ID урока> <this|following|all>`\n\n" +
+			"• `this` - только это занятие\n" +
+			"• `following` - это и следующие, серия заканчивается накануне\n" +
+			"• `all` - вся серия"
+
+		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
+		return
+	}
+
+	lessonID, err := strconv.Atoi(args[0])
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Некорректный ID урока")
+		return
+	}
+
+	st := repos(db)
+	now := time.Now()
+	lesson, affected, problem := seriesOccurrence(st, user, lessonID, scope, now)
+	if problem != "" {
+		sendMessage(bot, message.Chat.ID, problem)
+		return
+	}
+
+	messages, err := seriesNotifications(st, affected,
+		fmt.Sprintf("❌ **Отмена занятий**\n\n📚 Предмет: %s\n\nОтменены занятия:\n", escapeMarkdown(lesson.SubjectName)),
+		func(l store.Lesson) string { return l.StartTime.Format("02.01.2006 15:04") })
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения записанных студентов серии", "series_id", lesson.SeriesID, "err", err)
+	}
+
+	switch scope {
+	case seriesScopeThis:
+		err = st.Series.CancelOccurrence(lesson.SeriesID, lesson.ID, lesson.StartTime)
+	case seriesScopeFollowing:
+		err = st.Series.CancelFrom(lesson.SeriesID, lesson.StartTime, false)
+	default:
+		err = st.Series.CancelFrom(lesson.SeriesID, now, true)
+	}
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка отмены занятий серии", "series_id", lesson.SeriesID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка при отмене занятий")
+		return
+	}
+
+	lessonIDs := make([]int, len(affected))
+	for i, l := range affected {
+		lessonIDs[i] = l.ID
+	}
+	audit(bot, db, userID, auditSeriesCancelled, store.AuditSeries, lesson.SeriesID,
+		auditState{"scope": scope, "lesson_ids": lessonIDs, "status": "active"},
+		auditState{"scope": scope, "lesson_ids": lessonIDs, "status": "cancelled"})
+
+	queued, err := enqueueMessages(bot, db, notificationLessonCancelled,
+		"❌ Отмена занятий серии: "+escapeMarkdown(lesson.SubjectName), messages, userID, message.Chat.ID)
+
+	resultText := fmt.Sprintf("✅ **Занятия серии отменены**\n\n"+
+		"📚 Предмет: %s\n"+
+		"🗓 Отменено занятий: %d\n\n"+
+		"%s",
+		escapeMarkdown(lesson.SubjectName), len(affected), queuedReport(queued, err))
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
+
+// Запись на все предстоящие занятия серии; при отсутствии мест - в лист ожидания
+func handleEnrollSeriesCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	userID := message.From.ID
+
+	seriesID, err := strconv.Atoi(strings.TrimSpace(message.CommandArguments()))
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Укажите ID серии: /enroll_series <series_id>")
+		return
+	}
+
+	st := repos(db)
+	student, err := st.Students.GetByTelegramID(userID)
+	if err != nil {
+		sendMessage(bot, message.Chat.ID, "❌ Вы не зарегистрированы как студент. Используйте /register")
+		return
+	}
+
+	series, err := st.Series.Get(seriesID)
+	if err != nil || series.Status != "active" {
+		sendMessage(bot, message.Chat.ID, "❌ Серия не найдена или отменена")
+		return
+	}
+	lessons, err := st.Series.Lessons(seriesID)
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения занятий серии", "series_id", seriesID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка записи на серию")
+		return
+	}
+	if len(lessons) > 0 && lessons[0].CourseID > 0 {
+		sendMessage(bot, message.Chat.ID, fmt.Sprintf("ℹ️ Это уроки курса. Запишитесь на курс: /enroll_course %d", lessons[0].CourseID))
+		return
+	}
+
+	// Повторная запись дозаписывает на занятия, где студента нет
+	if err := st.Series.Enroll(seriesID, student.ID); err != nil && !errors.Is(err, store.ErrAlreadyEnrolled) {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка записи на серию", "series_id", seriesID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка записи на серию")
+		return
+	}
+
+	now := time.Now()
+	var enrolled, already, waitlisted, conflicts, failed int
+	for _, lesson := range lessons {
+		if !lesson.IsBookable(now) {
+			continue
+		}
+		err := enrollStudent(st, student.ID, lesson.ID, now)
+		switch {
+		case err == nil:
+			enrolled++
+		case errors.Is(err, store.ErrAlreadyEnrolled):
+			already++
+		case errors.Is(err, store.ErrStudentScheduleConflict):
+			conflicts++
+		case errors.Is(err, store.ErrLessonFull):
+			if _, err := st.Waitlist.Add(student.ID, lesson.ID); err == nil || errors.Is(err, store.ErrAlreadyWaitlisted) {
+				waitlisted++
+			} else {
+				failed++
+			}
+		default:
+			slog.ErrorContext(telegram.Context(bot), "Ошибка записи на занятие серии", "lesson_id", lesson.ID, "err", err)
+			failed++
+		}
+	}
+
+	audit(bot, db, userID, auditSeriesEnrolled, store.AuditSeries, seriesID, nil,
+		auditState{"student_id": student.ID, "enrolled": enrolled, "waitlisted": waitlisted})
+	LogUserAction(telegram.Context(bot), db, "series_enrolled", userID,
+		fmt.Sprintf("Серия %d (%s): записан на %d, в листе ожидания %d", seriesID, series.SubjectName, enrolled, waitlisted))
+
+	resultText := fmt.Sprintf("✅ **Вы записаны на серию**\n\n"+
+		"📚 Предмет: %s\n"+
+		"🔁 %s\n\n"+
+		"✅ Записаны на занятий: %d\n", escapeMarkdown(series.SubjectName), formatSeriesRule(*series), enrolled)
+	if already > 0 {
+		resultText += fmt.Sprintf("ℹ️ Уже были записаны: %d\n", already)
+	}
+	if waitlisted > 0 {
+		resultText += fmt.Sprintf("⏳ Нет мест, вы в листе ожидания: %d\n", waitlisted)
+	}
+	if conflicts > 0 {
+		resultText += fmt.Sprintf("⚠️ Пересекаются с другими вашими уроками: %d\n", conflicts)
+	}
+	if failed > 0 {
+		resultText += fmt.Sprintf("❌ Не удалось записать: %d\n", failed)
+	}
+
+	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
+	msg.ParseMode = "Markdown"
+	bot.Send(msg)
+}
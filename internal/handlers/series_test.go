@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Тест разбора аргументов /create_series
+func TestParseSeriesArgs(t *testing.T) {
+	req, err := parseSeriesArgs(`"3D-моделирование" 02.09.2025 16:30 weekly 12`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req.SubjectName != "3D-моделирование" || req.IntervalWeeks != 1 || req.Occurrences != 12 ||
+		req.StartTime.Format("02.01.2006 15:04") != "02.09.2025 16:30" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	req, err = parseSeriesArgs("Геймдев 03.09.2025 18:00 biweekly 24.12.2025 skip=05.11.2025,19.11.2025")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if req.SubjectName != "Геймдев" || req.IntervalWeeks != 2 || req.Until.Format("02.01.2006") != "24.12.2025" ||
+		len(req.Skip) != 2 || req.Skip[1].Format("02.01.2006") != "19.11.2025" {
+		t.Errorf("Unexpected request: %+v", req)
+	}
+
+	for _, args := range []string{
+		"Геймдев 03.09.2025 18:00 weekly",
+		"Геймдев 03.09.2025 18:00 daily 5",
+		"Геймдев 03.09.2025 18:00 weekly 0",
+		"Геймдев 03.09.2025 18:00 weekly 01.09.2025",
+		"Геймдев 03.09.2025 18:00 weekly 5 skip=32.13.2025",
+		`"Геймдев 03.09.2025 18:00 weekly 5`,
+	} {
+		if _, err := parseSeriesArgs(args); err == nil {
+			t.Errorf("Expected error for %q", args)
+		}
+	}
+}
+
+// Тест дат серии: пропуски, число занятий, дата окончания и раз в две недели
+func TestLessonSeriesDates(t *testing.T) {
+	start := time.Date(2025, 9, 2, 16, 30, 0, 0, time.UTC)
+	format := func(dates []time.Time) []string {
+		formatted := make([]string, len(dates))
+		for i, d := range dates {
+			formatted[i] = d.Format("02.01 15:04")
+		}
+		return formatted
+	}
+	equal := func(got []time.Time, want ...string) bool {
+		f := format(got)
+		if len(f) != len(want) {
+			return false
+		}
+		for i := range f {
+			if f[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	weekly := store.LessonSeries{StartTime: start, IntervalWeeks: 1, Occurrences: 3,
+		Skip: []time.Time{time.Date(2025, 9, 9, 0, 0, 0, 0, time.UTC)}}
+	if got := weekly.Dates(); !equal(got, "02.09 16:30", "16.09 16:30", "23.09 16:30") {
+		t.Errorf("Unexpected weekly dates: %v", format(got))
+	}
+
+	biweekly := store.LessonSeries{StartTime: start, IntervalWeeks: 2, Until: time.Date(2025, 9, 30, 0, 0, 0, 0, time.UTC)}
+	if got := biweekly.Dates(); !equal(got, "02.09 16:30", "16.09 16:30", "30.09 16:30") {
+		t.Errorf("Unexpected biweekly dates: %v", format(got))
+	}
+
+	long := store.LessonSeries{StartTime: start, IntervalWeeks: 1, Occurrences: 100}
+	if got := long.Dates(); len(got) != store.MaxSeriesLessons {
+		t.Errorf("Expected %d dates, got %d", store.MaxSeriesLessons, len(got))
+	}
+
+	if got := (store.LessonSeries{StartTime: start, IntervalWeeks: 1}).Dates(); got != nil {
+		t.Errorf("Expected no dates without an end, got %v", format(got))
+	}
+}
@@ -3,29 +3,24 @@ package handlers
 import (
 	"database/sql"
 	"fmt"
-	"strconv"
+	"log/slog"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/telegram"
 )
 
 // Статистика rate limiting
-func handleRateLimitStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для просмотра статистики")
+func handleRateLimitStatsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	// Получаем детальную статистику rate limiting
+	stats, err := repos(db).Stats.PendingOperations()
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения операций rate limiting", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения статистики")
 		return
 	}
 	
-	// Получаем детальную статистику rate limiting
-	stats := getRateLimitDetailedStats(db)
-	
 	// Формируем отчет
 	reportText := "📊 **Статистика Rate Limiting**\n\n"
 	
@@ -35,9 +30,9 @@ func handleRateLimitStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 		reportText += "🔄 **Активные операции:**\n\n"
 		
 		for _, stat := range stats {
-			reportText += fmt.Sprintf("👤 **Пользователь:** %s\n", stat.UserName)
-			reportText += fmt.Sprintf("🆔 **Telegram ID:** %s\n", stat.TelegramID)
-			reportText += fmt.Sprintf("📝 **Операция:** %s\n", stat.OperationType)
+			reportText += fmt.Sprintf("👤 **Пользователь:** %s\n", escapeMarkdown(stat.UserName))
+			reportText += fmt.Sprintf("🆔 **Telegram ID:** %d\n", stat.TelegramID)
+			reportText += fmt.Sprintf("📝 **Операция:** %s\n", stat.Operation)
 			reportText += fmt.Sprintf("⏰ **Начата:** %s\n", stat.StartedAt.Format("02.01.2006 15:04:05"))
 			reportText += fmt.Sprintf("⏱️ **Длительность:** %s\n", time.Since(stat.StartedAt).Round(time.Second))
 			reportText += "---\n"
@@ -50,22 +45,15 @@ func handleRateLimitStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 }
 
 // Общая статистика системы
-func handleStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для просмотра статистики")
+func handleStatsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	// Получаем базовую статистику системы
+	stats, err := repos(db).Stats.System()
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения статистики системы", "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения статистики")
 		return
 	}
 	
-	// Получаем базовую статистику системы
-	stats := getBasicSystemStats(db)
-	
 	// Формируем отчет
 	reportText := "📊 **Общая статистика системы**\n\n"
 	
@@ -86,94 +74,45 @@ func handleStatsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql
 	reportText += fmt.Sprintf("• Активных: %d\n", stats.ActiveEnrollments)
 	reportText += fmt.Sprintf("• Отмененных: %d\n\n", stats.CancelledEnrollments)
 	
+	reportText += fmt.Sprintf("🙋 **Посещаемость:**\n")
+	reportText += fmt.Sprintf("• Присутствовали: %d\n", stats.AttendancePresent)
+	reportText += fmt.Sprintf("• Опоздали: %d\n", stats.AttendanceLate)
+	reportText += fmt.Sprintf("• Не пришли: %d\n", stats.AttendanceAbsent)
+	reportText += fmt.Sprintf("• По уважительной причине: %d\n", stats.AttendanceExcused)
+	reportText += fmt.Sprintf("• Доля неявок: %.1f%%\n\n", stats.NoShowRate())
+	
 	reportText += fmt.Sprintf("⏰ **Листы ожидания:**\n")
 	reportText += fmt.Sprintf("• Всего записей: %d\n\n", stats.WaitlistEntries)
 	
 	reportText += fmt.Sprintf("🔄 **Rate Limiting:**\n")
-	reportText += fmt.Sprintf("• Активных операций: %d\n", stats.ActiveRateLimitOperations)
+	reportText += fmt.Sprintf("• Активных операций: %d\n\n", stats.ActiveRateLimitOperations)
+	
+	reportText += formatUpdateStats(UpdateStats())
 	
 	msg := tgbotapi.NewMessage(message.Chat.ID, reportText)
 	msg.ParseMode = "Markdown"
 	bot.Send(msg)
 }
 
-// Структура для статистики rate limiting
-type RateLimitStat struct {
-	UserName      string
-	TelegramID    string
-	OperationType string
-	StartedAt     time.Time
-}
-
-// Получение детальной статистики rate limiting
-func getRateLimitDetailedStats(db *sql.DB) []RateLimitStat {
-	rows, err := db.Query(`
-		SELECT u.full_name, u.tg_id, po.operation_type, po.started_at
-		FROM pending_operations po
-		JOIN users u ON po.user_id = u.id
-		WHERE po.finished_at IS NULL
-		ORDER BY po.started_at DESC`)
-	
-	if err != nil {
-		return []RateLimitStat{}
+// formatUpdateStats - обработанные с запуска бота обновления (метрики middleware)
+func formatUpdateStats(routes []RouteStats) string {
+	var total, denied, limited, panics int64
+	var duration time.Duration
+	for _, route := range routes {
+		total += route.Count()
+		denied += route.Outcomes[outcomeDenied] + route.Outcomes[outcomeBlocked]
+		limited += route.Outcomes[outcomeRateLimited]
+		panics += route.Outcomes[outcomePanic]
+		duration += route.Duration
 	}
-	defer rows.Close()
 	
-	var stats []RateLimitStat
-	for rows.Next() {
-		var stat RateLimitStat
-		if err := rows.Scan(&stat.UserName, &stat.TelegramID, &stat.OperationType, &stat.StartedAt); err != nil {
-			continue
-		}
-		stats = append(stats, stat)
+	text := "⚙️ **Обработка обновлений (с запуска):**\n"
+	text += fmt.Sprintf("• Всего: %d\n", total)
+	text += fmt.Sprintf("• Отказано в доступе: %d\n", denied)
+	text += fmt.Sprintf("• Отклонено rate limiting: %d\n", limited)
+	text += fmt.Sprintf("• Ошибок (паник): %d\n", panics)
+	if total > 0 {
+		text += fmt.Sprintf("• Среднее время: %v\n", (duration / time.Duration(total)).Round(time.Millisecond))
 	}
-	
-	return stats
-}
-
-// Структура для базовой статистики системы
-type BasicSystemStats struct {
-	TotalUsers                    int
-	ActiveUsers                   int
-	Students                      int
-	Teachers                      int
-	Admins                        int
-	TotalLessons                  int
-	ActiveLessons                 int
-	CancelledLessons              int
-	TotalEnrollments              int
-	ActiveEnrollments             int
-	CancelledEnrollments          int
-	WaitlistEntries               int
-	ActiveRateLimitOperations     int
-}
-
-// Получение базовой статистики системы
-func getBasicSystemStats(db *sql.DB) BasicSystemStats {
-	var stats BasicSystemStats
-	
-	// Статистика пользователей
-	db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
-	db.QueryRow("SELECT COUNT(*) FROM users WHERE is_active = true").Scan(&stats.ActiveUsers)
-	db.QueryRow("SELECT COUNT(*) FROM students WHERE soft_deleted = false").Scan(&stats.Students)
-	db.QueryRow("SELECT COUNT(*) FROM teachers WHERE soft_deleted = false").Scan(&stats.Teachers)
-	db.QueryRow("SELECT COUNT(*) FROM users WHERE role = 'superuser'").Scan(&stats.Admins)
-	
-	// Статистика уроков
-	db.QueryRow("SELECT COUNT(*) FROM lessons").Scan(&stats.TotalLessons)
-	db.QueryRow("SELECT COUNT(*) FROM lessons WHERE soft_deleted = false").Scan(&stats.ActiveLessons)
-	db.QueryRow("SELECT COUNT(*) FROM lessons WHERE soft_deleted = true").Scan(&stats.CancelledLessons)
-	
-	// Статистика записей
-	db.QueryRow("SELECT COUNT(*) FROM enrollments").Scan(&stats.TotalEnrollments)
-	db.QueryRow("SELECT COUNT(*) FROM enrollments WHERE status = 'enrolled'").Scan(&stats.ActiveEnrollments)
-	db.QueryRow("SELECT COUNT(*) FROM enrollments WHERE status = 'cancelled'").Scan(&stats.CancelledEnrollments)
-	
-	// Статистика листов ожидания
-	db.QueryRow("SELECT COUNT(*) FROM waitlist").Scan(&stats.WaitlistEntries)
-	
-	// Статистика rate limiting
-	db.QueryRow("SELECT COUNT(*) FROM pending_operations WHERE finished_at IS NULL").Scan(&stats.ActiveRateLimitOperations)
-	
-	return stats
+	return text
 }
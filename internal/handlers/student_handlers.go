@@ -2,52 +2,31 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-)
-
-// Глобальный rate limiter (инициализируется в main)
-var GlobalRateLimiter *RateLimiter
 
-// Обработчик команд студентов
-func handleStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	switch message.Command() {
-	case "subjects":
-		handleSubjectsCommand(bot, message, db)
-	case "schedule":
-		handleScheduleCommand(bot, message, db)
-	case "enroll":
-		// Применяем rate limiting для записи на урок
-		lessonID := ExtractLessonIDFromMessage(message)
-		handleEnrollWithRateLimit(bot, message, db, lessonID)
-	case "waitlist":
-		// Применяем rate limiting для записи в очередь
-		lessonID := ExtractLessonIDFromMessage(message)
-		handleWaitlistWithRateLimit(bot, message, db, lessonID)
-	case "my_lessons":
-		handleMyLessonsCommand(bot, message, db)
-	}
-}
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/telegram"
+)
 
 // Показ доступных предметов
-func handleSubjectsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	rows, err := db.Query("SELECT name, description, category FROM subjects WHERE is_active = true ORDER BY name")
+func handleSubjectsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
+	list, err := repos(db).Subjects.List(true)
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения предметов", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки предметов")
 		return
 	}
-	defer rows.Close()
 
 	var subjects []string
-	for rows.Next() {
-		var name, description, category string
-		if err := rows.Scan(&name, &description, &category); err != nil {
-			continue
-		}
-		subjects = append(subjects, fmt.Sprintf("📚 **%s** (%s)\n%s", name, category, description))
+	for _, subject := range list {
+		subjects = append(subjects, fmt.Sprintf("📚 **%s** (%s)\n%s",
+			escapeMarkdown(subject.Name), escapeMarkdown(subject.Category), escapeMarkdown(subject.Description)))
 	}
 
 	if len(subjects) == 0 {
@@ -62,65 +41,37 @@ func handleSubjectsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *
 }
 
 // Расписание уроков на неделю с кнопками
-func handleScheduleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	// Получаем роль пользователя
-	userRole, err := getUserRole(db, message.From.ID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка определения роли пользователя")
-		return
-	}
-
-	// Используем новую функцию с кнопками
-	sendScheduleWithButtons(bot, message.Chat.ID, db, userRole)
+func handleScheduleCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
+	// Кнопки расписания зависят от прав пользователя
+	sendScheduleWithButtons(bot, message.Chat.ID, db, user)
 }
 
 // Запись на урок (используется функция из student_commands.go)
 
 // Лист ожидания - показ переполненных уроков
-func handleWaitlistCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleWaitlistCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Показываем уроки, где нет мест (для добавления в лист ожидания)
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time, s.name, u.full_name, l.max_students,
-			COUNT(e.id) as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'
-		WHERE l.start_time > NOW() AND l.start_time < NOW() + INTERVAL '7 days'
-			AND l.soft_deleted = false AND l.status = 'active'
-		GROUP BY l.id, l.start_time, s.name, u.full_name, l.max_students
-		HAVING COUNT(e.id) >= l.max_students
-		ORDER BY l.start_time LIMIT 5`)
-		
+	now := time.Now()
+	lessons, err := repos(db).Lessons.Full(now, now.AddDate(0, 0, 7), 5)
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения переполненных уроков", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки переполненных уроков")
 		return
 	}
-	defer rows.Close()
 
-	hasLessons := false
-	for rows.Next() {
-		hasLessons = true
-		var lessonID int
-		var startTime time.Time
-		var subjectName, teacherName string
-		var maxStudents, enrolledCount int
-		
-		if err := rows.Scan(&lessonID, &startTime, &subjectName, &teacherName, &maxStudents, &enrolledCount); err != nil {
-			continue
-		}
-		
+	for _, lesson := range lessons {
+		lessonID := lesson.ID
 		text := fmt.Sprintf("📅 **%s**\n📚 %s\n👨‍🏫 %s\n🔴 Мест нет (%d/%d)", 
-			startTime.Format("02.01.2006 15:04"), subjectName, teacherName, enrolledCount, maxStudents)
+			lesson.StartTime.Format("02.01.2006 15:04"), lesson.SubjectName, lesson.TeacherName,
+			lesson.EnrolledCount, lesson.MaxStudents)
 
 		// Кнопка для добавления в лист ожидания
 		buttons := [][]tgbotapi.InlineKeyboardButton{
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("⏳ В лист ожидания", fmt.Sprintf("waitlist_lesson_%d", lessonID)),
+				callback.Button("⏳ В лист ожидания", callback.WithID(callback.Waitlist, lessonID)),
 			),
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("ℹ️ Подробнее", fmt.Sprintf("info_lesson_%d", lessonID)),
+				callback.Button("ℹ️ Подробнее", callback.WithID(callback.LessonInfo, lessonID)),
 			),
 		}
 
@@ -130,13 +81,13 @@ func handleWaitlistCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *
 		bot.Send(msg)
 	}
 
-	if !hasLessons {
+	if len(lessons) == 0 {
 		sendMessage(bot, message.Chat.ID, "⏳ Все уроки на ближайшую неделю имеют свободные места!\n\nИспользуйте /enroll для записи")
 	}
 }
 
 // Мои уроки с кнопками управления
-func handleMyLessonsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleMyLessonsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Получаем student_id
 	studentID, err := getStudentID(db, int(message.From.ID))
 	if err != nil {
@@ -145,44 +96,26 @@ func handleMyLessonsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db
 	}
 
 	// Запрос активных записей студента
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time, s.name, u.full_name, e.status
-		FROM enrollments e
-		JOIN lessons l ON e.lesson_id = l.id
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		WHERE e.student_id = $1 AND e.status = 'enrolled' 
-			AND l.start_time > NOW() AND l.soft_deleted = false
-		ORDER BY l.start_time`, studentID)
-		
+	st := repos(db)
+	lessons, err := st.Enrollments.Lessons(studentID, time.Now())
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения уроков студента", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки ваших уроков")
 		return
 	}
-	defer rows.Close()
 
-	hasLessons := false
-	for rows.Next() {
-		hasLessons = true
-		var lessonID int
-		var startTime time.Time
-		var subjectName, teacherName, status string
-		
-		if err := rows.Scan(&lessonID, &startTime, &subjectName, &teacherName, &status); err != nil {
-			continue
-		}
-		
+	for _, lesson := range lessons {
+		lessonID := lesson.ID
 		text := fmt.Sprintf("📅 **%s**\n📚 %s\n👨‍🏫 %s\n✅ Вы записаны", 
-			startTime.Format("02.01.2006 15:04"), subjectName, teacherName)
+			lesson.StartTime.Format("02.01.2006 15:04"), lesson.SubjectName, lesson.TeacherName)
 
 		// Кнопки управления записью
 		buttons := [][]tgbotapi.InlineKeyboardButton{
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("❌ Отменить запись", fmt.Sprintf("unenroll_lesson_%d", lessonID)),
+				callback.Button("❌ Отменить запись", callback.WithID(callback.Unenroll, lessonID)),
 			),
 			tgbotapi.NewInlineKeyboardRow(
-				tgbotapi.NewInlineKeyboardButtonData("ℹ️ Подробнее", fmt.Sprintf("info_lesson_%d", lessonID)),
+				callback.Button("ℹ️ Подробнее", callback.WithID(callback.LessonInfo, lessonID)),
 			),
 		}
 
@@ -192,132 +125,61 @@ func handleMyLessonsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db
 		bot.Send(msg)
 	}
 
-	if !hasLessons {
+	if len(lessons) == 0 {
 		sendMessage(bot, message.Chat.ID, "📚 У вас пока нет записей на уроки\n\nИспользуйте /enroll для записи на урок")
 	}
 
-	// Дополнительно показываем лист ожидания
-	rows2, err := db.Query(`
-		SELECT l.id, l.start_time, s.name, u.full_name
-		FROM waitlist w
-		JOIN lessons l ON w.lesson_id = l.id
-		JOIN subjects s ON l.subject_id = s.id  
-		LEFT JOIN teachers t ON l.teacher_id = t.id
-		LEFT JOIN users u ON t.user_id = u.id
-		WHERE w.student_id = $1 AND l.start_time > NOW() 
-			AND l.soft_deleted = false
-		ORDER BY l.start_time`, studentID)
-		
-	if err == nil {
-		defer rows2.Close()
-		
-		waitlistCount := 0
-		for rows2.Next() {
-			if waitlistCount == 0 {
-				sendMessage(bot, message.Chat.ID, "⏳ **Лист ожидания:**")
-			}
-			waitlistCount++
-			
-			var lessonID int
-			var startTime time.Time
-			var subjectName, teacherName string
-			
-			if err := rows2.Scan(&lessonID, &startTime, &subjectName, &teacherName); err != nil {
-				continue
-			}
-			
-			text := fmt.Sprintf("📅 %s\n📚 %s\n👨‍🏫 %s\n⏳ В очереди", 
-				startTime.Format("02.01.2006 15:04"), subjectName, teacherName)
-			
-			sendMessage(bot, message.Chat.ID, text)
-		}
+	// История посещаемости прошедших уроков
+	history, err := st.Attendance.History(studentID, 10)
+	summary, summaryErr := st.Attendance.Summary(studentID)
+	if err != nil || summaryErr != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения посещаемости", "err", errors.Join(err, summaryErr))
+	} else if len(history) > 0 {
+		msg := tgbotapi.NewMessage(message.Chat.ID, formatAttendanceHistory(history, summary))
+		msg.ParseMode = "Markdown"
+		bot.Send(msg)
 	}
-}
-
-// ========================= ИНТЕГРАЦИЯ RATE-LIMITING =========================
 
-// handleEnrollWithRateLimit - запись на урок с rate limiting
-func handleEnrollWithRateLimit(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, lessonID int) {
-	userID := message.From.ID
-	
-	// Проверяем rate limiting
-	if GlobalRateLimiter != nil {
-		allowed, reason := GlobalRateLimiter.IsOperationAllowed(userID, OPERATION_ENROLL, lessonID)
-		if !allowed {
-			sendMessage(bot, message.Chat.ID, reason.Error())
-			return
-		}
-		
-		// Регистрируем начало операции
-		if err := GlobalRateLimiter.StartOperation(userID, OPERATION_ENROLL, lessonID); err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Системная ошибка. Попробуйте позже.")
-			return
-		}
-		
-		// Выполняем операцию
-		handleEnrollCommand(bot, message, db)
-		
-		// Завершаем операцию
-		GlobalRateLimiter.FinishOperation(userID, OPERATION_ENROLL, lessonID)
-	} else {
-		// Fallback если rate limiter не инициализирован
-		handleEnrollCommand(bot, message, db)
+	// Дополнительно показываем лист ожидания
+	waitlist, err := st.Waitlist.Lessons(studentID, time.Now())
+	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения листа ожидания студента", "err", err)
+		return
 	}
-}
-
-// handleWaitlistWithRateLimit - запись в очередь с rate limiting  
-func handleWaitlistWithRateLimit(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, lessonID int) {
-	userID := message.From.ID
-	
-	// Проверяем rate limiting
-	if GlobalRateLimiter != nil {
-		allowed, reason := GlobalRateLimiter.IsOperationAllowed(userID, OPERATION_WAITLIST, lessonID)
-		if !allowed {
-			sendMessage(bot, message.Chat.ID, reason.Error())
-			return
+	for i, lesson := range waitlist {
+		if i == 0 {
+			sendMessage(bot, message.Chat.ID, "⏳ **Лист ожидания:**")
 		}
+		text := fmt.Sprintf("📅 %s\n📚 %s\n👨‍🏫 %s\n⏳ В очереди", 
+			lesson.StartTime.Format("02.01.2006 15:04"), lesson.SubjectName, lesson.TeacherName)
 		
-		// Регистrируем начало операции
-		if err := GlobalRateLimiter.StartOperation(userID, OPERATION_WAITLIST, lessonID); err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Системная ошибка. Попробуйте позже.")
-			return
-		}
-		
-		// Выполняем операцию
-		handleWaitlistCommand(bot, message, db)
-		
-		// Завершаем операцию
-		GlobalRateLimiter.FinishOperation(userID, OPERATION_WAITLIST, lessonID)
-	} else {
-		// Fallback если rate limiter не инициализирован
-		handleWaitlistCommand(bot, message, db)
+		sendMessage(bot, message.Chat.ID, text)
 	}
 }
 
 // ========================= СТУДЕНЧЕСКОЕ ГЛАВНОЕ МЕНЮ =========================
 
 // Главное меню студента с кнопками
-func showStudentMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func showStudentMainMenu(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	
 	// Получаем имя студента
-	var userName string
-	err := db.QueryRow("SELECT full_name FROM users WHERE tg_id = $1", userID).Scan(&userName)
-	if err != nil {
-		userName = "Студент"
+	userName := "Студент"
+	if user, err := repos(db).Users.GetByTelegramID(userID); err == nil {
+		userName = user.FullName
 	}
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📚 Записаться на урок", "enroll_subjects"),
-			tgbotapi.NewInlineKeyboardButtonData("📅 Мои уроки", "my_lessons_menu"),
+			callback.Button("📚 Записаться на урок", callback.New(callback.EnrollSubjects)),
+			callback.Button("📅 Мои уроки", callback.New(callback.MyLessons)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("📆 Расписание школы", "school_schedule"),
-			tgbotapi.NewInlineKeyboardButtonData("⏳ Мои очереди", "my_waitlist"),
+			callback.Button("📆 Расписание школы", callback.New(callback.Schedule)),
+			callback.Button("⏳ Мои очереди", callback.New(callback.MyWaitlist)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
-			tgbotapi.NewInlineKeyboardButtonData("❓ Справка", "help_student"),
+			callback.Button("❓ Справка", callback.New(callback.HelpStudent)),
 		),
 	)
 	
@@ -332,42 +194,21 @@ func showStudentMainMenu(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sq
 }
 
 // Показать предметы для записи с кнопками
-func showSubjectsForEnrollment(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB) {
+func showSubjectsForEnrollment(bot telegram.Messenger, chatID int64, db *sql.DB) {
 	// Получаем предметы с доступными уроками
-	rows, err := db.Query(`
-		SELECT s.id, s.name, COUNT(l.id) as available_lessons
-		FROM subjects s
-		JOIN lessons l ON l.subject_id = s.id
-		WHERE l.start_time > NOW() 
-		  AND l.soft_deleted = false
-		  AND (
-		    SELECT COUNT(*) FROM enrollments e 
-		    WHERE e.lesson_id = l.id AND e.soft_deleted = false
-		  ) < l.max_students
-		GROUP BY s.id, s.name
-		ORDER BY s.name`)
-	
+	subjects, err := repos(db).Subjects.Bookable(time.Now())
 	if err != nil {
+		slog.Error("Ошибка получения предметов для записи", "err", err)
 		sendMessage(bot, chatID, "❌ Ошибка получения предметов")
 		return
 	}
-	defer rows.Close()
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	
-	for rows.Next() {
-		var subjectID int
-		var subjectName string
-		var availableLessons int
-		
-		if err := rows.Scan(&subjectID, &subjectName, &availableLessons); err != nil {
-			continue
-		}
-		
-		buttonText := fmt.Sprintf("📚 %s (%d уроков)", subjectName, availableLessons)
-		callbackData := fmt.Sprintf("enroll_subject:%d", subjectID)
-		
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+	for _, subject := range subjects {
+		subjectID := subject.ID
+		buttonText := fmt.Sprintf("📚 %s (%d уроков)", subject.Name, subject.BookableLessons)
+		button := callback.Button(buttonText, callback.WithID(callback.EnrollSubject, subjectID))
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
@@ -377,7 +218,7 @@ func showSubjectsForEnrollment(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB) {
 	}
 	
 	// Кнопка "Назад в главное меню"
-	backButton := tgbotapi.NewInlineKeyboardButtonData("🔙 Главное меню", "student_dashboard")
+	backButton := callback.Button("🔙 Главное меню", callback.New(callback.StudentDashboard))
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{backButton})
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
@@ -393,94 +234,76 @@ func showSubjectsForEnrollment(bot *tgbotapi.BotAPI, chatID int64, db *sql.DB) {
 }
 
 // Показать доступные уроки конкретного предмета
-func showAvailableLessonsForSubject(bot *tgbotapi.BotAPI, query *tgbotapi.CallbackQuery, db *sql.DB, subjectID int) {
+func showAvailableLessonsForSubject(bot telegram.Messenger, query *tgbotapi.CallbackQuery, db *sql.DB, subjectID int) {
 	userID := query.From.ID
 	
-	// Получаем уроки предмета с информацией о записях
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time::date, l.start_time::time, l.max_students,
-		       COUNT(e.id) as enrolled_count,
-		       EXISTS(
-		           SELECT 1 FROM enrollments e2 
-		           WHERE e2.lesson_id = l.id AND e2.student_id = (
-		               SELECT s.id FROM students s 
-		               JOIN users u ON s.user_id = u.id 
-		               WHERE u.tg_id = $1
-		           ) AND e2.soft_deleted = false
-		       ) as is_enrolled
-		FROM lessons l
-		LEFT JOIN enrollments e ON e.lesson_id = l.id AND e.soft_deleted = false
-		WHERE l.subject_id = $2 
-		  AND l.start_time > NOW()
-		  AND l.soft_deleted = false
-		GROUP BY l.id, l.start_time, l.max_students
-		ORDER BY l.start_time`, 
-		userID, subjectID)
-	
+	// Получаем уроки предмета и записи студента на них
+	st := repos(db)
+	lessons, err := st.Lessons.BySubject(subjectID, time.Now())
 	if err != nil {
+		slog.Error("Ошибка получения уроков предмета", "subject_id", subjectID, "err", err)
 		sendMessage(bot, query.Message.Chat.ID, "❌ Ошибка получения уроков")
 		return
 	}
-	defer rows.Close()
+	enrolled := map[int]bool{}
+	if studentID, err := getStudentID(db, int(userID)); err == nil {
+		own, err := st.Enrollments.Lessons(studentID, time.Now())
+		if err != nil {
+			slog.Error("Ошибка получения записей студента", "err", err)
+		}
+		for _, lesson := range own {
+			enrolled[lesson.ID] = true
+		}
+	}
 	
 	var buttons [][]tgbotapi.InlineKeyboardButton
 	
-	for rows.Next() {
-		var lessonID, maxStudents, enrolledCount int
-		var lessonDate, lessonTime string
-		var isEnrolled bool
-		
-		if err := rows.Scan(&lessonID, &lessonDate, &lessonTime, &maxStudents, &enrolledCount, &isEnrolled); err != nil {
-			continue
-		}
+	for _, lesson := range lessons {
+		lessonID, maxStudents, enrolledCount := lesson.ID, lesson.MaxStudents, lesson.EnrolledCount
+		lessonDate, lessonTime := lesson.StartTime.Format("02.01.2006"), lesson.StartTime.Format("15:04")
+		isEnrolled := enrolled[lesson.ID]
 		
 		var buttonText string
-		var callbackData string
+		var payload callback.Payload
 		
 		if isEnrolled {
 			buttonText = fmt.Sprintf("✅ %s %s (записан)", lessonDate, lessonTime)
-			callbackData = fmt.Sprintf("unenroll_lesson_%d", lessonID)
+			payload = callback.WithID(callback.Unenroll, lessonID)
 		} else if enrolledCount >= maxStudents {
 			buttonText = fmt.Sprintf("🔒 %s %s (мест нет)", lessonDate, lessonTime)
-			callbackData = fmt.Sprintf("waitlist_lesson_%d", lessonID) // Встать в очередь
+			payload = callback.WithID(callback.Waitlist, lessonID) // Встать в очередь
 		} else {
 			freeSpots := maxStudents - enrolledCount
 			buttonText = fmt.Sprintf("📝 %s %s (свободно %d/%d)", 
 				lessonDate, lessonTime, freeSpots, maxStudents)
-			callbackData = fmt.Sprintf("enroll_lesson_%d", lessonID)
+			payload = callback.WithID(callback.Enroll, lessonID)
 		}
 		
-		button := tgbotapi.NewInlineKeyboardButtonData(buttonText, callbackData)
+		button := callback.Button(buttonText, payload)
 		buttons = append(buttons, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
 	if len(buttons) == 0 {
-		editMsg := tgbotapi.NewEditMessageText(
-			query.Message.Chat.ID, 
-			query.Message.MessageID,
-			"📭 Нет доступных уроков по этому предмету")
-		bot.Send(editMsg)
+		bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, "📭 Нет доступных уроков по этому предмету", nil)
 		return
 	}
 	
 	// Кнопка "Назад к предметам"
-	backButton := tgbotapi.NewInlineKeyboardButtonData("🔙 К предметам", "enroll_subjects")
+	backButton := callback.Button("🔙 К предметам", callback.New(callback.EnrollSubjects))
 	buttons = append(buttons, []tgbotapi.InlineKeyboardButton{backButton})
 	
 	keyboard := tgbotapi.NewInlineKeyboardMarkup(buttons...)
 	
 	// Получаем название предмета
-	var subjectName string
-	db.QueryRow("SELECT name FROM subjects WHERE id = $1", subjectID).Scan(&subjectName)
+	subjectName := ""
+	if subject, err := st.Subjects.Get(subjectID); err == nil {
+		subjectName = subject.Name
+	}
 	
-	text := fmt.Sprintf("📚 **Доступные уроки: %s**\n\n", subjectName) +
+	text := fmt.Sprintf("📚 **Доступные уроки: %s**\n\n", escapeMarkdown(subjectName)) +
 		"📝 - можно записаться\n" +
 		"🔒 - нет мест (можно встать в очередь)\n" +
 		"✅ - вы уже записаны"
 	
-	editMsg := tgbotapi.NewEditMessageText(query.Message.Chat.ID, query.Message.MessageID, text)
-	editMsg.ParseMode = "Markdown"
-	editMsg.ReplyMarkup = &keyboard
-	
-	bot.Send(editMsg)
+	bot.EditMessage(query.Message.Chat.ID, query.Message.MessageID, text, &keyboard)
 }
@@ -2,26 +2,20 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
 )
 
 // Деактивация студента (отсутствующая команда SuperUser)
-func handleDeactivateStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", userID).Scan(&role)
-
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для деактивации студентов")
-		return
-	}
-
+func handleDeactivateStudentCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Парсинг сообщения
 	args := strings.Fields(message.Text)
 	if len(args) < 2 {
@@ -54,100 +48,47 @@ func handleDeactivateStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Mess
 	}
 
 	// Проверяем, существует ли пользователь
-	var fullName, currentRole string
-	var isActive bool
-	err = db.QueryRow("SELECT full_name, role, is_active FROM users WHERE id = $1", studentUserID).Scan(&fullName, &currentRole, &isActive)
-
-	if err == sql.ErrNoRows {
+	st := repos(db)
+	user, err := st.Users.Get(studentUserID)
+	if errors.Is(err, store.ErrNotFound) {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь не найден")
 		return
 	} else if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка поиска пользователя")
 		return
 	}
+	fullName := user.FullName
 
-	if currentRole != "student" {
+	if user.Role != "student" {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь не является студентом")
 		return
 	}
 
-	if !isActive {
+	if !user.IsActive {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь уже деактивирован")
 		return
 	}
 
-	// Получаем количество активных записей
-	var activeEnrollments int
-	err = db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM enrollments e
-		JOIN students s ON e.student_id = s.id
-		WHERE s.user_id = $1 AND e.status = 'enrolled'`, studentUserID).Scan(&activeEnrollments)
-	if err != nil {
-		activeEnrollments = 0
-	}
-
-	// Получаем количество записей в листе ожидания
-	var waitlistEntries int
-	err = db.QueryRow(`
-		SELECT COUNT(*) 
-		FROM waitlist w
-		JOIN students s ON w.student_id = s.id
-		WHERE s.user_id = $1`, studentUserID).Scan(&waitlistEntries)
-	if err != nil {
-		waitlistEntries = 0
-	}
-
-	// Начинаем транзакцию
-	tx, err := db.Begin()
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка начала транзакции")
+	// Пользователь, записи и листы ожидания - одним запросом
+	activeEnrollments, waitlistEntries, err := st.Students.Deactivate(studentUserID)
+	if errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Пользователь уже деактивирован")
 		return
-	}
-	defer tx.Rollback()
-
-	// Деактивируем пользователя
-	_, err = tx.Exec("UPDATE users SET is_active = false WHERE id = $1", studentUserID)
-	if err != nil {
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка деактивации студента", "user_id", studentUserID, "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка деактивации пользователя")
 		return
 	}
 
-	// Отменяем все записи на уроки
-	_, err = tx.Exec(`
-		UPDATE enrollments 
-		SET status = 'cancelled' 
-		WHERE student_id IN (
-			SELECT id FROM students WHERE user_id = $1
-		) AND status = 'enrolled'`, studentUserID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка отмены записей")
-		return
-	}
-
-	// Удаляем из листов ожидания
-	_, err = tx.Exec(`
-		DELETE FROM waitlist 
-		WHERE student_id IN (
-			SELECT id FROM students WHERE user_id = $1
-		)`, studentUserID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка удаления из листа ожидания")
-		return
-	}
-
-	// Фиксируем транзакцию
-	if err := tx.Commit(); err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка фиксации транзакции")
-		return
-	}
-
 	// Логируем деактивацию студента
-	LogSystemAction(db, "student_deactivated", fmt.Sprintf("Студент %s (ID: %d) деактивирован, отменено записей: %d, удалено из листа ожидания: %d", fullName, studentUserID, activeEnrollments, waitlistEntries))
+	LogSystemAction(telegram.Context(bot), db, "student_deactivated", fmt.Sprintf("Студент %s (ID: %d) деактивирован, отменено записей: %d, удалено из листа ожидания: %d", fullName, studentUserID, activeEnrollments, waitlistEntries))
+	audit(bot, db, message.From.ID, auditStudentDeactivated, store.AuditUser, studentUserID,
+		auditState{"is_active": true, "active_enrollments": activeEnrollments, "waitlist_entries": waitlistEntries},
+		auditState{"is_active": false, "active_enrollments": 0, "waitlist_entries": 0})
 
 	// Отчет администратору
 	resultText := "✅ **Студент деактивирован**\n\n" +
-		"👤 Студент: " + fullName + "\n" +
+		"👤 Студент: " + escapeMarkdown(fullName) + "\n" +
 		"🆔 ID: " + strconv.Itoa(studentUserID) + "\n\n" +
 		"📊 Действия:\n" +
 		"• 🚫 Пользователь деактивирован\n" +
@@ -161,18 +102,7 @@ func handleDeactivateStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Mess
 }
 
 // Активация студента (отсутствующая команда SuperUser)
-func handleActivateStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", userID).Scan(&role)
-
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для активации студентов")
-		return
-	}
-
+func handleActivateStudentCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Парсинг сообщения
 	args := strings.Fields(message.Text)
 	if len(args) < 2 {
@@ -204,41 +134,42 @@ func handleActivateStudentCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Messag
 	}
 
 	// Проверяем, существует ли пользователь
-	var fullName, currentRole string
-	var isActive bool
-	err = db.QueryRow("SELECT full_name, role, is_active FROM users WHERE id = $1", studentUserID).Scan(&fullName, &currentRole, &isActive)
-
-	if err == sql.ErrNoRows {
+	st := repos(db)
+	user, err := st.Users.Get(studentUserID)
+	if errors.Is(err, store.ErrNotFound) {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь не найден")
 		return
 	} else if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка поиска пользователя")
 		return
 	}
+	fullName := user.FullName
 
-	if currentRole != "student" {
+	if user.Role != "student" {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь не является студентом")
 		return
 	}
 
-	if isActive {
+	if user.IsActive {
 		sendMessage(bot, message.Chat.ID, "❌ Пользователь уже активирован")
 		return
 	}
 
 	// Активируем пользователя
-	_, err = db.Exec("UPDATE users SET is_active = true WHERE id = $1", studentUserID)
-	if err != nil {
+	if err := st.Users.SetActive(studentUserID, true); err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка активации студента", "user_id", studentUserID, "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка активации пользователя")
 		return
 	}
 
 	// Логируем активацию студента
-	LogSystemAction(db, "student_activated", fmt.Sprintf("Студент %s (ID: %d) активирован", fullName, studentUserID))
+	LogSystemAction(telegram.Context(bot), db, "student_activated", fmt.Sprintf("Студент %s (ID: %d) активирован", fullName, studentUserID))
+	audit(bot, db, message.From.ID, auditStudentActivated, store.AuditUser, studentUserID,
+		auditState{"is_active": false}, auditState{"is_active": true})
 
 	// Отчет администратору
 	resultText := "✅ **Студент активирован**\n\n" +
-		"👤 Студент: " + fullName + "\n" +
+		"👤 Студент: " + escapeMarkdown(fullName) + "\n" +
 		"🆔 ID: " + strconv.Itoa(studentUserID) + "\n\n" +
 		"📊 Действия:\n" +
 		"• ✅ Пользователь активирован\n" +
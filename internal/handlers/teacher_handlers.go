@@ -2,76 +2,45 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
-)
 
-// Обработчик команд для преподавателей
-func handleTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав преподавателя")
-		return
-	}
-	
-	// Маршрутизация команд преподавателя
-	switch message.Command() {
-	case "create_lesson":
-		handleCreateLessonCommand(bot, message, db)
-	case "reschedule_lesson":
-		handleRescheduleLessonCommand(bot, message, db)
-	case "cancel_lesson":
-		handleCancelLessonCommand(bot, message, db)
-	case "my_schedule":
-		handleMyScheduleCommand(bot, message, db)
-	case "my_students":
-		handleTeacherStudentsCommand(bot, message, db)
-	default:
-		sendMessage(bot, message.Chat.ID, "❓ Неизвестная команда преподавателя")
-	}
-}
+	"constellation-school-bot/internal/auth"
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
 
-// Создание урока с интерактивными кнопками
-func handleCreateLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+// Создание урока: без аргументов - пошаговый диалог, с аргументами - быстрая команда
+func handleCreateLessonCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для создания уроков")
-		return
-	}
-	
-	// Если нет аргументов - показываем кнопки с предметами
+	// Если нет аргументов - запускаем диалог создания урока
 	args := message.CommandArguments()
 	if args == "" {
-		showSubjectButtons(bot, message, db, "create")
+		startDialog(bot, db, message.Chat.ID, userID, createLessonDialog, nil)
 		return
 	}
 	
+	// Аудитория - необязательный параметр room=<ID или название>
+	args, roomRef := cutRoomOption(args)
+	
 	// Парсинг аргументов команды  
 	argsList := strings.Fields(args)
 	if len(argsList) < 3 {
 		helpText := "📝 **Создание урока**\n\n" +
-			"**Формат:** `/create_lesson <предмет> <дата> <время>`\n\n" +
+			"**Формат:** `/create_lesson <предмет> <дата> <время> [room=<аудитория>]`\n\n" +
 			"**Примеры:**\n" +
 			"• `/create_lesson \"3D-моделирование\" 16.08.2025 16:30`\n" +
+			"• `/create_lesson \"3D-моделирование\" 16.08.2025 16:30 room=2`\n" +
 			"• `/create_lesson Математика 20.08.2025 10:00`\n\n" +
-			"💡 **Совет:** Используйте `/create_lesson` без параметров для выбора предмета кнопками!"
+			"💡 **Совет:** Используйте `/create_lesson` без параметров для пошагового создания урока!"
 		
 		msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
 		msg.ParseMode = "Markdown"
@@ -100,29 +69,9 @@ func handleCreateLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 	}
 	
 	// Парсинг даты и времени с несколькими форматами
-	datetimeStr := dateStr + " " + timeStr
-	var startTime time.Time
-	var parseErr error
-	
-	// Попробуем разные форматы
-	formats := []string{
-		"02.01.2006 15:04",
-		"2.01.2006 15:04", 
-		"02.1.2006 15:04",
-		"2.1.2006 15:04",
-		"02.01.2006 15:4",
-		"2.01.2006 15:4",
-	}
-	
-	for _, format := range formats {
-		startTime, parseErr = time.Parse(format, datetimeStr)
-		if parseErr == nil {
-			break
-		}
-	}
-	
+	startTime, parseErr := parseLessonDateTime(dateStr, timeStr)
 	if parseErr != nil {
-		sendMessage(bot, message.Chat.ID, fmt.Sprintf("❌ Неверный формат даты или времени: '%s'\nИспользуйте DD.MM.YYYY HH:MM или D.M.YYYY H:MM", datetimeStr))
+		sendMessage(bot, message.Chat.ID, "❌ "+parseErr.Error()+"\nИспользуйте DD.MM.YYYY HH:MM или D.M.YYYY H:MM")
 		return
 	}
 	
@@ -133,39 +82,60 @@ func handleCreateLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 	}
 	
 	// Получаем ID предмета
-	var subjectID int
-	err = db.QueryRow("SELECT id FROM subjects WHERE name = $1", subjectName).Scan(&subjectID)
+	subject, err := repos(db).Subjects.GetByName(subjectName)
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Предмет не найден. Используйте /subjects для просмотра доступных предметов")
 		return
 	}
+	subjectID := subject.ID
 	
 	// Получаем teacher_id для текущего пользователя
-	var teacherID int
-	err = db.QueryRow(`
-		SELECT t.id FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
+	teacherID, err := getTeacherID(db, int(userID))
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
 		return
 	}
 	
+	// Размер группы ограничен вместимостью аудитории
+	maxStudents, roomID, roomLine := defaultMaxStudents, 0, ""
+	if roomRef != "" {
+		room, err := lessonRoom(repos(db), roomRef, subjectID)
+		if err != nil {
+			sendMessage(bot, message.Chat.ID, "❌ "+err.Error())
+			return
+		}
+		maxStudents, roomID = room.CapStudents(maxStudents), room.ID
+		roomLine = "🚪 Аудитория: " + room.Name + "\n"
+	}
+	
 	// Создаем урок
-	_, err = db.Exec(`
-		INSERT INTO lessons (subject_id, teacher_id, start_time, max_students, status, created_at)
-		VALUES ($1, $2, $3, 10, 'active', NOW())`,
-		subjectID, teacherID, startTime)
-		
-	if err != nil {
+	lessonID, err := repos(db).Lessons.Create(store.Lesson{
+		SubjectID:       subjectID,
+		TeacherID:       teacherID,
+		StartTime:       startTime,
+		DurationMinutes: int(defaultLessonDuration / time.Minute),
+		MaxStudents:     maxStudents,
+		RoomID:          roomID,
+	})
+	switch {
+	case errors.Is(err, store.ErrScheduleConflict):
+		sendMessage(bot, message.Chat.ID, "❌ У вас уже есть урок, пересекающийся с этим временем. Проверьте расписание: /my_schedule")
+		return
+	case errors.Is(err, store.ErrRoomConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Аудитория занята в это время. Выберите другую: /rooms")
+		return
+	case err != nil:
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания урока")
 		return
 	}
+	audit(bot, db, message.From.ID, auditLessonCreated, store.AuditLesson, lessonID, nil,
+		newLessonState(subjectID, teacherID, startTime, maxStudents, roomID))
 	
 	successText := "✅ **Урок успешно создан!**\n\n" +
 		"📚 Предмет: " + subjectName + "\n" +
 		"📅 Дата: " + startTime.Format("02.01.2006 15:04") + "\n" +
-		"👥 Максимум студентов: 10\n\n" +
+		roomLine +
+		"👥 Максимум студентов: " + strconv.Itoa(maxStudents) + "\n\n" +
 		"Урок уже доступен для записи студентов!"
 		
 	msg := tgbotapi.NewMessage(message.Chat.ID, successText)
@@ -173,49 +143,14 @@ func handleCreateLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 	bot.Send(msg)
 }
 
-// Перенос урока
-func handleRescheduleLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для переноса уроков")
-		return
-	}
-	
-	// Пока заглушка - будет реализована позже
-	helpText := "📝 **Перенос урока**\n\n" +
-		"**Формат:** `/reschedule_lesson <ID урока> <новая дата> <новое время>`\n\n" +
-		"**Пример:** `/reschedule_lesson 123 16.08.2025 15:00`\n\n" +
-		"⚙️ Функция в разработке"
-		
-	msg := tgbotapi.NewMessage(message.Chat.ID, helpText)
-	msg.ParseMode = "Markdown"
-	bot.Send(msg)
-}
-
 // Отмена/удаление урока  
-func handleCancelLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleCancelLessonCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, user *requestUser) {
 	userID := message.From.ID
 	args := message.CommandArguments()
 	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для отмены уроков")
-		return
-	}
-	
 	// Если нет аргументов - показываем кнопки с предметами
 	if args == "" {
-		showSubjectButtons(bot, message, db, "delete")
+		showSubjectButtons(bot, message, db, callback.DeleteLessonSubject)
 		return
 	}
 	
@@ -226,102 +161,62 @@ func handleCancelLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 		return
 	}
 	
-	// Получаем teacher_id если это преподаватель
-	var teacherID int
-	if role == "teacher" {
-		err = db.QueryRow(`
-			SELECT t.id FROM teachers t 
-			JOIN users u ON t.user_id = u.id 
-			WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
-		
+	st := repos(db)
+	lesson, err := st.Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted {
+		sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
+		return
+	}
+	
+	// Без права на любые уроки - только свои
+	if !user.Can(auth.LessonEditAny) {
+		teacherID, err := getTeacherID(db, int(userID))
 		if err != nil {
 			sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
 			return
 		}
-		
-		// Проверяем что урок принадлежит этому преподавателю
-		var lessonExists bool
-		err = db.QueryRow(`
-			SELECT EXISTS(SELECT 1 FROM lessons WHERE id = $1 AND teacher_id = $2 AND soft_deleted = false)`,
-			lessonID, teacherID).Scan(&lessonExists)
-		
-		if err != nil || !lessonExists {
+		if lesson.TeacherID != teacherID {
 			sendMessage(bot, message.Chat.ID, "❌ Урок не найден или не принадлежит вам")
 			return
 		}
 	}
+	subjectName, startTime := lesson.SubjectName, lesson.StartTime
 	
-	// Получаем информацию об уроке
-	var subjectName string
-	var startTime time.Time
-	var enrolledCount int
-	err = db.QueryRow(`
-		SELECT s.name, l.start_time,
-		       (SELECT COUNT(*) FROM enrollments e WHERE e.lesson_id = l.id AND e.status = 'confirmed') as enrolled_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.id = $1 AND l.soft_deleted = false`, lessonID).Scan(&subjectName, &startTime, &enrolledCount)
-	
+	// Записанные студенты для уведомления (до отмены записей)
+	students, err := st.Enrollments.EnrolledStudents(lessonID)
 	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
-		return
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения записанных студентов урока", "lesson_id", lessonID, "err", err)
 	}
 	
-	// Мягкое удаление урока
-	_, err = db.Exec(`
-		UPDATE lessons SET soft_deleted = true, updated_at = NOW() 
-		WHERE id = $1`, lessonID)
-	
-	if err != nil {
-		log.Printf("Ошибка удаления урока: %v", err)
+	// Мягкое удаление урока с отменой записей и очисткой листа ожидания
+	if err := st.Lessons.Delete(lessonID); errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Урок не найден")
+		return
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка удаления урока", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка при удалении урока")
 		return
 	}
+	audit(bot, db, userID, auditLessonCancelled, store.AuditLesson, lessonID,
+		auditState{"soft_deleted": false}, auditState{"soft_deleted": true})
 	
-	// Отменяем все записи на урок
-	_, err = db.Exec(`
-		UPDATE enrollments SET status = 'cancelled', updated_at = NOW()
-		WHERE lesson_id = $1 AND status = 'confirmed'`, lessonID)
-	
-	if err != nil {
-		log.Printf("Ошибка отмены записей: %v", err)
-	}
-	
-	// Уведомляем студентов об отмене (если есть записавшиеся)
-	if enrolledCount > 0 {
-		rows, err := db.Query(`
-			SELECT u.tg_id FROM enrollments e
-			JOIN users u ON e.user_id = u.id
-			WHERE e.lesson_id = $1 AND e.status = 'cancelled'`, lessonID)
-		
-		if err == nil {
-			defer rows.Close()
-			notificationText := fmt.Sprintf(
-				"❌ **Урок отменен**\n\n"+
-				"📚 Предмет: %s\n"+
-				"📅 Время: %s\n\n"+
-				"Приносим извинения за неудобства.",
-				subjectName, startTime.Format("02.01.2006 15:04"))
-			
-			for rows.Next() {
-				var studentTgID string
-				if rows.Scan(&studentTgID) == nil {
-					studentID, _ := strconv.ParseInt(studentTgID, 10, 64)
-					msg := tgbotapi.NewMessage(studentID, notificationText)
-					msg.ParseMode = "Markdown"
-					bot.Send(msg)
-				}
-			}
-		}
-	}
+	// Уведомляем студентов об отмене через очередь, отчет о доставке придет преподавателю
+	notificationText := fmt.Sprintf(
+		"❌ **Урок отменен**\n\n"+
+		"📚 Предмет: %s\n"+
+		"📅 Время: %s\n\n"+
+		"Приносим извинения за неудобства.",
+		escapeMarkdown(subjectName), startTime.Format("02.01.2006 15:04"))
+	queued, err := enqueueNotification(bot, db, notificationLessonCancelled, notificationText, studentChatIDs(students),
+		userID, message.Chat.ID)
 	
 	// Подтверждение успешного удаления
 	confirmText := fmt.Sprintf(
 		"✅ **Урок успешно удален**\n\n"+
 		"📚 Предмет: %s\n"+
-		"📅 Время: %s\n"+
-		"👥 Уведомлено студентов: %d",
-		subjectName, startTime.Format("02.01.2006 15:04"), enrolledCount)
+		"📅 Время: %s\n\n"+
+		"%s",
+		subjectName, startTime.Format("02.01.2006 15:04"), queuedReport(queued, err))
 		
 	msg := tgbotapi.NewMessage(message.Chat.ID, confirmText)
 	msg.ParseMode = "Markdown"
@@ -329,15 +224,11 @@ func handleCancelLessonCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 }
 
 // Расписание преподавателя - мои уроки
-func handleMyScheduleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleMyScheduleCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	
 	// Получаем teacher_id для текущего пользователя
-	var teacherID int
-	err := db.QueryRow(`
-		SELECT t.id FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
+	teacherID, err := getTeacherID(db, int(userID))
 	
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
@@ -345,54 +236,32 @@ func handleMyScheduleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db
 	}
 	
 	// Получаем уроки преподавателя на ближайшую неделю
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time, s.name, l.max_students,
-		       (SELECT COUNT(*) FROM enrollments e WHERE e.lesson_id = l.id AND e.status = 'confirmed') as enrolled_count,
-		       l.status
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.teacher_id = $1 
-		  AND l.start_time >= NOW() 
-		  AND l.start_time <= NOW() + INTERVAL '7 days'
-		  AND l.soft_deleted = false
-		ORDER BY l.start_time`, teacherID)
-	
+	now := time.Now()
+	lessons, err := repos(db).Lessons.ByTeacher(teacherID, now, now.AddDate(0, 0, 7))
 	if err != nil {
-		log.Printf("Ошибка получения расписания преподавателя: %v", err)
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения расписания преподавателя", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки расписания")
 		return
 	}
-	defer rows.Close()
 	
 	responseText := "📅 **Мое расписание на неделю**\n\n"
-	hasLessons := false
-	
-	for rows.Next() {
-		hasLessons = true
-		var lessonID int
-		var startTime time.Time
-		var subjectName, status string
-		var maxStudents, enrolledCount int
-		
-		if err := rows.Scan(&lessonID, &startTime, &subjectName, &maxStudents, &enrolledCount, &status); err != nil {
-			continue
-		}
-		
+	
+	for _, lesson := range lessons {
 		statusIcon := "✅"
-		if status == "cancelled" {
+		if lesson.Status == "cancelled" {
 			statusIcon = "❌"
-		} else if status == "rescheduled" {
+		} else if lesson.Status == "rescheduled" {
 			statusIcon = "🔄"
 		}
 		
 		responseText += fmt.Sprintf(
 			"%s **%s**\n📅 %s\n👥 Записано: %d/%d\n🆔 ID: %d\n\n",
-			statusIcon, subjectName, 
-			startTime.Format("02.01.2006 15:04"), 
-			enrolledCount, maxStudents, lessonID)
+			statusIcon, lesson.SubjectName, 
+			lesson.StartTime.Format("02.01.2006 15:04"), 
+			lesson.EnrolledCount, lesson.MaxStudents, lesson.ID)
 	}
 	
-	if !hasLessons {
+	if len(lessons) == 0 {
 		responseText += "📭 У вас нет запланированных уроков на ближайшую неделю"
 	}
 	
@@ -402,16 +271,12 @@ func handleMyScheduleCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db
 }
 
 // Студенты преподавателя по урокам
-func handleTeacherStudentsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+func handleTeacherStudentsCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	args := message.CommandArguments()
 	
 	// Получаем teacher_id для текущего пользователя
-	var teacherID int
-	err := db.QueryRow(`
-		SELECT t.id FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE u.tg_id = $1`, strconv.FormatInt(userID, 10)).Scan(&teacherID)
+	teacherID, err := getTeacherID(db, int(userID))
 	
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден в системе")
@@ -432,68 +297,35 @@ func handleTeacherStudentsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Messag
 	}
 	
 	// Проверяем что урок принадлежит данному преподавателю
-	var lessonExists bool
-	err = db.QueryRow(`
-		SELECT EXISTS(SELECT 1 FROM lessons WHERE id = $1 AND teacher_id = $2 AND soft_deleted = false)`,
-		lessonID, teacherID).Scan(&lessonExists)
-	
-	if err != nil || !lessonExists {
+	st := repos(db)
+	lesson, err := st.Lessons.Get(lessonID)
+	if err != nil || lesson.SoftDeleted || lesson.TeacherID != teacherID {
 		sendMessage(bot, message.Chat.ID, "❌ Урок не найден или не принадлежит вам")
 		return
 	}
 	
-	// Получаем информацию об уроке и студентах
-	var subjectName string
-	var startTime time.Time
-	err = db.QueryRow(`
-		SELECT s.name, l.start_time
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.id = $1`, lessonID).Scan(&subjectName, &startTime)
-	
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки информации об уроке")
-		return
-	}
-	
 	// Получаем список студентов
-	rows, err := db.Query(`
-		SELECT u.full_name, u.tg_id, e.status, e.enrolled_at
-		FROM enrollments e
-		JOIN users u ON e.user_id = u.id
-		WHERE e.lesson_id = $1
-		ORDER BY e.enrolled_at`, lessonID)
-	
+	enrollments, err := st.Enrollments.ByLesson(lessonID)
 	if err != nil {
-		log.Printf("Ошибка получения студентов урока: %v", err)
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения студентов урока", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки студентов")
 		return
 	}
-	defer rows.Close()
 	
-	responseText := fmt.Sprintf("� **Студенты урока**\n\n📚 Урок: %s\n📅 %s\n\n", 
-		subjectName, startTime.Format("02.01.2006 15:04"))
+	responseText := fmt.Sprintf("👥 **Студенты урока**\n\n📚 Урок: %s\n📅 %s\n\n", 
+		lesson.SubjectName, lesson.StartTime.Format("02.01.2006 15:04"))
 	
-	studentCount := 0
-	for rows.Next() {
-		var fullName, tgID, status string
-		var enrolledAt time.Time
-		
-		if err := rows.Scan(&fullName, &tgID, &status, &enrolledAt); err != nil {
-			continue
-		}
-		
-		studentCount++
+	for i, enrollment := range enrollments {
 		statusIcon := "✅"
-		if status == "waitlist" {
-			statusIcon = "⏳"
-		} else if status == "cancelled" {
+		if enrollment.Status == "cancelled" {
 			statusIcon = "❌"
 		}
 		
-		responseText += fmt.Sprintf("%d. %s %s\n📞 @%s\n📅 Записался: %s\n\n",
-			studentCount, statusIcon, fullName, tgID, enrolledAt.Format("02.01.2006 15:04"))
+		responseText += fmt.Sprintf("%d. %s %s\n🆔 %d\n📅 Записался: %s\n\n",
+			i+1, statusIcon, escapeMarkdown(enrollment.StudentName), enrollment.TelegramID,
+			enrollment.EnrolledAt.Format("02.01.2006 15:04"))
 	}
+	studentCount := len(enrollments)
 	
 	if studentCount == 0 {
 		responseText += "👤 На урок пока никто не записался"
@@ -507,45 +339,24 @@ func handleTeacherStudentsCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Messag
 }
 
 // Показать уроки преподавателя для выбора студентов
-func handleShowTeacherLessonsForStudents(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, teacherID int) {
+func handleShowTeacherLessonsForStudents(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, teacherID int) {
 	// Получаем активные уроки преподавателя
-	rows, err := db.Query(`
-		SELECT l.id, l.start_time, s.name,
-		       (SELECT COUNT(*) FROM enrollments e WHERE e.lesson_id = l.id AND e.status IN ('confirmed', 'waitlist')) as student_count
-		FROM lessons l
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.teacher_id = $1 
-		  AND l.start_time >= NOW() - INTERVAL '1 day'
-		  AND l.soft_deleted = false
-		ORDER BY l.start_time`, teacherID)
-	
+	lessons, err := repos(db).Lessons.ByTeacher(teacherID, time.Now().AddDate(0, 0, -1), time.Time{})
 	if err != nil {
-		log.Printf("Ошибка получения уроков преподавателя: %v", err)
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения уроков преподавателя", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки уроков")
 		return
 	}
-	defer rows.Close()
 	
 	responseText := "👥 **Выберите урок для просмотра студентов**\n\n"
 	responseText += "Используйте команду: `/my_students [ID урока]`\n\n"
 	
-	hasLessons := false
-	for rows.Next() {
-		hasLessons = true
-		var lessonID int
-		var startTime time.Time
-		var subjectName string
-		var studentCount int
-		
-		if err := rows.Scan(&lessonID, &startTime, &subjectName, &studentCount); err != nil {
-			continue
-		}
-		
+	for _, lesson := range lessons {
 		responseText += fmt.Sprintf("🆔 **%d** - %s\n📅 %s\n👥 Студентов: %d\n\n",
-			lessonID, subjectName, startTime.Format("02.01.2006 15:04"), studentCount)
+			lesson.ID, lesson.SubjectName, lesson.StartTime.Format("02.01.2006 15:04"), lesson.EnrolledCount)
 	}
 	
-	if !hasLessons {
+	if len(lessons) == 0 {
 		responseText += "📭 У вас нет активных уроков"
 	}
 	
@@ -555,27 +366,19 @@ func handleShowTeacherLessonsForStudents(bot *tgbotapi.BotAPI, message *tgbotapi
 }
 
 // Показ кнопок с предметами для создания/удаления урока
-func showSubjectButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB, action string) {
+func showSubjectButtons(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB, action callback.Action) {
 	// Получаем все предметы из базы
-	rows, err := db.Query("SELECT id, name FROM subjects ORDER BY name")
+	subjects, err := repos(db).Subjects.List(false)
 	if err != nil {
-		log.Printf("Ошибка получения предметов: %v", err)
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения предметов", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка загрузки предметов")
 		return
 	}
-	defer rows.Close()
 	
 	var keyboard [][]tgbotapi.InlineKeyboardButton
 	
-	for rows.Next() {
-		var subjectID int
-		var subjectName string
-		if err := rows.Scan(&subjectID, &subjectName); err != nil {
-			continue
-		}
-		
-		callbackData := fmt.Sprintf("%s_lesson:%d", action, subjectID)
-		button := tgbotapi.NewInlineKeyboardButtonData(subjectName, callbackData)
+	for _, subject := range subjects {
+		button := callback.Button(subject.Name, callback.WithID(action, subject.ID))
 		keyboard = append(keyboard, []tgbotapi.InlineKeyboardButton{button})
 	}
 	
@@ -585,7 +388,7 @@ func showSubjectButtons(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql
 	}
 	
 	var headerText string
-	if action == "create" {
+	if action == callback.CreateLessonSubject {
 		headerText = "📚 **Выберите предмет для создания урока:**"
 	} else {
 		headerText = "📚 **Выберите предмет для удаления урока:**"
@@ -2,33 +2,35 @@ package handlers
 
 import (
 	"database/sql"
-	"strconv"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/telegram"
 )
 
 // Справка для преподавателей (отсутствующая команда Teacher)
-func handleHelpTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", strconv.FormatInt(userID, 10)).Scan(&role)
-
-	if err != nil || (role != "teacher" && role != "superuser") {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для просмотра справки преподавателя")
-		return
-	}
-
+func handleHelpTeacherCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	helpText := "👨‍🏫 **Справка для преподавателей**\n\n" +
 		"**📋 Доступные команды:**\n\n" +
 		"**📅 Управление уроками:**\n" +
 		"• `/create_lesson <subject_code> <date> <time> [max_students]` - создать урок\n" +
+		"• `/create_lesson <предмет> <дата> <время> room=<аудитория>` - урок в аудитории\n" +
+		"• `/rooms` - аудитории, вместимость и оборудование\n" +
 		"• `/reschedule_lesson <lesson_id> <new_date> <new_time>` - перенести урок\n" +
 		"• `/cancel_lesson <lesson_id>` - отменить урок\n\n" +
+		"**🔁 Серии уроков:**\n" +
+		"• `/create_series <предмет> <дата> <время> <weekly|biweekly> <N|дата окончания> [skip=<даты>]` - создать серию\n" +
+		"• `/series <series_id>` - занятия серии\n" +
+		"• `/edit_series <lesson_id> <this|following|all> <date> <time>` - перенести занятия серии\n" +
+		"• `/cancel_series <lesson_id> <this|following|all>` - отменить занятия серии\n\n" +
+		"**🎓 Курсы:**\n" +
+		"• `/create_course <название> <предмет> <мест>` и темы уроков с новой строки - создать курс\n" +
+		"• `/schedule_course <course_id> <date> <time> <weekly|biweekly> [skip=<даты>]` - запланировать уроки курса\n" +
+		"• `/course <course_id>` - план и расписание курса\n\n" +
 		"**👥 Управление студентами:**\n" +
 		"• `/my_students` - список моих студентов\n" +
-		"• `/my_lessons` - мои уроки\n\n" +
+		"• `/my_lessons` - мои уроки\n" +
+		"• `/attendance <lesson_id>` - отметить посещаемость урока\n\n" +
 		"**📚 Доступные предметы:**\n" +
 		"• `3D_MODELING` - 3D-моделирование\n" +
 		"• `GAMEDEV` - Геймдев\n" +
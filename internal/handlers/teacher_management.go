@@ -2,34 +2,35 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"time"
 
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
 )
 
-// Добавление преподавателя
-func handleAddTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
+// Добавление преподавателя: без аргументов - пошаговый диалог, с аргументами - быстрая команда
+func handleAddTeacherCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	userID := message.From.ID
 	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для добавления преподавателей")
+	// Без аргументов - запускаем диалог добавления преподавателя
+	args := strings.Fields(message.Text)
+	if len(args) == 1 {
+		startDialog(bot, db, message.Chat.ID, userID, addTeacherDialog, nil)
 		return
 	}
 	
-	// Парсинг аргументов команды
-	args := strings.Fields(message.Text)
 	if len(args) < 4 {
 		helpText := "📝 **Добавление преподавателя**\n\n" +
 			"**Формат:** `/add_teacher <Telegram ID> <Имя> <Фамилия>`\n\n" +
 			"**Пример:** `/add_teacher 999999999 Анна Петрова`\n\n" +
+			"💡 Используйте `/add_teacher` без параметров для пошагового добавления\n\n" +
 			"**Как узнать Telegram ID:**\n" +
 			"Попросите преподавателя написать боту @userinfobot"
 		
@@ -40,87 +41,71 @@ func handleAddTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db
 	}
 	
 	tgID := args[1]
-	firstName := args[2]
-	lastName := strings.Join(args[3:], " ")
+	fullName := args[2] + " " + strings.Join(args[3:], " ")
 	
 	// Проверяем, что Telegram ID корректный
-	_, err = strconv.ParseInt(tgID, 10, 64)
+	_, err := strconv.ParseInt(tgID, 10, 64)
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Некорректный Telegram ID")
 		return
 	}
 	
-	// Проверяем, не существует ли уже пользователь с таким tg_id
-	var existingUser string
-	err = db.QueryRow("SELECT tg_id FROM users WHERE tg_id = $1", tgID).Scan(&existingUser)
-	if err == nil {
-		sendMessage(bot, message.Chat.ID, "❌ Пользователь с таким Telegram ID уже существует")
-		return
-	}
-	
-	// Начинаем транзакцию
-	tx, err := db.Begin()
+	teacherID, err := createTeacher(db, tgID, fullName)
 	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка базы данных")
+		sendMessage(bot, message.Chat.ID, "❌ "+err.Error())
 		return
 	}
-	defer tx.Rollback()
-	
-	// Создаем пользователя
-	var userIDResult int
-	err = tx.QueryRow(`
-		INSERT INTO users (tg_id, full_name, role, is_active, created_at)
-		VALUES ($1, $2, $3, $4, NOW())
-		RETURNING id`,
-		tgID, firstName+" "+lastName, "teacher", true).Scan(&userIDResult)
-	
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания пользователя")
-		return
-	}
-	
-	// Создаем запись преподавателя
-	_, err = tx.Exec(`
-		INSERT INTO teachers (user_id, created_at)
-		VALUES ($1, NOW())`,
-		userIDResult)
+	audit(bot, db, userID, auditTeacherAdded, store.AuditTeacher, teacherID, nil, teacherState(tgID, fullName, false))
 	
+	sendTeacherAddedMessage(bot, message.Chat.ID, tgID, fullName)
+}
+
+// Создание пользователя с ролью преподавателя и записи в teachers, возвращает ID преподавателя.
+// Ошибка - текст для администратора.
+func createTeacher(db *sql.DB, tgID, fullName string) (int, error) {
+	telegramID, err := strconv.ParseInt(tgID, 10, 64)
 	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка создания преподавателя")
-		return
+		return 0, fmt.Errorf("некорректный Telegram ID")
 	}
 	
-	// Подтверждаем транзакцию
-	if err = tx.Commit(); err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка сохранения данных")
-		return
+	teacherID, err := repos(db).Teachers.Create(telegramID, fullName)
+	if errors.Is(err, store.ErrUserExists) {
+		return 0, fmt.Errorf("пользователь с таким Telegram ID уже существует")
+	} else if err != nil {
+		return 0, fmt.Errorf("ошибка создания преподавателя")
 	}
-	
+	return teacherID, nil
+}
+
+// Сообщение об успешном добавлении преподавателя
+func sendTeacherAddedMessage(bot telegram.Messenger, chatID int64, tgID, fullName string) {
 	successText := "✅ **Преподаватель успешно добавлен**\n\n" +
-		"👤 **Имя:** " + firstName + " " + lastName + "\n" +
+		"👤 **Имя:** " + escapeMarkdown(fullName) + "\n" +
 		"🆔 **Telegram ID:** " + tgID + "\n" +
 		"📅 **Дата добавления:** " + time.Now().Format("02.01.2006 15:04") + "\n\n" +
 		"Преподаватель может начать создавать уроки командой /create_lesson"
 	
-	msg := tgbotapi.NewMessage(message.Chat.ID, successText)
+	msg := tgbotapi.NewMessage(chatID, successText)
 	msg.ParseMode = "Markdown"
 	bot.Send(msg)
 }
 
-// Удаление преподавателя
-func handleDeleteTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для удаления преподавателей")
-		return
+// Парсинг ID преподавателя из команды вида "/delete_teacher <teacher_id>"
+func parseTeacherID(cmd string) (int, error) {
+	args := strings.Fields(cmd)
+	if len(args) != 2 {
+		return 0, fmt.Errorf("ожидается ровно один аргумент: ID преподавателя")
 	}
 	
+	teacherID, err := strconv.Atoi(args[1])
+	if err != nil || teacherID <= 0 {
+		return 0, fmt.Errorf("некорректный ID преподавателя: %s", args[1])
+	}
+	return teacherID, nil
+}
+
+// Удаление преподавателя
+func handleDeleteTeacherCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Парсинг аргументов команды
 	args := strings.Fields(message.Text)
 	if len(args) < 2 {
@@ -136,127 +121,69 @@ func handleDeleteTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 		return
 	}
 	
-	teacherIDStr := args[1]
-	teacherID, err := strconv.Atoi(teacherIDStr)
+	teacherID, err := parseTeacherID(message.Text)
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Некорректный ID преподавателя")
 		return
 	}
 	
 	// Получаем информацию о преподавателе
-	var teacherName string
-	err = db.QueryRow(`
-		SELECT u.full_name 
-		FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE t.id = $1 AND t.soft_deleted = false`, teacherID).Scan(&teacherName)
-	
-	if err == sql.ErrNoRows {
+	st := repos(db)
+	teacher, err := st.Teachers.Get(teacherID)
+	if errors.Is(err, store.ErrNotFound) || (err == nil && teacher.SoftDeleted) {
 		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден")
 		return
 	} else if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка поиска преподавателя")
 		return
 	}
+	teacherName := teacher.FullName
 	
 	// Получаем все уроки преподавателя
-	rows, err := db.Query(`
-		SELECT id FROM lessons 
-		WHERE teacher_id = $1 AND soft_deleted = false`, teacherID)
+	lessons, err := st.Lessons.ByTeacher(teacherID, time.Time{}, time.Time{})
 	if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения уроков")
 		return
 	}
-	defer rows.Close()
-	
-	var lessonIDs []int
-	for rows.Next() {
-		var lessonID int
-		if err := rows.Scan(&lessonID); err != nil {
-			continue
-		}
-		lessonIDs = append(lessonIDs, lessonID)
-	}
-	
-	// Начинаем транзакцию
-	tx, err := db.Begin()
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка базы данных")
-		return
-	}
-	defer tx.Rollback()
-	
-	// Soft delete всех уроков преподавателя
-	if len(lessonIDs) > 0 {
-		_, err = tx.Exec(`
-			UPDATE lessons 
-			SET soft_deleted = true, updated_at = NOW() 
-			WHERE teacher_id = $1 AND soft_deleted = false`, teacherID)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Ошибка отмены уроков")
-			return
-		}
-		
-		// Очищаем записи на отмененные уроки
-		_, err = tx.Exec(`
-			UPDATE enrollments 
-			SET status = 'cancelled', updated_at = NOW() 
-			WHERE lesson_id = ANY($1)`, lessonIDs)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Ошибка отмены записей")
-			return
-		}
-		
-		// Очищаем листы ожидания
-		_, err = tx.Exec(`
-			DELETE FROM waitlist 
-			WHERE lesson_id = ANY($1)`, lessonIDs)
-		if err != nil {
-			sendMessage(bot, message.Chat.ID, "❌ Ошибка очистки листов ожидания")
-			return
-		}
+	lessonIDs := []int{}
+	for _, lesson := range lessons {
+		lessonIDs = append(lessonIDs, lesson.ID)
 	}
 	
-	// Soft delete преподавателя
-	_, err = tx.Exec(`
-		UPDATE teachers 
-		SET soft_deleted = true, updated_at = NOW() 
-		WHERE id = $1`, teacherID)
+	// Уведомления готовим до отмены записей: после удаления записанных студентов уже не найти
+	notifications, err := teacherDeletionNotifications(db, lessonIDs, teacherName)
 	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка удаления преподавателя")
-		return
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения студентов преподавателя", "teacher_id", teacherID, "err", err)
 	}
 	
-	// Деактивируем пользователя
-	_, err = tx.Exec(`
-		UPDATE users 
-		SET is_active = false, updated_at = NOW() 
-		WHERE id = (SELECT user_id FROM teachers WHERE id = $1)`, teacherID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка деактивации пользователя")
+	// Уроки, записи, листы ожидания, преподаватель и пользователь - одним запросом
+	if err := st.Teachers.Delete(teacherID); errors.Is(err, store.ErrNotFound) {
+		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден")
 		return
-	}
-	
-	// Подтверждаем транзакцию
-	if err = tx.Commit(); err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка сохранения данных")
+	} else if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка удаления преподавателя", "teacher_id", teacherID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка удаления преподавателя")
 		return
 	}
 	
 	// Логируем удаление преподавателя
-	LogSystemAction(db, "teacher_deleted", fmt.Sprintf("Преподаватель %s (ID: %d) удален, отменено уроков: %d", teacherName, teacherID, len(lessonIDs)))
+	LogSystemAction(telegram.Context(bot), db, "teacher_deleted", fmt.Sprintf("Преподаватель %s (ID: %d) удален, отменено уроков: %d", teacherName, teacherID, len(lessonIDs)))
+	audit(bot, db, message.From.ID, auditTeacherDeleted, store.AuditTeacher, teacherID,
+		auditState{"soft_deleted": false, "is_active": true, "cancelled_lessons": []int{}},
+		auditState{"soft_deleted": true, "is_active": false, "cancelled_lessons": lessonIDs})
 	
-	// Отправляем уведомления студентам в отдельной горутине
-	go notifyStudentsAboutTeacherDeletion(bot, db, lessonIDs, teacherName)
+	// Уведомления студентам - через очередь, отчет о доставке придет администратору
+	queued, err := enqueueMessages(bot, db, notificationTeacherDeleted, "❌ **Отмена уроков преподавателя "+escapeMarkdown(teacherName)+"**",
+		notifications, message.From.ID, message.Chat.ID)
 	
 	// Отчет об удалении
 	resultText := "✅ **Преподаватель удален**\n\n" +
-		"👤 **Имя:** " + teacherName + "\n" +
+		"👤 **Имя:** " + escapeMarkdown(teacherName) + "\n" +
 		"📊 **Результаты:**\n" +
 		"• Отменено уроков: " + strconv.Itoa(len(lessonIDs)) + "\n" +
 		"• Очищены листы ожидания\n" +
 		"• Деактивирован аккаунт\n\n" +
-		"📢 **Уведомления:** Студенты получат сообщения об отмене уроков"
+		"📢 **Уведомления:**\n" + queuedReport(queued, err)
 	
 	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
 	msg.ParseMode = "Markdown"
@@ -264,62 +191,30 @@ func handleDeleteTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 }
 
 // Просмотр списка преподавателей
-func handleListTeachersCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для просмотра списка преподавателей")
-		return
-	}
-	
+func handleListTeachersCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Получаем список всех преподавателей
-	rows, err := db.Query(`
-		SELECT t.id, u.full_name, u.tg_id, u.is_active,
-			COUNT(l.id) as active_lessons
-		FROM teachers t
-		JOIN users u ON t.user_id = u.id
-		LEFT JOIN lessons l ON t.id = l.teacher_id AND l.soft_deleted = false
-		WHERE t.soft_deleted = false
-		GROUP BY t.id, u.full_name, u.tg_id, u.is_active
-		ORDER BY u.full_name`)
-	
+	teachers, err := repos(db).Teachers.List()
 	if err != nil {
+		slog.ErrorContext(telegram.Context(bot), "Ошибка получения списка преподавателей", "err", err)
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка получения списка преподавателей")
 		return
 	}
-	defer rows.Close()
 	
 	var teachersText strings.Builder
 	teachersText.WriteString("👨‍🏫 **Список преподавателей**\n\n")
 	
-	var teacherCount int
-	for rows.Next() {
-		var id int
-		var fullName, tgID string
-		var isActive bool
-		var activeLessons int
-		
-		if err := rows.Scan(&id, &fullName, &tgID, &isActive, &activeLessons); err != nil {
-			continue
-		}
-		
+	for _, teacher := range teachers {
 		status := "✅ Активен"
-		if !isActive {
+		if !teacher.IsActive {
 			status = "❌ Неактивен"
 		}
 		
-		teachersText.WriteString(fmt.Sprintf("**%d.** %s\n", id, fullName))
-		teachersText.WriteString(fmt.Sprintf("   🆔 ID: %s\n", tgID))
+		teachersText.WriteString(fmt.Sprintf("**%d.** %s\n", teacher.ID, escapeMarkdown(teacher.FullName)))
+		teachersText.WriteString(fmt.Sprintf("   🆔 ID: %d\n", teacher.TelegramID))
 		teachersText.WriteString(fmt.Sprintf("   📊 Статус: %s\n", status))
-		teachersText.WriteString(fmt.Sprintf("   📚 Активных уроков: %d\n\n", activeLessons))
-		
-		teacherCount++
+		teachersText.WriteString(fmt.Sprintf("   📚 Активных уроков: %d\n\n", teacher.ActiveLessons))
 	}
+	teacherCount := len(teachers)
 	
 	if teacherCount == 0 {
 		teachersText.WriteString("Пока нет зарегистрированных преподавателей")
@@ -333,19 +228,7 @@ func handleListTeachersCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message,
 }
 
 // Восстановление преподавателя
-func handleRestoreTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message, db *sql.DB) {
-	userID := message.From.ID
-	
-	// Проверяем роль пользователя
-	var role string
-	err := db.QueryRow("SELECT role FROM users WHERE tg_id = $1", 
-		strconv.FormatInt(userID, 10)).Scan(&role)
-	
-	if err != nil || role != "superuser" {
-		sendMessage(bot, message.Chat.ID, "❌ У вас нет прав для восстановления преподавателей")
-		return
-	}
-	
+func handleRestoreTeacherCommand(bot telegram.Messenger, message *tgbotapi.Message, db *sql.DB) {
 	// Парсинг аргументов команды
 	args := strings.Fields(message.Text)
 	if len(args) < 2 {
@@ -372,82 +255,45 @@ func handleRestoreTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 	}
 	
 	// Получаем информацию о преподавателе
-	var teacherData struct {
-		ID   int
-		Name string
-	}
-	err = db.QueryRow(`
-		SELECT t.id, u.full_name 
-		FROM teachers t 
-		JOIN users u ON t.user_id = u.id 
-		WHERE t.id = $1`, teacherID).Scan(&teacherData.ID, &teacherData.Name)
-	
-	if err == sql.ErrNoRows {
+	st := repos(db)
+	teacher, err := st.Teachers.Get(teacherID)
+	if errors.Is(err, store.ErrNotFound) {
 		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден")
 		return
 	} else if err != nil {
 		sendMessage(bot, message.Chat.ID, "❌ Ошибка поиска преподавателя")
 		return
 	}
-	
-	// Начинаем транзакцию
-	tx, err := db.Begin()
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка базы данных")
-		return
-	}
-	defer tx.Rollback()
-	
-	// Восстанавливаем преподавателя
-	_, err = tx.Exec(`
-		UPDATE teachers 
-		SET soft_deleted = false, updated_at = NOW() 
-		WHERE id = $1`, teacherID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка восстановления преподавателя")
-		return
-	}
-	
-	// Активируем пользователя
-	_, err = tx.Exec(`
-		UPDATE users 
-		SET is_active = true, updated_at = NOW() 
-		WHERE id = (SELECT user_id FROM teachers WHERE id = $1)`, teacherID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка активации пользователя")
+	// Преподаватель, пользователь и уроки - одним запросом
+	err = st.Teachers.Restore(teacherID)
+	switch {
+	case errors.Is(err, store.ErrNotFound):
+		sendMessage(bot, message.Chat.ID, "❌ Преподаватель не найден")
 		return
-	}
-	
-	// Восстанавливаем уроки преподавателя
-	_, err = tx.Exec(`
-		UPDATE lessons 
-		SET soft_deleted = false, updated_at = NOW() 
-		WHERE teacher_id = $1 AND soft_deleted = true`, teacherID)
-	if err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка восстановления уроков")
+	case errors.Is(err, store.ErrScheduleConflict), errors.Is(err, store.ErrRoomConflict):
+		sendMessage(bot, message.Chat.ID, "❌ Уроки преподавателя пересекаются с другими уроками в тех же аудиториях")
 		return
-	}
-	
-	// Подтверждаем транзакцию
-	if err = tx.Commit(); err != nil {
-		sendMessage(bot, message.Chat.ID, "❌ Ошибка сохранения данных")
+	case err != nil:
+		slog.ErrorContext(telegram.Context(bot), "Ошибка восстановления преподавателя", "teacher_id", teacherID, "err", err)
+		sendMessage(bot, message.Chat.ID, "❌ Ошибка восстановления преподавателя")
 		return
 	}
 	
 	// Логируем восстановление преподавателя
-	LogSystemAction(db, "teacher_restored", fmt.Sprintf("Преподаватель %s (ID: %d) восстановлен", teacherData.Name, teacherID))
+	LogSystemAction(telegram.Context(bot), db, "teacher_restored", fmt.Sprintf("Преподаватель %s (ID: %d) восстановлен", teacher.FullName, teacherID))
+	audit(bot, db, message.From.ID, auditTeacherRestored, store.AuditTeacher, teacherID,
+		auditState{"soft_deleted": true, "is_active": false}, auditState{"soft_deleted": false, "is_active": true})
 	
 	// Отправляем уведомления студентам
-	sent, failed := notifyStudentsAboutTeacherRestoration(bot, db, teacherID, teacherData.Name)
+	queued, err := notifyStudentsAboutTeacherRestoration(bot, db, teacherID, teacher.FullName, message.From.ID, message.Chat.ID)
 	
 	// Отчет о восстановлении
 	resultText := "✅ **Преподаватель восстановлен**\n\n" +
-		"👤 **Имя:** " + teacherData.Name + "\n" +
+		"👤 **Имя:** " + escapeMarkdown(teacher.FullName) + "\n" +
 		"📊 **Результаты:**\n" +
 		"• Восстановлен аккаунт\n" +
-		"• Восстановлены все уроки\n" +
-		"• Уведомлено студентов: " + strconv.Itoa(sent) + "\n" +
-		"• Ошибок отправки: " + strconv.Itoa(failed) + "\n\n" +
+		"• Восстановлены все уроки\n\n" +
+		"📢 **Уведомления:**\n" + queuedReport(queued, err) + "\n\n" +
 		"🎉 Преподаватель может снова создавать уроки!"
 	
 	msg := tgbotapi.NewMessage(message.Chat.ID, resultText)
@@ -455,137 +301,76 @@ func handleRestoreTeacherCommand(bot *tgbotapi.BotAPI, message *tgbotapi.Message
 	bot.Send(msg)
 }
 
-// notifyStudentsAboutTeacherDeletion - уведомление студентов об удалении преподавателя
-func notifyStudentsAboutTeacherDeletion(bot *tgbotapi.BotAPI, db *sql.DB, lessonIDs []int, teacherName string) {
+// teacherDeletionNotifications - сообщения студентам об отмене их уроков у удаляемого преподавателя
+func teacherDeletionNotifications(db *sql.DB, lessonIDs []int, teacherName string) ([]store.OutboxMessage, error) {
 	if len(lessonIDs) == 0 {
-		return
+		return nil, nil
 	}
 	
 	// Получаем всех студентов с группировкой по студенту
-	query := `
-		SELECT DISTINCT u.tg_id, u.full_name,
-			STRING_AGG(
-				s.name || ' (' || l.start_time::date || ' ' || l.start_time::time || ')',
-				E'\n• '
-			) as lessons_info
-		FROM enrollments e
-		JOIN students st ON e.student_id = st.id
-		JOIN users u ON st.user_id = u.id
-		JOIN lessons l ON e.lesson_id = l.id
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.id = ANY($1) AND e.status = 'enrolled'
-		GROUP BY u.tg_id, u.full_name`
-	
-	rows, err := db.Query(query, lessonIDs)
+	students, err := repos(db).Enrollments.EnrolledByStudent(lessonIDs)
 	if err != nil {
-		return
-	}
-	defer rows.Close()
-	
-	sent := 0
-	failed := 0
-	
-	for rows.Next() {
-		var studentTelegramID int64
-		var studentName, lessonsInfo string
-		
-		if err := rows.Scan(&studentTelegramID, &studentName, &lessonsInfo); err != nil {
-			failed++
-			continue
-		}
-		
-		// Формируем уведомление
-		notificationText := "❌ **Отмена уроков**\n\n" +
-			"К сожалению, преподаватель **" + teacherName + "** больше не работает в школе.\n\n" +
-			"📚 **Отмененные уроки:**\n" +
-			"• " + lessonsInfo + "\n\n" +
-			"💔 Приносим извинения за неудобства.\n" +
-			"🔄 Вы можете записаться на другие уроки командой /schedule"
-		
-		msg := tgbotapi.NewMessage(studentTelegramID, notificationText)
-		msg.ParseMode = "Markdown"
-		
-		// Retry механизм (3 попытки)
-		success := false
-		for attempt := 0; attempt < 3; attempt++ {
-			if _, err := bot.Send(msg); err == nil {
-				success = true
-				break
-			}
-			time.Sleep(500 * time.Millisecond)
-		}
-		
-		if success {
-			sent++
-		} else {
-			failed++
-		}
-	}
+		return nil, err
+	}
+	
+	var notifications []store.OutboxMessage
+	for _, student := range students {
+		notifications = append(notifications, store.OutboxMessage{
+			ChatID: student.TelegramID,
+			Text: "❌ **Отмена уроков**\n\n" +
+				"К сожалению, преподаватель **" + escapeMarkdown(teacherName) + "** больше не работает в школе.\n\n" +
+				"📚 **Отмененные уроки:**\n" +
+				formatStudentLessons(student.Lessons) + "\n\n" +
+				"💔 Приносим извинения за неудобства.\n" +
+				"🔄 Вы можете записаться на другие уроки командой /schedule",
+		})
+	}
+	return notifications, nil
 }
 
-// notifyStudentsAboutTeacherRestoration - уведомление о восстановлении преподавателя
-func notifyStudentsAboutTeacherRestoration(bot *tgbotapi.BotAPI, db *sql.DB, teacherID int, teacherName string) (int, int) {
-	// Получаем всех студентов с активными записями к этому преподавателю
-	query := `
-		SELECT DISTINCT u.tg_id, u.full_name,
-			STRING_AGG(
-				s.name || ' (' || l.start_time::date || ' ' || l.start_time::time || ')',
-				E'\n• '
-			) as lessons_info
-		FROM enrollments e
-		JOIN students st ON e.student_id = st.id
-		JOIN users u ON st.user_id = u.id
-		JOIN lessons l ON e.lesson_id = l.id
-		JOIN subjects s ON l.subject_id = s.id
-		WHERE l.teacher_id = $1 AND l.is_active = true AND l.start_time > NOW()
-			AND e.status = 'enrolled'
-		GROUP BY u.tg_id, u.full_name`
-	
-	rows, err := db.Query(query, teacherID)
+// notifyStudentsAboutTeacherRestoration - уведомление о восстановлении преподавателя через очередь
+func notifyStudentsAboutTeacherRestoration(bot telegram.Messenger, db *sql.DB, teacherID int, teacherName string, senderID, reportChatID int64) (int, error) {
+	// Получаем всех студентов с активными записями на предстоящие уроки преподавателя
+	st := repos(db)
+	lessons, err := st.Lessons.ByTeacher(teacherID, time.Now(), time.Time{})
 	if err != nil {
-		return 0, 0
+		return 0, err
 	}
-	defer rows.Close()
-	
-	sent := 0
-	failed := 0
-	
-	for rows.Next() {
-		var studentTelegramID int64
-		var studentName, lessonsInfo string
-		
-		if err := rows.Scan(&studentTelegramID, &studentName, &lessonsInfo); err != nil {
-			failed++
-			continue
+	var lessonIDs []int
+	for _, lesson := range lessons {
+		if lesson.Status == "active" {
+			lessonIDs = append(lessonIDs, lesson.ID)
 		}
-		
-		// Формируем уведомление
-		notificationText := "🎉 **ОТЛИЧНЫЕ НОВОСТИ!**\n\n" +
-			"Преподаватель **" + teacherName + "** возобновляет работу!\n\n" +
-			"📚 **Ваши восстановленные уроки:**\n" +
-			"• " + lessonsInfo + "\n\n" +
-			"✅ Все ваши записи остаются активными\n" +
-			"🎯 Ждем вас на занятиях!"
-		
-		msg := tgbotapi.NewMessage(studentTelegramID, notificationText)
-		msg.ParseMode = "Markdown"
-		
-		// Retry механизм
-		success := false
-		for attempt := 0; attempt < 3; attempt++ {
-			if _, err := bot.Send(msg); err == nil {
-				success = true
-				break
-			}
-			time.Sleep(500 * time.Millisecond)
+	}
+	
+	var notifications []store.OutboxMessage
+	if len(lessonIDs) > 0 {
+		students, err := st.Enrollments.EnrolledByStudent(lessonIDs)
+		if err != nil {
+			return 0, err
 		}
-		
-		if success {
-			sent++
-		} else {
-			failed++
+		for _, student := range students {
+			notifications = append(notifications, store.OutboxMessage{
+				ChatID: student.TelegramID,
+				Text: "🎉 **ОТЛИЧНЫЕ НОВОСТИ!**\n\n" +
+					"Преподаватель **" + escapeMarkdown(teacherName) + "** возобновляет работу!\n\n" +
+					"📚 **Ваши восстановленные уроки:**\n" +
+					formatStudentLessons(student.Lessons) + "\n\n" +
+					"✅ Все ваши записи остаются активными\n" +
+					"🎯 Ждем вас на занятиях!",
+			})
 		}
 	}
 	
-	return sent, failed
+	return enqueueMessages(bot, db, notificationTeacherRestored, "🎉 **Преподаватель "+escapeMarkdown(teacherName)+" возобновляет работу**",
+		notifications, senderID, reportChatID)
+}
+
+// formatStudentLessons - уроки студента списком для уведомлений
+func formatStudentLessons(lessons []store.Lesson) string {
+	var lines []string
+	for _, lesson := range lessons {
+		lines = append(lines, fmt.Sprintf("• %s (%s)", escapeMarkdown(lesson.SubjectName), lesson.StartTime.Format("02.01.2006 15:04")))
+	}
+	return strings.Join(lines, "\n")
 }
@@ -0,0 +1,203 @@
+// Package health - проверки /healthz и /readyz для healthcheck docker-compose.
+//
+// /healthz (liveness) - цикл обработки обновлений не завис: если обновления
+// ждут обработки, последнее обработано не раньше MaxUpdateAge назад.
+// Бот без входящих обновлений жив.
+// /readyz (readiness) - дополнительно доступны PostgreSQL и Telegram (getMe
+// с кэшем на TelegramCacheTTL, чтобы частые проверки не упирались в лимиты Bot API).
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Pinger - проверка соединения с БД (*sql.DB)
+type Pinger interface {
+	PingContext(ctx context.Context) error
+}
+
+// Options - настройки проверок
+type Options struct {
+	DB               Pinger
+	GetMe            func() error  // запрос getMe к Telegram
+	TelegramCacheTTL time.Duration // время жизни результата getMe
+	MaxUpdateAge     time.Duration // допустимое время без обработанных обновлений при непустой очереди
+	CheckTimeout     time.Duration // таймаут проверки БД
+}
+
+// Checker - состояние проверок процесса
+type Checker struct {
+	opts Options
+	now  func() time.Time
+
+	started       time.Time
+	pending       atomic.Int64 // обновлений получено, но не обработано
+	lastProcessed atomic.Int64 // время последнего обработанного обновления (UnixNano)
+
+	telegramMu        sync.Mutex
+	telegramCheckedAt time.Time
+	telegramErr       error
+}
+
+// Check - результат одной проверки
+type Check struct {
+	Status string `json:"status"` // ok или fail
+	Error  string `json:"error,omitempty"`
+}
+
+// Report - ответ /healthz и /readyz
+type Report struct {
+	Status                 string           `json:"status"`
+	Checks                 map[string]Check `json:"checks"`
+	PendingUpdates         int64            `json:"pending_updates"`
+	SecondsSinceLastUpdate float64          `json:"seconds_since_last_update"` // с запуска, если обновлений еще не было
+	LastUpdateAt           *time.Time       `json:"last_update_at,omitempty"`
+}
+
+const (
+	statusOK   = "ok"
+	statusFail = "fail"
+)
+
+// New - проверки с настройками по умолчанию для нулевых значений
+func New(opts Options) *Checker {
+	if opts.TelegramCacheTTL <= 0 {
+		opts.TelegramCacheTTL = time.Minute
+	}
+	if opts.MaxUpdateAge <= 0 {
+		opts.MaxUpdateAge = 5 * time.Minute
+	}
+	if opts.CheckTimeout <= 0 {
+		opts.CheckTimeout = 3 * time.Second
+	}
+	return &Checker{opts: opts, now: time.Now, started: time.Now()}
+}
+
+// UpdateReceived - обновление поставлено в обработку
+func (c *Checker) UpdateReceived() {
+	c.pending.Add(1)
+}
+
+// UpdateProcessed - обработка обновления завершена (в том числе паникой)
+func (c *Checker) UpdateProcessed() {
+	c.pending.Add(-1)
+	c.lastProcessed.Store(c.now().UnixNano())
+}
+
+// Register - маршруты /healthz и /readyz
+func (c *Checker) Register(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Liveness())
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeReport(w, c.Readiness(r.Context()))
+	})
+}
+
+// Liveness - проверка цикла обработки обновлений
+func (c *Checker) Liveness() Report {
+	report := c.newReport()
+	report.Checks["update_loop"] = c.checkUpdateLoop()
+	return report.finish()
+}
+
+// Readiness - цикл обработки, PostgreSQL и Telegram
+func (c *Checker) Readiness(ctx context.Context) Report {
+	report := c.newReport()
+	report.Checks["update_loop"] = c.checkUpdateLoop()
+	report.Checks["database"] = c.checkDB(ctx)
+	report.Checks["telegram"] = c.checkTelegram()
+	return report.finish()
+}
+
+func (c *Checker) newReport() *Report {
+	report := &Report{
+		Checks:         make(map[string]Check),
+		PendingUpdates: c.pending.Load(),
+	}
+	report.SecondsSinceLastUpdate = c.now().Sub(c.lastProcessedAt()).Seconds()
+	if nanos := c.lastProcessed.Load(); nanos != 0 {
+		processedAt := time.Unix(0, nanos).UTC()
+		report.LastUpdateAt = &processedAt
+	}
+	return report
+}
+
+// lastProcessedAt - время последнего обработанного обновления или запуска
+func (c *Checker) lastProcessedAt() time.Time {
+	if nanos := c.lastProcessed.Load(); nanos != 0 {
+		return time.Unix(0, nanos)
+	}
+	return c.started
+}
+
+func (c *Checker) checkUpdateLoop() Check {
+	if c.pending.Load() <= 0 {
+		return Check{Status: statusOK}
+	}
+	if age := c.now().Sub(c.lastProcessedAt()); age > c.opts.MaxUpdateAge {
+		return failed(fmt.Errorf("обновления ждут обработки, последнее обработано %v назад", age.Round(time.Second)))
+	}
+	return Check{Status: statusOK}
+}
+
+func (c *Checker) checkDB(ctx context.Context) Check {
+	if c.opts.DB == nil {
+		return Check{Status: statusOK}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.opts.CheckTimeout)
+	defer cancel()
+	if err := c.opts.DB.PingContext(ctx); err != nil {
+		return failed(err)
+	}
+	return Check{Status: statusOK}
+}
+
+// checkTelegram - getMe не чаще раза в TelegramCacheTTL
+func (c *Checker) checkTelegram() Check {
+	if c.opts.GetMe == nil {
+		return Check{Status: statusOK}
+	}
+
+	c.telegramMu.Lock()
+	defer c.telegramMu.Unlock()
+
+	if c.telegramCheckedAt.IsZero() || c.now().Sub(c.telegramCheckedAt) >= c.opts.TelegramCacheTTL {
+		c.telegramErr = c.opts.GetMe()
+		c.telegramCheckedAt = c.now()
+	}
+	if c.telegramErr != nil {
+		return failed(c.telegramErr)
+	}
+	return Check{Status: statusOK}
+}
+
+func failed(err error) Check {
+	return Check{Status: statusFail, Error: err.Error()}
+}
+
+// finish - общий статус: fail, если не прошла хотя бы одна проверка
+func (r *Report) finish() Report {
+	r.Status = statusOK
+	for _, check := range r.Checks {
+		if check.Status != statusOK {
+			r.Status = statusFail
+		}
+	}
+	return *r
+}
+
+func writeReport(w http.ResponseWriter, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != statusOK {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type pingFunc func(ctx context.Context) error
+
+func (f pingFunc) PingContext(ctx context.Context) error { return f(ctx) }
+
+// fakeClock - управляемое время для проверок
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestChecker(opts Options) (*Checker, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	c := New(opts)
+	c.now = clock.Now
+	c.started = clock.now
+	return c, clock
+}
+
+// Тест: бот без обновлений жив, зависшая очередь - нет
+func TestLivenessUpdateLoop(t *testing.T) {
+	c, clock := newTestChecker(Options{MaxUpdateAge: time.Minute})
+
+	clock.now = clock.now.Add(time.Hour)
+	if report := c.Liveness(); report.Status != statusOK {
+		t.Fatalf("Expected idle bot to be alive, got %+v", report)
+	}
+
+	c.UpdateReceived()
+	c.UpdateReceived()
+	c.UpdateProcessed()
+	clock.now = clock.now.Add(30 * time.Second)
+	if report := c.Liveness(); report.Status != statusOK || report.PendingUpdates != 1 {
+		t.Fatalf("Expected alive with 1 pending update, got %+v", report)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	report := c.Liveness()
+	if report.Status != statusFail || report.Checks["update_loop"].Error == "" {
+		t.Fatalf("Expected stuck update loop, got %+v", report)
+	}
+	if report.SecondsSinceLastUpdate != 90 {
+		t.Errorf("Expected 90 seconds since last update, got %v", report.SecondsSinceLastUpdate)
+	}
+
+	c.UpdateProcessed()
+	if report := c.Liveness(); report.Status != statusOK {
+		t.Errorf("Expected alive after queue drained, got %+v", report)
+	}
+}
+
+// Тест: результат getMe кэшируется на TelegramCacheTTL
+func TestReadinessCachesTelegram(t *testing.T) {
+	calls := 0
+	var telegramErr error
+	c, clock := newTestChecker(Options{
+		DB:               pingFunc(func(ctx context.Context) error { return nil }),
+		GetMe:            func() error { calls++; return telegramErr },
+		TelegramCacheTTL: time.Minute,
+	})
+
+	c.Readiness(context.Background())
+	telegramErr = errors.New("telegram unreachable")
+	if report := c.Readiness(context.Background()); report.Status != statusOK {
+		t.Fatalf("Expected cached ok result, got %+v", report)
+	}
+	if calls != 1 {
+		t.Fatalf("Expected 1 getMe call, got %d", calls)
+	}
+
+	clock.now = clock.now.Add(time.Minute)
+	report := c.Readiness(context.Background())
+	if report.Status != statusFail || report.Checks["telegram"].Error != "telegram unreachable" {
+		t.Errorf("Expected telegram failure after cache expiry, got %+v", report)
+	}
+	if calls != 2 {
+		t.Errorf("Expected 2 getMe calls, got %d", calls)
+	}
+}
+
+// Тест HTTP ответов: 200 для ok, 503 при недоступной БД только на /readyz
+func TestHandlers(t *testing.T) {
+	c, _ := newTestChecker(Options{
+		DB: pingFunc(func(ctx context.Context) error { return errors.New("connection refused") }),
+	})
+	mux := http.NewServeMux()
+	c.Register(mux)
+
+	tests := []struct {
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{"/healthz", http.StatusOK, `"status":"ok"`},
+		{"/readyz", http.StatusServiceUnavailable, `"database":{"status":"fail","error":"connection refused"}`},
+	}
+	for _, tt := range tests {
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if recorder.Code != tt.wantStatus {
+			t.Errorf("%s: expected %d, got %d", tt.path, tt.wantStatus, recorder.Code)
+		}
+		if !strings.Contains(recorder.Body.String(), tt.wantBody) {
+			t.Errorf("%s: expected %s in body %s", tt.path, tt.wantBody, recorder.Body.String())
+		}
+	}
+}
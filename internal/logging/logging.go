@@ -0,0 +1,182 @@
+// Package logging - структурированные логи процесса на slog.
+//
+// Setup настраивает slog по умолчанию: уровень и формат из конфигурации,
+// correlation ID обновления из контекста в каждой записи и маскирование
+// персональных данных (телефоны, email, токен бота) в тексте и атрибутах.
+// Стандартный log после Setup пишет через тот же обработчик на уровне INFO.
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+// CorrelationKey - атрибут записи с correlation ID обновления
+const CorrelationKey = "correlation_id"
+
+type correlationKey struct{}
+
+// WithCorrelationID - контекст с correlation ID обновления
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationID - correlation ID из контекста ("" если не задан)
+func CorrelationID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// NewCorrelationID - случайный ID из 16 hex-символов
+func NewCorrelationID() string {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "0000000000000000"
+	}
+	return hex.EncodeToString(b[:])
+}
+
+// ParseLevel - уровень по имени (debug, info, warn, error); неизвестное имя - info
+func ParseLevel(name string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(name))); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// New - логгер с уровнем level в формате format (json или text)
+func New(w io.Writer, level, format string) *slog.Logger {
+	opts := &slog.HandlerOptions{Level: ParseLevel(level)}
+
+	var handler slog.Handler
+	if strings.EqualFold(format, "json") {
+		handler = slog.NewJSONHandler(w, opts)
+	} else {
+		handler = slog.NewTextHandler(w, opts)
+	}
+	return slog.New(&contextHandler{next: handler})
+}
+
+// Setup - логгер по умолчанию для slog и стандартного log
+func Setup(w io.Writer, level, format string) *slog.Logger {
+	logger := New(w, level, format)
+	slog.SetDefault(logger)
+	return logger
+}
+
+// contextHandler - correlation ID из контекста и маскирование персональных данных
+type contextHandler struct {
+	next slog.Handler
+}
+
+func (h *contextHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	redacted := slog.NewRecord(record.Time, record.Level, Redact(record.Message), record.PC)
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAttr(attr))
+		return true
+	})
+	if id := CorrelationID(ctx); id != "" {
+		redacted.AddAttrs(slog.String(CorrelationKey, id))
+	}
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+	for i, attr := range attrs {
+		redacted[i] = redactAttr(attr)
+	}
+	return &contextHandler{next: h.next.WithAttrs(redacted)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{next: h.next.WithGroup(name)}
+}
+
+// Ключи атрибутов, значения которых не пишутся в лог целиком
+var sensitiveKeys = map[string]bool{
+	"phone":    true,
+	"token":    true,
+	"secret":   true,
+	"password": true,
+}
+
+func redactAttr(attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, "[REDACTED]")
+	}
+
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, Redact(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		redacted := make([]any, len(group))
+		for i, a := range group {
+			redacted[i] = redactAttr(a)
+		}
+		return slog.Group(attr.Key, redacted...)
+	case slog.KindAny:
+		if err, ok := value.Any().(error); ok {
+			return slog.String(attr.Key, Redact(err.Error()))
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+var (
+	// Токен бота: <id>:<35 символов>, в том числе внутри URL Bot API
+	botTokenPattern = regexp.MustCompile(`\d{6,12}:[A-Za-z0-9_-]{30,}`)
+	emailPattern    = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	// Телефоны: +7 999 123-45-67, 89991234567, 8 (999) 123-45-67.
+	// Telegram ID (до 10 цифр подряд) и даты под шаблоны не попадают.
+	phonePatterns = []*regexp.Regexp{
+		regexp.MustCompile(`\+\d[\d\s()-]{8,18}\d`),
+		regexp.MustCompile(`\b[78]\d{10}\b`),
+		regexp.MustCompile(`\b[78][\s(-]+\d{3}[\s)-]+\d{3}[\s-]?\d{2}[\s-]?\d{2}\b`),
+	}
+	// Поле phone/phone_number в JSON обновлений Telegram
+	phoneFieldPattern = regexp.MustCompile(`("phone(?:_number)?"\s*:\s*)"[^"]*"`)
+)
+
+// Redact - маскирует телефоны, email и токен бота в строке
+func Redact(s string) string {
+	if s == "" {
+		return s
+	}
+	s = botTokenPattern.ReplaceAllString(s, "[TOKEN]")
+	s = phoneFieldPattern.ReplaceAllString(s, `$1"[REDACTED]"`)
+	s = emailPattern.ReplaceAllString(s, "[EMAIL]")
+	for _, pattern := range phonePatterns {
+		s = pattern.ReplaceAllStringFunc(s, maskPhone)
+	}
+	return s
+}
+
+// maskPhone - от телефона остаются последние две цифры
+func maskPhone(match string) string {
+	digits := 0
+	for _, r := range match {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	if digits < 10 || digits > 15 {
+		return match
+	}
+	return "[PHONE:**" + match[len(match)-2:] + "]"
+}
@@ -0,0 +1,81 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// Тест маскирования персональных данных
+func TestRedact(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"телефон +7 999 123-45-67", "телефон [PHONE:**67]"},
+		{"телефон 89991234567", "телефон [PHONE:**67]"},
+		{"телефон 8 (999) 123-45-67", "телефон [PHONE:**67]"},
+		{`{"phone_number":"79991234567","first_name":"Ivan"}`, `{"phone_number":"[REDACTED]","first_name":"Ivan"}`},
+		{"почта ivan@example.com", "почта [EMAIL]"},
+		{"https://api.telegram.org/bot123456789:AAGdGtyllqYq8ijIXPwPCfgExTNOrAu0QEc/getMe", "https://api.telegram.org/bot[TOKEN]/getMe"},
+		// Telegram ID, даты и ID уроков остаются как есть
+		{"пользователь 7231695922, урок 15", "пользователь 7231695922, урок 15"},
+		{"урок на 2025-01-15 18:00", "урок на 2025-01-15 18:00"},
+	}
+	for _, tt := range tests {
+		if got := Redact(tt.in); got != tt.want {
+			t.Errorf("Redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+// Тест: уровень, correlation ID из контекста и маскирование атрибутов
+func TestLoggerRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger := New(&buf, "warn", "json")
+
+	ctx := WithCorrelationID(context.Background(), "abc123")
+	logger.InfoContext(ctx, "пропускается по уровню")
+	logger.ErrorContext(ctx, "Ошибка регистрации", "phone", "+79991234567", "err", errors.New("user ivan@example.com exists"))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("Expected 1 record at warn level, got %d: %s", len(lines), buf.String())
+	}
+
+	var record map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("Invalid JSON record: %v", err)
+	}
+	want := map[string]any{
+		"level":          "ERROR",
+		"correlation_id": "abc123",
+		"phone":          "[REDACTED]",
+		"err":            "user [EMAIL] exists",
+	}
+	for key, value := range want {
+		if record[key] != value {
+			t.Errorf("Expected %s=%v, got %v", key, value, record[key])
+		}
+	}
+}
+
+// Тест разбора уровня из конфигурации
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"INFO":  slog.LevelInfo,
+		"warn":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+		"loud":  slog.LevelInfo,
+	} {
+		if got := ParseLevel(name); got != want {
+			t.Errorf("ParseLevel(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package metrics - метрики процесса бота для Prometheus.
+//
+// Метрики регистрируются в реестре по умолчанию и отдаются по /metrics
+// (METRICS_LISTEN_ADDR). Метки ограничены известными значениями: маршруты
+// команд и кнопок, типы запросов Bot API, репозитории - без ID пользователей.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"constellation-school-bot/internal/worker"
+)
+
+const namespace = "constellation"
+
+var (
+	// UpdatesTotal - обработанные обновления по типу (command, text, callback) и итогу
+	UpdatesTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "updates_total",
+		Help:      "Обработанные обновления Telegram по типу и итогу обработки.",
+	}, []string{"type", "outcome"})
+
+	// CommandDuration - время обработки команд
+	CommandDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "command_duration_seconds",
+		Help:      "Время обработки команды от получения до ответа.",
+		Buckets:   []float64{.01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"command"})
+
+	// CallbackActionsTotal - нажатия inline-кнопок по действию и итогу
+	CallbackActionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "callback_actions_total",
+		Help:      "Нажатия inline-кнопок по действию и итогу обработки.",
+	}, []string{"action", "outcome"})
+
+	// TelegramSendFailuresTotal - ошибки запросов к Bot API по типу запроса
+	TelegramSendFailuresTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "telegram_send_failures_total",
+		Help:      "Неудачные запросы к Telegram Bot API по типу запроса.",
+	}, []string{"request"})
+
+	// DBQueryDuration - время запросов репозиториев к PostgreSQL
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Время запросов репозиториев к PostgreSQL.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"repository", "operation"})
+
+	// RateLimitRejectionsTotal - операции, отклоненные rate limiter
+	RateLimitRejectionsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Операции записи, отклоненные rate limiter из-за незавершенной операции.",
+	}, []string{"operation"})
+
+	// WaitlistPromotionsTotal - записи на урок из листа ожидания
+	WaitlistPromotionsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "waitlist_promotions_total",
+		Help:      "Студенты, записанные на урок из листа ожидания после освобождения места.",
+	})
+
+	// NotificationsTotal - попытки доставки уведомлений из очереди по итогу
+	// (sent, retry, throttled, failed, unreachable)
+	NotificationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "notifications_total",
+		Help:      "Попытки доставки уведомлений из очереди по итогу.",
+	}, []string{"outcome"})
+
+	// RemindersQueuedTotal - автоматические напоминания об уроках, поставленные в очередь, по типу (24h, 1h)
+	RemindersQueuedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "reminders_queued_total",
+		Help:      "Автоматические напоминания об уроках, поставленные в очередь уведомлений.",
+	}, []string{"reminder"})
+)
+
+// Since - секунды с момента start (для Observe)
+func Since(start time.Time) float64 {
+	return time.Since(start).Seconds()
+}
+
+// RegisterDB - метрики пула соединений database/sql (открытые, занятые, ожидание соединения)
+func RegisterDB(db *sql.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+// RegisterWorkerPool - метрики пула обработки обновлений по снимкам stats:
+// глубина очередей, обновления в обработке, паники и ожидание места в очереди (back-pressure)
+func RegisterWorkerPool(stats func() worker.Stats) {
+	gauge := func(name, help string, value func(worker.Stats) float64) prometheus.Collector {
+		return prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "worker",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(stats()) })
+	}
+	counter := func(name, help string, value func(worker.Stats) float64) prometheus.Collector {
+		return prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "worker",
+			Name:      name,
+			Help:      help,
+		}, func() float64 { return value(stats()) })
+	}
+
+	prometheus.MustRegister(
+		gauge("queue_depth", "Обновления в очередях воркеров, ожидающие обработки.",
+			func(s worker.Stats) float64 { return float64(s.Queued) }),
+		gauge("queue_capacity", "Суммарная емкость очередей воркеров.",
+			func(s worker.Stats) float64 { return float64(s.Workers * s.QueueSize) }),
+		gauge("in_flight", "Обновления в обработке.",
+			func(s worker.Stats) float64 { return float64(s.InFlight) }),
+		counter("processed_total", "Обработанные воркерами обновления.",
+			func(s worker.Stats) float64 { return float64(s.Processed) }),
+		counter("panics_total", "Обработчики обновлений, завершившиеся паникой.",
+			func(s worker.Stats) float64 { return float64(s.Panics) }),
+		counter("blocked_submits_total", "Постановки в очередь, ожидавшие места в заполненной очереди.",
+			func(s worker.Stats) float64 { return float64(s.Blocked) }),
+		counter("blocked_seconds_total", "Суммарное время ожидания места в заполненной очереди.",
+			func(s worker.Stats) float64 { return s.BlockedTime.Seconds() }),
+	)
+}
+
+// NewServer - HTTP сервер с /metrics на адресе addr.
+// mounts добавляют служебные маршруты на тот же адрес (например, /healthz).
+func NewServer(addr string, mounts ...func(mux *http.ServeMux)) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	for _, mount := range mounts {
+		mount(mux)
+	}
+
+	return &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/worker"
+)
+
+// Тест: /metrics отдает метрики бота в формате Prometheus
+func TestMetricsEndpoint(t *testing.T) {
+	UpdatesTotal.WithLabelValues("command", "ok").Inc()
+	CommandDuration.WithLabelValues("/start").Observe(0.02)
+	TelegramSendFailuresTotal.WithLabelValues("MessageConfig").Inc()
+	WaitlistPromotionsTotal.Inc()
+	RegisterWorkerPool(func() worker.Stats {
+		return worker.Stats{Workers: 4, QueueSize: 100, Queued: 3, Blocked: 2, BlockedTime: 1500 * time.Millisecond}
+	})
+
+	recorder := httptest.NewRecorder()
+	NewServer(":0").Handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	if recorder.Code != 200 {
+		t.Fatalf("Expected 200, got %d", recorder.Code)
+	}
+	body, _ := io.ReadAll(recorder.Body)
+	for _, want := range []string{
+		`constellation_updates_total{outcome="ok",type="command"}`,
+		`constellation_command_duration_seconds_bucket{command="/start",le="0.025"} 1`,
+		`constellation_telegram_send_failures_total{request="MessageConfig"}`,
+		`constellation_waitlist_promotions_total`,
+		`constellation_worker_queue_depth 3`,
+		`constellation_worker_queue_capacity 400`,
+		`constellation_worker_blocked_submits_total 2`,
+		`constellation_worker_blocked_seconds_total 1.5`,
+		`go_goroutines`,
+	} {
+		if !strings.Contains(string(body), want) {
+			t.Errorf("Expected %s in /metrics output", want)
+		}
+	}
+}
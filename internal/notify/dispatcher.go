@@ -0,0 +1,324 @@
+// Package notify - доставка уведомлений из очереди в PostgreSQL (outbox).
+//
+// Обработчики ставят рассылку в очередь (store.OutboxRepository.Enqueue) и сразу
+// отвечают отправителю. Dispatcher в фоне отправляет сообщения с учетом лимитов
+// Telegram: не больше GlobalRate сообщений в секунду всего и одно сообщение в чат
+// за PerChatInterval. Ошибки сети и 5xx повторяются с экспоненциальной задержкой,
+// 429 откладывает всю отправку на retry_after из ответа. Получатели, заблокировавшие
+// бота (403), помечаются недоступными. Когда рассылка завершена, отправитель
+// получает отчет о доставке.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/metrics"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+)
+
+// Options - лимиты и повторы; нулевые GlobalRate и PerChatInterval - без ограничения
+type Options struct {
+	GlobalRate      int           // сообщений в секунду на бота (Telegram допускает около 30)
+	PerChatInterval time.Duration // минимальный интервал между сообщениями в один чат
+	PollInterval    time.Duration // пауза между проверками очереди
+	BatchSize       int           // сообщений, забираемых из очереди за раз
+	MaxAttempts     int           // попыток доставки до статуса failed
+	BaseBackoff     time.Duration // задержка перед первым повтором, дальше удваивается
+	MaxBackoff      time.Duration
+}
+
+// Итоги попытки доставки (метка metrics.NotificationsTotal)
+const (
+	outcomeSent        = "sent"
+	outcomeRetry       = "retry"
+	outcomeThrottled   = "throttled"
+	outcomeFailed      = "failed"
+	outcomeUnreachable = "unreachable"
+)
+
+// Dispatcher - фоновая отправка уведомлений из очереди
+type Dispatcher struct {
+	outbox store.OutboxRepository
+	bot    telegram.Messenger
+	opts   Options
+
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu       sync.Mutex
+	nextSend time.Time           // глобальный лимит: следующее сообщение не раньше
+	lastSent map[int64]time.Time // время последнего сообщения в чат
+}
+
+// New - диспетчер с настройками по умолчанию для нулевых значений
+func New(outbox store.OutboxRepository, bot telegram.Messenger, opts Options) *Dispatcher {
+	if opts.PollInterval <= 0 {
+		opts.PollInterval = time.Second
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 50
+	}
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = 5
+	}
+	if opts.BaseBackoff <= 0 {
+		opts.BaseBackoff = 2 * time.Second
+	}
+	if opts.MaxBackoff < opts.BaseBackoff {
+		opts.MaxBackoff = 10 * time.Minute
+	}
+	return &Dispatcher{
+		outbox:   outbox,
+		bot:      bot,
+		opts:     opts,
+		now:      time.Now,
+		sleep:    sleepContext,
+		lastSent: make(map[int64]time.Time),
+	}
+}
+
+// Run - отправка до отмены ctx. Сообщения, взятые до прошлой остановки, возвращаются в очередь.
+func (d *Dispatcher) Run(ctx context.Context) {
+	if released, err := d.outbox.ReleaseClaimed(); err != nil {
+		slog.ErrorContext(ctx, "Ошибка возврата неотправленных уведомлений в очередь", "err", err)
+	} else if released > 0 {
+		slog.InfoContext(ctx, "Неотправленные уведомления возвращены в очередь", "count", released)
+	}
+
+	for {
+		if _, err := d.Flush(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Ошибка отправки уведомлений из очереди", "err", err)
+		}
+		if d.sleep(ctx, d.opts.PollInterval) != nil {
+			return
+		}
+	}
+}
+
+// Flush - отправка всех сообщений, которым пора, и отчетов о завершенных рассылках.
+// Возвращает число попыток доставки.
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	attempts := 0
+	for {
+		messages, err := d.outbox.Claim(d.opts.BatchSize)
+		if err != nil {
+			return attempts, err
+		}
+		if len(messages) == 0 {
+			break
+		}
+		for i, message := range messages {
+			delivered, err := d.deliver(ctx, message)
+			if err != nil {
+				// Остановка: недоставленное вернется в очередь сразу, а не после перезапуска
+				for _, rest := range messages[i:] {
+					d.save(ctx, rest, d.outbox.Postpone(rest.ID, d.now()))
+				}
+				return attempts, err
+			}
+			if delivered {
+				attempts++
+			}
+		}
+	}
+	d.forgetIdleChats()
+
+	return attempts, d.report(ctx)
+}
+
+// deliver - одна попытка доставки; false - сообщение отложено из-за лимита чата
+func (d *Dispatcher) deliver(ctx context.Context, message store.OutboxMessage) (bool, error) {
+	if last, ok := d.lastSent[message.ChatID]; ok && d.opts.PerChatInterval > 0 {
+		if next := last.Add(d.opts.PerChatInterval); next.After(d.now()) {
+			d.save(ctx, message, d.outbox.Postpone(message.ID, next))
+			return false, nil
+		}
+	}
+	if err := d.waitGlobal(ctx); err != nil {
+		return false, err
+	}
+
+	msg := tgbotapi.NewMessage(message.ChatID, message.Text)
+	msg.ParseMode = message.ParseMode
+	_, sendErr := d.bot.Send(msg)
+	d.sent(message.ChatID)
+
+	outcome, retryAfter := classify(sendErr)
+	metrics.NotificationsTotal.WithLabelValues(outcome).Inc()
+
+	now := d.now()
+	var err error
+	switch outcome {
+	case outcomeSent:
+		err = d.outbox.MarkSent(message.ID)
+	case outcomeThrottled:
+		// Лимит бота, а не ошибка сообщения: попытка не учитывается, вся отправка ждет retry_after
+		if retryAfter <= 0 {
+			retryAfter = d.opts.BaseBackoff
+		}
+		if until := now.Add(retryAfter); until.After(d.nextSend) {
+			d.nextSend = until
+		}
+		slog.WarnContext(ctx, "Telegram ограничил отправку уведомлений", "retry_after", retryAfter)
+		err = d.outbox.Postpone(message.ID, now.Add(retryAfter))
+	case outcomeRetry:
+		if message.Attempts+1 >= d.opts.MaxAttempts {
+			err = d.outbox.MarkFailed(message.ID, sendErr.Error())
+		} else {
+			err = d.outbox.Retry(message.ID, now.Add(d.backoff(message.Attempts+1)), sendErr.Error())
+		}
+	case outcomeUnreachable:
+		err = d.outbox.MarkUnreachable(message.ID, sendErr.Error())
+	default:
+		slog.WarnContext(ctx, "Уведомление не доставлено", "notification_id", message.ID, "err", sendErr)
+		err = d.outbox.MarkFailed(message.ID, sendErr.Error())
+	}
+	d.save(ctx, message, err)
+	return true, nil
+}
+
+// waitGlobal - ожидание глобального лимита отправки
+func (d *Dispatcher) waitGlobal(ctx context.Context) error {
+	if wait := d.nextSend.Sub(d.now()); wait > 0 {
+		return d.sleep(ctx, wait)
+	}
+	return ctx.Err()
+}
+
+// sent - учет отправки в лимитах
+func (d *Dispatcher) sent(chatID int64) {
+	now := d.now()
+	d.lastSent[chatID] = now
+	if d.opts.GlobalRate > 0 {
+		if next := now.Add(time.Second / time.Duration(d.opts.GlobalRate)); next.After(d.nextSend) {
+			d.nextSend = next
+		}
+	}
+}
+
+// forgetIdleChats - чаты, которым уже можно писать, не нужно помнить
+func (d *Dispatcher) forgetIdleChats() {
+	now := d.now()
+	for chatID, last := range d.lastSent {
+		if now.Sub(last) >= d.opts.PerChatInterval {
+			delete(d.lastSent, chatID)
+		}
+	}
+}
+
+// backoff - задержка перед повтором номер attempt (с 1)
+func (d *Dispatcher) backoff(attempt int) time.Duration {
+	delay := d.opts.BaseBackoff
+	for i := 1; i < attempt && delay < d.opts.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > d.opts.MaxBackoff {
+		delay = d.opts.MaxBackoff
+	}
+	return delay
+}
+
+// save - ошибка записи статуса не останавливает отправку: сообщение останется
+// в sending и вернется в очередь при следующем запуске
+func (d *Dispatcher) save(ctx context.Context, message store.OutboxMessage, err error) {
+	if err != nil {
+		slog.ErrorContext(ctx, "Ошибка сохранения статуса уведомления", "notification_id", message.ID, "err", err)
+	}
+}
+
+// report - отчеты о доставке отправителям завершенных рассылок
+func (d *Dispatcher) report(ctx context.Context) error {
+	batches, err := d.outbox.CompletedBatches(d.opts.BatchSize)
+	if err != nil {
+		return err
+	}
+
+	for _, batch := range batches {
+		if err := d.waitGlobal(ctx); err != nil {
+			return err
+		}
+		msg := tgbotapi.NewMessage(batch.ReportChatID, DeliveryReport(batch))
+		msg.ParseMode = "Markdown"
+		_, sendErr := d.bot.Send(msg)
+		d.sent(batch.ReportChatID)
+
+		// Временную ошибку повторим в следующий раз, остальные - не отправлять отчет бесконечно
+		if outcome, _ := classify(sendErr); outcome == outcomeRetry || outcome == outcomeThrottled {
+			slog.WarnContext(ctx, "Отчет о рассылке не отправлен, повторим позже", "batch_id", batch.ID, "err", sendErr)
+			continue
+		} else if sendErr != nil {
+			slog.WarnContext(ctx, "Отчет о рассылке не доставлен", "batch_id", batch.ID, "err", sendErr)
+		}
+		if err := d.outbox.MarkReported(batch.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeliveryReport - текст отчета о доставке рассылки
+func DeliveryReport(batch store.NotificationBatch) string {
+	text := "📬 **Отчет о доставке**\n\n" +
+		batch.Title + "\n\n" +
+		fmt.Sprintf("👥 Получателей: %d\n", batch.Total) +
+		fmt.Sprintf("✅ Доставлено: %d\n", batch.Sent)
+	if batch.Unreachable > 0 {
+		text += fmt.Sprintf("🚫 Заблокировали бота: %d\n", batch.Unreachable)
+	}
+	if batch.Failed > 0 {
+		text += fmt.Sprintf("❌ Не доставлено: %d\n", batch.Failed)
+	}
+	return text
+}
+
+// classify - итог попытки по ошибке Bot API и задержка из retry_after для 429
+func classify(err error) (string, time.Duration) {
+	if err == nil {
+		return outcomeSent, 0
+	}
+
+	var apiErr *tgbotapi.Error
+	if !errors.As(err, &apiErr) {
+		// Сеть, таймаут или ответ не от Bot API (502 балансировщика)
+		return outcomeRetry, 0
+	}
+
+	description := strings.ToLower(apiErr.Message)
+	switch {
+	case apiErr.Code == http.StatusTooManyRequests:
+		return outcomeThrottled, time.Duration(apiErr.RetryAfter) * time.Second
+	case apiErr.Code >= http.StatusInternalServerError:
+		return outcomeRetry, 0
+	case apiErr.Code == http.StatusForbidden,
+		strings.Contains(description, "chat not found"),
+		strings.Contains(description, "user is deactivated"):
+		return outcomeUnreachable, 0
+	default:
+		return outcomeFailed, 0
+	}
+}
+
+// sleepContext - пауза с прерыванием по отмене ctx
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,336 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/store"
+)
+
+// fakeClock - время, которое двигает только sleep
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Sleep(ctx context.Context, d time.Duration) error {
+	c.now = c.now.Add(d)
+	return ctx.Err()
+}
+
+type fakeNotification struct {
+	store.OutboxMessage
+	status    store.NotificationStatus
+	nextAt    time.Time
+	lastError string
+}
+
+// fakeOutbox - очередь в памяти
+type fakeOutbox struct {
+	clock         *fakeClock
+	batches       []store.NotificationBatch
+	notifications []*fakeNotification
+	reported      map[int]bool
+	unreachable   []int64
+}
+
+func (f *fakeOutbox) Enqueue(batch store.NotificationBatch, messages []store.OutboxMessage) (int, error) {
+	batch.ID = len(f.batches) + 1
+	batch.Total = len(messages)
+	f.batches = append(f.batches, batch)
+	for _, m := range messages {
+		m.ID, m.BatchID = len(f.notifications)+1, batch.ID
+		f.notifications = append(f.notifications, &fakeNotification{
+			OutboxMessage: m,
+			status:        store.NotificationPending,
+			nextAt:        f.clock.now,
+		})
+	}
+	return batch.ID, nil
+}
+
+// enqueue - рассылка одного текста получателям chatIDs
+func (f *fakeOutbox) enqueue(batch store.NotificationBatch, text string, chatIDs ...int64) {
+	messages := make([]store.OutboxMessage, len(chatIDs))
+	for i, chatID := range chatIDs {
+		messages[i] = store.OutboxMessage{ChatID: chatID, Text: text}
+	}
+	f.Enqueue(batch, messages)
+}
+
+func (f *fakeOutbox) Claim(limit int) ([]store.OutboxMessage, error) {
+	var claimed []store.OutboxMessage
+	for _, n := range f.notifications {
+		if len(claimed) < limit && n.status == store.NotificationPending && !n.nextAt.After(f.clock.now) {
+			n.status = store.NotificationSending
+			claimed = append(claimed, n.OutboxMessage)
+		}
+	}
+	return claimed, nil
+}
+
+func (f *fakeOutbox) set(id int, status store.NotificationStatus, attempt bool, at time.Time, lastError string) {
+	n := f.notifications[id-1]
+	n.status = status
+	n.nextAt = at
+	n.lastError = lastError
+	if attempt {
+		n.Attempts++
+	}
+}
+
+func (f *fakeOutbox) MarkSent(id int) error {
+	f.set(id, store.NotificationSent, true, time.Time{}, "")
+	return nil
+}
+
+func (f *fakeOutbox) Retry(id int, at time.Time, lastError string) error {
+	f.set(id, store.NotificationPending, true, at, lastError)
+	return nil
+}
+
+func (f *fakeOutbox) Postpone(id int, at time.Time) error {
+	f.set(id, store.NotificationPending, false, at, f.notifications[id-1].lastError)
+	return nil
+}
+
+func (f *fakeOutbox) MarkFailed(id int, lastError string) error {
+	f.set(id, store.NotificationFailed, true, time.Time{}, lastError)
+	return nil
+}
+
+func (f *fakeOutbox) MarkUnreachable(id int, lastError string) error {
+	f.set(id, store.NotificationUnreachable, true, time.Time{}, lastError)
+	f.unreachable = append(f.unreachable, f.notifications[id-1].ChatID)
+	return nil
+}
+
+func (f *fakeOutbox) ReleaseClaimed() (int, error) {
+	released := 0
+	for _, n := range f.notifications {
+		if n.status == store.NotificationSending {
+			n.status = store.NotificationPending
+			released++
+		}
+	}
+	return released, nil
+}
+
+func (f *fakeOutbox) CompletedBatches(limit int) ([]store.NotificationBatch, error) {
+	var completed []store.NotificationBatch
+	for _, batch := range f.batches {
+		if batch.ReportChatID == 0 || f.reported[batch.ID] {
+			continue
+		}
+		done := true
+		for _, n := range f.notifications {
+			if n.BatchID != batch.ID {
+				continue
+			}
+			switch n.status {
+			case store.NotificationPending, store.NotificationSending:
+				done = false
+			case store.NotificationSent:
+				batch.Sent++
+			case store.NotificationFailed:
+				batch.Failed++
+			case store.NotificationUnreachable:
+				batch.Unreachable++
+			}
+		}
+		if done {
+			completed = append(completed, batch)
+		}
+	}
+	return completed, nil
+}
+
+func (f *fakeOutbox) MarkReported(batchID int) error {
+	f.reported[batchID] = true
+	return nil
+}
+
+// fakeBot - Messenger, который отвечает ошибками из очереди errors[chatID]
+type fakeBot struct {
+	clock  *fakeClock
+	errors map[int64][]error
+	sent   []sentMessage
+}
+
+type sentMessage struct {
+	chatID int64
+	text   string
+	at     time.Time
+}
+
+func (b *fakeBot) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	msg := c.(tgbotapi.MessageConfig)
+	if queue := b.errors[msg.ChatID]; len(queue) > 0 {
+		b.errors[msg.ChatID] = queue[1:]
+		if queue[0] != nil {
+			return tgbotapi.Message{}, queue[0]
+		}
+	}
+	b.sent = append(b.sent, sentMessage{chatID: msg.ChatID, text: msg.Text, at: b.clock.now})
+	return tgbotapi.Message{}, nil
+}
+
+func (b *fakeBot) Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	return &tgbotapi.APIResponse{Ok: true}, nil
+}
+
+func (b *fakeBot) AnswerCallback(callbackID, text string) error { return nil }
+
+func (b *fakeBot) EditMessage(chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) error {
+	return nil
+}
+
+func newTestDispatcher(opts Options) (*Dispatcher, *fakeOutbox, *fakeBot, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)}
+	outbox := &fakeOutbox{clock: clock, reported: make(map[int]bool)}
+	bot := &fakeBot{clock: clock, errors: make(map[int64][]error)}
+	d := New(outbox, bot, opts)
+	d.now = clock.Now
+	d.sleep = clock.Sleep
+	return d, outbox, bot, clock
+}
+
+func apiError(code int, message string, retryAfter int) error {
+	return &tgbotapi.Error{Code: code, Message: message, ResponseParameters: tgbotapi.ResponseParameters{RetryAfter: retryAfter}}
+}
+
+// Тест: глобальный лимит растягивает рассылку, второе сообщение в чат ждет PerChatInterval
+func TestDispatcherRateLimits(t *testing.T) {
+	d, outbox, bot, clock := newTestDispatcher(Options{GlobalRate: 10, PerChatInterval: time.Second})
+	start := clock.now
+
+	outbox.enqueue(store.NotificationBatch{}, "first", 1, 2, 3)
+	outbox.enqueue(store.NotificationBatch{}, "second", 1)
+
+	if _, err := d.Flush(context.Background()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(bot.sent) != 3 {
+		t.Fatalf("Expected 3 messages before per-chat limit, got %+v", bot.sent)
+	}
+	for i, message := range bot.sent {
+		if want := start.Add(time.Duration(i) * 100 * time.Millisecond); !message.at.Equal(want) {
+			t.Errorf("Message %d: expected at %v, got %v", i, want, message.at)
+		}
+	}
+
+	clock.now = start.Add(time.Second)
+	d.Flush(context.Background())
+	if len(bot.sent) != 4 || bot.sent[3].chatID != 1 || bot.sent[3].text != "second" {
+		t.Fatalf("Expected postponed message to chat 1, got %+v", bot.sent)
+	}
+}
+
+// Тест: 429 откладывает отправку на retry_after без учета попытки, 5xx повторяется с backoff
+func TestDispatcherRetries(t *testing.T) {
+	d, outbox, bot, clock := newTestDispatcher(Options{BaseBackoff: time.Second, MaxAttempts: 3})
+	start := clock.now
+
+	bot.errors[1] = []error{apiError(429, "Too Many Requests: retry after 7", 7)}
+	bot.errors[2] = []error{apiError(502, "Bad Gateway", 0), errors.New("connection reset"), errors.New("connection reset")}
+	outbox.enqueue(store.NotificationBatch{ReportChatID: 99}, "text", 1, 2)
+
+	d.Flush(context.Background())
+	// После 429 вся отправка ждет retry_after, затем сообщение уходит без повторной попытки
+	if len(bot.sent) != 1 || bot.sent[0].chatID != 1 || !bot.sent[0].at.Equal(start.Add(7*time.Second)) {
+		t.Fatalf("Expected throttled message sent after retry_after, got %+v", bot.sent)
+	}
+	if n := outbox.notifications[0]; n.status != store.NotificationSent || n.Attempts != 1 {
+		t.Fatalf("Expected throttled attempt not counted, got %+v", n)
+	}
+	if n := outbox.notifications[1]; n.Attempts != 1 || !n.nextAt.Equal(start.Add(8*time.Second)) {
+		t.Fatalf("Expected 5xx retry after global pause and 1s backoff, got %+v", n)
+	}
+
+	clock.now = start.Add(8 * time.Second)
+	d.Flush(context.Background())
+	if n := outbox.notifications[1]; n.Attempts != 2 || !n.nextAt.Equal(clock.now.Add(2*time.Second)) {
+		t.Fatalf("Expected doubled backoff, got %+v", n)
+	}
+	clock.now = clock.now.Add(2 * time.Second)
+	d.Flush(context.Background())
+
+	if n := outbox.notifications[1]; n.status != store.NotificationFailed || n.lastError != "connection reset" {
+		t.Errorf("Expected message failed after max attempts, got %+v", n)
+	}
+
+	report := bot.sent[len(bot.sent)-1]
+	if report.chatID != 99 || !strings.Contains(report.text, "Доставлено: 1") || !strings.Contains(report.text, "Не доставлено: 1") {
+		t.Errorf("Expected delivery report to sender, got %+v", report)
+	}
+}
+
+// Тест: заблокировавший бота помечается недоступным, ошибка запроса не повторяется
+func TestDispatcherUnreachableAndReport(t *testing.T) {
+	d, outbox, bot, _ := newTestDispatcher(Options{})
+
+	bot.errors[2] = []error{apiError(403, "Forbidden: bot was blocked by the user", 0)}
+	bot.errors[3] = []error{apiError(400, "Bad Request: can't parse entities", 0)}
+	outbox.enqueue(store.NotificationBatch{Title: "📢 **Массовое уведомление**", ReportChatID: 99}, "Текст", 1, 2, 3)
+	outbox.enqueue(store.NotificationBatch{}, "без отчета", 1)
+
+	d.Flush(context.Background())
+
+	want := []store.NotificationStatus{store.NotificationSent, store.NotificationUnreachable, store.NotificationFailed, store.NotificationSent}
+	for i, status := range want {
+		if got := outbox.notifications[i].status; got != status {
+			t.Errorf("Notification %d: expected %s, got %s", i+1, status, got)
+		}
+	}
+	if len(outbox.unreachable) != 1 || outbox.unreachable[0] != 2 {
+		t.Errorf("Expected chat 2 marked unreachable, got %v", outbox.unreachable)
+	}
+
+	var reports []sentMessage
+	for _, message := range bot.sent {
+		if message.chatID == 99 {
+			reports = append(reports, message)
+		}
+	}
+	if len(reports) != 1 {
+		t.Fatalf("Expected 1 report, got %+v", reports)
+	}
+	for _, line := range []string{"**Массовое уведомление**", "Получателей: 3", "Доставлено: 1", "Заблокировали бота: 1", "Не доставлено: 1"} {
+		if !strings.Contains(reports[0].text, line) {
+			t.Errorf("Expected %q in report %q", line, reports[0].text)
+		}
+	}
+
+	d.Flush(context.Background())
+	if len(bot.sent) != 3 {
+		t.Errorf("Expected report sent once, got %+v", bot.sent)
+	}
+}
+
+// Тест: при остановке взятые сообщения возвращаются в очередь
+func TestDispatcherStopReleasesClaimed(t *testing.T) {
+	d, outbox, bot, _ := newTestDispatcher(Options{GlobalRate: 1})
+	outbox.enqueue(store.NotificationBatch{}, "text", 1, 2, 3)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	d.sleep = func(ctx context.Context, d time.Duration) error {
+		cancel()
+		return ctx.Err()
+	}
+
+	if _, err := d.Flush(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected context.Canceled, got %v", err)
+	}
+	if len(bot.sent) != 1 {
+		t.Fatalf("Expected 1 message before stop, got %+v", bot.sent)
+	}
+	for _, n := range outbox.notifications[1:] {
+		if n.status != store.NotificationPending || n.Attempts != 0 {
+			t.Errorf("Expected unsent message back in queue, got %+v", n)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+// Package reminders - автоматические напоминания студентам о предстоящих уроках.
+//
+// Scheduler периодически ищет записи, которым пора напоминание за заданный интервал
+// до начала урока (например, за 24 часа и за 1 час), и ставит их в очередь уведомлений
+// (internal/notify). Отправленные напоминания хранятся по записям в БД, поэтому
+// перезапуск бота их не дублирует. Если бот пропустил момент напоминания и уже
+// наступило время следующего, более близкого, отправляется только оно.
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sort"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/metrics"
+	"constellation-school-bot/internal/store"
+)
+
+// kind - тип рассылки напоминаний в очереди уведомлений
+const kind = "lesson_reminder"
+
+// Options - интервалы напоминаний и частота проверки
+type Options struct {
+	Offsets       []time.Duration // за сколько до начала урока напоминать; пустой список - без напоминаний
+	CheckInterval time.Duration   // пауза между проверками
+	BatchSize     int             // записей за один запрос
+}
+
+// Scheduler - фоновая постановка напоминаний в очередь уведомлений
+type Scheduler struct {
+	reminders store.ReminderRepository
+	opts      Options
+
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// New - планировщик с настройками по умолчанию для нулевых значений
+func New(reminders store.ReminderRepository, opts Options) *Scheduler {
+	opts.Offsets = Normalize(opts.Offsets)
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = time.Minute
+	}
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 100
+	}
+	return &Scheduler{reminders: reminders, opts: opts, sleep: sleepContext}
+}
+
+// Normalize - положительные интервалы без повторов, от большего к меньшему
+func Normalize(offsets []time.Duration) []time.Duration {
+	seen := make(map[time.Duration]bool, len(offsets))
+	normalized := make([]time.Duration, 0, len(offsets))
+	for _, offset := range offsets {
+		if offset <= 0 || seen[offset] {
+			continue
+		}
+		seen[offset] = true
+		normalized = append(normalized, offset)
+	}
+	sort.Slice(normalized, func(i, j int) bool { return normalized[i] > normalized[j] })
+	return normalized
+}
+
+// Run - проверки до отмены ctx
+func (s *Scheduler) Run(ctx context.Context) {
+	if len(s.opts.Offsets) == 0 {
+		slog.InfoContext(ctx, "Автоматические напоминания об уроках отключены")
+		return
+	}
+	for {
+		if _, err := s.Check(ctx); err != nil && ctx.Err() == nil {
+			slog.ErrorContext(ctx, "Ошибка постановки напоминаний в очередь", "err", err)
+		}
+		if s.sleep(ctx, s.opts.CheckInterval) != nil {
+			return
+		}
+	}
+}
+
+// Check - постановка в очередь всех напоминаний, которым пора. Возвращает их число.
+func (s *Scheduler) Check(ctx context.Context) (int, error) {
+	total := 0
+	for i, offset := range s.opts.Offsets {
+		// Окно напоминания заканчивается там, где начинается окно следующего
+		var until time.Duration
+		if i+1 < len(s.opts.Offsets) {
+			until = s.opts.Offsets[i+1]
+		}
+
+		queued, err := s.checkOffset(ctx, offset, until)
+		total += queued
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
+
+// checkOffset - напоминания одного типа, порциями по BatchSize
+func (s *Scheduler) checkOffset(ctx context.Context, offset, until time.Duration) (int, error) {
+	reminder := Label(offset)
+	total := 0
+	for ctx.Err() == nil {
+		due, err := s.reminders.Due(reminder, offset, until, s.opts.BatchSize)
+		if err != nil || len(due) == 0 {
+			return total, err
+		}
+
+		messages := make([]store.OutboxMessage, len(due))
+		for i, d := range due {
+			messages[i] = store.OutboxMessage{ChatID: d.TelegramID, Text: Text(d), ParseMode: "Markdown"}
+		}
+		queued, err := s.reminders.Enqueue(reminder, store.NotificationBatch{
+			Kind:  kind,
+			Title: "⏰ Напоминания об уроках за " + Describe(offset),
+		}, due, messages)
+		if err != nil {
+			return total, err
+		}
+		total += queued
+		metrics.RemindersQueuedTotal.WithLabelValues(reminder).Add(float64(queued))
+		slog.InfoContext(ctx, "Напоминания об уроках поставлены в очередь", "reminder", reminder, "count", queued)
+
+		if len(due) < s.opts.BatchSize {
+			return total, nil
+		}
+	}
+	return total, ctx.Err()
+}
+
+// Label - тип напоминания для интервала: "24h", "1h", "30m"
+func Label(offset time.Duration) string {
+	switch {
+	case offset%time.Hour == 0:
+		return fmt.Sprintf("%dh", offset/time.Hour)
+	case offset%time.Minute == 0:
+		return fmt.Sprintf("%dm", offset/time.Minute)
+	default:
+		return offset.String()
+	}
+}
+
+// Describe - интервал для сообщений: "24 ч", "30 мин"
+func Describe(offset time.Duration) string {
+	switch {
+	case offset%time.Hour == 0:
+		return fmt.Sprintf("%d ч", offset/time.Hour)
+	case offset%time.Minute == 0:
+		return fmt.Sprintf("%d мин", offset/time.Minute)
+	default:
+		return offset.String()
+	}
+}
+
+// Text - текст напоминания студенту. Время до урока не пишется: после простоя бота
+// напоминание может прийти позже своего интервала, поэтому указывается время начала.
+// Названия экранируются: Telegram отклоняет сообщение с непарной разметкой.
+func Text(due store.DueReminder) string {
+	text := fmt.Sprintf("⏰ **Напоминание об уроке**\n\n"+
+		"📚 Урок: %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, due.SubjectName))
+	if due.TeacherName != "" {
+		text += fmt.Sprintf("👨‍🏫 Преподаватель: %s\n", tgbotapi.EscapeText(tgbotapi.ModeMarkdown, due.TeacherName))
+	}
+	text += fmt.Sprintf("📅 Время: %s\n\n"+
+		"🔕 Настроить напоминания: /reminders", due.StartTime.Format("02.01.2006 15:04"))
+	return text
+}
+
+// sleepContext - пауза с прерыванием по отмене ctx
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
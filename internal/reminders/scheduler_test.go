@@ -0,0 +1,194 @@
+package reminders
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+type fakeEnrollment struct {
+	store.DueReminder
+	enrolledAt time.Time
+}
+
+// fakeReminders - записи и отметки о напоминаниях в памяти, с теми же условиями, что и SQL
+type fakeReminders struct {
+	now         time.Time
+	enrollments []fakeEnrollment
+	sent        map[string]bool // enrollment/reminder/start_time
+	optedOut    map[int]map[string]bool
+	queued      []store.OutboxMessage
+	batches     []store.NotificationBatch
+}
+
+func newFakeReminders(now time.Time) *fakeReminders {
+	return &fakeReminders{now: now, sent: make(map[string]bool), optedOut: make(map[int]map[string]bool)}
+}
+
+// enroll - запись студента studentID (Telegram ID 1000+studentID) на урок через startIn
+func (f *fakeReminders) enroll(studentID int, startIn, enrolledAgo time.Duration) {
+	f.enrollments = append(f.enrollments, fakeEnrollment{
+		DueReminder: store.DueReminder{
+			EnrollmentID: len(f.enrollments) + 1,
+			StudentID:    studentID,
+			TelegramID:   int64(1000 + studentID),
+			LessonID:     len(f.enrollments) + 1,
+			SubjectName:  "Астрономия",
+			StartTime:    f.now.Add(startIn),
+		},
+		enrolledAt: f.now.Add(-enrolledAgo),
+	})
+}
+
+func sentKey(d store.DueReminder, reminder string) string {
+	return fmt.Sprintf("%d/%s/%s", d.EnrollmentID, reminder, d.StartTime)
+}
+
+func (f *fakeReminders) Due(reminder string, offset, until time.Duration, limit int) ([]store.DueReminder, error) {
+	var due []store.DueReminder
+	for _, e := range f.enrollments {
+		if e.StartTime.After(f.now.Add(offset)) || !e.StartTime.After(f.now.Add(until)) ||
+			e.enrolledAt.After(e.StartTime.Add(-offset)) ||
+			f.sent[sentKey(e.DueReminder, reminder)] || f.optedOut[e.StudentID][reminder] {
+			continue
+		}
+		if len(due) == limit {
+			break
+		}
+		due = append(due, e.DueReminder)
+	}
+	return due, nil
+}
+
+func (f *fakeReminders) Enqueue(reminder string, batch store.NotificationBatch, due []store.DueReminder, messages []store.OutboxMessage) (int, error) {
+	queued := 0
+	for i, d := range due {
+		if key := sentKey(d, reminder); !f.sent[key] {
+			f.sent[key] = true
+			f.queued = append(f.queued, messages[i])
+			queued++
+		}
+	}
+	if queued > 0 {
+		f.batches = append(f.batches, batch)
+	}
+	return queued, nil
+}
+
+func (f *fakeReminders) OptedOut(studentID int) ([]string, error) {
+	var reminders []string
+	for reminder, optedOut := range f.optedOut[studentID] {
+		if optedOut {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+func (f *fakeReminders) SetOptOut(studentID int, reminder string, optOut bool) error {
+	if f.optedOut[studentID] == nil {
+		f.optedOut[studentID] = make(map[string]bool)
+	}
+	f.optedOut[studentID][reminder] = optOut
+	return nil
+}
+
+func (f *fakeReminders) recipients() []int64 {
+	chatIDs := make([]int64, len(f.queued))
+	for i, m := range f.queued {
+		chatIDs[i] = m.ChatID
+	}
+	return chatIDs
+}
+
+func check(t *testing.T, s *Scheduler, want int) {
+	t.Helper()
+	queued, err := s.Check(context.Background())
+	if err != nil {
+		t.Fatalf("Check failed: %v", err)
+	}
+	if queued != want {
+		t.Errorf("Expected %d queued reminders, got %d", want, queued)
+	}
+}
+
+// Каждое напоминание ставится в очередь один раз, в том числе после перезапуска планировщика
+func TestSchedulerQueuesEachReminderOnce(t *testing.T) {
+	repo := newFakeReminders(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	repo.enroll(1, 23*time.Hour, 48*time.Hour)
+	repo.enroll(2, 23*time.Hour, 48*time.Hour)
+	repo.enroll(3, 72*time.Hour, time.Hour)
+
+	opts := Options{Offsets: []time.Duration{time.Hour, 24 * time.Hour, time.Hour}, BatchSize: 1}
+	s := New(repo, opts)
+	check(t, s, 2)
+	check(t, s, 0)
+
+	repo.now = repo.now.Add(22*time.Hour + 30*time.Minute)
+	check(t, New(repo, opts), 2)
+	check(t, New(repo, opts), 0)
+
+	if got := repo.recipients(); len(got) != 4 || got[0] != 1001 || got[1] != 1002 || got[2] != 1001 {
+		t.Errorf("Unexpected recipients: %v", got)
+	}
+	if len(repo.batches) != 4 || repo.batches[0].Kind != "lesson_reminder" || !strings.Contains(repo.batches[0].Title, "24 ч") ||
+		!strings.Contains(repo.batches[2].Title, "1 ч") {
+		t.Errorf("Unexpected batches: %+v", repo.batches)
+	}
+	if m := repo.queued[0]; m.ParseMode != "Markdown" || !strings.Contains(m.Text, "Астрономия") || !strings.Contains(m.Text, "02.03.2025 09:00") {
+		t.Errorf("Unexpected reminder message: %+v", m)
+	}
+}
+
+// Пропущенное напоминание не отправляется, если наступило время следующего;
+// записавшиеся позже момента напоминания и отказавшиеся от типа его не получают
+func TestSchedulerSkipsMissedLateAndOptedOut(t *testing.T) {
+	repo := newFakeReminders(time.Date(2025, 3, 1, 10, 0, 0, 0, time.UTC))
+	repo.enroll(1, 30*time.Minute, 48*time.Hour) // бот пропустил 24h, пора 1h
+	repo.enroll(2, 10*time.Hour, 5*time.Minute)  // записался позже момента 24h
+	repo.enroll(3, 20*time.Hour, 48*time.Hour)   // отказался от 24h
+	repo.SetOptOut(3, "24h", true)
+
+	s := New(repo, Options{Offsets: []time.Duration{24 * time.Hour, time.Hour}})
+	check(t, s, 1)
+	if got := repo.recipients(); len(got) != 1 || got[0] != 1001 || !strings.Contains(repo.batches[0].Title, "1 ч") {
+		t.Fatalf("Expected only the 1h reminder for student 1, got %v %+v", got, repo.batches)
+	}
+
+	repo.now = repo.now.Add(9*time.Hour + 30*time.Minute)
+	check(t, s, 1)
+	if got := repo.recipients(); got[1] != 1002 {
+		t.Errorf("Expected the 1h reminder for student 2, got %v", got)
+	}
+}
+
+func TestLabelAndDescribe(t *testing.T) {
+	for offset, want := range map[time.Duration][2]string{
+		24 * time.Hour:   {"24h", "24 ч"},
+		time.Hour:        {"1h", "1 ч"},
+		30 * time.Minute: {"30m", "30 мин"},
+		90 * time.Minute: {"90m", "90 мин"},
+	} {
+		if got := [2]string{Label(offset), Describe(offset)}; got != want {
+			t.Errorf("%v: expected %v, got %v", offset, want, got)
+		}
+	}
+}
+
+func TestTextEscapesMarkdown(t *testing.T) {
+	text := Text(store.DueReminder{
+		SubjectName: "3D_моделирование [базовый]",
+		TeacherName: "Анна *Петрова*",
+		StartTime:   time.Date(2025, 9, 1, 16, 30, 0, 0, time.UTC),
+	})
+
+	for _, want := range []string{`3D\_моделирование \[базовый]`, `Анна \*Петрова\*`, "01.09.2025 16:30"} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %q in reminder text, got %q", want, text)
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package store
+
+// AttendanceRepository - отметки посещаемости по записям на уроки (attendance)
+type AttendanceRepository interface {
+	// Sheet - записанные на урок студенты с отметками, по имени
+	Sheet(lessonID int) ([]AttendanceMark, error)
+	// Mark - отметка студента на уроке; возвращает прежнюю отметку ("" - не было).
+	// ErrNotEnrolled, если студент не записан на урок.
+	Mark(lessonID, studentID int, status string, markedBy int64) (string, error)
+	// History - последние прошедшие уроки студента с отметками, от новых к старым
+	History(studentID, limit int) ([]AttendanceRecord, error)
+	// Summary - число прошедших уроков студента по отметкам ("" - не отмечен)
+	Summary(studentID int) (map[string]int, error)
+}
+
+type pgAttendance struct {
+	db queryer
+}
+
+func (r *pgAttendance) Sheet(lessonID int) ([]AttendanceMark, error) {
+	rows, err := r.db.Query(`
+		SELECT s.id, u.full_name, COALESCE(a.status, '')
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		LEFT JOIN attendance a ON a.enrollment_id = e.id
+		WHERE e.lesson_id = $1 AND e.status = 'enrolled'
+		ORDER BY u.full_name, s.id`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var marks []AttendanceMark
+	for rows.Next() {
+		var mark AttendanceMark
+		if err := rows.Scan(&mark.StudentID, &mark.StudentName, &mark.Status); err != nil {
+			return nil, err
+		}
+		marks = append(marks, mark)
+	}
+	return marks, rows.Err()
+}
+
+func (r *pgAttendance) Mark(lessonID, studentID int, status string, markedBy int64) (string, error) {
+	var marked bool
+	var previous string
+	err := r.db.QueryRow(`
+		WITH enrollment AS (
+			SELECT id FROM enrollments
+			WHERE lesson_id = $1 AND student_id = $2 AND status = 'enrolled'
+			ORDER BY id DESC LIMIT 1
+		), previous AS (
+			SELECT a.status FROM attendance a JOIN enrollment e ON a.enrollment_id = e.id
+		), marked AS (
+			INSERT INTO attendance (enrollment_id, status, marked_by_tg_id, marked_at)
+			SELECT id, $3, NULLIF($4::bigint, 0), NOW() FROM enrollment
+			ON CONFLICT (enrollment_id)
+			DO UPDATE SET status = EXCLUDED.status, marked_by_tg_id = EXCLUDED.marked_by_tg_id, marked_at = NOW()
+			RETURNING enrollment_id
+		)
+		SELECT EXISTS (SELECT 1 FROM marked), COALESCE((SELECT status FROM previous), '')`,
+		lessonID, studentID, status, markedBy).Scan(&marked, &previous)
+	if err != nil {
+		return "", err
+	}
+	if !marked {
+		return "", ErrNotEnrolled
+	}
+	return previous, nil
+}
+
+// pastEnrollments - записи студента на прошедшие проведенные уроки
+const pastEnrollments = `
+	FROM enrollments e
+	JOIN lessons l ON e.lesson_id = l.id
+	JOIN subjects s ON l.subject_id = s.id
+	LEFT JOIN attendance a ON a.enrollment_id = e.id
+	WHERE e.student_id = $1 AND e.status = 'enrolled'
+		AND l.start_time <= NOW() AND l.status = 'active' AND l.soft_deleted = false`
+
+func (r *pgAttendance) History(studentID, limit int) ([]AttendanceRecord, error) {
+	rows, err := r.db.Query(`
+		SELECT l.id, s.name, l.start_time, COALESCE(a.status, '')`+pastEnrollments+`
+		ORDER BY l.start_time DESC
+		LIMIT $2`, studentID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []AttendanceRecord
+	for rows.Next() {
+		var record AttendanceRecord
+		if err := rows.Scan(&record.LessonID, &record.SubjectName, &record.StartTime, &record.Status); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (r *pgAttendance) Summary(studentID int) (map[string]int, error) {
+	rows, err := r.db.Query(`
+		SELECT COALESCE(a.status, ''), COUNT(*)`+pastEnrollments+`
+		GROUP BY 1`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	summary := make(map[string]int)
+	for rows.Next() {
+		var status string
+		var count int
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, err
+		}
+		summary[status] = count
+	}
+	return summary, rows.Err()
+}
@@ -0,0 +1,107 @@
+package store
+
+import (
+	"strconv"
+	"strings"
+)
+
+// AuditRepository - журнал аудита audit_log
+type AuditRepository interface {
+	// Record - запись об изменении сущности
+	Record(entry AuditEntry) error
+	// Search - записи по фильтру, новые первыми, и общее число найденных
+	Search(filter AuditFilter, limit, offset int) ([]AuditEntry, int, error)
+}
+
+type pgAudit struct {
+	db queryer
+}
+
+func (r *pgAudit) Record(entry AuditEntry) error {
+	_, err := r.db.Exec(`
+		INSERT INTO audit_log (actor_tg_id, action, entity_type, entity_id, before, after, correlation_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), NOW())`,
+		entry.ActorTgID, entry.Action, string(entry.Entity), entry.EntityID,
+		jsonb(entry.Before), jsonb(entry.After), entry.CorrelationID)
+	return err
+}
+
+func (r *pgAudit) Search(filter AuditFilter, limit, offset int) ([]AuditEntry, int, error) {
+	where, args := auditConditions(filter)
+
+	var total int
+	if err := r.db.QueryRow("SELECT COUNT(*) FROM audit_log a"+where, args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 {
+		return nil, 0, nil
+	}
+
+	args = append(args, limit, offset)
+	rows, err := r.db.Query(`
+		SELECT a.id, a.actor_tg_id, COALESCE(u.full_name, ''), a.action, a.entity_type, a.entity_id,
+			a.before, a.after, COALESCE(a.correlation_id, ''), a.created_at
+		FROM audit_log a
+		LEFT JOIN users u ON u.tg_id = a.actor_tg_id::text`+where+`
+		ORDER BY a.created_at DESC, a.id DESC
+		LIMIT $`+strconv.Itoa(len(args)-1)+` OFFSET $`+strconv.Itoa(len(args)), args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	var entries []AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var entity string
+		if err := rows.Scan(&entry.ID, &entry.ActorTgID, &entry.ActorName, &entry.Action, &entity, &entry.EntityID,
+			&entry.Before, &entry.After, &entry.CorrelationID, &entry.CreatedAt); err != nil {
+			return nil, 0, err
+		}
+		entry.Entity = AuditEntity(entity)
+		entries = append(entries, entry)
+	}
+	return entries, total, rows.Err()
+}
+
+// auditConditions - WHERE по заполненным полям фильтра и его аргументы
+func auditConditions(filter AuditFilter) (string, []any) {
+	var conditions []string
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	if filter.ActorTgID != 0 {
+		add("a.actor_tg_id = ?", filter.ActorTgID)
+	}
+	if filter.Entity != "" {
+		add("a.entity_type = ?", string(filter.Entity))
+	}
+	if filter.EntityID != 0 {
+		add("a.entity_id = ?", filter.EntityID)
+	}
+	if filter.Action != "" {
+		add("a.action = ?", filter.Action)
+	}
+	if !filter.From.IsZero() {
+		add("a.created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		add("a.created_at < ?", filter.To)
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " WHERE " + strings.Join(conditions, " AND "), args
+}
+
+// jsonb - пустое значение записывается как NULL, а не как пустая строка
+func jsonb(data []byte) any {
+	if len(data) == 0 {
+		return nil
+	}
+	return string(data)
+}
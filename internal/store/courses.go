@@ -0,0 +1,302 @@
+package store
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// CourseRepository - курсы, их план и участники (courses, course_plan, course_enrollments)
+type CourseRepository interface {
+	// Create - курс с планом из тем course.Plan; возвращает ID курса
+	Create(course Course) (int, error)
+	// Get - курс с предметом, преподавателем и планом
+	Get(courseID int) (*Course, error)
+	// List - активные курсы без плана
+	List() ([]Course, error)
+	// Schedule - уроки для еще не запланированных тем плана в порядке тем: серия series
+	// на время starts. Участники курса сразу записываются на новые уроки.
+	// Возвращает ID серии и число созданных записей. ErrScheduleConflict, если урок пересечется
+	// с другим уроком преподавателя, ErrStudentScheduleConflict - с другим уроком участника курса.
+	Schedule(courseID int, series LessonSeries, starts []time.Time) (int, int, error)
+	// Members - участники курса по дате записи
+	Members(courseID int) ([]Student, error)
+	// IsMember - записан ли студент на курс
+	IsMember(courseID, studentID int) (bool, error)
+	// Enroll - запись на курс и на все его предстоящие уроки; возвращает число записей на уроки.
+	// ErrNotFound для отмененного курса, ErrAlreadyEnrolled, ErrCourseFull,
+	// ErrStudentScheduleConflict, если урок курса пересекается с другим уроком студента.
+	Enroll(courseID, studentID int) (int, error)
+	// Leave - выход из курса с отменой записей на предстоящие уроки; возвращает число отмененных записей.
+	// ErrNotEnrolled, если студент не участник курса.
+	Leave(courseID, studentID int) (int, error)
+}
+
+type pgCourses struct {
+	db queryer
+}
+
+func (r *pgCourses) Create(course Course) (int, error) {
+	topics := make([]string, len(course.Plan))
+	for i, item := range course.Plan {
+		topics[i] = item.Topic
+	}
+
+	var id int
+	err := r.db.QueryRow(`
+		WITH course AS (
+			INSERT INTO courses (name, subject_id, teacher_id, capacity, status, created_by_tg_id, created_at)
+			VALUES ($1, $2, $3, $4, 'active', NULLIF($5, 0), NOW())
+			RETURNING id
+		), plan AS (
+			INSERT INTO course_plan (course_id, position, topic)
+			SELECT course.id, t.position, t.topic
+			FROM course, unnest($6::text[]) WITH ORDINALITY AS t(topic, position)
+		)
+		SELECT id FROM course`,
+		course.Name, course.SubjectID, course.TeacherID, course.Capacity, course.CreatedBy,
+		pq.Array(topics)).Scan(&id)
+	return id, err
+}
+
+// courseColumns - общий SELECT для Course
+const courseColumns = `
+	SELECT c.id, c.name, c.subject_id, s.name, COALESCE(c.teacher_id, 0), COALESCE(u.full_name, ''),
+		c.capacity, c.members, c.status, COALESCE(c.created_by_tg_id, 0)
+	FROM courses c
+	JOIN subjects s ON c.subject_id = s.id
+	LEFT JOIN teachers t ON c.teacher_id = t.id
+	LEFT JOIN users u ON t.user_id = u.id`
+
+func scanCourse(row interface{ Scan(dest ...any) error }) (Course, error) {
+	var course Course
+	err := row.Scan(&course.ID, &course.Name, &course.SubjectID, &course.SubjectName, &course.TeacherID,
+		&course.TeacherName, &course.Capacity, &course.Members, &course.Status, &course.CreatedBy)
+	return course, err
+}
+
+func (r *pgCourses) Get(courseID int) (*Course, error) {
+	course, err := scanCourse(r.db.QueryRow(courseColumns+`
+	WHERE c.id = $1`, courseID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT p.position, p.topic, COALESCE(p.lesson_id, 0), l.start_time,
+			COALESCE(CASE WHEN l.soft_deleted THEN 'deleted' ELSE l.status END, '')
+		FROM course_plan p
+		LEFT JOIN lessons l ON p.lesson_id = l.id
+		WHERE p.course_id = $1
+		ORDER BY p.position`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item CoursePlanItem
+		var start *time.Time
+		if err := rows.Scan(&item.Position, &item.Topic, &item.LessonID, &start, &item.LessonStatus); err != nil {
+			return nil, err
+		}
+		if start != nil {
+			item.StartTime = *start
+		}
+		course.Plan = append(course.Plan, item)
+	}
+	return &course, rows.Err()
+}
+
+func (r *pgCourses) List() ([]Course, error) {
+	rows, err := r.db.Query(courseColumns + `
+	WHERE c.status = 'active'
+	ORDER BY s.name, c.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var courses []Course
+	for rows.Next() {
+		course, err := scanCourse(rows)
+		if err != nil {
+			return nil, err
+		}
+		courses = append(courses, course)
+	}
+	return courses, rows.Err()
+}
+
+func (r *pgCourses) Schedule(courseID int, series LessonSeries, starts []time.Time) (int, int, error) {
+	// Темы без урока нумеруются по порядку плана, новые уроки - по времени начала
+	var seriesID, enrolled int
+	err := r.db.QueryRow(`
+		WITH series AS (
+			INSERT INTO lesson_series (subject_id, teacher_id, start_time, duration_minutes, max_students,
+				interval_weeks, until_date, occurrences, status, created_by_tg_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NULL, $7, 'active', NULLIF($8, 0), NOW())
+			RETURNING id
+		), exceptions AS (
+			INSERT INTO lesson_series_exceptions (series_id, skip_date, created_at)
+			SELECT series.id, d::date, NOW()
+			FROM series, unnest($9::text[]) AS d
+			ON CONFLICT DO NOTHING
+		), lessons AS (
+			INSERT INTO lessons (subject_id, teacher_id, start_time, duration_minutes, max_students, status,
+				series_id, course_id, created_at)
+			SELECT $1, $2, t::timestamp, $4, $5, 'active', series.id, $11, NOW()
+			FROM series, unnest($10::text[]) AS t
+			RETURNING id, start_time
+		), numbered_lessons AS (
+			SELECT id, ROW_NUMBER() OVER (ORDER BY start_time) AS n FROM lessons
+		), numbered_plan AS (
+			SELECT position, ROW_NUMBER() OVER (ORDER BY position) AS n
+			FROM course_plan
+			WHERE course_id = $11 AND lesson_id IS NULL
+		), planned AS (
+			UPDATE course_plan p
+			SET lesson_id = nl.id
+			FROM numbered_plan np
+			JOIN numbered_lessons nl ON nl.n = np.n
+			WHERE p.course_id = $11 AND p.position = np.position
+		), enrolled AS (
+			INSERT INTO enrollments (student_id, lesson_id, status, enrolled_at)
+			SELECT m.student_id, lessons.id, 'enrolled', NOW()
+			FROM lessons
+			JOIN course_enrollments m ON m.course_id = $11 AND m.status = 'active'
+			RETURNING id
+		)
+		SELECT series.id, (SELECT COUNT(*) FROM enrolled) FROM series`,
+		series.SubjectID, series.TeacherID, series.StartTime, series.DurationMinutes, series.MaxStudents,
+		series.IntervalWeeks, len(starts), series.CreatedBy,
+		pq.Array(timestamps(series.Skip)), pq.Array(timestamps(starts)), courseID).Scan(&seriesID, &enrolled)
+	return seriesID, enrolled, ScheduleConflict(err)
+}
+
+func (r *pgCourses) Members(courseID int) ([]Student, error) {
+	rows, err := r.db.Query(`
+		SELECT s.id, s.user_id, u.tg_id, u.full_name
+		FROM course_enrollments m
+		JOIN students s ON m.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE m.course_id = $1 AND m.status = 'active'
+		ORDER BY m.enrolled_at`, courseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var students []Student
+	for rows.Next() {
+		var student Student
+		if err := rows.Scan(&student.ID, &student.UserID, &student.TelegramID, &student.FullName); err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+func (r *pgCourses) IsMember(courseID, studentID int) (bool, error) {
+	var member bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM course_enrollments
+			WHERE course_id = $1 AND student_id = $2 AND status = 'active')`,
+		courseID, studentID).Scan(&member)
+	return member, err
+}
+
+func (r *pgCourses) Enroll(courseID, studentID int) (int, error) {
+	// Место занимается увеличением courses.members с проверкой вместимости в том же UPDATE,
+	// поэтому одновременные записи не превышают capacity. Записи на уроки, как и в
+	// EnrollmentRepository.Enroll, возвращаются из отмененных или создаются заново.
+	var active, member, joined bool
+	var lessons int
+	err := r.db.QueryRow(`
+		WITH member AS (
+			SELECT 1 FROM course_enrollments
+			WHERE course_id = $1 AND student_id = $2 AND status = 'active'
+		), seat AS (
+			UPDATE courses
+			SET members = members + 1, updated_at = NOW()
+			WHERE id = $1 AND status = 'active' AND members < capacity
+				AND NOT EXISTS (SELECT 1 FROM member)
+			RETURNING id
+		), joined AS (
+			INSERT INTO course_enrollments (course_id, student_id, status, enrolled_at)
+			SELECT seat.id, $2::int, 'active', NOW() FROM seat
+			ON CONFLICT (course_id, student_id)
+			DO UPDATE SET status = 'active', enrolled_at = NOW(), updated_at = NOW()
+			RETURNING id
+		), course_lessons AS (
+			SELECT l.id FROM lessons l
+			WHERE l.course_id = $1 AND l.status = 'active' AND l.soft_deleted = false
+				AND l.start_time > NOW() AND EXISTS (SELECT 1 FROM joined)
+		), reactivated AS (
+			UPDATE enrollments e
+			SET status = 'enrolled', enrolled_at = NOW(), updated_at = NOW()
+			FROM course_lessons
+			WHERE e.id = (
+				SELECT MAX(id) FROM enrollments
+				WHERE student_id = $2 AND lesson_id = course_lessons.id)
+			AND e.status <> 'enrolled'
+			RETURNING e.id
+		), inserted AS (
+			INSERT INTO enrollments (student_id, lesson_id, status, enrolled_at)
+			SELECT $2::int, course_lessons.id, 'enrolled', NOW() FROM course_lessons
+			WHERE NOT EXISTS (
+				SELECT 1 FROM enrollments e WHERE e.student_id = $2 AND e.lesson_id = course_lessons.id)
+			RETURNING id
+		)
+		SELECT EXISTS (SELECT 1 FROM courses WHERE id = $1 AND status = 'active'),
+			EXISTS (SELECT 1 FROM member),
+			EXISTS (SELECT 1 FROM joined),
+			(SELECT COUNT(*) FROM reactivated) + (SELECT COUNT(*) FROM inserted)`,
+		courseID, studentID).Scan(&active, &member, &joined, &lessons)
+	switch {
+	case err != nil:
+		return 0, ScheduleConflict(err)
+	case !active:
+		return 0, ErrNotFound
+	case member:
+		return 0, ErrAlreadyEnrolled
+	case !joined:
+		return 0, ErrCourseFull
+	}
+	return lessons, nil
+}
+
+func (r *pgCourses) Leave(courseID, studentID int) (int, error) {
+	var left bool
+	var cancelled int
+	err := r.db.QueryRow(`
+		WITH left_course AS (
+			UPDATE course_enrollments
+			SET status = 'left', updated_at = NOW()
+			WHERE course_id = $1 AND student_id = $2 AND status = 'active'
+			RETURNING id
+		), seat AS (
+			UPDATE courses
+			SET members = members - 1, updated_at = NOW()
+			WHERE id = $1 AND EXISTS (SELECT 1 FROM left_course)
+		), cancelled AS (
+			UPDATE enrollments e
+			SET status = 'cancelled', updated_at = NOW()
+			FROM lessons l
+			WHERE e.lesson_id = l.id AND l.course_id = $1 AND l.start_time > NOW()
+				AND e.student_id = $2 AND e.status = 'enrolled'
+				AND EXISTS (SELECT 1 FROM left_course)
+			RETURNING e.id
+		)
+		SELECT EXISTS (SELECT 1 FROM left_course), (SELECT COUNT(*) FROM cancelled)`,
+		courseID, studentID).Scan(&left, &cancelled)
+	if err != nil {
+		return 0, err
+	}
+	if !left {
+		return 0, ErrNotEnrolled
+	}
+	return cancelled, nil
+}
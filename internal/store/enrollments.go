@@ -0,0 +1,218 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// EnrollmentRepository - записи студентов на уроки
+type EnrollmentRepository interface {
+	// IsEnrolled - записан ли студент на урок
+	IsEnrolled(studentID, lessonID int) (bool, error)
+	// Enroll - записывает студента, повторно активируя отмененную запись. Места проверяются под блокировкой урока:
+	// ErrLessonFull, если мест нет, ErrLessonUnavailable, если урок отменен или удален, ErrAlreadyEnrolled,
+	// если уже записан, ErrStudentScheduleConflict, если студент записан на другой урок в это время.
+	Enroll(studentID, lessonID int) error
+	// Unenroll - отменяет запись. ErrNotEnrolled, если записи нет.
+	Unenroll(studentID, lessonID int) error
+	// EnrolledStudents - студенты, записанные на урок
+	EnrolledStudents(lessonID int) ([]Student, error)
+	// ByLesson - все записи на урок, включая отмененные, с именами студентов, по времени записи
+	ByLesson(lessonID int) ([]Enrollment, error)
+	// EnrolledByStudent - записанные на выбранные уроки студенты с их уроками, по имени студента
+	EnrolledByStudent(lessonIDs []int) ([]StudentLessons, error)
+	// Lessons - неудаленные уроки после from, на которые записан студент
+	Lessons(studentID int, from time.Time) ([]Lesson, error)
+}
+
+type pgEnrollments struct {
+	db queryer
+}
+
+func (r *pgEnrollments) IsEnrolled(studentID, lessonID int) (bool, error) {
+	var enrolled bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(
+			SELECT 1 FROM enrollments
+			WHERE student_id = $1 AND lesson_id = $2 AND status = 'enrolled')`,
+		studentID, lessonID).Scan(&enrolled)
+	return enrolled, err
+}
+
+func (r *pgEnrollments) Enroll(studentID, lessonID int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Блокировка урока: одновременные записи выполняются по очереди, и занятые места
+	// считаются следующим запросом уже с учетом записей, зафиксированных до нас
+	var maxStudents int
+	err = tx.QueryRow(`
+		SELECT max_students FROM lessons
+		WHERE id = $1 AND status = 'active' AND soft_deleted = false
+		FOR UPDATE`, lessonID).Scan(&maxStudents)
+	if err == sql.ErrNoRows {
+		return ErrLessonUnavailable
+	} else if err != nil {
+		return err
+	}
+
+	var taken int
+	var enrolled bool
+	err = tx.QueryRow(`
+		SELECT COUNT(*), COUNT(*) FILTER (WHERE student_id = $2) > 0
+		FROM enrollments
+		WHERE lesson_id = $1 AND status = 'enrolled'`, lessonID, studentID).Scan(&taken, &enrolled)
+	if err != nil {
+		return err
+	}
+	if enrolled {
+		return ErrAlreadyEnrolled
+	}
+	if taken >= maxStudents {
+		return ErrLessonFull
+	}
+
+	// Уникального индекса на (student_id, lesson_id) нет, поэтому сначала
+	// возвращаем последнюю отмененную запись, и только если записей не было - создаем новую
+	result, err := tx.Exec(`
+		UPDATE enrollments
+		SET status = 'enrolled', enrolled_at = NOW(), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM enrollments
+			WHERE student_id = $1 AND lesson_id = $2
+			ORDER BY id DESC LIMIT 1)
+		AND status <> 'enrolled'`, studentID, lessonID)
+	if err != nil {
+		return ScheduleConflict(err)
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		result, err = tx.Exec(`
+			INSERT INTO enrollments (student_id, lesson_id, status, enrolled_at)
+			SELECT $1::int, $2::int, 'enrolled', NOW()
+			WHERE NOT EXISTS (
+				SELECT 1 FROM enrollments WHERE student_id = $1 AND lesson_id = $2)`,
+			studentID, lessonID)
+		if err != nil {
+			return ScheduleConflict(err)
+		}
+		if affected, _ := result.RowsAffected(); affected == 0 {
+			return ErrAlreadyEnrolled
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *pgEnrollments) Unenroll(studentID, lessonID int) error {
+	result, err := r.db.Exec(`
+		UPDATE enrollments
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE student_id = $1 AND lesson_id = $2 AND status = 'enrolled'`,
+		studentID, lessonID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotEnrolled
+	}
+	return nil
+}
+
+func (r *pgEnrollments) EnrolledStudents(lessonID int) ([]Student, error) {
+	rows, err := r.db.Query(`
+		SELECT s.id, s.user_id, u.tg_id, u.full_name
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE e.lesson_id = $1 AND e.status = 'enrolled'
+		ORDER BY e.enrolled_at`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var students []Student
+	for rows.Next() {
+		var student Student
+		if err := rows.Scan(&student.ID, &student.UserID, &student.TelegramID, &student.FullName); err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+func (r *pgEnrollments) ByLesson(lessonID int) ([]Enrollment, error) {
+	rows, err := r.db.Query(`
+		SELECT e.id, e.student_id, e.lesson_id, e.status, e.enrolled_at, u.full_name, u.tg_id
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE e.lesson_id = $1
+		ORDER BY e.enrolled_at`, lessonID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var enrollments []Enrollment
+	for rows.Next() {
+		var enrollment Enrollment
+		if err := rows.Scan(&enrollment.ID, &enrollment.StudentID, &enrollment.LessonID, &enrollment.Status,
+			&enrollment.EnrolledAt, &enrollment.StudentName, &enrollment.TelegramID); err != nil {
+			return nil, err
+		}
+		enrollments = append(enrollments, enrollment)
+	}
+	return enrollments, rows.Err()
+}
+
+func (r *pgEnrollments) EnrolledByStudent(lessonIDs []int) ([]StudentLessons, error) {
+	rows, err := r.db.Query(`
+		SELECT st.id, st.user_id, u.tg_id, u.full_name, l.id, l.subject_id, s.name, l.start_time
+		FROM enrollments e
+		JOIN students st ON e.student_id = st.id
+		JOIN users u ON st.user_id = u.id
+		JOIN lessons l ON e.lesson_id = l.id
+		JOIN subjects s ON l.subject_id = s.id
+		WHERE e.lesson_id = ANY($1) AND e.status = 'enrolled'
+		ORDER BY u.full_name, st.id, l.start_time`, pq.Array(lessonIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []StudentLessons
+	for rows.Next() {
+		var student Student
+		var lesson Lesson
+		if err := rows.Scan(&student.ID, &student.UserID, &student.TelegramID, &student.FullName,
+			&lesson.ID, &lesson.SubjectID, &lesson.SubjectName, &lesson.StartTime); err != nil {
+			return nil, err
+		}
+		// Строки одного студента идут подряд
+		if len(result) == 0 || result[len(result)-1].ID != student.ID {
+			result = append(result, StudentLessons{Student: student})
+		}
+		last := &result[len(result)-1]
+		last.Lessons = append(last.Lessons, lesson)
+	}
+	return result, rows.Err()
+}
+
+func (r *pgEnrollments) Lessons(studentID int, from time.Time) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	JOIN enrollments own ON own.lesson_id = l.id
+	WHERE own.student_id = $1 AND own.status = 'enrolled'
+		AND l.start_time > $2 AND l.soft_deleted = false`+lessonGroupBy+`
+	ORDER BY l.start_time`, studentID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// FSMStateRepository - состояния диалогов FSM с ограниченным временем жизни (таблица fsm_states)
+type FSMStateRepository interface {
+	// Get - неистекшее состояние пользователя и данные диалога. ErrNotFound, если состояния нет или оно истекло.
+	Get(telegramID int64) (string, map[string]string, error)
+	// SetState - устанавливает состояние на ttl; данные истекшего состояния сбрасываются
+	SetState(telegramID int64, state string, ttl time.Duration) error
+	// SetData - сохраняет значение в данных неистекшего состояния и продлевает его на ttl.
+	// ErrNotFound, если активного состояния нет.
+	SetData(telegramID int64, key, value string, ttl time.Duration) error
+	// Reset - удаляет состояние и данные пользователя
+	Reset(telegramID int64) error
+	// Expire - удаляет истекшие состояния, возвращает число удаленных
+	Expire() (int64, error)
+}
+
+type pgFSMStates struct {
+	db queryer
+}
+
+func (r *pgFSMStates) Get(telegramID int64) (string, map[string]string, error) {
+	var state string
+	var rawData []byte
+	err := r.db.QueryRow(`
+		SELECT state, data FROM fsm_states
+		WHERE tg_id = $1 AND expires_at > NOW()`, telegramID).Scan(&state, &rawData)
+	if err != nil {
+		return "", nil, notFound(err)
+	}
+
+	data := map[string]string{}
+	if len(rawData) > 0 {
+		if err := json.Unmarshal(rawData, &data); err != nil {
+			return "", nil, fmt.Errorf("ошибка разбора данных FSM: %w", err)
+		}
+	}
+	return state, data, nil
+}
+
+func (r *pgFSMStates) SetState(telegramID int64, state string, ttl time.Duration) error {
+	_, err := r.db.Exec(`
+		INSERT INTO fsm_states (tg_id, state, data, expires_at)
+		VALUES ($1, $2, '{}', NOW() + make_interval(secs => $3))
+		ON CONFLICT (tg_id) DO UPDATE SET
+			state = EXCLUDED.state,
+			data = CASE WHEN fsm_states.expires_at > NOW() THEN fsm_states.data ELSE '{}' END,
+			expires_at = EXCLUDED.expires_at`,
+		telegramID, state, ttl.Seconds())
+	return err
+}
+
+func (r *pgFSMStates) SetData(telegramID int64, key, value string, ttl time.Duration) error {
+	patch, err := json.Marshal(map[string]string{key: value})
+	if err != nil {
+		return err
+	}
+
+	result, err := r.db.Exec(`
+		UPDATE fsm_states
+		SET data = data || $2::jsonb, expires_at = NOW() + make_interval(secs => $3)
+		WHERE tg_id = $1 AND expires_at > NOW()`,
+		telegramID, string(patch), ttl.Seconds())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgFSMStates) Reset(telegramID int64) error {
+	_, err := r.db.Exec("DELETE FROM fsm_states WHERE tg_id = $1", telegramID)
+	return err
+}
+
+func (r *pgFSMStates) Expire() (int64, error) {
+	result, err := r.db.Exec("DELETE FROM fsm_states WHERE expires_at <= NOW()")
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
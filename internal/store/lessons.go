@@ -0,0 +1,246 @@
+package store
+
+import (
+	"database/sql"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LessonRepository - уроки
+type LessonRepository interface {
+	// Get - урок по ID, включая отмененные и удаленные
+	Get(lessonID int) (*Lesson, error)
+	// Upcoming - активные уроки в интервале [from, to), не более limit; limit 0 - без ограничения
+	Upcoming(from, to time.Time, limit int) ([]Lesson, error)
+	// Create - новый активный урок, возвращает ID. RoomID 0 - без аудитории.
+	// ErrScheduleConflict, если урок пересекается с другим уроком преподавателя,
+	// ErrRoomConflict - с другим уроком в аудитории.
+	Create(lesson Lesson) (int, error)
+	// ByTeacher - неудаленные уроки преподавателя в интервале [from, to) по времени начала;
+	// нулевые границы не ограничивают выборку
+	ByTeacher(teacherID int, from, to time.Time) ([]Lesson, error)
+	// BySubject - активные уроки предмета, начинающиеся после from
+	BySubject(subjectID int, from time.Time) ([]Lesson, error)
+	// Full - активные уроки без свободных мест в интервале (from, to), не более limit
+	Full(from, to time.Time, limit int) ([]Lesson, error)
+	// Reschedule - перенос активного урока на startTime, возвращает прежнее время начала.
+	// Записи и лист ожидания сохраняются. ErrNotFound, если урок отменен или удален;
+	// пересечения проверяют ограничения БД: ErrScheduleConflict - у преподавателя,
+	// ErrStudentScheduleConflict - у записанного студента, ErrRoomConflict - в аудитории.
+	Reschedule(lessonID int, startTime time.Time) (time.Time, error)
+	// Cancel - отмена урока
+	Cancel(lessonID int) error
+	// Delete - мягкое удаление урока с отменой записей и очисткой листа ожидания.
+	// ErrNotFound, если урока нет или он уже удален.
+	Delete(lessonID int) error
+	// Restore - восстановление удаленного урока с отмененными записями. ErrNotFound, если урок не удален;
+	// пересечения проверяют ограничения БД: ErrScheduleConflict - у преподавателя,
+	// ErrStudentScheduleConflict - у записанного студента, ErrRoomConflict - в аудитории.
+	Restore(lessonID int) error
+}
+
+type pgLessons struct {
+	db queryer
+}
+
+// lessonColumns - общий SELECT для Lesson, используется с lessonGroupBy
+const lessonColumns = `
+	SELECT l.id, COALESCE(l.teacher_id, 0), l.subject_id, s.name, COALESCE(u.full_name, ''),
+		l.start_time, COALESCE(l.duration_minutes, 90), l.max_students, COUNT(e.id),
+		l.status, l.soft_deleted, COALESCE(l.series_id, 0),
+		COALESCE(l.course_id, 0), COALESCE(l.room_id, 0), COALESCE(r.name, '')
+	FROM lessons l
+	JOIN subjects s ON l.subject_id = s.id
+	LEFT JOIN teachers t ON l.teacher_id = t.id
+	LEFT JOIN users u ON t.user_id = u.id
+	LEFT JOIN rooms r ON l.room_id = r.id
+	LEFT JOIN enrollments e ON l.id = e.lesson_id AND e.status = 'enrolled'`
+
+const lessonGroupBy = `
+	GROUP BY l.id, s.name, u.full_name, r.name`
+
+func scanLesson(row interface{ Scan(dest ...any) error }) (Lesson, error) {
+	var lesson Lesson
+	err := row.Scan(&lesson.ID, &lesson.TeacherID, &lesson.SubjectID, &lesson.SubjectName, &lesson.TeacherName,
+		&lesson.StartTime, &lesson.DurationMinutes, &lesson.MaxStudents, &lesson.EnrolledCount,
+		&lesson.Status, &lesson.SoftDeleted, &lesson.SeriesID, &lesson.CourseID,
+		&lesson.RoomID, &lesson.RoomName)
+	return lesson, err
+}
+
+func scanLessons(rows *sql.Rows) ([]Lesson, error) {
+	var lessons []Lesson
+	for rows.Next() {
+		lesson, err := scanLesson(rows)
+		if err != nil {
+			return nil, err
+		}
+		lessons = append(lessons, lesson)
+	}
+	return lessons, rows.Err()
+}
+
+func (r *pgLessons) Get(lessonID int) (*Lesson, error) {
+	lesson, err := scanLesson(r.db.QueryRow(lessonColumns+`
+	WHERE l.id = $1`+lessonGroupBy, lessonID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &lesson, nil
+}
+
+func (r *pgLessons) Upcoming(from, to time.Time, limit int) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE l.start_time >= $1 AND l.start_time < $2
+		AND l.soft_deleted = false AND l.status = 'active'`+lessonGroupBy+`
+	ORDER BY l.start_time
+	LIMIT NULLIF($3, 0)`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
+
+func (r *pgLessons) Create(lesson Lesson) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		INSERT INTO lessons (subject_id, teacher_id, start_time, duration_minutes, max_students, room_id, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, 0), 'active', NOW())
+		RETURNING id`,
+		lesson.SubjectID, lesson.TeacherID, lesson.StartTime, lesson.DurationMinutes, lesson.MaxStudents,
+		lesson.RoomID).Scan(&id)
+	return id, ScheduleConflict(err)
+}
+
+func (r *pgLessons) ByTeacher(teacherID int, from, to time.Time) ([]Lesson, error) {
+	conditions := []string{"l.soft_deleted = false"}
+	var args []any
+	add := func(condition string, arg any) {
+		args = append(args, arg)
+		conditions = append(conditions, strings.Replace(condition, "?", "$"+strconv.Itoa(len(args)), 1))
+	}
+
+	add("l.teacher_id = ?", teacherID)
+	if !from.IsZero() {
+		add("l.start_time >= ?", from)
+	}
+	if !to.IsZero() {
+		add("l.start_time < ?", to)
+	}
+
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE `+strings.Join(conditions, " AND ")+lessonGroupBy+`
+	ORDER BY l.start_time`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
+
+func (r *pgLessons) BySubject(subjectID int, from time.Time) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE l.subject_id = $1 AND l.start_time > $2
+		AND l.soft_deleted = false AND l.status = 'active'`+lessonGroupBy+`
+	ORDER BY l.start_time`, subjectID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
+
+func (r *pgLessons) Full(from, to time.Time, limit int) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE l.start_time > $1 AND l.start_time < $2
+		AND l.soft_deleted = false AND l.status = 'active'`+lessonGroupBy+`
+	HAVING COUNT(e.id) >= l.max_students
+	ORDER BY l.start_time
+	LIMIT $3`, from, to, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
+
+func (r *pgLessons) Reschedule(lessonID int, startTime time.Time) (time.Time, error) {
+	// old - строка до обновления: прежнее время для уведомлений
+	var previous time.Time
+	err := r.db.QueryRow(`
+		UPDATE lessons l
+		SET start_time = $2, updated_at = NOW()
+		FROM lessons old
+		WHERE l.id = $1 AND old.id = l.id
+			AND l.status = 'active' AND l.soft_deleted = false
+		RETURNING old.start_time`, lessonID, startTime).Scan(&previous)
+	if err != nil {
+		return time.Time{}, ScheduleConflict(notFound(err))
+	}
+	return previous, nil
+}
+
+func (r *pgLessons) Cancel(lessonID int) error {
+	result, err := r.db.Exec(`
+		UPDATE lessons
+		SET status = 'cancelled', updated_at = NOW()
+		WHERE id = $1`, lessonID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgLessons) Delete(lessonID int) error {
+	var deleted bool
+	err := r.db.QueryRow(`
+		WITH deleted AS (
+			UPDATE lessons
+			SET soft_deleted = true, updated_at = NOW()
+			WHERE id = $1 AND soft_deleted = false
+			RETURNING id
+		), cancelled AS (
+			UPDATE enrollments
+			SET status = 'cancelled', updated_at = NOW()
+			WHERE lesson_id IN (SELECT id FROM deleted) AND status = 'enrolled'
+		), cleared AS (
+			DELETE FROM waitlist
+			WHERE lesson_id IN (SELECT id FROM deleted)
+		)
+		SELECT EXISTS (SELECT 1 FROM deleted)`, lessonID).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgLessons) Restore(lessonID int) error {
+	var restored bool
+	err := r.db.QueryRow(`
+		WITH restored AS (
+			UPDATE lessons
+			SET soft_deleted = false, updated_at = NOW()
+			WHERE id = $1 AND soft_deleted = true
+			RETURNING id
+		), enrolled AS (
+			UPDATE enrollments
+			SET status = 'enrolled', updated_at = NOW()
+			WHERE lesson_id IN (SELECT id FROM restored) AND status = 'cancelled'
+		)
+		SELECT EXISTS (SELECT 1 FROM restored)`, lessonID).Scan(&restored)
+	if err != nil {
+		return ScheduleConflict(err)
+	}
+	if !restored {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package store
+
+// Уровни записей simple_logs
+const (
+	LogLevelInfo  = "INFO"
+	LogLevelWarn  = "WARN"
+	LogLevelError = "ERROR"
+)
+
+// LogRepository - журнал действий simple_logs
+type LogRepository interface {
+	// Add - запись в журнал; UserID nil для системных действий, пустой Level - INFO
+	Add(entry LogEntry) error
+	// RecentErrors - последние записи уровня ERROR
+	RecentErrors(limit int) ([]LogEntry, error)
+}
+
+type pgLogs struct {
+	db queryer
+}
+
+func (r *pgLogs) Add(entry LogEntry) error {
+	if entry.Level == "" {
+		entry.Level = LogLevelInfo
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO simple_logs (level, action, user_id, details, correlation_id, created_at)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NOW())`,
+		entry.Level, entry.Action, entry.UserID, entry.Details, entry.CorrelationID)
+	return err
+}
+
+func (r *pgLogs) RecentErrors(limit int) ([]LogEntry, error) {
+	rows, err := r.db.Query(`
+		SELECT id, level, action, user_id, COALESCE(details, ''), COALESCE(correlation_id, ''), created_at
+		FROM simple_logs
+		WHERE level = 'ERROR'
+		ORDER BY created_at DESC
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var logs []LogEntry
+	for rows.Next() {
+		var entry LogEntry
+		if err := rows.Scan(&entry.ID, &entry.Level, &entry.Action, &entry.UserID, &entry.Details, &entry.CorrelationID, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		logs = append(logs, entry)
+	}
+	return logs, rows.Err()
+}
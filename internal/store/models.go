@@ -0,0 +1,388 @@
+package store
+
+import "time"
+
+// User - пользователь бота
+type User struct {
+	ID         int
+	TelegramID int64
+	Role       string
+	FullName   string
+	Phone      string
+	IsActive   bool
+	CreatedAt  time.Time
+
+	Unreachable bool // заблокировал бота: рассылки его пропускают до следующего сообщения
+}
+
+// Teacher - преподаватель
+type Teacher struct {
+	ID          int
+	UserID      int
+	TelegramID  int64
+	FullName    string
+	SoftDeleted bool
+	IsActive    bool
+
+	ActiveLessons   int       // неудаленные уроки, заполняется в List
+	UpcomingLessons int       // неудаленные предстоящие уроки, заполняется в List
+	DeletedAt       time.Time // время удаления, заполняется в Deleted
+}
+
+// Student - студент
+type Student struct {
+	ID         int
+	UserID     int
+	TelegramID int64
+	FullName   string
+
+	// Заполняются в List
+	IsActive             bool
+	TotalEnrollments     int
+	ActiveEnrollments    int
+	CancelledEnrollments int
+}
+
+// Subject - предмет
+type Subject struct {
+	ID          int
+	Name        string
+	Category    string
+	Description string
+	IsActive    bool
+
+	BookableLessons int // предстоящие уроки со свободными местами, заполняется в Bookable
+}
+
+// Lesson - урок с названием предмета, именем преподавателя и числом записанных
+type Lesson struct {
+	ID              int
+	TeacherID       int
+	SubjectID       int
+	SubjectName     string
+	TeacherName     string
+	StartTime       time.Time
+	DurationMinutes int
+	MaxStudents     int
+	EnrolledCount   int
+	Status          string
+	SoftDeleted     bool
+	SeriesID        int // серия повторяющихся уроков, 0 - разовый урок
+	CourseID        int // курс, 0 - урок вне курса
+	RoomID          int // аудитория, 0 - не назначена
+	RoomName        string
+}
+
+// FreeSpots - количество свободных мест
+func (l Lesson) FreeSpots() int {
+	if free := l.MaxStudents - l.EnrolledCount; free > 0 {
+		return free
+	}
+	return 0
+}
+
+// IsBookable - на урок можно записаться: активен, не удален и еще не начался
+func (l Lesson) IsBookable(now time.Time) bool {
+	return l.Status == "active" && !l.SoftDeleted && l.StartTime.After(now)
+}
+
+// Enrollment - запись студента на урок
+type Enrollment struct {
+	ID         int
+	StudentID  int
+	LessonID   int
+	Status     string
+	EnrolledAt time.Time
+
+	StudentName string
+	TelegramID  int64
+}
+
+// StudentLessons - студент и его записи на выбранные уроки
+type StudentLessons struct {
+	Student
+	Lessons []Lesson
+}
+
+// WaitlistEntry - место в листе ожидания
+type WaitlistEntry struct {
+	ID         int
+	StudentID  int
+	LessonID   int
+	Position   int
+	TelegramID int64
+	CreatedAt  time.Time
+}
+
+// LogEntry - запись simple_logs
+type LogEntry struct {
+	ID            int
+	Level         string // INFO, WARN, ERROR
+	Action        string
+	UserID        *int
+	Details       string
+	CorrelationID string // ID обновления, в котором сделана запись
+	CreatedAt     time.Time
+}
+
+// AuditEntity - тип сущности в журнале аудита
+type AuditEntity string
+
+const (
+	AuditLesson     AuditEntity = "lesson"
+	AuditTeacher    AuditEntity = "teacher"
+	AuditStudent    AuditEntity = "student"
+	AuditEnrollment AuditEntity = "enrollment" // EntityID - ID урока, студент в Before/After
+	AuditWaitlist   AuditEntity = "waitlist"   // EntityID - ID урока, студент в Before/After
+	AuditUser       AuditEntity = "user"
+	AuditSeries     AuditEntity = "series"
+	AuditCourse     AuditEntity = "course"
+	AuditRoom       AuditEntity = "room"
+)
+
+// AuditEntities - все типы сущностей журнала аудита
+var AuditEntities = []AuditEntity{AuditLesson, AuditTeacher, AuditStudent, AuditEnrollment, AuditWaitlist, AuditUser, AuditSeries, AuditCourse, AuditRoom}
+
+// AuditEntry - запись audit_log: кто и как изменил сущность
+type AuditEntry struct {
+	ID            int
+	ActorTgID     *int64 // nil для системных действий
+	ActorName     string // имя автора из users, если он зарегистрирован
+	Action        string
+	Entity        AuditEntity
+	EntityID      int
+	Before        []byte // JSON значений до изменения, nil - сущность создана
+	After         []byte // JSON значений после изменения, nil - сущность удалена
+	CorrelationID string
+	CreatedAt     time.Time
+}
+
+// AuditFilter - условия поиска по журналу аудита; нулевые поля не ограничивают выборку
+type AuditFilter struct {
+	ActorTgID int64
+	Entity    AuditEntity
+	EntityID  int
+	Action    string
+	From      time.Time // включительно
+	To        time.Time // не включительно
+}
+
+// NotificationStatus - состояние сообщения в очереди уведомлений
+type NotificationStatus string
+
+const (
+	NotificationPending     NotificationStatus = "pending"     // ждет отправки (в том числе повторной)
+	NotificationSending     NotificationStatus = "sending"     // взято диспетчером
+	NotificationSent        NotificationStatus = "sent"        // доставлено
+	NotificationFailed      NotificationStatus = "failed"      // ошибка без повторов или попытки исчерпаны
+	NotificationUnreachable NotificationStatus = "unreachable" // получатель заблокировал бота или удален
+)
+
+// NotificationBatch - рассылка: сообщения получателям и общий отчет о доставке
+type NotificationBatch struct {
+	ID           int
+	Kind         string // тип рассылки: notify_all, lesson_cancelled, ...
+	Title        string // заголовок в отчете о доставке
+	CreatedBy    int64  // Telegram ID отправителя, 0 - система
+	ReportChatID int64  // чат для отчета о доставке, 0 - без отчета
+	CreatedAt    time.Time
+
+	// Итоги доставки (заполняются в CompletedBatches)
+	Total       int
+	Sent        int
+	Failed      int
+	Unreachable int
+}
+
+// OutboxMessage - сообщение рассылки одному получателю
+type OutboxMessage struct {
+	ID        int
+	BatchID   int
+	ChatID    int64
+	Text      string
+	ParseMode string
+	Attempts  int // неудачных попыток до текущей
+}
+
+// DueReminder - запись на урок, которой пора автоматическое напоминание
+type DueReminder struct {
+	EnrollmentID int
+	StudentID    int
+	TelegramID   int64
+	LessonID     int
+	SubjectName  string
+	TeacherName  string
+	StartTime    time.Time
+}
+
+// MaxSeriesLessons - наибольшее число уроков в одной серии (год еженедельных занятий)
+const MaxSeriesLessons = 52
+
+// LessonSeries - серия повторяющихся уроков: каждую неделю или через неделю,
+// до даты Until или Occurrences занятий, кроме дат Skip
+type LessonSeries struct {
+	ID              int
+	SubjectID       int
+	SubjectName     string
+	TeacherID       int
+	TeacherName     string
+	StartTime       time.Time // первое занятие: день недели и время серии
+	DurationMinutes int
+	MaxStudents     int
+	IntervalWeeks   int       // 1 - каждую неделю, 2 - через неделю
+	Until           time.Time // последний день серии, нулевое - не ограничен
+	Occurrences     int       // число занятий, 0 - не ограничено
+	Skip            []time.Time
+	Status          string
+	CreatedBy       int64
+}
+
+// Dates - время начала занятий серии по правилу, не больше MaxSeriesLessons.
+// Пропущенные даты не считаются в Occurrences.
+func (s LessonSeries) Dates() []time.Time {
+	if s.IntervalWeeks < 1 || (s.Until.IsZero() && s.Occurrences <= 0) {
+		return nil
+	}
+	skip := make(map[string]bool, len(s.Skip))
+	for _, date := range s.Skip {
+		skip[date.Format("2006-01-02")] = true
+	}
+	until := s.Until.Format("2006-01-02")
+
+	var dates []time.Time
+	for i := 0; len(dates) < MaxSeriesLessons && i < MaxSeriesLessons+len(s.Skip); i++ {
+		start := s.StartTime.AddDate(0, 0, 7*s.IntervalWeeks*i)
+		day := start.Format("2006-01-02")
+		if !s.Until.IsZero() && day > until {
+			break
+		}
+		if s.Occurrences > 0 && len(dates) == s.Occurrences {
+			break
+		}
+		if !skip[day] {
+			dates = append(dates, start)
+		}
+	}
+	return dates
+}
+
+// Course - курс: план из нескольких уроков по предмету у преподавателя и запись на него целиком
+type Course struct {
+	ID          int
+	Name        string
+	SubjectID   int
+	SubjectName string
+	TeacherID   int
+	TeacherName string
+	Capacity    int
+	Members     int // записанные участники
+	Status      string
+	CreatedBy   int64
+	Plan        []CoursePlanItem
+}
+
+// FreeSeats - количество свободных мест на курсе
+func (c Course) FreeSeats() int {
+	if free := c.Capacity - c.Members; free > 0 {
+		return free
+	}
+	return 0
+}
+
+// Unscheduled - темы плана, для которых еще не создан урок
+func (c Course) Unscheduled() int {
+	n := 0
+	for _, item := range c.Plan {
+		if item.LessonID == 0 {
+			n++
+		}
+	}
+	return n
+}
+
+// CoursePlanItem - тема урока в плане курса и созданный для нее урок
+type CoursePlanItem struct {
+	Position     int
+	Topic        string
+	LessonID     int       // 0 - урок еще не запланирован
+	StartTime    time.Time // время урока, если он запланирован
+	LessonStatus string
+}
+
+// Отметки посещаемости
+const (
+	AttendancePresent = "present"
+	AttendanceLate    = "late"
+	AttendanceAbsent  = "absent" // неявка без уважительной причины
+	AttendanceExcused = "excused"
+)
+
+// AttendanceStatuses - все отметки посещаемости
+var AttendanceStatuses = []string{AttendancePresent, AttendanceLate, AttendanceAbsent, AttendanceExcused}
+
+// AttendanceMark - студент в листе посещаемости урока
+type AttendanceMark struct {
+	StudentID   int
+	StudentName string
+	Status      string // "" - не отмечен
+}
+
+// AttendanceRecord - прошедший урок студента и отметка о посещении
+type AttendanceRecord struct {
+	LessonID    int
+	SubjectName string
+	StartTime   time.Time
+	Status      string // "" - не отмечен
+}
+
+// Room - аудитория: вместимость и оборудование (теги в нижнем регистре)
+type Room struct {
+	ID        int
+	Name      string
+	Capacity  int
+	Equipment []string
+	CreatedBy int64
+}
+
+// CapStudents - размер группы, ограниченный вместимостью аудитории
+func (r Room) CapStudents(maxStudents int) int {
+	return min(maxStudents, r.Capacity)
+}
+
+// SystemStats - сводные счетчики школы для /stats
+type SystemStats struct {
+	TotalUsers                int
+	ActiveUsers               int
+	Students                  int
+	Teachers                  int
+	Admins                    int
+	TotalLessons              int
+	ActiveLessons             int
+	CancelledLessons          int
+	TotalEnrollments          int
+	ActiveEnrollments         int
+	CancelledEnrollments      int
+	WaitlistEntries           int
+	ActiveRateLimitOperations int
+	AttendancePresent         int
+	AttendanceLate            int
+	AttendanceAbsent          int
+	AttendanceExcused         int
+}
+
+// NoShowRate - доля неявок без уважительной причины среди отмеченных записей, %
+func (s SystemStats) NoShowRate() float64 {
+	marked := s.AttendancePresent + s.AttendanceLate + s.AttendanceAbsent + s.AttendanceExcused
+	if marked == 0 {
+		return 0
+	}
+	return float64(s.AttendanceAbsent) * 100 / float64(marked)
+}
+
+// PendingOperation - незавершенная операция записи (rate limiting)
+type PendingOperation struct {
+	UserName   string
+	TelegramID int64
+	Operation  string
+	StartedAt  time.Time
+}
@@ -0,0 +1,63 @@
+package store
+
+import "time"
+
+// OperationRepository - незавершенные операции пользователей для rate limiting
+type OperationRepository interface {
+	// Pending - незавершенные операции пользователя, начатые не раньше ttl назад: того же типа
+	// и любого типа по уроку lessonID. ErrNotFound, если пользователя с таким Telegram ID нет.
+	Pending(telegramID int64, operation string, lessonID int, ttl time.Duration) (byOperation, byLesson int, err error)
+	// Start - регистрирует начало операции. ErrNotFound, если пользователя с таким Telegram ID нет.
+	Start(telegramID int64, operation string, lessonID int) error
+	// Finish - удаляет операцию пользователя
+	Finish(telegramID int64, operation string, lessonID int) error
+	// Expire - удаляет операции старше ttl, возвращает число удаленных
+	Expire(ttl time.Duration) (int64, error)
+}
+
+type pgOperations struct {
+	db queryer
+}
+
+func (r *pgOperations) Pending(telegramID int64, operation string, lessonID int, ttl time.Duration) (byOperation, byLesson int, err error) {
+	err = r.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM pending_operations
+			WHERE user_id = u.id AND operation = $2 AND created_at > NOW() - make_interval(secs => $4)),
+			(SELECT COUNT(*) FROM pending_operations
+			WHERE user_id = u.id AND lesson_id = $3 AND created_at > NOW() - make_interval(secs => $4))
+		FROM users u
+		WHERE u.tg_id = $1`, tgID(telegramID), operation, lessonID, ttl.Seconds()).Scan(&byOperation, &byLesson)
+	return byOperation, byLesson, notFound(err)
+}
+
+func (r *pgOperations) Start(telegramID int64, operation string, lessonID int) error {
+	result, err := r.db.Exec(`
+		INSERT INTO pending_operations (user_id, operation, lesson_id, created_at)
+		SELECT id, $2, $3, NOW() FROM users WHERE tg_id = $1`, tgID(telegramID), operation, lessonID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgOperations) Finish(telegramID int64, operation string, lessonID int) error {
+	_, err := r.db.Exec(`
+		DELETE FROM pending_operations
+		WHERE user_id = (SELECT id FROM users WHERE tg_id = $1) AND operation = $2 AND lesson_id = $3`,
+		tgID(telegramID), operation, lessonID)
+	return err
+}
+
+func (r *pgOperations) Expire(ttl time.Duration) (int64, error) {
+	result, err := r.db.Exec(`
+		DELETE FROM pending_operations
+		WHERE created_at < NOW() - make_interval(secs => $1)`, ttl.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
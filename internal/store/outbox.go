@@ -0,0 +1,198 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// OutboxRepository - очередь исходящих уведомлений (notification_batches, notifications)
+type OutboxRepository interface {
+	// Enqueue - рассылка сообщений (ChatID, Text, ParseMode), возвращает ID рассылки.
+	// Повторные сообщения в один чат убираются.
+	Enqueue(batch NotificationBatch, messages []OutboxMessage) (int, error)
+	// Claim - до limit сообщений, которым пора отправляться; они переходят в sending
+	Claim(limit int) ([]OutboxMessage, error)
+	// MarkSent - сообщение доставлено
+	MarkSent(id int) error
+	// Retry - неудачная попытка, следующая не раньше at
+	Retry(id int, at time.Time, lastError string) error
+	// Postpone - вернуть в очередь до at без учета попытки (лимиты Telegram)
+	Postpone(id int, at time.Time) error
+	// MarkFailed - окончательная ошибка доставки
+	MarkFailed(id int, lastError string) error
+	// MarkUnreachable - получатель заблокировал бота: сообщение и пользователь помечаются недоступными
+	MarkUnreachable(id int, lastError string) error
+	// ReleaseClaimed - вернуть в очередь сообщения, оставшиеся в sending после остановки процесса
+	ReleaseClaimed() (int, error)
+	// CompletedBatches - завершенные рассылки с отчетом, который еще не отправлен
+	CompletedBatches(limit int) ([]NotificationBatch, error)
+	// MarkReported - отчет о рассылке отправлен
+	MarkReported(batchID int) error
+}
+
+type pgOutbox struct {
+	db queryer
+}
+
+func (r *pgOutbox) Enqueue(batch NotificationBatch, messages []OutboxMessage) (int, error) {
+	messages = uniqueRecipients(messages)
+	chatIDs := make([]int64, len(messages))
+	texts := make([]string, len(messages))
+	parseModes := make([]string, len(messages))
+	for i, m := range messages {
+		chatIDs[i], texts[i], parseModes[i] = m.ChatID, m.Text, m.ParseMode
+	}
+
+	var id int
+	err := r.db.QueryRow(`
+		WITH batch AS (
+			INSERT INTO notification_batches (kind, title, created_by_tg_id, report_chat_id, total, created_at)
+			VALUES ($1, $2, NULLIF($3, 0), NULLIF($4, 0), $5, NOW())
+			RETURNING id
+		), queued AS (
+			INSERT INTO notifications (batch_id, chat_id, text, parse_mode, status, next_attempt_at, created_at, updated_at)
+			SELECT batch.id, m.chat_id, m.text, m.parse_mode, 'pending', NOW(), NOW(), NOW()
+			FROM batch, unnest($6::bigint[], $7::text[], $8::text[]) AS m(chat_id, text, parse_mode)
+		)
+		SELECT id FROM batch`,
+		batch.Kind, batch.Title, batch.CreatedBy, batch.ReportChatID, len(messages),
+		pq.Array(chatIDs), pq.Array(texts), pq.Array(parseModes)).Scan(&id)
+	return id, err
+}
+
+func (r *pgOutbox) Claim(limit int) ([]OutboxMessage, error) {
+	rows, err := r.db.Query(`
+		UPDATE notifications
+		SET status = 'sending', updated_at = NOW()
+		WHERE id IN (
+			SELECT id FROM notifications
+			WHERE status = 'pending' AND next_attempt_at <= NOW()
+			ORDER BY next_attempt_at, id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, batch_id, chat_id, text, parse_mode, attempts`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []OutboxMessage
+	for rows.Next() {
+		var m OutboxMessage
+		if err := rows.Scan(&m.ID, &m.BatchID, &m.ChatID, &m.Text, &m.ParseMode, &m.Attempts); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	sort.Slice(messages, func(i, j int) bool { return messages[i].ID < messages[j].ID })
+	return messages, rows.Err()
+}
+
+func (r *pgOutbox) MarkSent(id int) error {
+	_, err := r.db.Exec(`
+		UPDATE notifications
+		SET status = 'sent', attempts = attempts + 1, last_error = NULL, sent_at = NOW(), updated_at = NOW()
+		WHERE id = $1`, id)
+	return err
+}
+
+func (r *pgOutbox) Retry(id int, at time.Time, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE notifications
+		SET status = 'pending', attempts = attempts + 1, next_attempt_at = $2, last_error = $3, updated_at = NOW()
+		WHERE id = $1`, id, at, lastError)
+	return err
+}
+
+func (r *pgOutbox) Postpone(id int, at time.Time) error {
+	_, err := r.db.Exec(`
+		UPDATE notifications
+		SET status = 'pending', next_attempt_at = $2, updated_at = NOW()
+		WHERE id = $1`, id, at)
+	return err
+}
+
+func (r *pgOutbox) MarkFailed(id int, lastError string) error {
+	_, err := r.db.Exec(`
+		UPDATE notifications
+		SET status = 'failed', attempts = attempts + 1, last_error = $2, updated_at = NOW()
+		WHERE id = $1`, id, lastError)
+	return err
+}
+
+func (r *pgOutbox) MarkUnreachable(id int, lastError string) error {
+	_, err := r.db.Exec(`
+		WITH notification AS (
+			UPDATE notifications
+			SET status = 'unreachable', attempts = attempts + 1, last_error = $2, updated_at = NOW()
+			WHERE id = $1
+			RETURNING chat_id
+		)
+		UPDATE users SET unreachable_at = NOW()
+		WHERE tg_id = (SELECT chat_id::text FROM notification) AND unreachable_at IS NULL`, id, lastError)
+	return err
+}
+
+func (r *pgOutbox) ReleaseClaimed() (int, error) {
+	result, err := r.db.Exec(`
+		UPDATE notifications SET status = 'pending', updated_at = NOW()
+		WHERE status = 'sending'`)
+	if err != nil {
+		return 0, err
+	}
+	n, err := result.RowsAffected()
+	return int(n), err
+}
+
+func (r *pgOutbox) CompletedBatches(limit int) ([]NotificationBatch, error) {
+	rows, err := r.db.Query(`
+		SELECT b.id, b.kind, b.title, COALESCE(b.created_by_tg_id, 0), b.report_chat_id, b.created_at,
+			b.total,
+			COUNT(*) FILTER (WHERE n.status = 'sent'),
+			COUNT(*) FILTER (WHERE n.status = 'failed'),
+			COUNT(*) FILTER (WHERE n.status = 'unreachable')
+		FROM notification_batches b
+		JOIN notifications n ON n.batch_id = b.id
+		WHERE b.reported_at IS NULL AND b.report_chat_id IS NOT NULL
+		GROUP BY b.id
+		HAVING COUNT(*) FILTER (WHERE n.status IN ('pending', 'sending')) = 0
+		ORDER BY b.id
+		LIMIT $1`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var batches []NotificationBatch
+	for rows.Next() {
+		var b NotificationBatch
+		if err := rows.Scan(&b.ID, &b.Kind, &b.Title, &b.CreatedBy, &b.ReportChatID, &b.CreatedAt,
+			&b.Total, &b.Sent, &b.Failed, &b.Unreachable); err != nil {
+			return nil, err
+		}
+		batches = append(batches, b)
+	}
+	return batches, rows.Err()
+}
+
+func (r *pgOutbox) MarkReported(batchID int) error {
+	_, err := r.db.Exec("UPDATE notification_batches SET reported_at = NOW() WHERE id = $1", batchID)
+	return err
+}
+
+// uniqueRecipients - сообщения без повторных и нулевых получателей, в исходном порядке
+func uniqueRecipients(messages []OutboxMessage) []OutboxMessage {
+	seen := make(map[int64]bool, len(messages))
+	unique := make([]OutboxMessage, 0, len(messages))
+	for _, m := range messages {
+		if m.ChatID == 0 || seen[m.ChatID] {
+			continue
+		}
+		seen[m.ChatID] = true
+		unique = append(unique, m)
+	}
+	return unique
+}
@@ -0,0 +1,147 @@
+package store
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// ReminderRepository - автоматические напоминания об уроках (lesson_reminders, reminder_opt_outs).
+// Тип напоминания - метка интервала до начала урока, например "24h".
+type ReminderRepository interface {
+	// Due - записи, которым пора напоминание reminder: до начала урока не больше offset,
+	// но больше until (там начинается окно следующего, более близкого напоминания).
+	// Пропускаются уже напомненные, записавшиеся позже момента напоминания,
+	// отказавшиеся от этого типа и недоступные пользователи.
+	Due(reminder string, offset, until time.Duration, limit int) ([]DueReminder, error)
+	// Enqueue - отмечает напоминания отправленными и ставит messages[i] для due[i] в очередь
+	// уведомлений одной операцией. Уже отмеченные пропускаются; возвращает число поставленных.
+	Enqueue(reminder string, batch NotificationBatch, due []DueReminder, messages []OutboxMessage) (int, error)
+	// OptedOut - типы напоминаний, от которых студент отказался
+	OptedOut(studentID int) ([]string, error)
+	// SetOptOut - отказ от типа напоминаний (optOut = true) или возврат к нему
+	SetOptOut(studentID int, reminder string, optOut bool) error
+}
+
+type pgReminders struct {
+	db queryer
+}
+
+func (r *pgReminders) Due(reminder string, offset, until time.Duration, limit int) ([]DueReminder, error) {
+	rows, err := r.db.Query(`
+		SELECT e.id, s.id, u.tg_id, l.id, sub.name, COALESCE(tu.full_name, ''), l.start_time
+		FROM enrollments e
+		JOIN students s ON e.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		JOIN lessons l ON e.lesson_id = l.id
+		JOIN subjects sub ON l.subject_id = sub.id
+		LEFT JOIN teachers t ON l.teacher_id = t.id
+		LEFT JOIN users tu ON t.user_id = tu.id
+		WHERE e.status = 'enrolled'
+			AND l.status = 'active' AND l.soft_deleted = false
+			AND u.is_active = true AND u.unreachable_at IS NULL
+			AND l.start_time <= NOW() + $2::float8 * INTERVAL '1 second'
+			AND l.start_time > NOW() + $3::float8 * INTERVAL '1 second'
+			AND e.enrolled_at <= l.start_time - $2::float8 * INTERVAL '1 second'
+			AND NOT EXISTS (
+				SELECT 1 FROM lesson_reminders lr
+				WHERE lr.enrollment_id = e.id AND lr.reminder = $1 AND lr.start_time = l.start_time)
+			AND NOT EXISTS (
+				SELECT 1 FROM reminder_opt_outs o
+				WHERE o.student_id = s.id AND o.reminder = $1)
+		ORDER BY l.start_time, e.id
+		LIMIT $4`, reminder, offset.Seconds(), until.Seconds(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []DueReminder
+	for rows.Next() {
+		var d DueReminder
+		if err := rows.Scan(&d.EnrollmentID, &d.StudentID, &d.TelegramID, &d.LessonID,
+			&d.SubjectName, &d.TeacherName, &d.StartTime); err != nil {
+			return nil, err
+		}
+		due = append(due, d)
+	}
+	return due, rows.Err()
+}
+
+func (r *pgReminders) Enqueue(reminder string, batch NotificationBatch, due []DueReminder, messages []OutboxMessage) (int, error) {
+	enrollmentIDs := make([]int64, len(due))
+	chatIDs := make([]int64, len(due))
+	texts := make([]string, len(due))
+	parseModes := make([]string, len(due))
+	for i := range due {
+		enrollmentIDs[i] = int64(due[i].EnrollmentID)
+		chatIDs[i], texts[i], parseModes[i] = messages[i].ChatID, messages[i].Text, messages[i].ParseMode
+	}
+
+	// Отметка и постановка в очередь в одном запросе: после перезапуска напоминание
+	// не будет ни потеряно, ни отправлено дважды
+	var queued int
+	err := r.db.QueryRow(`
+		WITH due AS (
+			SELECT * FROM unnest($2::bigint[], $3::bigint[], $4::text[], $5::text[])
+				AS d(enrollment_id, chat_id, text, parse_mode)
+		), claimed AS (
+			INSERT INTO lesson_reminders (enrollment_id, reminder, start_time, sent_at)
+			SELECT e.id, $1, l.start_time, NOW()
+			FROM due
+			JOIN enrollments e ON e.id = due.enrollment_id
+			JOIN lessons l ON l.id = e.lesson_id
+			ON CONFLICT (enrollment_id, reminder, start_time) DO NOTHING
+			RETURNING enrollment_id
+		), batch AS (
+			INSERT INTO notification_batches (kind, title, created_by_tg_id, report_chat_id, total, created_at)
+			SELECT $6, $7, NULLIF($8, 0), NULLIF($9, 0), COUNT(*), NOW()
+			FROM claimed
+			HAVING COUNT(*) > 0
+			RETURNING id, total
+		), queued AS (
+			INSERT INTO notifications (batch_id, chat_id, text, parse_mode, status, next_attempt_at, created_at, updated_at)
+			SELECT batch.id, due.chat_id, due.text, due.parse_mode, 'pending', NOW(), NOW(), NOW()
+			FROM batch, claimed
+			JOIN due ON due.enrollment_id = claimed.enrollment_id
+		)
+		SELECT COALESCE((SELECT total FROM batch), 0)`,
+		reminder, pq.Array(enrollmentIDs), pq.Array(chatIDs), pq.Array(texts), pq.Array(parseModes),
+		batch.Kind, batch.Title, batch.CreatedBy, batch.ReportChatID).Scan(&queued)
+	return queued, err
+}
+
+func (r *pgReminders) OptedOut(studentID int) ([]string, error) {
+	rows, err := r.db.Query(`
+		SELECT reminder FROM reminder_opt_outs
+		WHERE student_id = $1
+		ORDER BY reminder`, studentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []string
+	for rows.Next() {
+		var reminder string
+		if err := rows.Scan(&reminder); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, reminder)
+	}
+	return reminders, rows.Err()
+}
+
+func (r *pgReminders) SetOptOut(studentID int, reminder string, optOut bool) error {
+	if !optOut {
+		_, err := r.db.Exec(`
+			DELETE FROM reminder_opt_outs
+			WHERE student_id = $1 AND reminder = $2`, studentID, reminder)
+		return err
+	}
+	_, err := r.db.Exec(`
+		INSERT INTO reminder_opt_outs (student_id, reminder, created_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (student_id, reminder) DO NOTHING`, studentID, reminder)
+	return err
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// RoomRepository - аудитории и их занятость (rooms, lessons.room_id)
+type RoomRepository interface {
+	// Create - новая аудитория; возвращает ID. ErrRoomExists, если название занято.
+	Create(room Room) (int, error)
+	// Find - активная аудитория по ID или названию (без учета регистра). ErrNotFound, если нет.
+	Find(ref string) (*Room, error)
+	// List - активные аудитории по названию
+	List() ([]Room, error)
+	// Available - активные аудитории с оборудованием для предмета, свободные
+	// в интервале [start, start + duration)
+	Available(subjectID int, start time.Time, duration time.Duration) ([]Room, error)
+	// MissingEquipment - оборудование, которое требует предмет и которого нет в аудитории
+	MissingEquipment(roomID, subjectID int) ([]string, error)
+	// Schedule - активные уроки в аудитории за день day по времени начала
+	Schedule(roomID int, day time.Time) ([]Lesson, error)
+}
+
+type pgRooms struct {
+	db queryer
+}
+
+func (r *pgRooms) Create(room Room) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		INSERT INTO rooms (name, capacity, equipment, is_active, created_by_tg_id, created_at)
+		VALUES ($1, $2, $3, true, NULLIF($4, 0), NOW())
+		RETURNING id`, room.Name, room.Capacity, pq.Array(room.Equipment), room.CreatedBy).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "rooms_name_key" {
+		return 0, ErrRoomExists
+	}
+	return id, err
+}
+
+// roomColumns - общий SELECT для Room
+const roomColumns = `
+	SELECT r.id, r.name, r.capacity, r.equipment, COALESCE(r.created_by_tg_id, 0)
+	FROM rooms r`
+
+func scanRoom(row interface{ Scan(dest ...any) error }) (Room, error) {
+	var room Room
+	err := row.Scan(&room.ID, &room.Name, &room.Capacity, pq.Array(&room.Equipment), &room.CreatedBy)
+	return room, err
+}
+
+func scanRooms(rows *sql.Rows) ([]Room, error) {
+	var rooms []Room
+	for rows.Next() {
+		room, err := scanRoom(rows)
+		if err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	return rooms, rows.Err()
+}
+
+func (r *pgRooms) Find(ref string) (*Room, error) {
+	// Числовая ссылка - ID, иначе название
+	id, _ := strconv.Atoi(ref)
+	room, err := scanRoom(r.db.QueryRow(roomColumns+`
+	WHERE r.is_active = true AND (r.id = $1 OR LOWER(r.name) = LOWER($2))
+	ORDER BY r.id = $1 DESC
+	LIMIT 1`, id, ref))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &room, nil
+}
+
+func (r *pgRooms) List() ([]Room, error) {
+	rows, err := r.db.Query(roomColumns + `
+	WHERE r.is_active = true
+	ORDER BY r.name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRooms(rows)
+}
+
+func (r *pgRooms) Available(subjectID int, start time.Time, duration time.Duration) ([]Room, error) {
+	rows, err := r.db.Query(roomColumns+`
+	JOIN subjects s ON s.id = $1
+	WHERE r.is_active = true AND r.equipment @> s.required_equipment
+		AND NOT EXISTS (
+			SELECT 1 FROM lessons l
+			WHERE l.room_id = r.id AND l.status = 'active' AND l.soft_deleted = false
+				AND l.start_time < $2::timestamp + $3::float8 * INTERVAL '1 second'
+				AND l.start_time + COALESCE(l.duration_minutes, 90) * INTERVAL '1 minute' > $2::timestamp)
+	ORDER BY r.name`, subjectID, start, duration.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanRooms(rows)
+}
+
+func (r *pgRooms) MissingEquipment(roomID, subjectID int) ([]string, error) {
+	var missing []string
+	err := r.db.QueryRow(`
+		SELECT ARRAY(
+			SELECT unnest(s.required_equipment)
+			EXCEPT
+			SELECT unnest(r.equipment)
+			ORDER BY 1)
+		FROM subjects s, rooms r
+		WHERE s.id = $1 AND r.id = $2`, subjectID, roomID).Scan(pq.Array(&missing))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return missing, nil
+}
+
+func (r *pgRooms) Schedule(roomID int, day time.Time) ([]Lesson, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE l.room_id = $1 AND l.start_time >= $2 AND l.start_time < $3
+		AND l.soft_deleted = false AND l.status = 'active'`+lessonGroupBy+`
+	ORDER BY l.start_time`, roomID, from, from.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
@@ -0,0 +1,200 @@
+package store
+
+import (
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// SeriesRepository - серии повторяющихся уроков (lesson_series, lesson_series_exceptions, series_enrollments)
+type SeriesRepository interface {
+	// Create - серия и ее уроки на время starts одной операцией; возвращает ID серии.
+	// ErrScheduleConflict, если урок пересечется с другим уроком преподавателя.
+	Create(series LessonSeries, starts []time.Time) (int, error)
+	// Get - серия с предметом, преподавателем и пропущенными датами
+	Get(seriesID int) (*LessonSeries, error)
+	// Lessons - неудаленные уроки серии по времени начала
+	Lessons(seriesID int) ([]Lesson, error)
+	// Shift - перенос уроков серии на delta. wholeSeries переносит и правило серии.
+	// ErrScheduleConflict, если урок пересечется с другим уроком преподавателя, ErrStudentScheduleConflict -
+	// с другим уроком записанного студента, ErrRoomConflict - с другим уроком в той же аудитории;
+	// тогда ничего не меняется.
+	Shift(seriesID int, lessonIDs []int, delta time.Duration, wholeSeries bool) error
+	// CancelOccurrence - отмена одного занятия: урок отменяется, дата становится исключением серии
+	CancelOccurrence(seriesID, lessonID int, date time.Time) error
+	// CancelFrom - отмена активных уроков серии, начинающихся не раньше from.
+	// wholeSeries отменяет серию, иначе серия заканчивается накануне from.
+	CancelFrom(seriesID int, from time.Time, wholeSeries bool) error
+	// Enroll - запись студента на серию. ErrAlreadyEnrolled, если уже записан.
+	Enroll(seriesID, studentID int) error
+}
+
+type pgSeries struct {
+	db queryer
+}
+
+// timestamps - время для массивов PostgreSQL (pq.Array не принимает time.Time)
+func timestamps(times []time.Time) []string {
+	formatted := make([]string, len(times))
+	for i, t := range times {
+		formatted[i] = t.Format("2006-01-02 15:04:05")
+	}
+	return formatted
+}
+
+// nullDate - DATE или NULL для нулевого времени
+func nullDate(t time.Time) any {
+	if t.IsZero() {
+		return nil
+	}
+	return t.Format("2006-01-02")
+}
+
+func (r *pgSeries) Create(series LessonSeries, starts []time.Time) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		WITH series AS (
+			INSERT INTO lesson_series (subject_id, teacher_id, start_time, duration_minutes, max_students,
+				interval_weeks, until_date, occurrences, status, created_by_tg_id, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7::date, NULLIF($8, 0), 'active', NULLIF($9, 0), NOW())
+			RETURNING id
+		), exceptions AS (
+			INSERT INTO lesson_series_exceptions (series_id, skip_date, created_at)
+			SELECT series.id, d::date, NOW()
+			FROM series, unnest($10::text[]) AS d
+			ON CONFLICT DO NOTHING
+		), lessons AS (
+			INSERT INTO lessons (subject_id, teacher_id, start_time, duration_minutes, max_students, status, series_id, created_at)
+			SELECT $1, $2, t::timestamp, $4, $5, 'active', series.id, NOW()
+			FROM series, unnest($11::text[]) AS t
+		)
+		SELECT id FROM series`,
+		series.SubjectID, series.TeacherID, series.StartTime, series.DurationMinutes, series.MaxStudents,
+		series.IntervalWeeks, nullDate(series.Until), series.Occurrences, series.CreatedBy,
+		pq.Array(timestamps(series.Skip)), pq.Array(timestamps(starts))).Scan(&id)
+	return id, ScheduleConflict(err)
+}
+
+func (r *pgSeries) Get(seriesID int) (*LessonSeries, error) {
+	var series LessonSeries
+	var until *time.Time
+	var skip []string
+	err := r.db.QueryRow(`
+		SELECT ls.id, ls.subject_id, s.name, COALESCE(ls.teacher_id, 0), COALESCE(u.full_name, ''),
+			ls.start_time, ls.duration_minutes, ls.max_students, ls.interval_weeks,
+			ls.until_date, COALESCE(ls.occurrences, 0), ls.status, COALESCE(ls.created_by_tg_id, 0),
+			ARRAY(SELECT to_char(e.skip_date, 'YYYY-MM-DD') FROM lesson_series_exceptions e
+				WHERE e.series_id = ls.id ORDER BY e.skip_date)
+		FROM lesson_series ls
+		JOIN subjects s ON ls.subject_id = s.id
+		LEFT JOIN teachers t ON ls.teacher_id = t.id
+		LEFT JOIN users u ON t.user_id = u.id
+		WHERE ls.id = $1`, seriesID).Scan(
+		&series.ID, &series.SubjectID, &series.SubjectName, &series.TeacherID, &series.TeacherName,
+		&series.StartTime, &series.DurationMinutes, &series.MaxStudents, &series.IntervalWeeks,
+		&until, &series.Occurrences, &series.Status, &series.CreatedBy, pq.Array(&skip))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	if until != nil {
+		series.Until = *until
+	}
+	for _, date := range skip {
+		if day, err := time.Parse("2006-01-02", date); err == nil {
+			series.Skip = append(series.Skip, day)
+		}
+	}
+	return &series, nil
+}
+
+func (r *pgSeries) Lessons(seriesID int) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	WHERE l.series_id = $1 AND l.soft_deleted = false`+lessonGroupBy+`
+	ORDER BY l.start_time`, seriesID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
+
+func (r *pgSeries) Shift(seriesID int, lessonIDs []int, delta time.Duration, wholeSeries bool) error {
+	// Проверка пересечений и перенос в одном запросе: уроки серии двигаются вместе
+	// и друг с другом не пересекаются, проверяются только остальные уроки преподавателя
+	var conflict bool
+	err := r.db.QueryRow(`
+		WITH moved AS (
+			SELECT id, teacher_id, start_time + $3::float8 * INTERVAL '1 second' AS new_start,
+				COALESCE(duration_minutes, 90) AS duration
+			FROM lessons
+			WHERE id = ANY($2) AND series_id = $1
+		), conflict AS (
+			SELECT 1 FROM moved m
+			JOIN lessons o ON o.teacher_id = m.teacher_id
+			WHERE o.id <> ALL($2) AND o.soft_deleted = false AND o.status = 'active'
+				AND o.start_time < m.new_start + make_interval(mins => m.duration)
+				AND o.start_time + make_interval(mins => COALESCE(o.duration_minutes, 90)) > m.new_start
+			LIMIT 1
+		), updated AS (
+			UPDATE lessons l
+			SET start_time = m.new_start, updated_at = NOW()
+			FROM moved m
+			WHERE l.id = m.id AND NOT EXISTS (SELECT 1 FROM conflict)
+		), series AS (
+			UPDATE lesson_series
+			SET start_time = start_time + $3::float8 * INTERVAL '1 second', updated_at = NOW()
+			WHERE id = $1 AND $4 AND NOT EXISTS (SELECT 1 FROM conflict)
+		)
+		SELECT EXISTS (SELECT 1 FROM conflict)`,
+		seriesID, pq.Array(lessonIDs), delta.Seconds(), wholeSeries).Scan(&conflict)
+	if err != nil {
+		return ScheduleConflict(err)
+	}
+	if conflict {
+		return ErrScheduleConflict
+	}
+	return nil
+}
+
+func (r *pgSeries) CancelOccurrence(seriesID, lessonID int, date time.Time) error {
+	_, err := r.db.Exec(`
+		WITH cancelled AS (
+			UPDATE lessons
+			SET status = 'cancelled', updated_at = NOW()
+			WHERE id = $2 AND series_id = $1
+		)
+		INSERT INTO lesson_series_exceptions (series_id, skip_date, created_at)
+		VALUES ($1, $3::date, NOW())
+		ON CONFLICT DO NOTHING`, seriesID, lessonID, date.Format("2006-01-02"))
+	return err
+}
+
+func (r *pgSeries) CancelFrom(seriesID int, from time.Time, wholeSeries bool) error {
+	_, err := r.db.Exec(`
+		WITH cancelled AS (
+			UPDATE lessons
+			SET status = 'cancelled', updated_at = NOW()
+			WHERE series_id = $1 AND start_time >= $2
+				AND status = 'active' AND soft_deleted = false
+		)
+		UPDATE lesson_series
+		SET status = CASE WHEN $3 THEN 'cancelled' ELSE status END,
+			until_date = CASE WHEN $3 THEN until_date ELSE ($2::timestamp - INTERVAL '1 day')::date END,
+			updated_at = NOW()
+		WHERE id = $1`, seriesID, from, wholeSeries)
+	return err
+}
+
+func (r *pgSeries) Enroll(seriesID, studentID int) error {
+	result, err := r.db.Exec(`
+		INSERT INTO series_enrollments (series_id, student_id, enrolled_at)
+		VALUES ($1, $2, NOW())
+		ON CONFLICT (series_id, student_id) DO NOTHING`, seriesID, studentID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return ErrAlreadyEnrolled
+	}
+	return nil
+}
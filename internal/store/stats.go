@@ -0,0 +1,65 @@
+package store
+
+// StatsRepository - сводная статистика для администраторов
+type StatsRepository interface {
+	// System - счетчики пользователей, уроков, записей, листов ожидания и посещаемости
+	System() (SystemStats, error)
+	// PendingOperations - незавершенные операции записи, последние первыми
+	PendingOperations() ([]PendingOperation, error)
+}
+
+type pgStats struct {
+	db queryer
+}
+
+func (r *pgStats) System() (SystemStats, error) {
+	var stats SystemStats
+	err := r.db.QueryRow(`
+		SELECT
+			(SELECT COUNT(*) FROM users),
+			(SELECT COUNT(*) FROM users WHERE is_active = true),
+			(SELECT COUNT(*) FROM students WHERE soft_deleted = false),
+			(SELECT COUNT(*) FROM teachers WHERE soft_deleted = false),
+			(SELECT COUNT(*) FROM users WHERE role IN ('admin', 'superuser')),
+			(SELECT COUNT(*) FROM lessons),
+			(SELECT COUNT(*) FROM lessons WHERE soft_deleted = false),
+			(SELECT COUNT(*) FROM lessons WHERE soft_deleted = true),
+			(SELECT COUNT(*) FROM enrollments),
+			(SELECT COUNT(*) FROM enrollments WHERE status = 'enrolled'),
+			(SELECT COUNT(*) FROM enrollments WHERE status = 'cancelled'),
+			(SELECT COUNT(*) FROM waitlist),
+			(SELECT COUNT(*) FROM pending_operations WHERE finished_at IS NULL),
+			(SELECT COUNT(*) FROM attendance WHERE status = 'present'),
+			(SELECT COUNT(*) FROM attendance WHERE status = 'late'),
+			(SELECT COUNT(*) FROM attendance WHERE status = 'absent'),
+			(SELECT COUNT(*) FROM attendance WHERE status = 'excused')`).Scan(
+		&stats.TotalUsers, &stats.ActiveUsers, &stats.Students, &stats.Teachers, &stats.Admins,
+		&stats.TotalLessons, &stats.ActiveLessons, &stats.CancelledLessons,
+		&stats.TotalEnrollments, &stats.ActiveEnrollments, &stats.CancelledEnrollments,
+		&stats.WaitlistEntries, &stats.ActiveRateLimitOperations,
+		&stats.AttendancePresent, &stats.AttendanceLate, &stats.AttendanceAbsent, &stats.AttendanceExcused)
+	return stats, err
+}
+
+func (r *pgStats) PendingOperations() ([]PendingOperation, error) {
+	rows, err := r.db.Query(`
+		SELECT u.full_name, u.tg_id, po.operation_type, po.started_at
+		FROM pending_operations po
+		JOIN users u ON po.user_id = u.id
+		WHERE po.finished_at IS NULL
+		ORDER BY po.started_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var operations []PendingOperation
+	for rows.Next() {
+		var operation PendingOperation
+		if err := rows.Scan(&operation.UserName, &operation.TelegramID, &operation.Operation, &operation.StartedAt); err != nil {
+			return nil, err
+		}
+		operations = append(operations, operation)
+	}
+	return operations, rows.Err()
+}
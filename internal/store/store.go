@@ -0,0 +1,151 @@
+// Package store - слой доступа к данным: типизированные репозитории поверх PostgreSQL.
+// Обработчики Telegram работают с доменными структурами и ошибками этого пакета, а не с SQL.
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+
+	"constellation-school-bot/internal/metrics"
+)
+
+// Ошибки предметной области
+var (
+	ErrNotFound                = errors.New("запись не найдена")
+	ErrAlreadyEnrolled         = errors.New("студент уже записан на урок")
+	ErrNotEnrolled             = errors.New("студент не записан на урок")
+	ErrAlreadyWaitlisted       = errors.New("студент уже в листе ожидания")
+	ErrLessonFull              = errors.New("на уроке нет свободных мест")
+	ErrLessonUnavailable       = errors.New("урок отменен, удален или уже прошел")
+	ErrCourseLesson            = errors.New("урок входит в курс, записаться можно только на курс")
+	ErrCourseFull              = errors.New("на курсе нет свободных мест")
+	ErrScheduleConflict        = errors.New("у преподавателя уже есть урок в это время")
+	ErrStudentScheduleConflict = errors.New("студент записан на другой урок в это время")
+	ErrRoomConflict            = errors.New("аудитория занята в это время")
+	ErrRoomExists              = errors.New("аудитория с таким названием уже есть")
+	ErrUserExists              = errors.New("пользователь с таким Telegram ID уже существует")
+)
+
+// ScheduleConflict - нарушение ограничений пересечения расписания как ErrScheduleConflict,
+// ErrStudentScheduleConflict или ErrRoomConflict; остальные ошибки возвращаются без изменений
+func ScheduleConflict(err error) error {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) || pqErr.Code != "23P01" {
+		return err
+	}
+	switch pqErr.Constraint {
+	case "lessons_teacher_no_overlap":
+		return ErrScheduleConflict
+	case "enrollments_student_no_overlap":
+		return ErrStudentScheduleConflict
+	case "lessons_room_no_overlap":
+		return ErrRoomConflict
+	}
+	return err
+}
+
+// Store - набор репозиториев
+type Store struct {
+	Users       UserRepository
+	Teachers    TeacherRepository
+	Students    StudentRepository
+	Lessons     LessonRepository
+	Enrollments EnrollmentRepository
+	Waitlist    WaitlistRepository
+	Logs        LogRepository
+	Audit       AuditRepository
+	Outbox      OutboxRepository
+	Reminders   ReminderRepository
+	Series      SeriesRepository
+	Courses     CourseRepository
+	Attendance  AttendanceRepository
+	Rooms       RoomRepository
+	Subjects    SubjectRepository
+	Stats       StatsRepository
+	Operations  OperationRepository
+	FSMStates   FSMStateRepository
+}
+
+// New - репозитории поверх PostgreSQL
+func New(db *sql.DB) *Store {
+	return &Store{
+		Users:       &pgUsers{db: timed(db, "users")},
+		Teachers:    &pgTeachers{db: timed(db, "teachers")},
+		Students:    &pgStudents{db: timed(db, "students")},
+		Lessons:     &pgLessons{db: timed(db, "lessons")},
+		Enrollments: &pgEnrollments{db: timed(db, "enrollments")},
+		Waitlist:    &pgWaitlist{db: timed(db, "waitlist")},
+		Logs:        &pgLogs{db: timed(db, "logs")},
+		Audit:       &pgAudit{db: timed(db, "audit")},
+		Outbox:      &pgOutbox{db: timed(db, "outbox")},
+		Reminders:   &pgReminders{db: timed(db, "reminders")},
+		Series:      &pgSeries{db: timed(db, "series")},
+		Courses:     &pgCourses{db: timed(db, "courses")},
+		Attendance:  &pgAttendance{db: timed(db, "attendance")},
+		Rooms:       &pgRooms{db: timed(db, "rooms")},
+		Subjects:    &pgSubjects{db: timed(db, "subjects")},
+		Stats:       &pgStats{db: timed(db, "stats")},
+		Operations:  &pgOperations{db: timed(db, "operations")},
+		FSMStates:   &pgFSMStates{db: timed(db, "fsm_states")},
+	}
+}
+
+// queryer - то, что репозиториям нужно от *sql.DB
+type queryer interface {
+	QueryRow(query string, args ...any) *sql.Row
+	Query(query string, args ...any) (*sql.Rows, error)
+	Exec(query string, args ...any) (sql.Result, error)
+	// Begin - транзакция для операций, которым нужна блокировка между запросами
+	Begin() (*sql.Tx, error)
+}
+
+// timedDB - запросы репозитория с замером времени (metrics.DBQueryDuration)
+type timedDB struct {
+	db         *sql.DB
+	repository string
+}
+
+func timed(db *sql.DB, repository string) queryer {
+	return &timedDB{db: db, repository: repository}
+}
+
+func (t *timedDB) observe(operation string, start time.Time) {
+	metrics.DBQueryDuration.WithLabelValues(t.repository, operation).Observe(metrics.Since(start))
+}
+
+func (t *timedDB) QueryRow(query string, args ...any) *sql.Row {
+	defer t.observe("query_row", time.Now())
+	return t.db.QueryRow(query, args...)
+}
+
+func (t *timedDB) Query(query string, args ...any) (*sql.Rows, error) {
+	defer t.observe("query", time.Now())
+	return t.db.Query(query, args...)
+}
+
+func (t *timedDB) Exec(query string, args ...any) (sql.Result, error) {
+	defer t.observe("exec", time.Now())
+	return t.db.Exec(query, args...)
+}
+
+func (t *timedDB) Begin() (*sql.Tx, error) {
+	defer t.observe("begin", time.Now())
+	return t.db.Begin()
+}
+
+// notFound - приводит sql.ErrNoRows к ErrNotFound
+func notFound(err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrNotFound
+	}
+	return err
+}
+
+// tgID - tg_id хранится в users как VARCHAR
+func tgID(telegramID int64) string {
+	return strconv.FormatInt(telegramID, 10)
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+)
+
+// StudentRepository - студенты
+type StudentRepository interface {
+	// GetByTelegramID - студент по Telegram ID пользователя
+	GetByTelegramID(telegramID int64) (*Student, error)
+	// Register - новый активный пользователь с ролью student и запись студента, возвращает ID пользователя.
+	// ErrUserExists, если Telegram ID уже зарегистрирован.
+	Register(telegramID int64, fullName, phone string) (int, error)
+	// List - неудаленные студенты по имени с числом записей: всего, активных и отмененных
+	List() ([]Student, error)
+	// Deactivate - деактивирует активного пользователя-студента по ID пользователя, отменяет его записи
+	// и убирает из листов ожидания; возвращает число отмененных записей и мест в очередях.
+	// ErrNotFound, если активного студента с таким ID нет.
+	Deactivate(userID int) (cancelled, waitlisted int, err error)
+}
+
+type pgStudents struct {
+	db queryer
+}
+
+func (r *pgStudents) GetByTelegramID(telegramID int64) (*Student, error) {
+	var student Student
+	err := r.db.QueryRow(`
+		SELECT s.id, s.user_id, u.tg_id, u.full_name
+		FROM students s
+		JOIN users u ON s.user_id = u.id
+		WHERE u.tg_id = $1`, tgID(telegramID)).Scan(
+		&student.ID, &student.UserID, &student.TelegramID, &student.FullName)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &student, nil
+}
+
+func (r *pgStudents) Register(telegramID int64, fullName, phone string) (int, error) {
+	var userID int
+	err := r.db.QueryRow(`
+		WITH student_user AS (
+			INSERT INTO users (tg_id, role, full_name, phone, is_active, created_at)
+			VALUES ($1, 'student', $2, $3, true, NOW())
+			RETURNING id
+		)
+		INSERT INTO students (user_id, created_at)
+		SELECT id, NOW() FROM student_user
+		RETURNING user_id`, tgID(telegramID), fullName, phone).Scan(&userID)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_tg_id_key" {
+		return 0, ErrUserExists
+	}
+	return userID, err
+}
+
+func (r *pgStudents) List() ([]Student, error) {
+	rows, err := r.db.Query(`
+		SELECT s.id, s.user_id, u.tg_id, u.full_name, COALESCE(u.is_active, true),
+			COUNT(e.id),
+			COUNT(e.id) FILTER (WHERE e.status = 'enrolled'),
+			COUNT(e.id) FILTER (WHERE e.status = 'cancelled')
+		FROM students s
+		JOIN users u ON s.user_id = u.id
+		LEFT JOIN enrollments e ON s.id = e.student_id
+		WHERE s.soft_deleted = false
+		GROUP BY s.id, u.id
+		ORDER BY u.full_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var students []Student
+	for rows.Next() {
+		var student Student
+		if err := rows.Scan(&student.ID, &student.UserID, &student.TelegramID, &student.FullName, &student.IsActive,
+			&student.TotalEnrollments, &student.ActiveEnrollments, &student.CancelledEnrollments); err != nil {
+			return nil, err
+		}
+		students = append(students, student)
+	}
+	return students, rows.Err()
+}
+
+func (r *pgStudents) Deactivate(userID int) (cancelled, waitlisted int, err error) {
+	var deactivated bool
+	err = r.db.QueryRow(`
+		WITH deactivated AS (
+			UPDATE users
+			SET is_active = false, updated_at = NOW()
+			WHERE id = $1 AND role = 'student' AND is_active = true
+			RETURNING id
+		), student AS (
+			SELECT s.id FROM students s JOIN deactivated d ON s.user_id = d.id
+		), cancelled AS (
+			UPDATE enrollments
+			SET status = 'cancelled', updated_at = NOW()
+			WHERE student_id IN (SELECT id FROM student) AND status = 'enrolled'
+			RETURNING id
+		), removed AS (
+			DELETE FROM waitlist
+			WHERE student_id IN (SELECT id FROM student)
+			RETURNING id
+		)
+		SELECT EXISTS (SELECT 1 FROM deactivated),
+			(SELECT COUNT(*) FROM cancelled), (SELECT COUNT(*) FROM removed)`, userID).Scan(
+		&deactivated, &cancelled, &waitlisted)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !deactivated {
+		return 0, 0, ErrNotFound
+	}
+	return cancelled, waitlisted, nil
+}
@@ -0,0 +1,100 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SubjectRepository - предметы школы
+type SubjectRepository interface {
+	// Get - предмет по ID. ErrNotFound, если нет.
+	Get(subjectID int) (*Subject, error)
+	// GetByName - предмет по точному названию. ErrNotFound, если нет.
+	GetByName(name string) (*Subject, error)
+	// List - предметы по названию; activeOnly скрывает неактивные
+	List(activeOnly bool) ([]Subject, error)
+	// Bookable - предметы, у которых есть активные уроки после from со свободными местами
+	Bookable(from time.Time) ([]Subject, error)
+}
+
+type pgSubjects struct {
+	db queryer
+}
+
+// subjectColumns - общий SELECT для Subject
+const subjectColumns = `
+	SELECT s.id, s.name, COALESCE(s.category, ''), COALESCE(s.description, ''), s.is_active`
+
+func scanSubject(row interface{ Scan(dest ...any) error }, extra ...any) (Subject, error) {
+	var subject Subject
+	dest := append([]any{&subject.ID, &subject.Name, &subject.Category, &subject.Description, &subject.IsActive}, extra...)
+	err := row.Scan(dest...)
+	return subject, err
+}
+
+func (r *pgSubjects) Get(subjectID int) (*Subject, error) {
+	subject, err := scanSubject(r.db.QueryRow(subjectColumns+`
+	FROM subjects s
+	WHERE s.id = $1`, subjectID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &subject, nil
+}
+
+func (r *pgSubjects) GetByName(name string) (*Subject, error) {
+	subject, err := scanSubject(r.db.QueryRow(subjectColumns+`
+	FROM subjects s
+	WHERE s.name = $1`, name))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &subject, nil
+}
+
+func (r *pgSubjects) List(activeOnly bool) ([]Subject, error) {
+	rows, err := r.db.Query(subjectColumns+`
+	FROM subjects s
+	WHERE s.is_active = true OR NOT $1
+	ORDER BY s.name`, activeOnly)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSubjects(rows, false)
+}
+
+func (r *pgSubjects) Bookable(from time.Time) ([]Subject, error) {
+	rows, err := r.db.Query(subjectColumns+`, COUNT(l.id)
+	FROM subjects s
+	JOIN lessons l ON l.subject_id = s.id
+	WHERE l.start_time > $1 AND l.soft_deleted = false AND l.status = 'active'
+		AND (SELECT COUNT(*) FROM enrollments e
+			WHERE e.lesson_id = l.id AND e.status = 'enrolled') < l.max_students
+	GROUP BY s.id
+	ORDER BY s.name`, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanSubjects(rows, true)
+}
+
+// scanSubjects - предметы из выборки; withLessons - последний столбец BookableLessons
+func scanSubjects(rows *sql.Rows, withLessons bool) ([]Subject, error) {
+	var subjects []Subject
+	for rows.Next() {
+		var lessons int
+		var extra []any
+		if withLessons {
+			extra = append(extra, &lessons)
+		}
+		subject, err := scanSubject(rows, extra...)
+		if err != nil {
+			return nil, err
+		}
+		subject.BookableLessons = lessons
+		subjects = append(subjects, subject)
+	}
+	return subjects, rows.Err()
+}
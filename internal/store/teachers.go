@@ -0,0 +1,202 @@
+package store
+
+import (
+	"errors"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TeacherRepository - преподаватели
+type TeacherRepository interface {
+	// GetByTelegramID - преподаватель по Telegram ID пользователя
+	GetByTelegramID(telegramID int64) (*Teacher, error)
+	// Get - преподаватель по ID, в том числе удаленный. ErrNotFound, если нет.
+	Get(teacherID int) (*Teacher, error)
+	// List - неудаленные преподаватели по имени с числом неудаленных и предстоящих уроков
+	List() ([]Teacher, error)
+	// Deleted - удаленные преподаватели, сначала удаленные последними
+	Deleted() ([]Teacher, error)
+	// Create - новый активный пользователь с ролью teacher и запись преподавателя, возвращает ID преподавателя.
+	// ErrUserExists, если Telegram ID уже зарегистрирован.
+	Create(telegramID int64, fullName string) (int, error)
+	// Delete - мягкое удаление преподавателя и его уроков с отменой записей, очисткой листов ожидания
+	// и деактивацией пользователя. ErrNotFound, если преподавателя нет или он уже удален.
+	Delete(teacherID int) error
+	// Restore - восстановление преподавателя, его пользователя и уроков. ErrNotFound, если преподавателя нет,
+	// ErrRoomConflict, если аудитория восстанавливаемого урока уже занята.
+	Restore(teacherID int) error
+	// OwnsLesson - ведет ли преподаватель урок
+	OwnsLesson(teacherID, lessonID int) (bool, error)
+}
+
+type pgTeachers struct {
+	db queryer
+}
+
+// teacherColumns - общий SELECT для Teacher
+const teacherColumns = `
+	SELECT t.id, t.user_id, u.tg_id, u.full_name, t.soft_deleted, COALESCE(u.is_active, true)
+	FROM teachers t
+	JOIN users u ON t.user_id = u.id`
+
+func scanTeacher(row interface{ Scan(dest ...any) error }, extra ...any) (Teacher, error) {
+	var teacher Teacher
+	dest := append([]any{&teacher.ID, &teacher.UserID, &teacher.TelegramID, &teacher.FullName,
+		&teacher.SoftDeleted, &teacher.IsActive}, extra...)
+	err := row.Scan(dest...)
+	return teacher, err
+}
+
+func (r *pgTeachers) GetByTelegramID(telegramID int64) (*Teacher, error) {
+	teacher, err := scanTeacher(r.db.QueryRow(teacherColumns+`
+	WHERE u.tg_id = $1`, tgID(telegramID)))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &teacher, nil
+}
+
+func (r *pgTeachers) Get(teacherID int) (*Teacher, error) {
+	teacher, err := scanTeacher(r.db.QueryRow(teacherColumns+`
+	WHERE t.id = $1`, teacherID))
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &teacher, nil
+}
+
+func (r *pgTeachers) List() ([]Teacher, error) {
+	rows, err := r.db.Query(teacherColumns + `, COUNT(l.id), COUNT(l.id) FILTER (WHERE l.start_time > NOW())
+	LEFT JOIN lessons l ON t.id = l.teacher_id AND l.soft_deleted = false
+	WHERE t.soft_deleted = false
+	GROUP BY t.id, u.id
+	ORDER BY u.full_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teachers []Teacher
+	for rows.Next() {
+		var lessons, upcoming int
+		teacher, err := scanTeacher(rows, &lessons, &upcoming)
+		if err != nil {
+			return nil, err
+		}
+		teacher.ActiveLessons = lessons
+		teacher.UpcomingLessons = upcoming
+		teachers = append(teachers, teacher)
+	}
+	return teachers, rows.Err()
+}
+
+func (r *pgTeachers) Deleted() ([]Teacher, error) {
+	rows, err := r.db.Query(teacherColumns + `, COALESCE(t.updated_at, t.created_at)
+	WHERE t.soft_deleted = true
+	ORDER BY COALESCE(t.updated_at, t.created_at) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var teachers []Teacher
+	for rows.Next() {
+		var deletedAt time.Time
+		teacher, err := scanTeacher(rows, &deletedAt)
+		if err != nil {
+			return nil, err
+		}
+		teacher.DeletedAt = deletedAt
+		teachers = append(teachers, teacher)
+	}
+	return teachers, rows.Err()
+}
+
+func (r *pgTeachers) Create(telegramID int64, fullName string) (int, error) {
+	var id int
+	err := r.db.QueryRow(`
+		WITH teacher_user AS (
+			INSERT INTO users (tg_id, full_name, role, is_active, created_at)
+			VALUES ($1, $2, 'teacher', true, NOW())
+			RETURNING id
+		)
+		INSERT INTO teachers (user_id, created_at)
+		SELECT id, NOW() FROM teacher_user
+		RETURNING id`, tgID(telegramID), fullName).Scan(&id)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "users_tg_id_key" {
+		return 0, ErrUserExists
+	}
+	return id, err
+}
+
+func (r *pgTeachers) Delete(teacherID int) error {
+	var deleted bool
+	err := r.db.QueryRow(`
+		WITH teacher AS (
+			UPDATE teachers
+			SET soft_deleted = true, updated_at = NOW()
+			WHERE id = $1 AND soft_deleted = false
+			RETURNING id, user_id
+		), deleted AS (
+			UPDATE lessons
+			SET soft_deleted = true, updated_at = NOW()
+			WHERE teacher_id IN (SELECT id FROM teacher) AND soft_deleted = false
+			RETURNING id
+		), cancelled AS (
+			UPDATE enrollments
+			SET status = 'cancelled', updated_at = NOW()
+			WHERE lesson_id IN (SELECT id FROM deleted) AND status = 'enrolled'
+		), cleared AS (
+			DELETE FROM waitlist
+			WHERE lesson_id IN (SELECT id FROM deleted)
+		), deactivated AS (
+			UPDATE users
+			SET is_active = false, updated_at = NOW()
+			WHERE id IN (SELECT user_id FROM teacher)
+		)
+		SELECT EXISTS (SELECT 1 FROM teacher)`, teacherID).Scan(&deleted)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgTeachers) Restore(teacherID int) error {
+	var restored bool
+	err := r.db.QueryRow(`
+		WITH teacher AS (
+			UPDATE teachers
+			SET soft_deleted = false, updated_at = NOW()
+			WHERE id = $1
+			RETURNING id, user_id
+		), activated AS (
+			UPDATE users
+			SET is_active = true, updated_at = NOW()
+			WHERE id IN (SELECT user_id FROM teacher)
+		), restored AS (
+			UPDATE lessons
+			SET soft_deleted = false, updated_at = NOW()
+			WHERE teacher_id IN (SELECT id FROM teacher) AND soft_deleted = true
+		)
+		SELECT EXISTS (SELECT 1 FROM teacher)`, teacherID).Scan(&restored)
+	if err != nil {
+		return ScheduleConflict(err)
+	}
+	if !restored {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgTeachers) OwnsLesson(teacherID, lessonID int) (bool, error) {
+	var owns bool
+	err := r.db.QueryRow(`
+		SELECT EXISTS(SELECT 1 FROM lessons WHERE id = $1 AND teacher_id = $2)`,
+		lessonID, teacherID).Scan(&owns)
+	return owns, err
+}
@@ -0,0 +1,114 @@
+package store
+
+import "database/sql"
+
+// UserRepository - пользователи
+type UserRepository interface {
+	// GetByTelegramID - пользователь по Telegram ID (в том числе неактивный)
+	GetByTelegramID(telegramID int64) (*User, error)
+	// Get - пользователь по ID (в том числе неактивный). ErrNotFound, если нет.
+	Get(userID int) (*User, error)
+	// Role - роль пользователя по Telegram ID
+	Role(telegramID int64) (string, error)
+	// Exists - зарегистрирован ли Telegram ID
+	Exists(telegramID int64) (bool, error)
+	// SetRole - смена роли пользователя
+	SetRole(telegramID int64, role string) error
+	// SetActive - активация или деактивация пользователя по ID. ErrNotFound, если нет.
+	SetActive(userID int, active bool) error
+	// MarkReachable - пользователь снова пишет боту: рассылки его больше не пропускают
+	MarkReachable(telegramID int64) error
+	// Active - активные пользователи с Telegram ID по роли и имени, включая заблокировавших бота
+	Active() ([]User, error)
+}
+
+type pgUsers struct {
+	db queryer
+}
+
+// userColumns - общий SELECT для User
+const userColumns = `
+	SELECT id, tg_id, role, full_name, phone, COALESCE(is_active, true), created_at,
+		unreachable_at IS NOT NULL
+	FROM users`
+
+func scanUser(row interface{ Scan(dest ...any) error }) (*User, error) {
+	var user User
+	var phone sql.NullString
+	err := row.Scan(&user.ID, &user.TelegramID, &user.Role, &user.FullName, &phone, &user.IsActive, &user.CreatedAt,
+		&user.Unreachable)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	user.Phone = phone.String
+	return &user, nil
+}
+
+func (r *pgUsers) GetByTelegramID(telegramID int64) (*User, error) {
+	return scanUser(r.db.QueryRow(userColumns+`
+	WHERE tg_id = $1`, tgID(telegramID)))
+}
+
+func (r *pgUsers) Get(userID int) (*User, error) {
+	return scanUser(r.db.QueryRow(userColumns+`
+	WHERE id = $1`, userID))
+}
+
+func (r *pgUsers) Role(telegramID int64) (string, error) {
+	var role string
+	err := r.db.QueryRow("SELECT role FROM users WHERE tg_id = $1", tgID(telegramID)).Scan(&role)
+	return role, notFound(err)
+}
+
+func (r *pgUsers) Exists(telegramID int64) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow("SELECT EXISTS(SELECT 1 FROM users WHERE tg_id = $1)", tgID(telegramID)).Scan(&exists)
+	return exists, err
+}
+
+func (r *pgUsers) SetRole(telegramID int64, role string) error {
+	result, err := r.db.Exec("UPDATE users SET role = $1, updated_at = NOW() WHERE tg_id = $2", role, tgID(telegramID))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgUsers) SetActive(userID int, active bool) error {
+	result, err := r.db.Exec("UPDATE users SET is_active = $1, updated_at = NOW() WHERE id = $2", active, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *pgUsers) MarkReachable(telegramID int64) error {
+	_, err := r.db.Exec("UPDATE users SET unreachable_at = NULL WHERE tg_id = $1", tgID(telegramID))
+	return err
+}
+
+func (r *pgUsers) Active() ([]User, error) {
+	rows, err := r.db.Query(userColumns + `
+	WHERE is_active = true AND tg_id IS NOT NULL
+	ORDER BY role, full_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, *user)
+	}
+	return users, rows.Err()
+}
@@ -0,0 +1,72 @@
+package store
+
+import (
+	"database/sql"
+	"time"
+)
+
+// WaitlistRepository - лист ожидания
+type WaitlistRepository interface {
+	// Add - ставит студента в конец очереди, возвращает позицию. ErrAlreadyWaitlisted, если уже в очереди.
+	Add(studentID, lessonID int) (int, error)
+	// Next - первый в очереди на урок. ErrNotFound, если очередь пуста.
+	Next(lessonID int) (*WaitlistEntry, error)
+	// Remove - удаляет студента из очереди
+	Remove(studentID, lessonID int) error
+	// Lessons - неудаленные уроки после from, в очереди на которые стоит студент
+	Lessons(studentID int, from time.Time) ([]Lesson, error)
+}
+
+type pgWaitlist struct {
+	db queryer
+}
+
+func (r *pgWaitlist) Add(studentID, lessonID int) (int, error) {
+	var position int
+	err := r.db.QueryRow(`
+		INSERT INTO waitlist (student_id, lesson_id, position, created_at)
+		SELECT $1::int, $2::int,
+			(SELECT COALESCE(MAX(position), 0) + 1 FROM waitlist WHERE lesson_id = $2),
+			NOW()
+		WHERE NOT EXISTS (
+			SELECT 1 FROM waitlist WHERE student_id = $1 AND lesson_id = $2)
+		RETURNING position`, studentID, lessonID).Scan(&position)
+	if err == sql.ErrNoRows {
+		return 0, ErrAlreadyWaitlisted
+	}
+	return position, err
+}
+
+func (r *pgWaitlist) Next(lessonID int) (*WaitlistEntry, error) {
+	var entry WaitlistEntry
+	err := r.db.QueryRow(`
+		SELECT w.id, w.student_id, w.lesson_id, w.position, u.tg_id, w.created_at
+		FROM waitlist w
+		JOIN students s ON w.student_id = s.id
+		JOIN users u ON s.user_id = u.id
+		WHERE w.lesson_id = $1
+		ORDER BY w.position, w.created_at
+		LIMIT 1`, lessonID).Scan(
+		&entry.ID, &entry.StudentID, &entry.LessonID, &entry.Position, &entry.TelegramID, &entry.CreatedAt)
+	if err != nil {
+		return nil, notFound(err)
+	}
+	return &entry, nil
+}
+
+func (r *pgWaitlist) Remove(studentID, lessonID int) error {
+	_, err := r.db.Exec("DELETE FROM waitlist WHERE student_id = $1 AND lesson_id = $2", studentID, lessonID)
+	return err
+}
+
+func (r *pgWaitlist) Lessons(studentID int, from time.Time) ([]Lesson, error) {
+	rows, err := r.db.Query(lessonColumns+`
+	JOIN waitlist w ON w.lesson_id = l.id
+	WHERE w.student_id = $1 AND l.start_time > $2 AND l.soft_deleted = false`+lessonGroupBy+`
+	ORDER BY l.start_time`, studentID, from)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	return scanLessons(rows)
+}
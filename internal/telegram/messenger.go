@@ -0,0 +1,99 @@
+// Package telegram - узкий интерфейс отправки сообщений в Telegram для обработчиков.
+// В бою реализуется поверх tgbotapi.BotAPI, в тестах - поверх фейкового Bot API (telegramtest).
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/metrics"
+)
+
+// Messenger - все, что обработчикам нужно от Telegram
+type Messenger interface {
+	// Send - отправка сообщения или редактирование, возвращает отправленное сообщение
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	// Request - вызов метода Bot API без результата-сообщения
+	Request(c tgbotapi.Chattable) (*tgbotapi.APIResponse, error)
+	// AnswerCallback - ответ на нажатие inline-кнопки (пустой текст убирает индикатор загрузки)
+	AnswerCallback(callbackID, text string) error
+	// EditMessage - замена текста (Markdown) и клавиатуры сообщения; markup nil убирает клавиатуру
+	EditMessage(chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) error
+}
+
+// contextMessenger - Messenger одного обновления
+type contextMessenger struct {
+	Messenger
+	ctx context.Context
+}
+
+// WithContext - Messenger, который обработчики обновления получают вместо общего:
+// отправка идет через m, а Context(bot) отдает контекст обновления (correlation ID)
+func WithContext(m Messenger, ctx context.Context) Messenger {
+	if cm, ok := m.(*contextMessenger); ok {
+		m = cm.Messenger
+	}
+	return &contextMessenger{Messenger: m, ctx: ctx}
+}
+
+// Context - контекст обновления, с которым создан Messenger; без него - context.Background()
+func Context(m Messenger) context.Context {
+	if cm, ok := m.(*contextMessenger); ok {
+		return cm.ctx
+	}
+	return context.Background()
+}
+
+// Client - Messenger поверх tgbotapi.BotAPI
+type Client struct {
+	*tgbotapi.BotAPI
+}
+
+// NewClient - оборачивает BotAPI
+func NewClient(bot *tgbotapi.BotAPI) *Client {
+	return &Client{BotAPI: bot}
+}
+
+// Send - отправка с учетом ошибок в metrics.TelegramSendFailuresTotal
+func (c *Client) Send(chattable tgbotapi.Chattable) (tgbotapi.Message, error) {
+	message, err := c.BotAPI.Send(chattable)
+	countFailure(chattable, err)
+	return message, err
+}
+
+// Request - вызов метода с учетом ошибок в metrics.TelegramSendFailuresTotal
+func (c *Client) Request(chattable tgbotapi.Chattable) (*tgbotapi.APIResponse, error) {
+	response, err := c.BotAPI.Request(chattable)
+	countFailure(chattable, err)
+	return response, err
+}
+
+// countFailure - метка запроса - тип конфига tgbotapi (MessageConfig, CallbackConfig, ...)
+func countFailure(chattable tgbotapi.Chattable, err error) {
+	if err == nil {
+		return
+	}
+	request := strings.TrimPrefix(strings.TrimPrefix(fmt.Sprintf("%T", chattable), "*"), "tgbotapi.")
+	metrics.TelegramSendFailuresTotal.WithLabelValues(request).Inc()
+}
+
+// AnswerCallback - ответ на callback query
+func (c *Client) AnswerCallback(callbackID, text string) error {
+	_, err := c.Request(tgbotapi.NewCallback(callbackID, text))
+	return err
+}
+
+// EditMessage - редактирование текста и клавиатуры сообщения
+func (c *Client) EditMessage(chatID int64, messageID int, text string, markup *tgbotapi.InlineKeyboardMarkup) error {
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "Markdown"
+	if markup == nil {
+		markup = &tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+	}
+	edit.ReplyMarkup = markup
+	_, err := c.Send(edit)
+	return err
+}
@@ -0,0 +1,253 @@
+// Package telegramtest - фейковый Telegram Bot API в процессе для офлайн-тестов сценариев.
+// Сервер принимает запросы настоящего tgbotapi.BotAPI, записывает их и отвечает как Telegram.
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Token - токен тестового бота
+const Token = "123456:TEST-TOKEN"
+
+// BotID - Telegram ID тестового бота
+const BotID = 100000
+
+// Call - один вызов метода Bot API
+type Call struct {
+	Method string
+	Params url.Values
+
+	sentID int // ID, присвоенный сервером отправленному сообщению
+}
+
+// ChatID - получатель вызова
+func (c Call) ChatID() int64 {
+	id, _ := strconv.ParseInt(c.Params.Get("chat_id"), 10, 64)
+	return id
+}
+
+// MessageID - ID отправленного (sendMessage) или редактируемого (edit*) сообщения
+func (c Call) MessageID() int {
+	if c.sentID != 0 {
+		return c.sentID
+	}
+	id, _ := strconv.Atoi(c.Params.Get("message_id"))
+	return id
+}
+
+// Text - текст сообщения или ответа на callback
+func (c Call) Text() string {
+	return c.Params.Get("text")
+}
+
+// Keyboard - inline-клавиатура вызова, nil если ее нет
+func (c Call) Keyboard() *tgbotapi.InlineKeyboardMarkup {
+	raw := c.Params.Get("reply_markup")
+	if raw == "" {
+		return nil
+	}
+	var markup tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(raw), &markup); err != nil || markup.InlineKeyboard == nil {
+		return nil
+	}
+	return &markup
+}
+
+// Buttons - callback_data всех кнопок inline-клавиатуры
+func (c Call) Buttons() []string {
+	markup := c.Keyboard()
+	if markup == nil {
+		return nil
+	}
+	var data []string
+	for _, row := range markup.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData != nil {
+				data = append(data, *button.CallbackData)
+			}
+		}
+	}
+	return data
+}
+
+// Server - фейковый Bot API
+type Server struct {
+	*httptest.Server
+
+	mu            sync.Mutex
+	calls         []Call
+	nextMessageID int
+	failures      map[string]string
+}
+
+// NewServer - запускает фейковый Bot API, остановка - Close()
+func NewServer() *Server {
+	s := &Server{nextMessageID: 1, failures: make(map[string]string)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// Bot - настоящий клиент tgbotapi, направленный на фейковый сервер
+func (s *Server) Bot() (*tgbotapi.BotAPI, error) {
+	return tgbotapi.NewBotAPIWithAPIEndpoint(Token, s.URL+"/bot%s/%s")
+}
+
+// Fail - следующие вызовы method будут отвечать ошибкой Bot API с описанием description
+func (s *Server) Fail(method, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[method] = description
+}
+
+// Calls - все записанные вызовы, кроме getMe
+func (s *Server) Calls() []Call {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Call(nil), s.calls...)
+}
+
+// Messages - отправленные и отредактированные сообщения в чат, по порядку
+func (s *Server) Messages(chatID int64) []Call {
+	var messages []Call
+	for _, call := range s.Calls() {
+		if (call.Method == "sendMessage" || call.Method == "editMessageText") && call.ChatID() == chatID {
+			messages = append(messages, call)
+		}
+	}
+	return messages
+}
+
+// LastMessage - последнее сообщение в чат
+func (s *Server) LastMessage(chatID int64) (Call, bool) {
+	messages := s.Messages(chatID)
+	if len(messages) == 0 {
+		return Call{}, false
+	}
+	return messages[len(messages)-1], true
+}
+
+// CallbackAnswers - тексты ответов на callback query (непустые)
+func (s *Server) CallbackAnswers() []string {
+	var answers []string
+	for _, call := range s.Calls() {
+		if call.Method == "answerCallbackQuery" && call.Text() != "" {
+			answers = append(answers, call.Text())
+		}
+	}
+	return answers
+}
+
+// Reset - забывает записанные вызовы и ошибки
+func (s *Server) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = nil
+	s.failures = make(map[string]string)
+}
+
+// handle - /bot<token>/<method>
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 2 || parts[0] != "bot"+Token {
+		writeResponse(w, http.StatusUnauthorized, false, nil, "Unauthorized")
+		return
+	}
+	method := parts[1]
+
+	if err := r.ParseMultipartForm(1 << 20); err != nil && err != http.ErrNotMultipart {
+		writeResponse(w, http.StatusBadRequest, false, nil, "Bad Request: "+err.Error())
+		return
+	}
+
+	if method == "getMe" {
+		writeResponse(w, http.StatusOK, true, tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Test", UserName: "test_bot"}, "")
+		return
+	}
+
+	s.mu.Lock()
+	call := Call{Method: method, Params: r.Form}
+	description, failed := s.failures[method]
+	messageID := s.nextMessageID
+	if method == "sendMessage" && !failed {
+		call.sentID = messageID
+		s.nextMessageID++
+	}
+	s.calls = append(s.calls, call)
+	s.mu.Unlock()
+
+	if failed {
+		writeResponse(w, http.StatusBadRequest, false, nil, description)
+		return
+	}
+
+	switch method {
+	case "sendMessage", "editMessageText", "editMessageReplyMarkup":
+		chatID, _ := strconv.ParseInt(r.Form.Get("chat_id"), 10, 64)
+		if method != "sendMessage" {
+			messageID, _ = strconv.Atoi(r.Form.Get("message_id"))
+		}
+		writeResponse(w, http.StatusOK, true, tgbotapi.Message{
+			MessageID: messageID,
+			From:      &tgbotapi.User{ID: BotID, IsBot: true, FirstName: "Test", UserName: "test_bot"},
+			Chat:      &tgbotapi.Chat{ID: chatID, Type: "private"},
+			Date:      int(time.Now().Unix()),
+			Text:      r.Form.Get("text"),
+		}, "")
+	default:
+		writeResponse(w, http.StatusOK, true, true, "")
+	}
+}
+
+func writeResponse(w http.ResponseWriter, status int, ok bool, result any, description string) {
+	response := map[string]any{"ok": ok}
+	if ok {
+		response["result"] = result
+	} else {
+		response["error_code"] = status
+		response["description"] = description
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// MessageUpdate - входящее сообщение пользователя в личном чате (команды размечаются как команды)
+func MessageUpdate(userID int64, text string) tgbotapi.Update {
+	message := &tgbotapi.Message{
+		MessageID: int(time.Now().UnixNano() % 1_000_000),
+		From:      &tgbotapi.User{ID: userID, FirstName: fmt.Sprintf("User%d", userID)},
+		Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		length := len(strings.Fields(text)[0])
+		message.Entities = []tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: length}}
+	}
+	return tgbotapi.Update{Message: message}
+}
+
+// CallbackUpdate - нажатие inline-кнопки под сообщением messageID бота
+func CallbackUpdate(userID int64, messageID int, data string) tgbotapi.Update {
+	return tgbotapi.Update{
+		CallbackQuery: &tgbotapi.CallbackQuery{
+			ID:   fmt.Sprintf("cb-%d-%d", userID, time.Now().UnixNano()),
+			From: &tgbotapi.User{ID: userID, FirstName: fmt.Sprintf("User%d", userID)},
+			Message: &tgbotapi.Message{
+				MessageID: messageID,
+				Chat:      &tgbotapi.Chat{ID: userID, Type: "private"},
+			},
+			Data: data,
+		},
+	}
+}
@@ -0,0 +1,88 @@
+package telegramtest
+
+import (
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"constellation-school-bot/internal/telegram"
+)
+
+func newClient(t *testing.T) (*Server, *telegram.Client) {
+	server := NewServer()
+	t.Cleanup(server.Close)
+
+	bot, err := server.Bot()
+	if err != nil {
+		t.Fatalf("Failed to create bot against fake server: %v", err)
+	}
+	return server, telegram.NewClient(bot)
+}
+
+// Тест записи отправленных сообщений и клавиатур
+func TestServerRecordsMessages(t *testing.T) {
+	server, client := newClient(t)
+
+	msg := tgbotapi.NewMessage(42, "Привет")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Да", "yes"),
+		tgbotapi.NewInlineKeyboardButtonData("Нет", "no"),
+	))
+	sent, err := client.Send(msg)
+	if err != nil {
+		t.Fatalf("Unexpected send error: %v", err)
+	}
+
+	if err := client.EditMessage(42, sent.MessageID, "Изменено", nil); err != nil {
+		t.Fatalf("Unexpected edit error: %v", err)
+	}
+	if err := client.AnswerCallback("cb-1", "Готово"); err != nil {
+		t.Fatalf("Unexpected callback error: %v", err)
+	}
+
+	messages := server.Messages(42)
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages in chat, got %d", len(messages))
+	}
+	if messages[0].MessageID() != sent.MessageID {
+		t.Errorf("Expected sent message ID %d, got %d", sent.MessageID, messages[0].MessageID())
+	}
+	if buttons := messages[0].Buttons(); len(buttons) != 2 || buttons[0] != "yes" {
+		t.Errorf("Expected buttons [yes no], got %v", buttons)
+	}
+	if last, _ := server.LastMessage(42); last.Text() != "Изменено" || last.MessageID() != sent.MessageID {
+		t.Errorf("Expected edited message %d, got %+v", sent.MessageID, last)
+	}
+	if answers := server.CallbackAnswers(); len(answers) != 1 || answers[0] != "Готово" {
+		t.Errorf("Expected callback answer, got %v", answers)
+	}
+	if len(server.Messages(7)) != 0 {
+		t.Error("Messages from another chat should not be returned")
+	}
+}
+
+// Тест ошибок Bot API
+func TestServerFail(t *testing.T) {
+	server, client := newClient(t)
+	server.Fail("sendMessage", "Forbidden: bot was blocked by the user")
+
+	if _, err := client.Send(tgbotapi.NewMessage(42, "Привет")); err == nil {
+		t.Error("Expected error from failed method")
+	}
+
+	server.Reset()
+	if _, err := client.Send(tgbotapi.NewMessage(42, "Привет")); err != nil {
+		t.Errorf("Unexpected error after reset: %v", err)
+	}
+}
+
+// Тест разметки команд во входящих сообщениях
+func TestMessageUpdate(t *testing.T) {
+	update := MessageUpdate(5, "/enroll 12")
+	if !update.Message.IsCommand() || update.Message.Command() != "enroll" || update.Message.CommandArguments() != "12" {
+		t.Errorf("Expected /enroll command with argument, got %+v", update.Message)
+	}
+	if MessageUpdate(5, "Иван Иванов").Message.IsCommand() {
+		t.Error("Plain text should not be a command")
+	}
+}
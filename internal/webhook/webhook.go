@@ -0,0 +1,162 @@
+package webhook
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// Заголовок, в котором Telegram передает secret_token, указанный при setWebhook
+const SecretTokenHeader = "X-Telegram-Bot-Api-Secret-Token"
+
+const (
+	// Максимальный размер тела запроса с обновлением
+	maxBodySize = 1 << 20
+	// Размер буфера обновлений (как у GetUpdatesChan)
+	updatesBufferSize = 100
+)
+
+// Допустимые символы secret_token по документации Telegram Bot API
+var secretTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,256}$`)
+
+// Server - HTTP сервер, принимающий обновления Telegram через webhook
+type Server struct {
+	path    string
+	secret  string
+	updates chan tgbotapi.Update
+	server  *http.Server
+}
+
+// NewServer - создает webhook сервер на адресе addr, обновления принимаются по пути path
+func NewServer(addr, path, secret string) (*Server, error) {
+	if !secretTokenPattern.MatchString(secret) {
+		return nil, fmt.Errorf("WEBHOOK_SECRET должен содержать 1-256 символов A-Z, a-z, 0-9, _ или -")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	s := &Server{
+		path:    path,
+		secret:  secret,
+		updates: make(chan tgbotapi.Update, updatesBufferSize),
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle(path, s)
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      30 * time.Second,
+	}
+
+	return s, nil
+}
+
+// Updates - канал обновлений, совместимый с GetUpdatesChan
+func (s *Server) Updates() tgbotapi.UpdatesChannel {
+	return s.updates
+}
+
+// ListenAndServe - запускает HTTP сервер (блокирующий вызов)
+func (s *Server) ListenAndServe() error {
+	slog.Info("Webhook сервер запущен", "addr", s.server.Addr, "path", s.path)
+	return s.server.ListenAndServe()
+}
+
+// Shutdown - останавливает HTTP сервер и закрывает канал обновлений
+func (s *Server) Shutdown(ctx context.Context) error {
+	if err := s.server.Shutdown(ctx); err != nil {
+		// Обработчики могут еще писать в канал - не закрываем его
+		return err
+	}
+	close(s.updates)
+	return nil
+}
+
+// ServeHTTP - проверка секрета, разбор обновления и передача в канал
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	token := r.Header.Get(SecretTokenHeader)
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.secret)) != 1 {
+		slog.Warn("Webhook: запрос с неверным секретом", "remote_addr", r.RemoteAddr)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBodySize+1))
+	if err != nil {
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+	if len(body) > maxBodySize {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var update tgbotapi.Update
+	if err := json.Unmarshal(body, &update); err != nil {
+		slog.Warn("Webhook: некорректное обновление", "err", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	// Если очередь переполнена, Telegram повторит доставку после ошибки
+	select {
+	case s.updates <- update:
+		w.WriteHeader(http.StatusOK)
+	case <-r.Context().Done():
+		http.Error(w, "service unavailable", http.StatusServiceUnavailable)
+	}
+}
+
+// Register - регистрирует webhook в Telegram (setWebhook с secret_token)
+func Register(bot *tgbotapi.BotAPI, publicURL, path, secret string) error {
+	if publicURL == "" {
+		return fmt.Errorf("WEBHOOK_URL не задан")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+
+	params := tgbotapi.Params{
+		"url":          strings.TrimRight(publicURL, "/") + path,
+		"secret_token": secret,
+	}
+
+	resp, err := bot.MakeRequest("setWebhook", params)
+	if err != nil {
+		return fmt.Errorf("ошибка регистрации webhook: %w", err)
+	}
+	if !resp.Ok {
+		return fmt.Errorf("Telegram отклонил webhook: %s", resp.Description)
+	}
+
+	slog.Info("Webhook зарегистрирован", "url", params["url"])
+	return nil
+}
+
+// Unregister - удаляет webhook, чтобы работал long polling
+func Unregister(bot *tgbotapi.BotAPI) error {
+	if _, err := bot.Request(tgbotapi.DeleteWebhookConfig{}); err != nil {
+		return fmt.Errorf("ошибка удаления webhook: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSecret = "test_secret-123"
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer(":0", "/telegram/webhook", testSecret)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return s
+}
+
+// Тест проверки секрета при создании сервера
+func TestNewServerValidatesSecret(t *testing.T) {
+	for _, secret := range []string{"", "has space", "кириллица", strings.Repeat("a", 257)} {
+		if _, err := NewServer(":0", "/hook", secret); err == nil {
+			t.Errorf("Expected error for secret '%s'", secret)
+		}
+	}
+}
+
+// Тест обработки запросов webhook
+func TestServeHTTP(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		secret     string
+		body       string
+		wantStatus int
+		wantUpdate bool
+	}{
+		{"valid update", http.MethodPost, testSecret, `{"update_id": 42, "message": {"message_id": 1, "text": "/start"}}`, http.StatusOK, true},
+		{"wrong secret", http.MethodPost, "wrong", `{"update_id": 42}`, http.StatusUnauthorized, false},
+		{"missing secret", http.MethodPost, "", `{"update_id": 42}`, http.StatusUnauthorized, false},
+		{"invalid json", http.MethodPost, testSecret, `{not json`, http.StatusBadRequest, false},
+		{"wrong method", http.MethodGet, testSecret, ``, http.StatusMethodNotAllowed, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			req := httptest.NewRequest(test.method, "/telegram/webhook", strings.NewReader(test.body))
+			if test.secret != "" {
+				req.Header.Set(SecretTokenHeader, test.secret)
+			}
+			rec := httptest.NewRecorder()
+
+			s.ServeHTTP(rec, req)
+
+			if rec.Code != test.wantStatus {
+				t.Errorf("Expected status %d, got %d", test.wantStatus, rec.Code)
+			}
+
+			select {
+			case update := <-s.Updates():
+				if !test.wantUpdate {
+					t.Errorf("Unexpected update %d", update.UpdateID)
+				} else if update.UpdateID != 42 || update.Message == nil || update.Message.Text != "/start" {
+					t.Errorf("Update decoded incorrectly: %+v", update)
+				}
+			default:
+				if test.wantUpdate {
+					t.Error("Expected update in channel, got none")
+				}
+			}
+		})
+	}
+}
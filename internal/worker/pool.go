@@ -0,0 +1,191 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// HandlerFunc - обработчик одного обновления
+type HandlerFunc func(update tgbotapi.Update)
+
+// Pool - пул воркеров для параллельной обработки обновлений.
+// Обновления одного чата всегда попадают в одну очередь и обрабатываются по порядку.
+type Pool struct {
+	handler HandlerFunc
+	queues  []chan tgbotapi.Update
+	wg      sync.WaitGroup
+
+	stopOnce sync.Once
+
+	// Метрики
+	queued       atomic.Int64 // обновлений в очередях
+	inFlight     atomic.Int64 // обновлений в обработке
+	processed    atomic.Int64 // обработано всего
+	panics       atomic.Int64 // обработчиков, завершившихся паникой
+	blocked      atomic.Int64 // постановок в очередь, ожидавших места (back-pressure)
+	blockedNanos atomic.Int64 // суммарное время ожидания места в очереди
+}
+
+// Stats - снимок метрик пула
+type Stats struct {
+	Workers     int
+	QueueSize   int // емкость очереди одного воркера
+	Queued      int64
+	InFlight    int64
+	Processed   int64
+	Panics      int64
+	Blocked     int64
+	BlockedTime time.Duration
+}
+
+// NewPool - создает и запускает пул из workers воркеров с очередью queueSize на каждого
+func NewPool(workers, queueSize int, handler HandlerFunc) *Pool {
+	if workers < 1 {
+		workers = 1
+	}
+	if queueSize < 1 {
+		queueSize = 1
+	}
+
+	p := &Pool{
+		handler: handler,
+		queues:  make([]chan tgbotapi.Update, workers),
+	}
+
+	for i := range p.queues {
+		p.queues[i] = make(chan tgbotapi.Update, queueSize)
+		p.wg.Add(1)
+		go p.run(p.queues[i])
+	}
+
+	slog.Info("Пул обработки обновлений запущен", "workers", workers, "queue_size", queueSize)
+	return p
+}
+
+// Submit - ставит обновление в очередь его чата. Если очередь заполнена, ждет места
+// до отмены ctx; тогда обновление не ставится и возвращается ctx.Err().
+func (p *Pool) Submit(ctx context.Context, update tgbotapi.Update) error {
+	queue := p.queues[shardIndex(chatKey(update), len(p.queues))]
+
+	p.queued.Add(1)
+	select {
+	case queue <- update:
+		return nil
+	default:
+	}
+
+	// Очередь заполнена - ждем освобождения места (back-pressure на источник обновлений)
+	started := time.Now()
+	var err error
+	select {
+	case queue <- update:
+	case <-ctx.Done():
+		p.queued.Add(-1)
+		err = ctx.Err()
+	}
+	waited := time.Since(started)
+
+	if p.blocked.Add(1)%100 == 1 {
+		slog.Warn("Очередь обработки обновлений переполнена", "waited", waited, "blocked_total", p.blocked.Load())
+	}
+	p.blockedNanos.Add(int64(waited))
+	return err
+}
+
+// Stop - прекращает прием обновлений и ждет обработки уже поставленных в очередь
+func (p *Pool) Stop() {
+	p.closeQueues()
+	p.wg.Wait()
+}
+
+// Shutdown - как Stop, но ждет не дольше дедлайна ctx. Submit после вызова недопустим.
+func (p *Pool) Shutdown(ctx context.Context) error {
+	p.closeQueues()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		stats := p.Stats()
+		return fmt.Errorf("обработка не завершилась вовремя (в обработке: %d, в очереди: %d): %w",
+			stats.InFlight, stats.Queued, ctx.Err())
+	}
+}
+
+func (p *Pool) closeQueues() {
+	p.stopOnce.Do(func() {
+		for _, queue := range p.queues {
+			close(queue)
+		}
+	})
+}
+
+// Stats - текущие метрики пула
+func (p *Pool) Stats() Stats {
+	return Stats{
+		Workers:     len(p.queues),
+		QueueSize:   cap(p.queues[0]),
+		Queued:      p.queued.Load(),
+		InFlight:    p.inFlight.Load(),
+		Processed:   p.processed.Load(),
+		Panics:      p.panics.Load(),
+		Blocked:     p.blocked.Load(),
+		BlockedTime: time.Duration(p.blockedNanos.Load()),
+	}
+}
+
+func (p *Pool) run(queue <-chan tgbotapi.Update) {
+	defer p.wg.Done()
+
+	for update := range queue {
+		p.queued.Add(-1)
+		p.inFlight.Add(1)
+		p.handle(update)
+		p.inFlight.Add(-1)
+		p.processed.Add(1)
+	}
+}
+
+// handle - вызывает обработчик, паника в одном обновлении не останавливает воркер
+func (p *Pool) handle(update tgbotapi.Update) {
+	defer func() {
+		if r := recover(); r != nil {
+			p.panics.Add(1)
+			slog.Error("Паника при обработке обновления", "update_id", update.UpdateID, "panic", r, "stack", string(debug.Stack()))
+		}
+	}()
+
+	p.handler(update)
+}
+
+// chatKey - ключ упорядочивания: чат, иначе отправитель
+func chatKey(update tgbotapi.Update) int64 {
+	if chat := update.FromChat(); chat != nil {
+		return chat.ID
+	}
+	if user := update.SentFrom(); user != nil {
+		return user.ID
+	}
+	return 0
+}
+
+func shardIndex(key int64, shards int) int {
+	index := key % int64(shards)
+	if index < 0 {
+		index = -index
+	}
+	return int(index)
+}
@@ -0,0 +1,178 @@
+package worker
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+func messageUpdate(updateID int, chatID int64) tgbotapi.Update {
+	return tgbotapi.Update{
+		UpdateID: updateID,
+		Message: &tgbotapi.Message{
+			Chat: &tgbotapi.Chat{ID: chatID},
+			From: &tgbotapi.User{ID: chatID},
+		},
+	}
+}
+
+// Тест сохранения порядка обновлений одного чата
+func TestPoolPreservesPerChatOrder(t *testing.T) {
+	var mu sync.Mutex
+	order := make(map[int64][]int)
+
+	pool := NewPool(4, 10, func(update tgbotapi.Update) {
+		mu.Lock()
+		defer mu.Unlock()
+		chatID := update.Message.Chat.ID
+		order[chatID] = append(order[chatID], update.UpdateID)
+	})
+
+	for i := 0; i < 100; i++ {
+		pool.Submit(context.Background(), messageUpdate(i, int64(i%5)))
+	}
+	pool.Stop()
+
+	for chatID, ids := range order {
+		for i := 1; i < len(ids); i++ {
+			if ids[i] < ids[i-1] {
+				t.Fatalf("Chat %d: updates out of order: %v", chatID, ids)
+			}
+		}
+	}
+
+	if stats := pool.Stats(); stats.Processed != 100 || stats.Queued != 0 {
+		t.Errorf("Expected 100 processed and empty queue, got %+v", stats)
+	}
+}
+
+// Тест: медленный чат не блокирует другие чаты
+func TestPoolSlowChatDoesNotBlockOthers(t *testing.T) {
+	release := make(chan struct{})
+	fastDone := make(chan struct{})
+
+	pool := NewPool(2, 10, func(update tgbotapi.Update) {
+		if update.Message.Chat.ID == 0 {
+			<-release
+			return
+		}
+		close(fastDone)
+	})
+	defer pool.Stop()
+
+	pool.Submit(context.Background(), messageUpdate(1, 0)) // медленный чат (воркер 0)
+	pool.Submit(context.Background(), messageUpdate(2, 1)) // другой чат (воркер 1)
+
+	select {
+	case <-fastDone:
+	case <-time.After(time.Second):
+		t.Error("Update from another chat was blocked by a slow chat")
+	}
+	close(release)
+}
+
+// Тест: паника в обработчике не останавливает воркер
+func TestPoolRecoversFromPanic(t *testing.T) {
+	var handled sync.WaitGroup
+	handled.Add(1)
+
+	pool := NewPool(1, 10, func(update tgbotapi.Update) {
+		if update.UpdateID == 1 {
+			panic("test panic")
+		}
+		handled.Done()
+	})
+
+	pool.Submit(context.Background(), messageUpdate(1, 7))
+	pool.Submit(context.Background(), messageUpdate(2, 7))
+	handled.Wait()
+	pool.Stop()
+
+	if stats := pool.Stats(); stats.Panics != 1 || stats.Processed != 2 {
+		t.Errorf("Expected 1 panic and 2 processed, got %+v", stats)
+	}
+}
+
+// Тест back-pressure: Submit ждет, пока в очереди не появится место
+func TestPoolBackPressure(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(1, 1, func(update tgbotapi.Update) {
+		<-release
+	})
+
+	pool.Submit(context.Background(), messageUpdate(1, 3)) // в обработке
+	for pool.Stats().InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Submit(context.Background(), messageUpdate(2, 3)) // в очереди
+
+	submitted := make(chan struct{})
+	go func() {
+		pool.Submit(context.Background(), messageUpdate(3, 3)) // ждет места
+		close(submitted)
+	}()
+
+	select {
+	case <-submitted:
+		t.Fatal("Submit should block while the queue is full")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-submitted
+	pool.Stop()
+
+	if stats := pool.Stats(); stats.Blocked != 1 || stats.Processed != 3 {
+		t.Errorf("Expected 1 blocked submit and 3 processed, got %+v", stats)
+	}
+}
+
+// Тест: Shutdown возвращает ошибку, если обработка не уложилась в дедлайн
+func TestPoolShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(1, 1, func(update tgbotapi.Update) {
+		<-release
+	})
+	pool.Submit(context.Background(), messageUpdate(1, 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Shutdown(ctx); err == nil {
+		t.Error("Expected deadline error while handler is still running")
+	}
+
+	close(release)
+	if err := pool.Shutdown(context.Background()); err != nil {
+		t.Errorf("Unexpected error after handler finished: %v", err)
+	}
+}
+
+// Тест: Submit в заполненную очередь прерывается отменой ctx, обновление не ставится
+func TestPoolSubmitCancelled(t *testing.T) {
+	release := make(chan struct{})
+	pool := NewPool(1, 1, func(update tgbotapi.Update) {
+		<-release
+	})
+
+	pool.Submit(context.Background(), messageUpdate(1, 3)) // в обработке
+	for pool.Stats().InFlight != 1 {
+		time.Sleep(time.Millisecond)
+	}
+	pool.Submit(context.Background(), messageUpdate(2, 3)) // в очереди
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := pool.Submit(ctx, messageUpdate(3, 3)); err == nil {
+		t.Error("Expected error when ctx is done while the queue is full")
+	}
+
+	close(release)
+	pool.Stop()
+
+	if stats := pool.Stats(); stats.Processed != 2 || stats.Queued != 0 {
+		t.Errorf("Expected 2 processed and empty queue, got %+v", stats)
+	}
+}
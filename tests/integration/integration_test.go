@@ -0,0 +1,132 @@
+package integration
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"constellation-school-bot/internal/config"
+	"constellation-school-bot/internal/database"
+	"constellation-school-bot/internal/handlers"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+	"constellation-school-bot/internal/telegram/telegramtest"
+)
+
+// Сценарий записи на урок целиком: настоящая схема PostgreSQL, фейковый Telegram.
+// Требует Docker, без него тест пропускается.
+func TestEnrollScenarioWithPostgres(t *testing.T) {
+	testcontainers.SkipIfProviderIsNotHealthy(t)
+	ctx := context.Background()
+
+	container, err := postgres.Run(ctx, "postgres:16",
+		postgres.WithDatabase("testdb"),
+		postgres.WithUsername("testuser"),
+		postgres.WithPassword("testpass"),
+		testcontainers.WithWaitStrategy(
+			wait.ForLog("database system is ready to accept connections").
+				WithOccurrence(2).WithStartupTimeout(30*time.Second)),
+	)
+	if err != nil {
+		t.Fatalf("Failed to start PostgreSQL: %v", err)
+	}
+	t.Cleanup(func() { container.Terminate(ctx) })
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := database.Connect(&config.Config{
+		DBHost:      host,
+		DBPort:      port.Port(),
+		DBUser:      "testuser",
+		DBPassword:  "testpass",
+		DBName:      "testdb",
+		AutoMigrate: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	// Преподаватель, урок на одно место и два студента
+	var teacherID, lessonID int
+	mustExec := func(query string, args ...any) {
+		t.Helper()
+		if _, err := db.Exec(query, args...); err != nil {
+			t.Fatalf("Setup query failed: %v", err)
+		}
+	}
+	mustExec("INSERT INTO users (tg_id, role, full_name) VALUES ('5001', 'teacher', 'Анна Петрова')")
+	if err := db.QueryRow(`INSERT INTO teachers (user_id) SELECT id FROM users WHERE tg_id = '5001' RETURNING id`).Scan(&teacherID); err != nil {
+		t.Fatal(err)
+	}
+	if err := db.QueryRow(`
+		INSERT INTO lessons (teacher_id, subject_id, start_time, max_students)
+		SELECT $1, id, NOW() + INTERVAL '2 days', 1 FROM subjects ORDER BY id LIMIT 1
+		RETURNING id`, teacherID).Scan(&lessonID); err != nil {
+		t.Fatal(err)
+	}
+	for _, tgID := range []string{"5002", "5003"} {
+		mustExec("INSERT INTO users (tg_id, role, full_name) VALUES ($1, 'student', 'Студент')", tgID)
+		mustExec("INSERT INTO students (user_id) SELECT id FROM users WHERE tg_id = $1", tgID)
+	}
+
+	server := telegramtest.NewServer()
+	t.Cleanup(server.Close)
+	api, err := server.Bot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	bot := telegram.NewClient(api)
+
+	handlers.InitializeStore(store.New(db))
+	t.Cleanup(func() { handlers.InitializeStore(nil) })
+
+	say := func(userID int64, text, want string) {
+		t.Helper()
+		handlers.HandleUpdate(bot, telegramtest.MessageUpdate(userID, text), db)
+		reply, _ := server.LastMessage(userID)
+		if !strings.Contains(reply.Text(), want) {
+			t.Errorf("%q: expected reply containing %q, got %q", text, want, reply.Text())
+		}
+	}
+
+	enroll := "/enroll " + strconv.Itoa(lessonID)
+	say(5002, enroll, "Вы записаны на урок")
+	say(5002, enroll, "уже записаны")
+	say(5003, enroll, "Позиция в очереди: 1")
+	say(5003, enroll, "уже в листе ожидания")
+
+	var enrolled, waiting int
+	db.QueryRow("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID).Scan(&enrolled)
+	db.QueryRow("SELECT COUNT(*) FROM waitlist WHERE lesson_id = $1", lessonID).Scan(&waiting)
+	if enrolled != 1 || waiting != 1 {
+		t.Errorf("Expected 1 enrolled and 1 waiting, got %d and %d", enrolled, waiting)
+	}
+
+	// Освободившееся место достается первому из листа ожидания
+	st := store.New(db)
+	student, err := st.Students.GetByTelegramID(5002)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := st.Enrollments.Unenroll(student.ID, lessonID); err != nil {
+		t.Fatal(err)
+	}
+	next, err := st.Waitlist.Next(lessonID)
+	if err != nil || next.TelegramID != 5003 {
+		t.Errorf("Expected student 5003 first in waitlist, got %+v, %v", next, err)
+	}
+}
@@ -0,0 +1,184 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/store"
+)
+
+// Сценарии курсов: места на курсе, расписание и запись участников на уроки
+
+// createCourse - курс преподавателя по 3D-моделированию с темами уроков, возвращает ID
+func createCourse(h *Harness, teacher *User, name string, capacity int, topics ...string) int {
+	h.t.Helper()
+	teacher.Sends(fmt.Sprintf("/create_course %q \"3D-моделирование\" %d\n%s", name, capacity, strings.Join(topics, "\n"))).
+		ExpectsText("Курс создан")
+	return h.QueryInt("SELECT id FROM courses WHERE name = $1", name)
+}
+
+// scheduleCourse - уроки для тем курса раз в неделю, начиная через неделю
+func scheduleCourse(teacher *User, courseID int) {
+	date := time.Now().AddDate(0, 0, 7).Format("02.01.2006")
+	teacher.Sends(fmt.Sprintf("/schedule_course %d %s 16:30 weekly", courseID, date)).
+		ExpectsText("Уроки курса запланированы")
+}
+
+// studentID - students.id студента
+func (h *Harness) studentID(user *User) int {
+	h.t.Helper()
+	return h.QueryInt(`
+		SELECT s.id FROM students s JOIN users u ON s.user_id = u.id
+		WHERE u.tg_id = $1`, fmt.Sprint(user.ID))
+}
+
+// Запись на курс до заполнения мест
+func TestCourseCapacityFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7501, "Анна Петрова")
+	courseID := createCourse(h, teacher, "Основы 3D", 2, "Интерфейс", "Моделирование")
+	scheduleCourse(teacher, courseID)
+
+	first := h.Student(7502, "Первый Студент")
+	second := h.Student(7503, "Второй Студент")
+	third := h.Student(7504, "Третий Студент")
+
+	first.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Записаны на уроков: 2")
+	first.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("уже записаны")
+	second.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Вы записаны на курс")
+	third.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("нет свободных мест")
+
+	if members := h.QueryInt("SELECT members FROM courses WHERE id = $1", courseID); members != 2 {
+		t.Errorf("Ожидалось 2 участника курса, найдено %d", members)
+	}
+	enrolled := h.QueryInt(`
+		SELECT COUNT(*) FROM enrollments e JOIN lessons l ON e.lesson_id = l.id
+		WHERE l.course_id = $1 AND e.status = 'enrolled'`, courseID)
+	if enrolled != 4 {
+		t.Errorf("Ожидалось 4 записи на уроки курса, найдено %d", enrolled)
+	}
+}
+
+// Одновременные записи на курс не превышают число мест
+func TestConcurrentCourseEnrollment(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7601, "Анна Петрова")
+	courseID := createCourse(h, teacher, "Основы 3D", 3, "Интерфейс", "Моделирование")
+	scheduleCourse(teacher, courseID)
+
+	const students = 10
+	var studentIDs []int
+	for i := 0; i < students; i++ {
+		studentIDs = append(studentIDs, h.studentID(h.Student(int64(7610+i), fmt.Sprintf("Студент %d", i))))
+	}
+
+	courses := store.New(h.DB).Courses
+	results := make([]error, students)
+	var wg sync.WaitGroup
+	for i, studentID := range studentIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, results[i] = courses.Enroll(courseID, studentID)
+		}()
+	}
+	wg.Wait()
+
+	joined, full := 0, 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			joined++
+		case errors.Is(err, store.ErrCourseFull):
+			full++
+		default:
+			t.Errorf("Неожиданная ошибка записи на курс: %v", err)
+		}
+	}
+	if joined != 3 || full != students-3 {
+		t.Errorf("Ожидалось 3 записи и %d отказов, получено %d и %d", students-3, joined, full)
+	}
+	if members := h.QueryInt("SELECT members FROM courses WHERE id = $1", courseID); members != 3 {
+		t.Errorf("Ожидалось 3 участника курса, найдено %d", members)
+	}
+	active := h.QueryInt("SELECT COUNT(*) FROM course_enrollments WHERE course_id = $1 AND status = 'active'", courseID)
+	if active != 3 {
+		t.Errorf("Ожидалось 3 активных записи на курс, найдено %d", active)
+	}
+}
+
+// Расписание курса с уже записанными участниками: темы по порядку, участники записаны на уроки
+func TestScheduleCourseWithMembersFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7701, "Анна Петрова")
+	courseID := createCourse(h, teacher, "Основы 3D", 5, "Интерфейс", "Моделирование", "Свет")
+	first := h.Student(7702, "Первый Студент")
+	second := h.Student(7703, "Второй Студент")
+
+	first.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Уроки еще не запланированы")
+	second.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Уроки еще не запланированы")
+
+	scheduleCourse(teacher, courseID)
+	teacher.ExpectsText("Записано участников: 2")
+	first.ExpectsText("Расписание курса")
+	second.ExpectsText("Расписание курса")
+
+	if lessons := h.QueryInt("SELECT COUNT(*) FROM lessons WHERE course_id = $1", courseID); lessons != 3 {
+		t.Errorf("Ожидалось 3 урока курса, найдено %d", lessons)
+	}
+	// Позиция темы совпадает с порядковым номером урока по времени
+	inOrder := h.QueryInt(`
+		SELECT COUNT(*) FROM course_plan p JOIN lessons l ON p.lesson_id = l.id
+		WHERE p.course_id = $1 AND p.position = (
+			SELECT COUNT(*) FROM lessons o WHERE o.course_id = $1 AND o.start_time <= l.start_time)`, courseID)
+	if inOrder != 3 {
+		t.Errorf("Ожидалось 3 темы с уроками по порядку, найдено %d", inOrder)
+	}
+	enrolled := h.QueryInt(`
+		SELECT COUNT(*) FROM enrollments e JOIN lessons l ON e.lesson_id = l.id
+		WHERE l.course_id = $1 AND e.status = 'enrolled'`, courseID)
+	if enrolled != 6 {
+		t.Errorf("Ожидалось 6 записей участников на уроки, найдено %d", enrolled)
+	}
+
+	teacher.Sends(fmt.Sprintf("/schedule_course %d %s 16:30 weekly", courseID, time.Now().AddDate(0, 0, 7).Format("02.01.2006"))).
+		ExpectsText("уже запланированы")
+}
+
+// Выход из курса освобождает место, повторная запись возвращает записи на уроки
+func TestLeaveAndReenrollCourseFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7801, "Анна Петрова")
+	courseID := createCourse(h, teacher, "Основы 3D", 1, "Интерфейс", "Моделирование")
+	scheduleCourse(teacher, courseID)
+	first := h.Student(7802, "Первый Студент")
+	second := h.Student(7803, "Второй Студент")
+
+	first.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Записаны на уроков: 2")
+	second.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("нет свободных мест")
+
+	first.Sends(fmt.Sprintf("/leave_course %d", courseID)).ExpectsText("Отменено записей на уроки: 2")
+	first.Sends(fmt.Sprintf("/leave_course %d", courseID)).ExpectsText("не записаны")
+	second.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Записаны на уроков: 2")
+	second.Sends(fmt.Sprintf("/leave_course %d", courseID)).ExpectsText("Отменено записей на уроки: 2")
+
+	first.Sends(fmt.Sprintf("/enroll_course %d", courseID)).ExpectsText("Записаны на уроков: 2")
+
+	if members := h.QueryInt("SELECT members FROM courses WHERE id = $1", courseID); members != 1 {
+		t.Errorf("Ожидался 1 участник курса, найдено %d", members)
+	}
+	// Записи на уроки возвращены, а не созданы заново
+	rows := h.QueryInt(`
+		SELECT COUNT(*) FROM enrollments e JOIN lessons l ON e.lesson_id = l.id
+		WHERE l.course_id = $1 AND e.student_id = $2`, courseID, h.studentID(first))
+	enrolled := h.QueryInt(`
+		SELECT COUNT(*) FROM enrollments e JOIN lessons l ON e.lesson_id = l.id
+		WHERE l.course_id = $1 AND e.student_id = $2 AND e.status = 'enrolled'`, courseID, h.studentID(first))
+	if rows != 2 || enrolled != 2 {
+		t.Errorf("Ожидалось 2 записи на уроки, активных 2; найдено %d, активных %d", rows, enrolled)
+	}
+}
@@ -0,0 +1,195 @@
+package scenario
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/store"
+)
+
+// Сквозные сценарии основных пользовательских потоков
+
+// Регистрация нового пользователя через FSM
+func TestRegistrationFlow(t *testing.T) {
+	h := New(t)
+	user := h.User(7001)
+
+	user.Sends("/start").ExpectsText("/register")
+	user.Sends("/register").ExpectsText("Введите ваше полное имя")
+	user.Sends("7").ExpectsText("❌")
+	user.Sends("Мария Смирнова").ExpectsText("Введите ваш номер телефона")
+	user.Sends("не телефон").ExpectsText("❌")
+	user.Sends("+79001234567").ExpectsText("Регистрация завершена")
+
+	students := h.QueryInt(`
+		SELECT COUNT(*) FROM users u JOIN students s ON s.user_id = u.id
+		WHERE u.tg_id = '7001' AND u.role = 'student' AND u.full_name = 'Мария Смирнова'`)
+	if students != 1 {
+		t.Errorf("Ожидался 1 зарегистрированный студент, найдено %d", students)
+	}
+
+	user.Sends("/register").ExpectsText("уже зарегистрированы")
+}
+
+// Запись на урок кнопкой из расписания
+func TestEnrollFromScheduleFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7101, "Анна Петрова")
+	lessonID := h.Lesson(teacher, 2, 48*time.Hour)
+	student := h.Student(7102, "Иван Иванов")
+
+	enroll := callback.WithID(callback.Enroll, lessonID)
+	student.Sends("/schedule").ExpectsText("Анна Петрова").ExpectsButton(enroll)
+	student.Presses(enroll).ExpectsAnswer("успешно записались").ExpectsText("1/2")
+	student.Presses(enroll).ExpectsAnswer("уже записаны")
+
+	enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID)
+	if enrolled != 1 {
+		t.Errorf("Ожидалась 1 запись на урок, найдено %d", enrolled)
+	}
+}
+
+// Лист ожидания: освободившееся место достается первому в очереди
+func TestWaitlistPromotionFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7201, "Анна Петрова")
+	lessonID := h.Lesson(teacher, 1, 48*time.Hour)
+	first := h.Student(7202, "Первый Студент")
+	second := h.Student(7203, "Второй Студент")
+
+	enroll := callback.WithID(callback.Enroll, lessonID)
+	waitlist := callback.WithID(callback.Waitlist, lessonID)
+	unenroll := callback.WithID(callback.Unenroll, lessonID)
+
+	first.Sends("/schedule").Presses(enroll).ExpectsAnswer("успешно записались")
+	second.Sends("/schedule").ExpectsButton(waitlist)
+	second.Presses(waitlist).ExpectsAnswer("лист ожидания")
+
+	first.Presses(unenroll).ExpectsAnswer("Запись отменена")
+	second.ExpectsText("Освободилось место")
+
+	enrolled := h.QueryInt(`
+		SELECT COUNT(*) FROM enrollments e
+		JOIN students s ON e.student_id = s.id JOIN users u ON s.user_id = u.id
+		WHERE e.lesson_id = $1 AND e.status = 'enrolled' AND u.tg_id = '7203'`, lessonID)
+	if enrolled != 1 {
+		t.Errorf("Студент из листа ожидания должен быть записан, записей: %d", enrolled)
+	}
+	if waiting := h.QueryInt("SELECT COUNT(*) FROM waitlist WHERE lesson_id = $1", lessonID); waiting != 0 {
+		t.Errorf("Лист ожидания должен опустеть, осталось %d", waiting)
+	}
+}
+
+// Отмена урока преподавателем с уведомлением записанных студентов
+func TestTeacherCancelsLessonFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(7301, "Анна Петрова")
+	other := h.Teacher(7302, "Олег Сидоров")
+	lessonID := h.Lesson(teacher, 5, 48*time.Hour)
+	student := h.Student(7303, "Иван Иванов")
+	bystander := h.Student(7304, "Петр Петров")
+
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, lessonID)).ExpectsAnswer("успешно записались")
+
+	cancel := callback.WithID(callback.CancelLesson, lessonID)
+	other.Sends("/schedule").Presses(cancel).ExpectsAnswer("только свои уроки")
+
+	teacher.Sends("/schedule").Presses(cancel).ExpectsAnswer("Урок отменен")
+	student.ExpectsText("Уведомление об отмене")
+	bystander.ExpectsNothing()
+
+	if active := h.QueryInt("SELECT COUNT(*) FROM lessons WHERE id = $1 AND status = 'active'", lessonID); active != 0 {
+		t.Error("Урок должен быть отменен")
+	}
+}
+
+// Запись на урок, пересекающийся по времени с другим уроком студента
+func TestOverlappingEnrollmentFlow(t *testing.T) {
+	h := New(t)
+	first := h.Lesson(h.Teacher(7401, "Анна Петрова"), 5, 48*time.Hour)
+	second := h.Lesson(h.Teacher(7402, "Олег Сидоров"), 5, 48*time.Hour+30*time.Minute)
+	student := h.Student(7403, "Иван Иванов")
+
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, first)).ExpectsAnswer("успешно записались")
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, second)).ExpectsAnswer("другой урок")
+
+	enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", second)
+	if enrolled != 0 {
+		t.Errorf("Запись на пересекающийся урок не должна создаваться, найдено %d", enrolled)
+	}
+}
+
+// Одновременные записи на последние места урока не превышают max_students
+func TestConcurrentLessonEnrollment(t *testing.T) {
+	h := New(t)
+	lessonID := h.Lesson(h.Teacher(8501, "Анна Петрова"), 2, 48*time.Hour)
+
+	const students = 10
+	var studentIDs []int
+	for i := 0; i < students; i++ {
+		studentIDs = append(studentIDs, h.studentID(h.Student(int64(8510+i), fmt.Sprintf("Студент %d", i))))
+	}
+
+	enrollments := store.New(h.DB).Enrollments
+	results := make([]error, students)
+	var wg sync.WaitGroup
+	for i, studentID := range studentIDs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = enrollments.Enroll(studentID, lessonID)
+		}()
+	}
+	wg.Wait()
+
+	enrolled, full := 0, 0
+	for _, err := range results {
+		switch {
+		case err == nil:
+			enrolled++
+		case errors.Is(err, store.ErrLessonFull):
+			full++
+		default:
+			t.Errorf("Неожиданная ошибка записи на урок: %v", err)
+		}
+	}
+	if enrolled != 2 || full != students-2 {
+		t.Errorf("Ожидалось 2 записи и %d отказов, получено %d и %d", students-2, enrolled, full)
+	}
+	if count := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); count != 2 {
+		t.Errorf("Ожидалось 2 записи на урок, найдено %d", count)
+	}
+}
+
+// Перенос урока: записанные студенты получают уведомление, пересечения отклоняются
+func TestRescheduleLessonFlow(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(8601, "Анна Петрова")
+	other := h.Teacher(8602, "Олег Сидоров")
+	lessonID := h.Lesson(teacher, 5, 48*time.Hour)
+	h.Lesson(teacher, 5, 72*time.Hour)
+	busy := time.Now().Add(72 * time.Hour).Format("02.01.2006 15:04")
+	student := h.Student(8603, "Иван Иванов")
+
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, lessonID)).ExpectsAnswer("успешно записались")
+
+	other.Sends(fmt.Sprintf("/reschedule_lesson %d %s", lessonID, busy)).ExpectsText("не принадлежит вам")
+	teacher.Sends(fmt.Sprintf("/reschedule_lesson %d %s", lessonID, busy)).ExpectsText("пересекающийся с новым временем")
+	student.ExpectsNothing()
+
+	newTime := time.Now().AddDate(0, 0, 5).Format("02.01.2006") + " 12:00"
+	teacher.Sends(fmt.Sprintf("/reschedule_lesson %d %s", lessonID, newTime)).ExpectsText("Урок перенесен").ExpectsText(newTime)
+	student.ExpectsText("Урок перенесен").ExpectsText(newTime)
+
+	moved := h.QueryInt("SELECT COUNT(*) FROM lessons WHERE id = $1 AND to_char(start_time, 'DD.MM.YYYY HH24:MI') = $2", lessonID, newTime)
+	if moved != 1 {
+		t.Errorf("Урок должен начинаться %s", newTime)
+	}
+	if enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); enrolled != 1 {
+		t.Errorf("Запись студента должна сохраниться, найдено %d", enrolled)
+	}
+}
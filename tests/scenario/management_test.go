@@ -0,0 +1,135 @@
+package scenario
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/callback"
+)
+
+// Сценарии администрирования: преподаватели и студенты
+
+// teacherID - teachers.id преподавателя
+func (h *Harness) teacherID(user *User) int {
+	h.t.Helper()
+	return h.QueryInt(`
+		SELECT t.id FROM teachers t JOIN users u ON t.user_id = u.id
+		WHERE u.tg_id = $1`, fmt.Sprint(user.ID))
+}
+
+// Добавление преподавателя: повторный Telegram ID отклоняется
+func TestAddTeacherFlow(t *testing.T) {
+	h := New(t)
+	admin := h.Admin(7901, "Администратор")
+	h.Student(7902, "Иван Иванов")
+
+	admin.Sends("/add_teacher 7903 Анна Петрова").ExpectsText("Преподаватель успешно добавлен")
+	admin.Sends("/add_teacher 7903 Анна Петрова").ExpectsText("уже существует")
+	admin.Sends("/add_teacher 7902 Иван Иванов").ExpectsText("уже существует")
+
+	teachers := h.QueryInt(`
+		SELECT COUNT(*) FROM teachers t JOIN users u ON t.user_id = u.id
+		WHERE u.tg_id = '7903' AND u.role = 'teacher' AND u.is_active = true`)
+	if teachers != 1 {
+		t.Errorf("Ожидался 1 преподаватель, найдено %d", teachers)
+	}
+}
+
+// Удаление преподавателя отменяет его уроки и записи, восстановление возвращает уроки
+func TestDeleteAndRestoreTeacherFlow(t *testing.T) {
+	h := New(t)
+	admin := h.Admin(8001, "Администратор")
+	teacher := h.Teacher(8002, "Анна Петрова")
+	lessonID := h.Lesson(teacher, 1, 48*time.Hour)
+	h.Lesson(teacher, 5, 72*time.Hour)
+	first := h.Student(8003, "Первый Студент")
+	second := h.Student(8004, "Второй Студент")
+	teacherID := h.teacherID(teacher)
+
+	first.Sends("/schedule").Presses(callback.WithID(callback.Enroll, lessonID)).ExpectsAnswer("успешно записались")
+	second.Sends("/schedule").Presses(callback.WithID(callback.Waitlist, lessonID)).ExpectsAnswer("лист ожидания")
+
+	admin.Sends(fmt.Sprintf("/delete_teacher %d", teacherID)).ExpectsText("Отменено уроков: 2")
+	first.ExpectsText("больше не работает в школе")
+	admin.Sends(fmt.Sprintf("/delete_teacher %d", teacherID)).ExpectsText("Преподаватель не найден")
+
+	if deleted := h.QueryInt("SELECT COUNT(*) FROM lessons WHERE teacher_id = $1 AND soft_deleted = true", teacherID); deleted != 2 {
+		t.Errorf("Ожидалось 2 удаленных урока, найдено %d", deleted)
+	}
+	if enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); enrolled != 0 {
+		t.Errorf("Записи на уроки удаленного преподавателя должны быть отменены, осталось %d", enrolled)
+	}
+	if waiting := h.QueryInt("SELECT COUNT(*) FROM waitlist WHERE lesson_id = $1", lessonID); waiting != 0 {
+		t.Errorf("Лист ожидания должен быть очищен, осталось %d", waiting)
+	}
+	if active := h.QueryInt("SELECT COUNT(*) FROM users WHERE tg_id = '8002' AND is_active = true"); active != 0 {
+		t.Error("Пользователь удаленного преподавателя должен быть деактивирован")
+	}
+
+	admin.Sends(fmt.Sprintf("/restore_teacher %d", teacherID)).ExpectsText("Преподаватель восстановлен")
+	if restored := h.QueryInt("SELECT COUNT(*) FROM lessons WHERE teacher_id = $1 AND soft_deleted = false", teacherID); restored != 2 {
+		t.Errorf("Ожидалось 2 восстановленных урока, найдено %d", restored)
+	}
+	if active := h.QueryInt("SELECT COUNT(*) FROM users WHERE tg_id = '8002' AND is_active = true"); active != 1 {
+		t.Error("Пользователь восстановленного преподавателя должен быть активен")
+	}
+	admin.Sends("/list_teachers").ExpectsText("Анна Петрова")
+}
+
+// Удаление урока отменяет записи, восстановление возвращает их и уведомляет студентов
+func TestDeleteAndRestoreLessonFlow(t *testing.T) {
+	h := New(t)
+	admin := h.Admin(8701, "Администратор")
+	teacher := h.Teacher(8702, "Анна Петрова")
+	lessonID := h.Lesson(teacher, 5, 48*time.Hour)
+	student := h.Student(8703, "Иван Иванов")
+
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, lessonID)).ExpectsAnswer("успешно записались")
+	admin.Sends(fmt.Sprintf("/restore_lesson %d", lessonID)).ExpectsText("Урок уже активен")
+
+	admin.Sends(fmt.Sprintf("/delete_lesson %d", lessonID)).ExpectsText("Урок удален")
+	student.ExpectsText("Урок был удален администратором")
+	admin.Sends(fmt.Sprintf("/delete_lesson %d", lessonID)).ExpectsText("Урок не найден")
+	if enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); enrolled != 0 {
+		t.Errorf("Записи удаленного урока должны быть отменены, осталось %d", enrolled)
+	}
+
+	admin.Sends(fmt.Sprintf("/restore_lesson %d", lessonID)).ExpectsText("Урок восстановлен")
+	student.ExpectsText("УРОК ВОССТАНОВЛЕН")
+	if enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); enrolled != 1 {
+		t.Errorf("Запись студента должна быть восстановлена, активных записей %d", enrolled)
+	}
+}
+
+// Деактивация студента отменяет записи и убирает из листов ожидания
+func TestDeactivateStudentFlow(t *testing.T) {
+	h := New(t)
+	admin := h.Admin(8101, "Администратор")
+	teacher := h.Teacher(8102, "Анна Петрова")
+	lessonID := h.Lesson(teacher, 1, 48*time.Hour)
+	fullLessonID := h.Lesson(teacher, 1, 72*time.Hour)
+	student := h.Student(8103, "Иван Иванов")
+	other := h.Student(8104, "Другой Студент")
+	userID := h.QueryInt("SELECT id FROM users WHERE tg_id = '8103'")
+
+	other.Sends("/schedule").Presses(callback.WithID(callback.Enroll, fullLessonID)).ExpectsAnswer("успешно записались")
+	student.Sends("/schedule").Presses(callback.WithID(callback.Enroll, lessonID)).ExpectsAnswer("успешно записались")
+	student.Presses(callback.WithID(callback.Waitlist, fullLessonID)).ExpectsAnswer("лист ожидания")
+
+	admin.Sends(fmt.Sprintf("/deactivate_student %d", userID)).
+		ExpectsText("Студент деактивирован").
+		ExpectsText("Отменено записей: 1").
+		ExpectsText("Удалено из листа ожидания: 1")
+	admin.Sends(fmt.Sprintf("/deactivate_student %d", userID)).ExpectsText("уже деактивирован")
+
+	if enrolled := h.QueryInt("SELECT COUNT(*) FROM enrollments WHERE lesson_id = $1 AND status = 'enrolled'", lessonID); enrolled != 0 {
+		t.Errorf("Записи деактивированного студента должны быть отменены, осталось %d", enrolled)
+	}
+	if waiting := h.QueryInt("SELECT COUNT(*) FROM waitlist WHERE lesson_id = $1", fullLessonID); waiting != 0 {
+		t.Errorf("Деактивированный студент должен покинуть лист ожидания, осталось %d", waiting)
+	}
+
+	admin.Sends(fmt.Sprintf("/activate_student %d", userID)).ExpectsText("Студент активирован")
+	admin.Sends(fmt.Sprintf("/activate_student %d", userID)).ExpectsText("уже активирован")
+}
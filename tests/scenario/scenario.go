@@ -0,0 +1,345 @@
+// Package scenario - DSL для сквозных тестов разговоров с ботом:
+//
+//	h := scenario.New(t)
+//	h.User(42).Sends("/register").ExpectsText("Введите ваше полное имя")
+//	student.Presses(callback.WithID(callback.Enroll, 15)).ExpectsAnswer("успешно записались")
+//
+// Бот работает с настоящей схемой PostgreSQL и фейковым Telegram (telegramtest).
+// БД берется из TEST_DATABASE_URL (будет очищена!) или поднимается в Docker;
+// если нет ни того, ни другого, тест пропускается.
+//
+// Уведомления из очереди доставляются сразу после каждого действия пользователя,
+// без лимитов Telegram, поэтому их можно проверять тем же ExpectsText.
+package scenario
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/modules/postgres"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/database"
+	"constellation-school-bot/internal/handlers"
+	"constellation-school-bot/internal/notify"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+	"constellation-school-bot/internal/telegram/telegramtest"
+)
+
+// Harness - бот, БД и фейковый Telegram одного теста
+type Harness struct {
+	t        *testing.T
+	DB       *sql.DB
+	Telegram *telegramtest.Server
+	bot      telegram.Messenger
+	notifier *notify.Dispatcher
+	signer   *callback.Signer
+	users    map[int64]*User
+}
+
+// New - поднимает окружение сценария, все ресурсы освобождаются в t.Cleanup
+func New(t *testing.T) *Harness {
+	t.Helper()
+
+	db := openDB(t)
+
+	server := telegramtest.NewServer()
+	t.Cleanup(server.Close)
+	api, err := server.Bot()
+	if err != nil {
+		t.Fatalf("Не удалось создать бота: %v", err)
+	}
+
+	st := store.New(db)
+	handlers.InitializeStore(st)
+	t.Cleanup(func() { handlers.InitializeStore(nil) })
+
+	// Известный ключ, чтобы сценарий мог проверять подписанные кнопки
+	signer := callback.NewSigner([]byte("scenario-secret"), time.Hour)
+	handlers.InitializeCallbackSigner(signer)
+	t.Cleanup(func() { handlers.InitializeCallbackSigner(nil) })
+
+	bot := telegram.NewClient(api)
+	return &Harness{
+		t:        t,
+		DB:       db,
+		Telegram: server,
+		bot:      bot,
+		notifier: notify.New(st.Outbox, bot, notify.Options{}),
+		signer:   signer,
+		users:    make(map[int64]*User),
+	}
+}
+
+// openDB - чистая БД с актуальной схемой
+func openDB(t *testing.T) *sql.DB {
+	t.Helper()
+	ctx := context.Background()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		testcontainers.SkipIfProviderIsNotHealthy(t)
+
+		container, err := postgres.Run(ctx, "postgres:16",
+			postgres.WithDatabase("testdb"),
+			postgres.WithUsername("testuser"),
+			postgres.WithPassword("testpass"),
+			testcontainers.WithWaitStrategy(
+				wait.ForLog("database system is ready to accept connections").
+					WithOccurrence(2).WithStartupTimeout(30*time.Second)),
+		)
+		if err != nil {
+			t.Fatalf("Не удалось запустить PostgreSQL: %v", err)
+		}
+		t.Cleanup(func() { container.Terminate(ctx) })
+
+		dsn, err = container.ConnectionString(ctx, "sslmode=disable")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.Ping(); err != nil {
+		t.Skipf("Пропускаем сценарий: БД недоступна: %v", err)
+	}
+
+	migrator, err := database.NewMigrator(db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("Ошибка миграций: %v", err)
+	}
+
+	// Общая локальная БД переиспользуется между тестами - начинаем с пустых таблиц
+	_, err = db.Exec(`TRUNCATE users, teachers, students, lessons, enrollments, waitlist,
+		pending_operations, simple_logs, fsm_states, audit_log, notification_batches, notifications,
+		lesson_reminders, reminder_opt_outs, lesson_series, lesson_series_exceptions, series_enrollments,
+		courses, course_plan, course_enrollments, attendance, rooms RESTART IDENTITY CASCADE`)
+	if err != nil {
+		t.Fatalf("Ошибка очистки БД: %v", err)
+	}
+	return db
+}
+
+// User - участник разговора
+type User struct {
+	h  *Harness
+	ID int64
+
+	seenMessages int // сообщения до последнего действия пользователя
+	seenAnswers  int // ответы на callback до последнего действия
+}
+
+// User - пользователь Telegram (зарегистрированный или нет)
+func (h *Harness) User(id int64) *User {
+	if user, ok := h.users[id]; ok {
+		return user
+	}
+	user := &User{h: h, ID: id}
+	h.users[id] = user
+	return user
+}
+
+// Student - зарегистрированный студент
+func (h *Harness) Student(id int64, fullName string) *User {
+	h.t.Helper()
+	userID := h.insertUser(id, "student", fullName)
+	h.exec("INSERT INTO students (user_id) VALUES ($1)", userID)
+	return h.User(id)
+}
+
+// Teacher - преподаватель
+func (h *Harness) Teacher(id int64, fullName string) *User {
+	h.t.Helper()
+	userID := h.insertUser(id, "teacher", fullName)
+	h.exec("INSERT INTO teachers (user_id) VALUES ($1)", userID)
+	return h.User(id)
+}
+
+// Admin - суперпользователь
+func (h *Harness) Admin(id int64, fullName string) *User {
+	h.t.Helper()
+	h.insertUser(id, "superuser", fullName)
+	return h.User(id)
+}
+
+// Lesson - активный урок преподавателя по первому предмету, начинается через startsIn
+func (h *Harness) Lesson(teacher *User, maxStudents int, startsIn time.Duration) int {
+	h.t.Helper()
+	var lessonID int
+	err := h.DB.QueryRow(`
+		INSERT INTO lessons (teacher_id, subject_id, start_time, max_students, status)
+		SELECT t.id, (SELECT id FROM subjects ORDER BY id LIMIT 1), $2, $3, 'active'
+		FROM teachers t JOIN users u ON t.user_id = u.id
+		WHERE u.tg_id = $1
+		RETURNING id`, fmt.Sprint(teacher.ID), time.Now().Add(startsIn), maxStudents).Scan(&lessonID)
+	if err != nil {
+		h.t.Fatalf("Не удалось создать урок: %v", err)
+	}
+	return lessonID
+}
+
+// QueryInt - значение для проверки состояния БД
+func (h *Harness) QueryInt(query string, args ...any) int {
+	h.t.Helper()
+	var value int
+	if err := h.DB.QueryRow(query, args...).Scan(&value); err != nil {
+		h.t.Fatalf("Ошибка запроса %q: %v", query, err)
+	}
+	return value
+}
+
+func (h *Harness) insertUser(id int64, role, fullName string) int {
+	h.t.Helper()
+	var userID int
+	err := h.DB.QueryRow(`
+		INSERT INTO users (tg_id, role, full_name, phone, is_active)
+		VALUES ($1, $2, $3, '+79000000000', true)
+		RETURNING id`, fmt.Sprint(id), role, fullName).Scan(&userID)
+	if err != nil {
+		h.t.Fatalf("Не удалось создать пользователя %d: %v", id, err)
+	}
+	return userID
+}
+
+func (h *Harness) exec(query string, args ...any) {
+	h.t.Helper()
+	if _, err := h.DB.Exec(query, args...); err != nil {
+		h.t.Fatalf("Ошибка запроса %q: %v", query, err)
+	}
+}
+
+// Sends - пользователь пишет боту текст или команду
+func (u *User) Sends(text string) *User {
+	u.h.t.Helper()
+	u.markSeen()
+	handlers.HandleUpdate(u.h.bot, telegramtest.MessageUpdate(u.ID, text), u.h.DB)
+	u.h.deliverNotifications()
+	return u
+}
+
+// Presses - пользователь нажимает кнопку из последнего сообщения, где она есть
+func (u *User) Presses(button callback.Payload) *User {
+	u.h.t.Helper()
+
+	messages := u.h.Telegram.Messages(u.ID)
+	messageID, data := 0, ""
+	for i := len(messages) - 1; i >= 0 && messageID == 0; i-- {
+		if found, ok := u.findButton(messages[i], button); ok {
+			messageID, data = messages[i].MessageID(), found
+		}
+	}
+	if messageID == 0 {
+		u.h.t.Fatalf("Пользователь %d: кнопки %+v нет ни в одном сообщении", u.ID, button)
+	}
+
+	u.markSeen()
+	handlers.HandleUpdate(u.h.bot, telegramtest.CallbackUpdate(u.ID, messageID, data), u.h.DB)
+	u.h.deliverNotifications()
+	return u
+}
+
+// deliverNotifications - отправка уведомлений, поставленных в очередь последним действием
+func (h *Harness) deliverNotifications() {
+	h.t.Helper()
+	if _, err := h.notifier.Flush(context.Background()); err != nil {
+		h.t.Fatalf("Ошибка доставки уведомлений: %v", err)
+	}
+}
+
+// ExpectsText - после последнего действия пользователь получил сообщение с подстрокой
+func (u *User) ExpectsText(substr string) *User {
+	u.h.t.Helper()
+
+	var received []string
+	for _, message := range u.h.Telegram.Messages(u.ID)[u.seenMessages:] {
+		if strings.Contains(message.Text(), substr) {
+			return u
+		}
+		received = append(received, message.Text())
+	}
+	u.h.t.Errorf("Пользователь %d: ожидалось сообщение с %q, получены: %q", u.ID, substr, received)
+	return u
+}
+
+// ExpectsButton - после последнего действия пользователь получил кнопку
+func (u *User) ExpectsButton(button callback.Payload) *User {
+	u.h.t.Helper()
+
+	var received []string
+	for _, message := range u.h.Telegram.Messages(u.ID)[u.seenMessages:] {
+		if _, ok := u.findButton(message, button); ok {
+			return u
+		}
+		received = append(received, message.Buttons()...)
+	}
+	u.h.t.Errorf("Пользователь %d: ожидалась кнопка %+v, получены: %q", u.ID, button, received)
+	return u
+}
+
+// findButton - данные кнопки сообщения, которые бот разберет как button
+// (подписанные кнопки сравниваются после проверки подписи)
+func (u *User) findButton(message telegramtest.Call, button callback.Payload) (string, bool) {
+	for _, data := range message.Buttons() {
+		if p, err := u.h.signer.Decode(data, u.ID); err == nil && p == button {
+			return data, true
+		}
+	}
+	return "", false
+}
+
+// ExpectsAnswer - всплывающий ответ на нажатие кнопки содержит подстроку
+func (u *User) ExpectsAnswer(substr string) *User {
+	u.h.t.Helper()
+
+	answers := u.answers()
+	for _, answer := range answers[u.seenAnswers:] {
+		if strings.Contains(answer, substr) {
+			return u
+		}
+	}
+	u.h.t.Errorf("Пользователь %d: ожидался ответ на кнопку с %q, получены: %q", u.ID, substr, answers[u.seenAnswers:])
+	return u
+}
+
+// ExpectsNothing - после последнего действия пользователь ничего не получил
+func (u *User) ExpectsNothing() *User {
+	u.h.t.Helper()
+	if messages := u.h.Telegram.Messages(u.ID)[u.seenMessages:]; len(messages) > 0 {
+		u.h.t.Errorf("Пользователь %d: ожидалась тишина, получено сообщений: %d (%q)", u.ID, len(messages), messages[0].Text())
+	}
+	return u
+}
+
+func (u *User) markSeen() {
+	u.seenMessages = len(u.h.Telegram.Messages(u.ID))
+	u.seenAnswers = len(u.answers())
+}
+
+// answers - непустые ответы на callback query этого пользователя
+func (u *User) answers() []string {
+	prefix := fmt.Sprintf("cb-%d-", u.ID)
+	var answers []string
+	for _, call := range u.h.Telegram.Calls() {
+		if call.Method == "answerCallbackQuery" && call.Text() != "" &&
+			strings.HasPrefix(call.Params.Get("callback_query_id"), prefix) {
+			answers = append(answers, call.Text())
+		}
+	}
+	return answers
+}
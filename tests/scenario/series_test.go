@@ -0,0 +1,163 @@
+package scenario
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// Сценарии серий уроков: перенос и отмена занятий в областях this/following/all
+
+// createSeries - серия уроков по 3D-моделированию в 16:30, возвращает ID серии
+func createSeries(h *Harness, teacher *User, start time.Time, end string) int {
+	h.t.Helper()
+	teacher.Sends(fmt.Sprintf("/create_series \"3D-моделирование\" %s 16:30 weekly %s", start.Format("02.01.2006"), end)).
+		ExpectsText("Серия уроков создана")
+	return h.QueryInt("SELECT id FROM lesson_series WHERE teacher_id = $1 ORDER BY id DESC LIMIT 1", h.teacherID(teacher))
+}
+
+// seriesLessons - ID уроков серии по времени начала
+func (h *Harness) seriesLessons(seriesID int) []int {
+	h.t.Helper()
+	rows, err := h.DB.Query("SELECT id FROM lessons WHERE series_id = $1 ORDER BY start_time", seriesID)
+	if err != nil {
+		h.t.Fatalf("Ошибка получения уроков серии: %v", err)
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			h.t.Fatalf("Ошибка чтения урока серии: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// lessonClock - время начала урока в формате ЧЧ:ММ
+func (h *Harness) lessonClock(lessonID int) string {
+	h.t.Helper()
+	minutes := h.QueryInt("SELECT (EXTRACT(HOUR FROM start_time) * 60 + EXTRACT(MINUTE FROM start_time))::int FROM lessons WHERE id = $1", lessonID)
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Перенос одного занятия, занятия и следующих, всей серии
+func TestEditSeriesScopes(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(8201, "Анна Петрова")
+	other := h.Teacher(8202, "Другой Преподаватель")
+	start := time.Now().AddDate(0, 0, 7)
+	seriesID := createSeries(h, teacher, start, "4")
+	lessons := h.seriesLessons(seriesID)
+	if len(lessons) != 4 {
+		t.Fatalf("Ожидалось 4 урока серии, найдено %d", len(lessons))
+	}
+	day := func(i int) string { return start.AddDate(0, 0, 7*i).Format("02.01.2006") }
+
+	other.Sends(fmt.Sprintf("/edit_series %d this %s 18:00", lessons[3], day(3))).ExpectsText("не принадлежит вам")
+
+	teacher.Sends(fmt.Sprintf("/edit_series %d this %s 18:00", lessons[3], day(3))).
+		ExpectsText("Занятия серии перенесены").
+		ExpectsText("Занятий: 1")
+	if clock := h.lessonClock(lessons[3]); clock != "18:00" {
+		t.Errorf("Занятие должно начинаться в 18:00, начинается в %s", clock)
+	}
+
+	// Остальные занятия сдвигаются на ту же разницу, что и указанное
+	teacher.Sends(fmt.Sprintf("/edit_series %d following %s 17:00", lessons[1], day(1))).ExpectsText("Занятий: 3")
+	for i, want := range []string{"16:30", "17:00", "17:00", "18:30"} {
+		if clock := h.lessonClock(lessons[i]); clock != want {
+			t.Errorf("Занятие %d должно начинаться в %s, начинается в %s", i, want, clock)
+		}
+	}
+	if shifted := h.QueryInt("SELECT COUNT(*) FROM lesson_series WHERE id = $1 AND start_time::time = '16:30'", seriesID); shifted != 1 {
+		t.Error("Перенос части занятий не должен менять время серии")
+	}
+
+	teacher.Sends(fmt.Sprintf("/edit_series %d all %s 15:00", lessons[0], day(0))).ExpectsText("Занятий: 4")
+	for i, want := range []string{"15:00", "15:30", "15:30", "17:00"} {
+		if clock := h.lessonClock(lessons[i]); clock != want {
+			t.Errorf("Занятие %d должно начинаться в %s, начинается в %s", i, want, clock)
+		}
+	}
+	if shifted := h.QueryInt("SELECT COUNT(*) FROM lesson_series WHERE id = $1 AND start_time::time = '15:00'", seriesID); shifted != 1 {
+		t.Error("Перенос всей серии должен сдвинуть время серии")
+	}
+
+	yesterday := time.Now().AddDate(0, 0, -1).Format("02.01.2006")
+	teacher.Sends(fmt.Sprintf("/edit_series %d this %s 15:00", lessons[0], yesterday)).ExpectsText("Нельзя перенести занятия в прошлое")
+}
+
+// Перенос на время другого урока преподавателя отклоняется целиком
+func TestEditSeriesTeacherConflict(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(8301, "Анна Петрова")
+	start := time.Now().AddDate(0, 0, 7)
+	seriesID := createSeries(h, teacher, start, "3")
+	lessons := h.seriesLessons(seriesID)
+	busy := time.Now().Add(72 * time.Hour)
+	h.Lesson(teacher, 5, 72*time.Hour)
+
+	teacher.Sends(fmt.Sprintf("/edit_series %d all %s", lessons[0], busy.Format("02.01.2006 15:04"))).
+		ExpectsText("пересекающийся с новым временем")
+
+	for _, lessonID := range lessons {
+		if clock := h.lessonClock(lessonID); clock != "16:30" {
+			t.Errorf("Занятия серии не должны переноситься при конфликте, урок %d начинается в %s", lessonID, clock)
+		}
+	}
+}
+
+// Отмена одного занятия, занятия и следующих, всей серии
+func TestCancelSeriesScopes(t *testing.T) {
+	h := New(t)
+	teacher := h.Teacher(8401, "Анна Петрова")
+	student := h.Student(8402, "Иван Иванов")
+	start := time.Now().AddDate(0, 0, 7)
+	seriesID := createSeries(h, teacher, start, start.AddDate(0, 0, 21).Format("02.01.2006"))
+	lessons := h.seriesLessons(seriesID)
+	if len(lessons) != 4 {
+		t.Fatalf("Ожидалось 4 урока серии до даты окончания, найдено %d", len(lessons))
+	}
+	student.Sends(fmt.Sprintf("/enroll_series %d", seriesID)).ExpectsText("Записаны на занятий: 4")
+	active := func() int {
+		return h.QueryInt("SELECT COUNT(*) FROM lessons WHERE series_id = $1 AND status = 'active'", seriesID)
+	}
+
+	teacher.Sends(fmt.Sprintf("/cancel_series %d this", lessons[0])).
+		ExpectsText("Занятия серии отменены").
+		ExpectsText("Отменено занятий: 1")
+	student.ExpectsText("Отмена занятий")
+	if active := active(); active != 3 {
+		t.Errorf("Ожидалось 3 активных урока, найдено %d", active)
+	}
+	exceptions := h.QueryInt(`
+		SELECT COUNT(*) FROM lesson_series_exceptions
+		WHERE series_id = $1 AND skip_date = (SELECT start_time::date FROM lessons WHERE id = $2)`, seriesID, lessons[0])
+	if exceptions != 1 {
+		t.Error("Отмененное занятие должно попасть в исключения серии")
+	}
+	teacher.Sends(fmt.Sprintf("/cancel_series %d this", lessons[0])).ExpectsText("уже прошло или отменено")
+
+	// Серия заканчивается накануне отмененного занятия
+	teacher.Sends(fmt.Sprintf("/cancel_series %d following", lessons[2])).ExpectsText("Отменено занятий: 2")
+	if active := active(); active != 1 {
+		t.Errorf("Ожидался 1 активный урок, найдено %d", active)
+	}
+	truncated := h.QueryInt(`
+		SELECT COUNT(*) FROM lesson_series
+		WHERE id = $1 AND status = 'active' AND until_date = (SELECT start_time::date - 1 FROM lessons WHERE id = $2)`, seriesID, lessons[2])
+	if truncated != 1 {
+		t.Error("Дата окончания серии должна быть накануне отмененного занятия")
+	}
+
+	teacher.Sends(fmt.Sprintf("/cancel_series %d all", lessons[1])).ExpectsText("Отменено занятий: 1")
+	if active := active(); active != 0 {
+		t.Errorf("Все занятия серии должны быть отменены, активных %d", active)
+	}
+	if cancelled := h.QueryInt("SELECT COUNT(*) FROM lesson_series WHERE id = $1 AND status = 'cancelled'", seriesID); cancelled != 1 {
+		t.Error("Серия должна быть отменена")
+	}
+}
@@ -0,0 +1,730 @@
+package unit
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"constellation-school-bot/internal/callback"
+	"constellation-school-bot/internal/handlers"
+	"constellation-school-bot/internal/store"
+	"constellation-school-bot/internal/telegram"
+	"constellation-school-bot/internal/telegram/telegramtest"
+)
+
+// Сценарии разговоров с ботом без Telegram и без БД:
+// фейковый Bot API записывает ответы, репозитории подменены фейками в памяти.
+
+type fakeUsers struct {
+	users map[int64]*store.User
+}
+
+func (f *fakeUsers) GetByTelegramID(telegramID int64) (*store.User, error) {
+	user, ok := f.users[telegramID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	return user, nil
+}
+
+func (f *fakeUsers) Get(userID int) (*store.User, error) {
+	for _, user := range f.users {
+		if user.ID == userID {
+			return user, nil
+		}
+	}
+	return nil, store.ErrNotFound
+}
+
+func (f *fakeUsers) Role(telegramID int64) (string, error) {
+	user, err := f.GetByTelegramID(telegramID)
+	if err != nil {
+		return "", err
+	}
+	return user.Role, nil
+}
+
+func (f *fakeUsers) Exists(telegramID int64) (bool, error) {
+	_, ok := f.users[telegramID]
+	return ok, nil
+}
+
+func (f *fakeUsers) SetRole(telegramID int64, role string) error {
+	user, ok := f.users[telegramID]
+	if !ok {
+		return store.ErrNotFound
+	}
+	user.Role = role
+	return nil
+}
+
+func (f *fakeUsers) SetActive(userID int, active bool) error {
+	user, err := f.Get(userID)
+	if err != nil {
+		return err
+	}
+	user.IsActive = active
+	return nil
+}
+
+func (f *fakeUsers) MarkReachable(telegramID int64) error {
+	if user, ok := f.users[telegramID]; ok {
+		user.Unreachable = false
+	}
+	return nil
+}
+
+func (f *fakeUsers) Active() ([]store.User, error) {
+	var users []store.User
+	for _, user := range f.users {
+		if user.IsActive {
+			users = append(users, *user)
+		}
+	}
+	return users, nil
+}
+
+type fakeStudents struct {
+	users *fakeUsers
+}
+
+func (f *fakeStudents) GetByTelegramID(telegramID int64) (*store.Student, error) {
+	user, ok := f.users.users[telegramID]
+	if !ok || user.Role != "student" {
+		return nil, store.ErrNotFound
+	}
+	return &store.Student{ID: user.ID, UserID: user.ID, TelegramID: telegramID, FullName: user.FullName}, nil
+}
+
+func (f *fakeStudents) Register(telegramID int64, fullName, phone string) (int, error) {
+	if _, ok := f.users.users[telegramID]; ok {
+		return 0, store.ErrUserExists
+	}
+	user := &store.User{ID: len(f.users.users) + 1, TelegramID: telegramID, Role: "student", FullName: fullName,
+		Phone: phone, IsActive: true}
+	f.users.users[telegramID] = user
+	return user.ID, nil
+}
+
+func (f *fakeStudents) List() ([]store.Student, error) {
+	var students []store.Student
+	for _, user := range f.users.users {
+		if user.Role == "student" {
+			students = append(students, store.Student{ID: user.ID, UserID: user.ID, TelegramID: user.TelegramID,
+				FullName: user.FullName, IsActive: user.IsActive})
+		}
+	}
+	return students, nil
+}
+
+func (f *fakeStudents) Deactivate(userID int) (int, int, error) {
+	user, err := f.users.Get(userID)
+	if err != nil || user.Role != "student" || !user.IsActive {
+		return 0, 0, store.ErrNotFound
+	}
+	user.IsActive = false
+	return 0, 0, nil
+}
+
+type fakeLessons struct {
+	lessons map[int]*store.Lesson
+}
+
+func (f *fakeLessons) Get(lessonID int) (*store.Lesson, error) {
+	lesson, ok := f.lessons[lessonID]
+	if !ok {
+		return nil, store.ErrNotFound
+	}
+	result := *lesson
+	return &result, nil
+}
+
+func (f *fakeLessons) Upcoming(from, to time.Time, limit int) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Create(lesson store.Lesson) (int, error) {
+	lesson.ID = len(f.lessons) + 1
+	f.lessons[lesson.ID] = &lesson
+	return lesson.ID, nil
+}
+
+func (f *fakeLessons) ByTeacher(teacherID int, from, to time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) BySubject(subjectID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Full(from, to time.Time, limit int) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+func (f *fakeLessons) Reschedule(lessonID int, startTime time.Time) (time.Time, error) {
+	previous := f.lessons[lessonID].StartTime
+	f.lessons[lessonID].StartTime = startTime
+	return previous, nil
+}
+
+func (f *fakeLessons) Cancel(lessonID int) error {
+	f.lessons[lessonID].Status = "cancelled"
+	return nil
+}
+
+func (f *fakeLessons) Delete(lessonID int) error {
+	f.lessons[lessonID].SoftDeleted = true
+	return nil
+}
+
+func (f *fakeLessons) Restore(lessonID int) error {
+	f.lessons[lessonID].SoftDeleted = false
+	return nil
+}
+
+type fakeEnrollments struct {
+	lessons  *fakeLessons
+	enrolled map[[2]int]bool
+}
+
+func (f *fakeEnrollments) IsEnrolled(studentID, lessonID int) (bool, error) {
+	return f.enrolled[[2]int{studentID, lessonID}], nil
+}
+
+func (f *fakeEnrollments) Enroll(studentID, lessonID int) error {
+	f.enrolled[[2]int{studentID, lessonID}] = true
+	f.lessons.lessons[lessonID].EnrolledCount++
+	return nil
+}
+
+func (f *fakeEnrollments) Unenroll(studentID, lessonID int) error {
+	if !f.enrolled[[2]int{studentID, lessonID}] {
+		return store.ErrNotEnrolled
+	}
+	delete(f.enrolled, [2]int{studentID, lessonID})
+	f.lessons.lessons[lessonID].EnrolledCount--
+	return nil
+}
+
+func (f *fakeEnrollments) EnrolledStudents(lessonID int) ([]store.Student, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) ByLesson(lessonID int) ([]store.Enrollment, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) EnrolledByStudent(lessonIDs []int) ([]store.StudentLessons, error) {
+	return nil, nil
+}
+
+func (f *fakeEnrollments) Lessons(studentID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+type fakeWaitlist struct {
+	users     *fakeUsers
+	positions map[[2]int]int
+}
+
+func (f *fakeWaitlist) Add(studentID, lessonID int) (int, error) {
+	if _, ok := f.positions[[2]int{studentID, lessonID}]; ok {
+		return 0, store.ErrAlreadyWaitlisted
+	}
+	position := len(f.positions) + 1
+	f.positions[[2]int{studentID, lessonID}] = position
+	return position, nil
+}
+
+func (f *fakeWaitlist) Next(lessonID int) (*store.WaitlistEntry, error) {
+	var next *store.WaitlistEntry
+	for key, position := range f.positions {
+		if key[1] == lessonID && (next == nil || position < next.Position) {
+			next = &store.WaitlistEntry{StudentID: key[0], LessonID: lessonID, Position: position}
+		}
+	}
+	if next == nil {
+		return nil, store.ErrNotFound
+	}
+	for _, user := range f.users.users {
+		if user.ID == next.StudentID {
+			next.TelegramID = user.TelegramID
+		}
+	}
+	return next, nil
+}
+
+func (f *fakeWaitlist) Remove(studentID, lessonID int) error {
+	delete(f.positions, [2]int{studentID, lessonID})
+	return nil
+}
+
+func (f *fakeWaitlist) Lessons(studentID int, from time.Time) ([]store.Lesson, error) {
+	return nil, nil
+}
+
+type fakeLogs struct {
+	actions []string
+}
+
+func (f *fakeLogs) Add(entry store.LogEntry) error {
+	f.actions = append(f.actions, entry.Action)
+	return nil
+}
+
+func (f *fakeLogs) RecentErrors(limit int) ([]store.LogEntry, error) {
+	return nil, nil
+}
+
+type fakeAudit struct {
+	entries []store.AuditEntry
+}
+
+func (f *fakeAudit) Record(entry store.AuditEntry) error {
+	entry.ID = len(f.entries) + 1
+	entry.CreatedAt = time.Now()
+	f.entries = append(f.entries, entry)
+	return nil
+}
+
+func (f *fakeAudit) Search(filter store.AuditFilter, limit, offset int) ([]store.AuditEntry, int, error) {
+	var found []store.AuditEntry
+	for i := len(f.entries) - 1; i >= 0; i-- {
+		entry := f.entries[i]
+		if filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		if filter.ActorTgID != 0 && (entry.ActorTgID == nil || *entry.ActorTgID != filter.ActorTgID) {
+			continue
+		}
+		found = append(found, entry)
+	}
+	if offset >= len(found) {
+		return nil, len(found), nil
+	}
+	return found[offset:min(offset+limit, len(found))], len(found), nil
+}
+
+type fakeOutbox struct {
+	batches  []store.NotificationBatch
+	messages []store.OutboxMessage
+}
+
+func (f *fakeOutbox) Enqueue(batch store.NotificationBatch, messages []store.OutboxMessage) (int, error) {
+	batch.ID = len(f.batches) + 1
+	batch.Total = len(messages)
+	f.batches = append(f.batches, batch)
+	for _, m := range messages {
+		m.BatchID = batch.ID
+		f.messages = append(f.messages, m)
+	}
+	return batch.ID, nil
+}
+
+func (f *fakeOutbox) Claim(limit int) ([]store.OutboxMessage, error)                { return nil, nil }
+func (f *fakeOutbox) MarkSent(id int) error                                         { return nil }
+func (f *fakeOutbox) Retry(id int, at time.Time, lastError string) error            { return nil }
+func (f *fakeOutbox) Postpone(id int, at time.Time) error                           { return nil }
+func (f *fakeOutbox) MarkFailed(id int, lastError string) error                     { return nil }
+func (f *fakeOutbox) MarkUnreachable(id int, lastError string) error                { return nil }
+func (f *fakeOutbox) ReleaseClaimed() (int, error)                                  { return 0, nil }
+func (f *fakeOutbox) CompletedBatches(limit int) ([]store.NotificationBatch, error) { return nil, nil }
+func (f *fakeOutbox) MarkReported(batchID int) error                                { return nil }
+
+type fakeReminders struct {
+	optedOut map[int]map[string]bool
+}
+
+func (f *fakeReminders) Due(reminder string, offset, until time.Duration, limit int) ([]store.DueReminder, error) {
+	return nil, nil
+}
+
+func (f *fakeReminders) Enqueue(reminder string, batch store.NotificationBatch, due []store.DueReminder, messages []store.OutboxMessage) (int, error) {
+	return 0, nil
+}
+
+func (f *fakeReminders) OptedOut(studentID int) ([]string, error) {
+	var reminders []string
+	for reminder, optedOut := range f.optedOut[studentID] {
+		if optedOut {
+			reminders = append(reminders, reminder)
+		}
+	}
+	return reminders, nil
+}
+
+func (f *fakeReminders) SetOptOut(studentID int, reminder string, optOut bool) error {
+	if f.optedOut[studentID] == nil {
+		f.optedOut[studentID] = make(map[string]bool)
+	}
+	f.optedOut[studentID][reminder] = optOut
+	return nil
+}
+
+// fakeCourses - участники курсов; запись на курс записывает на его уроки из fakeLessons
+type fakeCourses struct {
+	enrollments *fakeEnrollments
+	capacity    map[int]int
+	members     map[[2]int]bool
+}
+
+func (f *fakeCourses) Create(course store.Course) (int, error) { return 0, nil }
+func (f *fakeCourses) Get(courseID int) (*store.Course, error) { return nil, store.ErrNotFound }
+func (f *fakeCourses) List() ([]store.Course, error)           { return nil, nil }
+func (f *fakeCourses) Members(courseID int) ([]store.Student, error) {
+	return nil, nil
+}
+func (f *fakeCourses) Schedule(courseID int, series store.LessonSeries, starts []time.Time) (int, int, error) {
+	return 0, 0, nil
+}
+
+func (f *fakeCourses) IsMember(courseID, studentID int) (bool, error) {
+	return f.members[[2]int{courseID, studentID}], nil
+}
+
+func (f *fakeCourses) Enroll(courseID, studentID int) (int, error) {
+	capacity, ok := f.capacity[courseID]
+	if !ok {
+		return 0, store.ErrNotFound
+	}
+	if f.members[[2]int{courseID, studentID}] {
+		return 0, store.ErrAlreadyEnrolled
+	}
+	members := 0
+	for key := range f.members {
+		if key[0] == courseID {
+			members++
+		}
+	}
+	if members >= capacity {
+		return 0, store.ErrCourseFull
+	}
+	f.members[[2]int{courseID, studentID}] = true
+
+	lessons := 0
+	for _, lesson := range f.enrollments.lessons.lessons {
+		if lesson.CourseID == courseID {
+			f.enrollments.Enroll(studentID, lesson.ID)
+			lessons++
+		}
+	}
+	return lessons, nil
+}
+
+func (f *fakeCourses) Leave(courseID, studentID int) (int, error) {
+	if !f.members[[2]int{courseID, studentID}] {
+		return 0, store.ErrNotEnrolled
+	}
+	delete(f.members, [2]int{courseID, studentID})
+	return 0, nil
+}
+
+// scenario - бот на фейковом Bot API с фейковыми репозиториями
+type scenario struct {
+	t         *testing.T
+	server    *telegramtest.Server
+	bot       telegram.Messenger
+	users     *fakeUsers
+	logs      *fakeLogs
+	audit     *fakeAudit
+	outbox    *fakeOutbox
+	reminders *fakeReminders
+	courses   *fakeCourses
+}
+
+func newScenario(t *testing.T, lessons ...store.Lesson) *scenario {
+	server := telegramtest.NewServer()
+	t.Cleanup(server.Close)
+
+	api, err := server.Bot()
+	if err != nil {
+		t.Fatalf("Failed to create bot: %v", err)
+	}
+
+	users := &fakeUsers{users: make(map[int64]*store.User)}
+	fl := &fakeLessons{lessons: make(map[int]*store.Lesson)}
+	for i := range lessons {
+		fl.lessons[lessons[i].ID] = &lessons[i]
+	}
+	logs := &fakeLogs{}
+	audit := &fakeAudit{}
+	outbox := &fakeOutbox{}
+	reminders := &fakeReminders{optedOut: make(map[int]map[string]bool)}
+	enrollments := &fakeEnrollments{lessons: fl, enrolled: make(map[[2]int]bool)}
+	courses := &fakeCourses{enrollments: enrollments, capacity: make(map[int]int), members: make(map[[2]int]bool)}
+
+	handlers.InitializeStore(&store.Store{
+		Users:       users,
+		Students:    &fakeStudents{users: users},
+		Lessons:     fl,
+		Enrollments: enrollments,
+		Waitlist:    &fakeWaitlist{users: users, positions: make(map[[2]int]int)},
+		Logs:        logs,
+		Audit:       audit,
+		Outbox:      outbox,
+		Reminders:   reminders,
+		Courses:     courses,
+	})
+	t.Cleanup(func() { handlers.InitializeStore(nil) })
+
+	return &scenario{t: t, server: server, bot: telegram.NewClient(api), users: users, logs: logs, audit: audit, outbox: outbox,
+		reminders: reminders, courses: courses}
+}
+
+// say - пользователь пишет боту и получает последний ответ
+func (s *scenario) say(userID int64, text string) string {
+	s.t.Helper()
+	handlers.HandleUpdate(s.bot, telegramtest.MessageUpdate(userID, text), nil)
+
+	reply, ok := s.server.LastMessage(userID)
+	if !ok {
+		s.t.Fatalf("No reply to %q", text)
+	}
+	return reply.Text()
+}
+
+func (s *scenario) expect(userID int64, text, want string) {
+	s.t.Helper()
+	if reply := s.say(userID, text); !strings.Contains(reply, want) {
+		s.t.Errorf("%q: expected reply containing %q, got %q", text, want, reply)
+	}
+}
+
+// Сценарий: незарегистрированный пользователь начинает и отменяет регистрацию
+func TestRegistrationConversation(t *testing.T) {
+	s := newScenario(t)
+	const userID = 1001
+
+	s.expect(userID, "/start", "/register")
+	s.expect(userID, "/register", "Введите ваше полное имя")
+	s.expect(userID, "7", "❌")
+	s.expect(userID, "Иван Иванов", "номер телефона")
+	s.expect(userID, "/cancel", "Регистрация отменена")
+	s.expect(userID, "/cancel", "Нет активного процесса")
+}
+
+// Сценарий: неизвестная команда
+func TestUnknownCommand(t *testing.T) {
+	s := newScenario(t)
+	s.expect(1002, "/does_not_exist", "Неизвестная команда")
+}
+
+// Сценарий: запись на урок и лист ожидания при отсутствии мест
+func TestEnrollConversation(t *testing.T) {
+	lesson := store.Lesson{
+		ID:          1,
+		SubjectName: "3D-моделирование",
+		TeacherName: "Анна Петрова",
+		StartTime:   time.Now().Add(48 * time.Hour),
+		MaxStudents: 1,
+		Status:      "active",
+	}
+	s := newScenario(t, lesson)
+	s.users.users[2001] = &store.User{ID: 1, TelegramID: 2001, Role: "student", FullName: "Первый Студент", IsActive: true}
+	s.users.users[2002] = &store.User{ID: 2, TelegramID: 2002, Role: "student", FullName: "Второй Студент", IsActive: true}
+
+	s.expect(2001, "/enroll 1", "Вы записаны на урок")
+	s.expect(2001, "/enroll 1", "уже записаны")
+	s.expect(2002, "/enroll 1", "Позиция в очереди: 1")
+	s.expect(2002, "/enroll 1", "уже в листе ожидания")
+	s.expect(2002, "/enroll 99", "Урок не найден")
+	s.expect(3003, "/enroll 1", "не зарегистрированы")
+
+	if len(s.logs.actions) != 2 || s.logs.actions[0] != "lesson_enrolled" || s.logs.actions[1] != "waitlist_added" {
+		t.Errorf("Expected enrollment and waitlist to be logged, got %v", s.logs.actions)
+	}
+	if len(s.audit.entries) != 2 {
+		t.Fatalf("Expected 2 audit entries, got %+v", s.audit.entries)
+	}
+	enrolled := s.audit.entries[0]
+	if enrolled.Action != "lesson_enrolled" || enrolled.Entity != store.AuditEnrollment || enrolled.EntityID != 1 ||
+		enrolled.ActorTgID == nil || *enrolled.ActorTgID != 2001 || enrolled.Before != nil ||
+		string(enrolled.After) != `{"status":"enrolled","student_id":1}` {
+		t.Errorf("Unexpected enrollment audit entry: %+v", enrolled)
+	}
+	if waitlisted := s.audit.entries[1]; waitlisted.Entity != store.AuditWaitlist || string(waitlisted.After) != `{"position":1,"student_id":2}` {
+		t.Errorf("Unexpected waitlist audit entry: %+v", waitlisted)
+	}
+}
+
+// Сценарий: кнопка отмены урока, собранная вручную или взятая из чужого чата, отклоняется
+func TestForgedCancelCallbackRejected(t *testing.T) {
+	lesson := store.Lesson{ID: 1, SubjectName: "Робототехника", StartTime: time.Now().Add(48 * time.Hour), MaxStudents: 5, Status: "active"}
+	s := newScenario(t, lesson)
+	s.users.users[4001] = &store.User{ID: 1, TelegramID: 4001, Role: "superuser", FullName: "Админ", IsActive: true}
+
+	signer := callback.NewSigner([]byte("unit-secret"), time.Hour)
+	handlers.InitializeCallbackSigner(signer)
+	t.Cleanup(func() { handlers.InitializeCallbackSigner(nil) })
+
+	otherChat, err := signer.Encode(callback.WithID(callback.CancelLesson, 1), 4002)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	for _, data := range []string{"v1:cancel_lesson:1", otherChat} {
+		handlers.HandleUpdate(s.bot, telegramtest.CallbackUpdate(4001, 1, data), nil)
+
+		reply, ok := s.server.LastMessage(4001)
+		if !ok || !strings.Contains(reply.Text(), "Недействительная кнопка") {
+			t.Errorf("%q: expected rejection, got %q", data, reply.Text())
+		}
+	}
+	for _, answer := range s.server.CallbackAnswers() {
+		if strings.Contains(answer, "отменен") {
+			t.Errorf("Forged callback must not cancel the lesson, got answer %q", answer)
+		}
+	}
+}
+
+// Сценарий: права проверяются маршрутом команды, администратора назначает только суперпользователь
+func TestRolePermissions(t *testing.T) {
+	s := newScenario(t)
+	s.users.users[5001] = &store.User{ID: 1, TelegramID: 5001, Role: "superuser", FullName: "Суперпользователь", IsActive: true}
+	s.users.users[5002] = &store.User{ID: 2, TelegramID: 5002, Role: "student", FullName: "Будущий Админ", IsActive: true}
+	s.users.users[5003] = &store.User{ID: 3, TelegramID: 5003, Role: "student", FullName: "Заблокированный", IsActive: false}
+
+	s.expect(5002, "/delete_lesson 1", "нет прав")
+	s.expect(5002, "/grant_admin 5003", "нет прав")
+	s.expect(5003, "/enroll 1", "деактивирован")
+	s.expect(6000, "/notify_all Привет", "не зарегистрированы")
+
+	s.expect(5001, "/grant_admin 5002", "Роль изменена")
+	if role := s.users.users[5002].Role; role != "admin" {
+		t.Fatalf("Expected admin role, got %q", role)
+	}
+	if reply, ok := s.server.LastMessage(5002); !ok || !strings.Contains(reply.Text(), "роль изменена") {
+		t.Errorf("Expected the new admin to be notified, got %q", reply.Text())
+	}
+
+	// Администратор не управляет ролями и не может записываться на уроки
+	s.expect(5002, "/grant_admin 5003", "нет прав")
+	s.expect(5002, "/enroll 1", "нет прав")
+	s.expect(5001, "/grant_admin 5002", "уже администратор")
+}
+
+// Сценарий: администратор листает журнал аудита с фильтром по действию
+func TestAuditConversation(t *testing.T) {
+	s := newScenario(t)
+	s.users.users[7001] = &store.User{ID: 1, TelegramID: 7001, Role: "admin", FullName: "Админ", IsActive: true}
+	s.users.users[7002] = &store.User{ID: 2, TelegramID: 7002, Role: "teacher", FullName: "Преподаватель", IsActive: true}
+	for i := 1; i <= 7; i++ {
+		s.audit.Record(store.AuditEntry{Action: "lesson_cancelled", Entity: store.AuditLesson, EntityID: i})
+	}
+	s.audit.Record(store.AuditEntry{Action: "lesson_created", Entity: store.AuditLesson, EntityID: 8})
+
+	s.expect(7002, "/audit", "нет прав")
+	s.expect(7001, "/audit entity=planet", "неизвестный тип")
+	s.expect(7001, "/audit action=lesson_created from=31.01.2025 to=01.01.2025", "дата from позже даты to")
+	s.expect(7001, "/audit action=lesson_created", "стр. 1/1")
+
+	reply := s.say(7001, "/audit action=lesson_cancelled")
+	if !strings.Contains(reply, "записей: 7, стр. 1/2") || !strings.Contains(reply, "lesson #7") || strings.Contains(reply, "lesson #2") {
+		t.Fatalf("Unexpected first audit page: %q", reply)
+	}
+	first, _ := s.server.LastMessage(7001)
+	buttons := first.Buttons()
+	if len(buttons) != 1 {
+		t.Fatalf("Expected only the next page button, got %v", buttons)
+	}
+
+	handlers.HandleUpdate(s.bot, telegramtest.CallbackUpdate(7001, first.MessageID(), buttons[0]), nil)
+	edit, ok := s.server.LastMessage(7001)
+	if !ok || !strings.Contains(edit.Text(), "стр. 2/2") || !strings.Contains(edit.Text(), "lesson #1") || strings.Contains(edit.Text(), "lesson_created") {
+		t.Errorf("Expected filtered second page, got %q", edit.Text())
+	}
+}
+
+// Сценарий: освободившееся место уходит первому в листе ожидания, уведомление ставится в очередь,
+// а пользователь, заблокировавший бота, снова доступен, когда пишет ему
+func TestWaitlistPromotionQueuesNotification(t *testing.T) {
+	lesson := store.Lesson{ID: 1, SubjectName: "Астрономия", StartTime: time.Now().Add(48 * time.Hour), MaxStudents: 1, Status: "active"}
+	s := newScenario(t, lesson)
+	s.users.users[8001] = &store.User{ID: 1, TelegramID: 8001, Role: "student", FullName: "Первый Студент", IsActive: true}
+	s.users.users[8002] = &store.User{ID: 2, TelegramID: 8002, Role: "student", FullName: "Второй Студент", IsActive: true, Unreachable: true}
+
+	s.expect(8001, "/enroll 1", "Вы записаны на урок")
+	s.expect(8002, "/enroll 1", "Позиция в очереди: 1")
+	if s.users.users[8002].Unreachable {
+		t.Error("Expected user who wrote to the bot to become reachable")
+	}
+
+	signer := callback.NewSigner([]byte("unit-secret"), time.Hour)
+	handlers.InitializeCallbackSigner(signer)
+	t.Cleanup(func() { handlers.InitializeCallbackSigner(nil) })
+	unenroll, err := signer.Encode(callback.WithID(callback.Unenroll, 1), 8001)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	handlers.HandleUpdate(s.bot, telegramtest.CallbackUpdate(8001, 1, unenroll), nil)
+
+	if len(s.outbox.batches) != 1 || len(s.outbox.messages) != 1 {
+		t.Fatalf("Expected one queued notification, got %+v %+v", s.outbox.batches, s.outbox.messages)
+	}
+	batch, message := s.outbox.batches[0], s.outbox.messages[0]
+	if batch.Kind != "waitlist_promoted" || batch.ReportChatID != 0 {
+		t.Errorf("Unexpected batch: %+v", batch)
+	}
+	if message.ChatID != 8002 || message.ParseMode != "Markdown" || !strings.Contains(message.Text, "Освободилось место") {
+		t.Errorf("Unexpected queued message: %+v", message)
+	}
+	if reply, ok := s.server.LastMessage(8002); !ok || strings.Contains(reply.Text(), "Освободилось место") {
+		t.Errorf("Notification must be sent by the dispatcher, not by the handler, got %q", reply.Text())
+	}
+}
+
+// Сценарий: студент отключает и включает типы автоматических напоминаний
+func TestRemindersConversation(t *testing.T) {
+	s := newScenario(t)
+	s.users.users[9001] = &store.User{ID: 1, TelegramID: 9001, Role: "student", FullName: "Студент", IsActive: true}
+	s.users.users[9002] = &store.User{ID: 2, TelegramID: 9002, Role: "teacher", FullName: "Преподаватель", IsActive: true}
+
+	handlers.InitializeReminders([]time.Duration{time.Hour, 24 * time.Hour})
+	t.Cleanup(func() { handlers.InitializeReminders(nil) })
+
+	s.expect(9002, "/reminders", "нет прав")
+	s.expect(9001, "/reminders", "за 24 ч (`24h`) - ✅ включено\n• за 1 ч (`1h`) - ✅ включено")
+	s.expect(9001, "/reminders off 2h", "Неизвестный тип напоминаний: 2h\nДоступные: 24h, 1h, all")
+	s.expect(9001, "/reminders mute 24h", "Используйте")
+	s.expect(9001, "/reminders off 24h", "за 24 ч (`24h`) - 🔕 отключено\n• за 1 ч (`1h`) - ✅ включено")
+	s.expect(9001, "/reminders off all", "за 1 ч (`1h`) - 🔕 отключено")
+	s.expect(9001, "/reminders on 1h", "за 24 ч (`24h`) - 🔕 отключено\n• за 1 ч (`1h`) - ✅ включено")
+
+	if !s.reminders.optedOut[1]["24h"] || s.reminders.optedOut[1]["1h"] {
+		t.Errorf("Unexpected opt-outs: %v", s.reminders.optedOut)
+	}
+
+	handlers.InitializeReminders(nil)
+	s.expect(9001, "/reminders", "напоминания об уроках в боте отключены")
+}
+
+// Сценарий: на урок курса записываются только участники курса, число участников ограничено
+func TestCourseEnrollConversation(t *testing.T) {
+	lesson := store.Lesson{
+		ID:          1,
+		SubjectName: "3D-моделирование",
+		StartTime:   time.Now().Add(48 * time.Hour),
+		MaxStudents: 1,
+		Status:      "active",
+		CourseID:    7,
+	}
+	s := newScenario(t, lesson)
+	s.courses.capacity[7] = 1
+	s.users.users[9101] = &store.User{ID: 1, TelegramID: 9101, Role: "student", FullName: "Первый Студент", IsActive: true}
+	s.users.users[9102] = &store.User{ID: 2, TelegramID: 9102, Role: "student", FullName: "Второй Студент", IsActive: true}
+
+	s.expect(9101, "/enroll 1", "Запишитесь на весь курс: /enroll_course 7")
+	s.expect(9101, "/enroll_course 8", "Курс не найден")
+	s.expect(9101, "/enroll_course 7", "Записаны на уроков: 1")
+	s.expect(9101, "/enroll_course 7", "уже записаны на этот курс")
+	s.expect(9101, "/enroll 1", "уже записаны")
+	s.expect(9102, "/enroll_course 7", "нет свободных мест")
+	s.expect(9102, "/leave_course 7", "не записаны на этот курс")
+	s.expect(9101, "/leave_course 7", "Вы вышли из курса")
+	s.expect(9102, "/enroll_course 7", "Вы записаны на курс")
+
+	if len(s.audit.entries) != 3 || s.audit.entries[0].Action != "course_enrolled" || s.audit.entries[0].Entity != store.AuditCourse ||
+		s.audit.entries[1].Action != "course_left" {
+		t.Errorf("Unexpected audit entries: %+v", s.audit.entries)
+	}
+}